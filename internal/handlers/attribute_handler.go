@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/services"
+)
+
+type AttributeHandler struct {
+	svc services.AttributeService
+}
+
+func NewAttributeHandler(svc services.AttributeService) *AttributeHandler {
+	return &AttributeHandler{svc: svc}
+}
+
+type attributeRequest struct {
+	Key         *string                     `json:"key"`
+	Label       *string                     `json:"label" binding:"omitempty,max=191"`
+	Description *string                     `json:"description"`
+	Type        *models.AttributeType       `json:"type"`
+	Required    *bool                       `json:"required"`
+	Options     []string                    `json:"options"`
+	Visibility  *models.AttributeVisibility `json:"visibility"`
+	Position    *int                        `json:"position"`
+}
+
+func (r attributeRequest) input() services.AttributeInput {
+	return services.AttributeInput{
+		Key:         r.Key,
+		Label:       r.Label,
+		Description: r.Description,
+		Type:        r.Type,
+		Required:    r.Required,
+		Options:     r.Options,
+		Visibility:  r.Visibility,
+		Position:    r.Position,
+	}
+}
+
+func attributeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAttributeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrAttributeExists):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidDefinition):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// List godoc
+// @Summary List custom user attributes
+// @Description Definitions of the custom profile attributes, ordered by position
+// @Tags user-attributes
+// @Produce json
+// @Success 200 {array} models.UserAttribute
+// @Security BearerAuth
+// @Router /api/user-attributes [get]
+func (h *AttributeHandler) List(c *gin.Context) {
+	attributes, err := h.svc.WithContext(c).List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": attributes})
+}
+
+// Get godoc
+// @Summary Get custom user attribute
+// @Tags user-attributes
+// @Produce json
+// @Param id path string true "Attribute ID"
+// @Success 200 {object} models.UserAttribute
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/user-attributes/{id} [get]
+func (h *AttributeHandler) Get(c *gin.Context) {
+	attribute, err := h.svc.WithContext(c).Get(c.Param("id"))
+	if err != nil {
+		c.JSON(attributeErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": attribute})
+}
+
+// Create godoc
+// @Summary Define custom user attribute
+// @Description Type is one of string, number, boolean, date (YYYY-MM-DD) or enum; enums list their options. Visibility is public, private (the user and administrators) or admin.
+// @Tags user-attributes
+// @Accept json
+// @Produce json
+// @Param request body attributeRequest true "Attribute definition"
+// @Success 201 {object} models.UserAttribute
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/user-attributes [post]
+func (h *AttributeHandler) Create(c *gin.Context) {
+	var req attributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	attribute, err := h.svc.WithContext(c).Create(req.input())
+	if err != nil {
+		c.JSON(attributeErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": attribute})
+}
+
+// Update godoc
+// @Summary Update custom user attribute
+// @Description Key and type cannot be changed once the attribute exists
+// @Tags user-attributes
+// @Accept json
+// @Produce json
+// @Param id path string true "Attribute ID"
+// @Param request body attributeRequest true "Fields to change"
+// @Success 200 {object} models.UserAttribute
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/user-attributes/{id} [patch]
+func (h *AttributeHandler) Update(c *gin.Context) {
+	var req attributeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	attribute, err := h.svc.WithContext(c).Update(c.Param("id"), req.input())
+	if err != nil {
+		c.JSON(attributeErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": attribute})
+}
+
+// Delete godoc
+// @Summary Delete custom user attribute
+// @Description Removes the definition together with every user's value
+// @Tags user-attributes
+// @Param id path string true "Attribute ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/user-attributes/{id} [delete]
+func (h *AttributeHandler) Delete(c *gin.Context) {
+	if err := h.svc.WithContext(c).Delete(c.Param("id")); err != nil {
+		c.JSON(attributeErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -2,23 +2,37 @@ package handlers
 
 import (
 	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/models"
 	"github.com/halolight/halolight-api-go/internal/repository"
 	"github.com/halolight/halolight-api-go/internal/services"
+	"github.com/halolight/halolight-api-go/internal/tenant"
 	"github.com/halolight/halolight-api-go/pkg/config"
 	"github.com/halolight/halolight-api-go/pkg/utils"
 )
 
 type AuthHandler struct {
 	auth             services.AuthService
+	accounts         services.AccountStatusService
+	logins           services.LoginHistoryService
+	teamInvites      services.TeamInvitationService
 	refreshTokenRepo repository.RefreshTokenRepository
 	cfg              config.Config
 }
 
-func NewAuthHandler(auth services.AuthService, refreshTokenRepo repository.RefreshTokenRepository, cfg config.Config) *AuthHandler {
-	return &AuthHandler{auth: auth, refreshTokenRepo: refreshTokenRepo, cfg: cfg}
+func NewAuthHandler(auth services.AuthService, accounts services.AccountStatusService, logins services.LoginHistoryService, teamInvites services.TeamInvitationService, refreshTokenRepo repository.RefreshTokenRepository, cfg config.Config) *AuthHandler {
+	return &AuthHandler{auth: auth, accounts: accounts, logins: logins, teamInvites: teamInvites, refreshTokenRepo: refreshTokenRepo, cfg: cfg}
+}
+
+// recordLogin adds the client's address and user agent to attempt and stores
+// it. Login history is best effort and never fails the request.
+func (h *AuthHandler) recordLogin(c *gin.Context, attempt services.LoginAttempt) {
+	attempt.IP = c.ClientIP()
+	attempt.UserAgent = c.Request.UserAgent()
+	_, _ = h.logins.Record(attempt)
 }
 
 type registerRequest struct {
@@ -74,6 +88,11 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// Team invitations sent to this address before sign-up; best effort
+	if err := h.teamInvites.WithContext(tenant.WithID(c, user.TenantID)).Claim(user.ID, user.Email); err != nil {
+		log.Printf("claim team invitations for %s: %v", user.ID, err)
+	}
+
 	c.JSON(http.StatusCreated, authResponse{
 		User:  user,
 		Token: token,
@@ -90,6 +109,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 // @Success 200 {object} authResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /api/auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req loginRequest
@@ -100,13 +120,25 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	user, token, err := h.auth.Login(req.Email, req.Password)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) || errors.Is(err, services.ErrAccountInactive) || errors.Is(err, services.ErrAccountSuspended) {
+			h.recordLogin(c, services.LoginAttempt{
+				Email:         req.Email,
+				Method:        models.LoginMethodPassword,
+				FailureReason: services.LoginFailureReason(err),
+			})
+		}
 		if errors.Is(err, services.ErrInvalidCredentials) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid email or password"})
 			return
 		}
+		if errors.Is(err, services.ErrAccountInactive) || errors.Is(err, services.ErrAccountSuspended) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
 		return
 	}
+	h.recordLogin(c, services.LoginAttempt{UserID: user.ID, Method: models.LoginMethodPassword, Success: true})
 
 	c.JSON(http.StatusOK, authResponse{
 		User:  user,
@@ -123,6 +155,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 // @Param request body refreshRequest true "Refresh token"
 // @Success 200 {object} authResponse
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /api/auth/refresh [post]
 func (h *AuthHandler) Refresh(c *gin.Context) {
 	var req struct {
@@ -135,17 +168,33 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 
 	// Verify refresh token
 	storedToken, err := h.refreshTokenRepo.FindByToken(req.RefreshToken)
-	if err != nil || storedToken == nil || storedToken.IsExpired() {
+	// A deleted user is not preloaded
+	if err != nil || storedToken == nil || storedToken.IsExpired() || storedToken.User.ID == "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Invalid refresh token"})
 		return
 	}
 
+	if err := h.accounts.CheckUser(&storedToken.User); err != nil {
+		if errors.Is(err, services.ErrAccountInactive) || errors.Is(err, services.ErrAccountSuspended) {
+			h.recordLogin(c, services.LoginAttempt{
+				UserID:        storedToken.UserID,
+				Method:        models.LoginMethodToken,
+				FailureReason: services.LoginFailureReason(err),
+			})
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to verify account"})
+		return
+	}
+
 	// Generate new tokens
-	accessToken, err := utils.GenerateAccessToken(storedToken.UserID, h.cfg.JWTSecret)
+	accessToken, err := utils.GenerateAccessToken(storedToken.UserID, storedToken.User.TenantID, h.cfg.JWTSecret)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Failed to generate token"})
 		return
 	}
+	h.recordLogin(c, services.LoginAttempt{UserID: storedToken.UserID, Method: models.LoginMethodToken, Success: true})
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":     true,
@@ -177,6 +226,39 @@ func (h *AuthHandler) Me(c *gin.Context) {
 	})
 }
 
+// LoginHistory godoc
+// @Summary Get login history
+// @Description List the authenticated user's sign-in attempts, newest first, with IP, user agent, method and outcome
+// @Tags auth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/auth/login-history [get]
+func (h *AuthHandler) LoginHistory(c *gin.Context) {
+	userID := c.GetString("userID")
+	page, limit := getPagination(c, 20)
+
+	events, total, err := h.logins.List(userID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    events,
+		"meta": gin.H{
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
 // Logout godoc
 // @Summary Logout user
 // @Description Invalidate refresh token
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
@@ -9,38 +10,51 @@ import (
 )
 
 type CalendarHandler struct {
-	svc services.CalendarService
+	svc   services.CalendarService
+	prefs services.PreferenceService
 }
 
-func NewCalendarHandler(svc services.CalendarService) *CalendarHandler {
-	return &CalendarHandler{svc: svc}
+func NewCalendarHandler(svc services.CalendarService, prefs services.PreferenceService) *CalendarHandler {
+	return &CalendarHandler{svc: svc, prefs: prefs}
 }
 
+// List renders event times in the user's preferred time zone. Bare
+// YYYY-MM-DD bounds are interpreted as whole days in that zone.
 func (h *CalendarHandler) List(c *gin.Context) {
 	userID := c.GetString("userID")
+	loc := h.prefs.Location(userID)
 	var startDate, endDate *time.Time
 
 	if s := c.Query("startDate"); s != "" {
 		if t, err := time.Parse(time.RFC3339, s); err == nil {
 			startDate = &t
+		} else if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+			startDate = &t
 		}
 	}
 	if e := c.Query("endDate"); e != "" {
 		if t, err := time.Parse(time.RFC3339, e); err == nil {
 			endDate = &t
+		} else if t, err := time.ParseInLocation("2006-01-02", e, loc); err == nil {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			endDate = &t
 		}
 	}
 
-	events, err := h.svc.List(userID, startDate, endDate)
+	events, err := h.svc.WithContext(c).List(userID, startDate, endDate)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": events})
+	for i := range events {
+		events[i].StartAt = events[i].StartAt.In(loc)
+		events[i].EndAt = events[i].EndAt.In(loc)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": events, "meta": gin.H{"timeZone": loc.String()}})
 }
 
 func (h *CalendarHandler) Get(c *gin.Context) {
-	event, err := h.svc.Get(c.Param("id"))
+	event, err := h.svc.WithContext(c).Get(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Event not found"})
 		return
@@ -69,8 +83,12 @@ func (h *CalendarHandler) Create(c *gin.Context) {
 	endAt, _ := time.Parse(time.RFC3339, req.EndAt)
 	userID := c.GetString("userID")
 
-	event, err := h.svc.Create(req.Title, req.Description, req.Location, startAt, endAt, req.Type, req.Color, req.AllDay, userID, req.AttendeeIDs)
+	event, err := h.svc.WithContext(c).Create(req.Title, req.Description, req.Location, startAt, endAt, req.Type, req.Color, req.AllDay, userID, req.AttendeeIDs)
 	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -82,7 +100,7 @@ func (h *CalendarHandler) Update(c *gin.Context) {
 	userID := c.GetString("userID")
 	eventID := c.Param("id")
 
-	if !h.svc.IsOwner(eventID, userID) {
+	if !h.svc.WithContext(c).IsOwner(eventID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can update"})
 		return
 	}
@@ -112,7 +130,7 @@ func (h *CalendarHandler) Update(c *gin.Context) {
 		endAt = &t
 	}
 
-	event, err := h.svc.Update(eventID, req.Title, req.Description, req.Location, req.Type, req.Color, req.AllDay, startAt, endAt)
+	event, err := h.svc.WithContext(c).Update(eventID, req.Title, req.Description, req.Location, req.Type, req.Color, req.AllDay, startAt, endAt)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -125,7 +143,7 @@ func (h *CalendarHandler) Reschedule(c *gin.Context) {
 	userID := c.GetString("userID")
 	eventID := c.Param("id")
 
-	if !h.svc.IsOwner(eventID, userID) {
+	if !h.svc.WithContext(c).IsOwner(eventID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can reschedule"})
 		return
 	}
@@ -142,7 +160,7 @@ func (h *CalendarHandler) Reschedule(c *gin.Context) {
 	startAt, _ := time.Parse(time.RFC3339, req.StartAt)
 	endAt, _ := time.Parse(time.RFC3339, req.EndAt)
 
-	event, err := h.svc.Reschedule(eventID, startAt, endAt)
+	event, err := h.svc.WithContext(c).Reschedule(eventID, startAt, endAt)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -155,7 +173,7 @@ func (h *CalendarHandler) AddAttendee(c *gin.Context) {
 	userID := c.GetString("userID")
 	eventID := c.Param("id")
 
-	if !h.svc.IsOwner(eventID, userID) {
+	if !h.svc.WithContext(c).IsOwner(eventID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can add attendees"})
 		return
 	}
@@ -168,8 +186,12 @@ func (h *CalendarHandler) AddAttendee(c *gin.Context) {
 		return
 	}
 
-	attendee, err := h.svc.AddAttendee(eventID, req.UserID)
+	attendee, err := h.svc.WithContext(c).AddAttendee(eventID, req.UserID)
 	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -181,12 +203,12 @@ func (h *CalendarHandler) RemoveAttendee(c *gin.Context) {
 	userID := c.GetString("userID")
 	eventID := c.Param("id")
 
-	if !h.svc.IsOwner(eventID, userID) {
+	if !h.svc.WithContext(c).IsOwner(eventID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can remove attendees"})
 		return
 	}
 
-	if err := h.svc.RemoveAttendee(eventID, c.Param("attendeeId")); err != nil {
+	if err := h.svc.WithContext(c).RemoveAttendee(eventID, c.Param("attendeeId")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -203,7 +225,7 @@ func (h *CalendarHandler) BatchDelete(c *gin.Context) {
 		return
 	}
 
-	if err := h.svc.DeleteMany(req.IDs); err != nil {
+	if err := h.svc.WithContext(c).DeleteMany(req.IDs); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -215,12 +237,12 @@ func (h *CalendarHandler) Delete(c *gin.Context) {
 	userID := c.GetString("userID")
 	eventID := c.Param("id")
 
-	if !h.svc.IsOwner(eventID, userID) {
+	if !h.svc.WithContext(c).IsOwner(eventID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can delete"})
 		return
 	}
 
-	if err := h.svc.Delete(eventID); err != nil {
+	if err := h.svc.WithContext(c).Delete(eventID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
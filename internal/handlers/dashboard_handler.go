@@ -16,7 +16,7 @@ func NewDashboardHandler(svc services.DashboardService) *DashboardHandler {
 }
 
 func (h *DashboardHandler) GetStats(c *gin.Context) {
-	stats, err := h.svc.GetStats()
+	stats, err := h.svc.WithContext(c).GetStats()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -25,27 +25,27 @@ func (h *DashboardHandler) GetStats(c *gin.Context) {
 }
 
 func (h *DashboardHandler) GetVisits(c *gin.Context) {
-	visits := h.svc.GetVisits()
+	visits := h.svc.WithContext(c).GetVisits()
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": visits})
 }
 
 func (h *DashboardHandler) GetSales(c *gin.Context) {
-	sales := h.svc.GetSales()
+	sales := h.svc.WithContext(c).GetSales()
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": sales})
 }
 
 func (h *DashboardHandler) GetProducts(c *gin.Context) {
-	products := h.svc.GetProducts()
+	products := h.svc.WithContext(c).GetProducts()
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": products})
 }
 
 func (h *DashboardHandler) GetOrders(c *gin.Context) {
-	orders := h.svc.GetOrders()
+	orders := h.svc.WithContext(c).GetOrders()
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": orders})
 }
 
 func (h *DashboardHandler) GetActivities(c *gin.Context) {
-	activities, err := h.svc.GetActivities()
+	activities, err := h.svc.WithContext(c).GetActivities()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -54,17 +54,17 @@ func (h *DashboardHandler) GetActivities(c *gin.Context) {
 }
 
 func (h *DashboardHandler) GetPieData(c *gin.Context) {
-	pieData := h.svc.GetPieData()
+	pieData := h.svc.WithContext(c).GetPieData()
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": pieData})
 }
 
 func (h *DashboardHandler) GetTasks(c *gin.Context) {
-	tasks := h.svc.GetTasks()
+	tasks := h.svc.WithContext(c).GetTasks()
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": tasks})
 }
 
 func (h *DashboardHandler) GetOverview(c *gin.Context) {
-	overview, err := h.svc.GetOverview()
+	overview, err := h.svc.WithContext(c).GetOverview()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -1,37 +1,95 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/halolight/halolight-api-go/internal/models"
 	"github.com/halolight/halolight-api-go/internal/services"
+	"golang.org/x/net/websocket"
+	"gorm.io/gorm"
 )
 
+// collabMaxMessage bounds a single message on a live editing connection
+const collabMaxMessage = 4 << 20
+
+// maxDocumentImportUpload caps each file of an import, zip archives included
+const maxDocumentImportUpload = 50 << 20
+
 type DocumentHandler struct {
-	svc services.DocumentService
+	svc       services.DocumentService
+	revisions services.DocumentRevisionService
+	collab    services.CollabService
+	comments  services.DocumentCommentService
+	exports   services.DocumentExportService
+	imports   services.DocumentImportService
+	templates services.DocumentTemplateService
+}
+
+func NewDocumentHandler(svc services.DocumentService, revisions services.DocumentRevisionService, collab services.CollabService, comments services.DocumentCommentService, exports services.DocumentExportService, imports services.DocumentImportService, templates services.DocumentTemplateService) *DocumentHandler {
+	return &DocumentHandler{svc: svc, revisions: revisions, collab: collab, comments: comments, exports: exports, imports: imports, templates: templates}
+}
+
+func revisionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrRevisionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidDiffMode):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func commentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrCommentNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrCommentAnchor), errors.Is(err, services.ErrCommentReplyAnchor),
+		errors.Is(err, services.ErrCommentNotThread):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+func exportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrExportFormat), errors.Is(err, services.ErrExportTemplate):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
 }
 
-func NewDocumentHandler(svc services.DocumentService) *DocumentHandler {
-	return &DocumentHandler{svc: svc}
+func importErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrImportNoFiles), errors.Is(err, services.ErrImportTooManyFiles):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrImportTooLargeTotal):
+		return http.StatusRequestEntityTooLarge
+	}
+	return teamContentErrorStatus(err)
 }
 
 func (h *DocumentHandler) List(c *gin.Context) {
 	userID := c.GetString("userID")
-	page := getIntQuery(c, "page", 1)
-	limit := getIntQuery(c, "limit", 10)
+	page, limit := getPagination(c, 10)
 	search := c.Query("search")
 	folder := c.Query("folder")
+	teamID := c.Query("teamId")
 	tagsStr := c.Query("tags")
 	var tags []string
 	if tagsStr != "" {
 		tags = strings.Split(tagsStr, ",")
 	}
 
-	docs, total, err := h.svc.List(userID, page, limit, search, folder, tags)
+	docs, total, err := h.svc.WithContext(c).List(userID, page, limit, search, folder, teamID, tags)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
@@ -47,16 +105,46 @@ func (h *DocumentHandler) List(c *gin.Context) {
 	})
 }
 
+func (h *DocumentHandler) Search(c *gin.Context) {
+	userID := c.GetString("userID")
+	page, limit := getPagination(c, 10)
+	var tags []string
+	if tagsStr := c.Query("tags"); tagsStr != "" {
+		tags = strings.Split(tagsStr, ",")
+	}
+
+	results, total, err := h.svc.WithContext(c).Search(userID, c.Query("q"), page, limit, c.Query("folder"), c.Query("teamId"), tags)
+	if errors.Is(err, services.ErrEmptySearchQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    results,
+		"meta": gin.H{
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
 func (h *DocumentHandler) Get(c *gin.Context) {
 	userID := c.GetString("userID")
 	docID := c.Param("id")
 
-	if !h.svc.HasAccess(docID, userID) {
+	if !h.svc.WithContext(c).HasAccess(docID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
 		return
 	}
 
-	doc, err := h.svc.Get(docID)
+	doc, err := h.svc.WithContext(c).Get(docID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Document not found"})
 		return
@@ -64,13 +152,77 @@ func (h *DocumentHandler) Get(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": doc})
 }
 
+// Export downloads a document rendered as md, html, pdf or docx
+func (h *DocumentHandler) Export(c *gin.Context) {
+	userID := c.GetString("userID")
+	docID := c.Param("id")
+
+	if !h.svc.WithContext(c).HasAccess(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return
+	}
+
+	export, err := h.exports.WithContext(c).Export(docID, c.DefaultQuery("format", services.ExportMarkdown), c.Query("template"))
+	if err != nil {
+		c.JSON(exportErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", contentDisposition("attachment", export.Filename))
+	c.Data(http.StatusOK, export.ContentType, export.Data)
+}
+
+// Import creates documents from the Markdown, HTML and DOCX files uploaded
+// under "file", which may repeat, and from those inside uploaded zip archives
+func (h *DocumentHandler) Import(c *gin.Context) {
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "file is required"})
+		return
+	}
+
+	var uploads []services.DocumentImportUpload
+	for _, fileHeader := range form.File["file"] {
+		if fileHeader.Size > maxDocumentImportUpload {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": fileHeader.Filename + " exceeds 50MB"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "failed to read " + fileHeader.Filename})
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "failed to read " + fileHeader.Filename})
+			return
+		}
+		uploads = append(uploads, services.DocumentImportUpload{Name: fileHeader.Filename, Data: data})
+	}
+
+	var teamID *string
+	if id := c.PostForm("teamId"); id != "" {
+		teamID = &id
+	}
+	userID := c.GetString("userID")
+	report, err := h.imports.WithContext(c).Import(userID, teamID, c.PostForm("folder"), uploads)
+	if err != nil {
+		c.JSON(importErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report, "message": "Documents imported"})
+}
+
+// Create creates a document. With templateId its title, content and type come
+// from the rendered template; a title or type in the request take precedence.
 func (h *DocumentHandler) Create(c *gin.Context) {
 	var req struct {
-		Title   string  `json:"title" binding:"required,min=1"`
-		Content string  `json:"content"`
-		Folder  string  `json:"folder"`
-		Type    string  `json:"type"`
-		TeamID  *string `json:"teamId"`
+		Title      string  `json:"title" binding:"required_without=TemplateID"`
+		Content    string  `json:"content"`
+		Folder     string  `json:"folder"`
+		Type       string  `json:"type"`
+		TeamID     *string `json:"teamId"`
+		TemplateID *string `json:"templateId"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
@@ -78,9 +230,21 @@ func (h *DocumentHandler) Create(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	doc, err := h.svc.Create(req.Title, req.Content, req.Folder, req.Type, userID, req.TeamID)
+	if req.TemplateID != nil {
+		rendered, err := h.templates.WithContext(c).Render(*req.TemplateID, userID, req.TeamID, req.Title)
+		if err != nil {
+			c.JSON(templateErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		req.Title, req.Content = rendered.Title, rendered.Content
+		if req.Type == "" {
+			req.Type = rendered.Type
+		}
+	}
+
+	doc, err := h.svc.WithContext(c).Create(req.Title, req.Content, req.Folder, req.Type, userID, req.TeamID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
@@ -91,8 +255,8 @@ func (h *DocumentHandler) Update(c *gin.Context) {
 	userID := c.GetString("userID")
 	docID := c.Param("id")
 
-	if !h.svc.IsOwner(docID, userID) {
-		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can update"})
+	if !h.svc.WithContext(c).CanEdit(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You may not edit this document"})
 		return
 	}
 
@@ -106,7 +270,17 @@ func (h *DocumentHandler) Update(c *gin.Context) {
 		return
 	}
 
-	doc, err := h.svc.Update(docID, req.Title, req.Content, req.Folder)
+	if req.Folder != "" && !h.svc.WithContext(c).IsOwner(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can move"})
+		return
+	}
+
+	if req.Content != "" && h.collab.Editing(docID) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Document is being edited live; join the session to change its content"})
+		return
+	}
+
+	doc, err := h.svc.WithContext(c).Update(docID, req.Title, req.Content, req.Folder, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -119,8 +293,8 @@ func (h *DocumentHandler) Rename(c *gin.Context) {
 	userID := c.GetString("userID")
 	docID := c.Param("id")
 
-	if !h.svc.IsOwner(docID, userID) {
-		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can rename"})
+	if !h.svc.WithContext(c).CanEdit(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You may not edit this document"})
 		return
 	}
 
@@ -132,7 +306,7 @@ func (h *DocumentHandler) Rename(c *gin.Context) {
 		return
 	}
 
-	doc, err := h.svc.Rename(docID, req.Title)
+	doc, err := h.svc.WithContext(c).Rename(docID, req.Title, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -145,7 +319,7 @@ func (h *DocumentHandler) Move(c *gin.Context) {
 	userID := c.GetString("userID")
 	docID := c.Param("id")
 
-	if !h.svc.IsOwner(docID, userID) {
+	if !h.svc.WithContext(c).IsOwner(docID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can move"})
 		return
 	}
@@ -158,7 +332,7 @@ func (h *DocumentHandler) Move(c *gin.Context) {
 		return
 	}
 
-	doc, err := h.svc.Move(docID, req.Folder)
+	doc, err := h.svc.WithContext(c).Move(docID, req.Folder)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -171,8 +345,8 @@ func (h *DocumentHandler) UpdateTags(c *gin.Context) {
 	userID := c.GetString("userID")
 	docID := c.Param("id")
 
-	if !h.svc.IsOwner(docID, userID) {
-		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can update tags"})
+	if !h.svc.WithContext(c).CanEdit(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You may not edit this document"})
 		return
 	}
 
@@ -184,7 +358,7 @@ func (h *DocumentHandler) UpdateTags(c *gin.Context) {
 		return
 	}
 
-	doc, err := h.svc.UpdateTags(docID, req.Tags)
+	doc, err := h.svc.WithContext(c).UpdateTags(docID, req.Tags)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -193,18 +367,30 @@ func (h *DocumentHandler) UpdateTags(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": doc, "message": "Tags updated"})
 }
 
+func shareErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrTeamNotFound),
+		errors.Is(err, services.ErrInvalidShareTarget), errors.Is(err, services.ErrInvalidSharePermission),
+		errors.Is(err, services.ErrShareExpiry):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 func (h *DocumentHandler) Share(c *gin.Context) {
 	userID := c.GetString("userID")
 	docID := c.Param("id")
 
-	if !h.svc.IsOwner(docID, userID) {
+	if !h.svc.WithContext(c).IsOwner(docID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can share"})
 		return
 	}
 
 	var req struct {
-		UserID     string                  `json:"userId" binding:"required"`
+		UserID     string                 `json:"userId"`
+		TeamID     string                 `json:"teamId"`
 		Permission models.SharePermission `json:"permission"`
+		ExpiresAt  *time.Time             `json:"expiresAt"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
@@ -215,39 +401,61 @@ func (h *DocumentHandler) Share(c *gin.Context) {
 		req.Permission = models.SharePermissionRead
 	}
 
-	if err := h.svc.Share(docID, req.UserID, req.Permission); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+	share, err := h.svc.WithContext(c).Share(docID, services.ShareInput{
+		UserID:     req.UserID,
+		TeamID:     req.TeamID,
+		Permission: req.Permission,
+		ExpiresAt:  req.ExpiresAt,
+	})
+	if err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Document shared"})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": share, "message": "Document shared"})
 }
 
 func (h *DocumentHandler) Unshare(c *gin.Context) {
 	userID := c.GetString("userID")
 	docID := c.Param("id")
 
-	if !h.svc.IsOwner(docID, userID) {
+	if !h.svc.WithContext(c).IsOwner(docID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can unshare"})
 		return
 	}
 
 	var req struct {
-		UserID string `json:"userId" binding:"required"`
+		UserID string `json:"userId"`
+		TeamID string `json:"teamId"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
-	if err := h.svc.Unshare(docID, req.UserID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+	if err := h.svc.WithContext(c).Unshare(docID, req.UserID, req.TeamID); err != nil {
+		c.JSON(shareErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Share removed"})
 }
 
+func (h *DocumentHandler) ListShares(c *gin.Context) {
+	docID := c.Param("id")
+	if !h.svc.WithContext(c).HasAccess(docID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return
+	}
+
+	shares, err := h.svc.WithContext(c).Shares(docID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": shares})
+}
+
 func (h *DocumentHandler) BatchDelete(c *gin.Context) {
 	var req struct {
 		IDs []string `json:"ids" binding:"required"`
@@ -257,7 +465,7 @@ func (h *DocumentHandler) BatchDelete(c *gin.Context) {
 		return
 	}
 
-	if err := h.svc.DeleteMany(req.IDs); err != nil {
+	if err := h.svc.WithContext(c).DeleteMany(req.IDs); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -269,15 +477,295 @@ func (h *DocumentHandler) Delete(c *gin.Context) {
 	userID := c.GetString("userID")
 	docID := c.Param("id")
 
-	if !h.svc.IsOwner(docID, userID) {
+	if !h.svc.WithContext(c).IsOwner(docID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can delete"})
 		return
 	}
 
-	if err := h.svc.Delete(docID); err != nil {
+	if err := h.svc.WithContext(c).Delete(docID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Document deleted"})
 }
+
+func (h *DocumentHandler) ListRevisions(c *gin.Context) {
+	docID := c.Param("id")
+	if !h.svc.WithContext(c).HasAccess(docID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return
+	}
+
+	page, limit := getPagination(c, 20)
+	revisions, total, err := h.revisions.WithContext(c).List(docID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    revisions,
+		"meta": gin.H{
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+func (h *DocumentHandler) GetRevision(c *gin.Context) {
+	docID := c.Param("id")
+	if !h.svc.WithContext(c).HasAccess(docID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return
+	}
+
+	revision, err := h.revisions.WithContext(c).Get(docID, c.Param("revisionId"))
+	if err != nil {
+		c.JSON(revisionErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": revision})
+}
+
+func (h *DocumentHandler) DiffRevisions(c *gin.Context) {
+	docID := c.Param("id")
+	if !h.svc.WithContext(c).HasAccess(docID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return
+	}
+
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "from and to revision IDs are required"})
+		return
+	}
+
+	diff, err := h.revisions.WithContext(c).Diff(docID, from, to, c.Query("mode"))
+	if err != nil {
+		c.JSON(revisionErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": diff})
+}
+
+func (h *DocumentHandler) RestoreRevision(c *gin.Context) {
+	userID := c.GetString("userID")
+	docID := c.Param("id")
+
+	if !h.svc.WithContext(c).CanEdit(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You may not edit this document"})
+		return
+	}
+	if h.collab.Editing(docID) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Document is being edited live; restore it once the session ends"})
+		return
+	}
+
+	doc, err := h.revisions.WithContext(c).Restore(docID, c.Param("revisionId"), userID)
+	if err != nil {
+		c.JSON(revisionErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": doc, "message": "Revision restored"})
+}
+
+func (h *DocumentHandler) ListComments(c *gin.Context) {
+	docID := c.Param("id")
+	if !h.svc.WithContext(c).HasAccess(docID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return
+	}
+
+	filter := c.Query("status")
+	if filter != services.CommentsAll && filter != services.CommentsOpen && filter != services.CommentsResolved {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": `status must be "open" or "resolved"`})
+		return
+	}
+
+	threads, err := h.comments.WithContext(c).List(docID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": threads})
+}
+
+func (h *DocumentHandler) CreateComment(c *gin.Context) {
+	userID := c.GetString("userID")
+	docID := c.Param("id")
+	if !h.svc.WithContext(c).CanComment(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You may not comment on this document"})
+		return
+	}
+
+	var req struct {
+		Content  string                  `json:"content" binding:"required,min=1,max=10000"`
+		ParentID *string                 `json:"parentId"`
+		Anchor   *services.CommentAnchor `json:"anchor"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	comment, err := h.comments.WithContext(c).Create(docID, userID, req.Content, req.ParentID, req.Anchor)
+	if err != nil {
+		c.JSON(commentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": comment, "message": "Comment added"})
+}
+
+func (h *DocumentHandler) UpdateComment(c *gin.Context) {
+	userID := c.GetString("userID")
+	docID := c.Param("id")
+	comments := h.comments.WithContext(c)
+
+	comment, err := comments.Get(docID, c.Param("commentId"))
+	if err != nil {
+		c.JSON(commentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if comment.AuthorID == nil || *comment.AuthorID != userID || !h.svc.WithContext(c).CanComment(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only the author can edit a comment"})
+		return
+	}
+
+	var req struct {
+		Content string `json:"content" binding:"required,min=1,max=10000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	comment, err = comments.Update(docID, comment.ID, req.Content)
+	if err != nil {
+		c.JSON(commentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": comment, "message": "Comment updated"})
+}
+
+func (h *DocumentHandler) ResolveComment(c *gin.Context) {
+	h.setCommentResolved(c, true)
+}
+
+func (h *DocumentHandler) ReopenComment(c *gin.Context) {
+	h.setCommentResolved(c, false)
+}
+
+func (h *DocumentHandler) setCommentResolved(c *gin.Context, resolved bool) {
+	userID := c.GetString("userID")
+	docID := c.Param("id")
+	if !h.svc.WithContext(c).CanComment(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You may not comment on this document"})
+		return
+	}
+
+	var comment *models.DocumentComment
+	var err error
+	message := "Thread resolved"
+	if resolved {
+		comment, err = h.comments.WithContext(c).Resolve(docID, c.Param("commentId"), userID)
+	} else {
+		comment, err = h.comments.WithContext(c).Reopen(docID, c.Param("commentId"))
+		message = "Thread reopened"
+	}
+	if err != nil {
+		c.JSON(commentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": comment, "message": message})
+}
+
+func (h *DocumentHandler) DeleteComment(c *gin.Context) {
+	userID := c.GetString("userID")
+	docID := c.Param("id")
+	comments := h.comments.WithContext(c)
+
+	comment, err := comments.Get(docID, c.Param("commentId"))
+	if err != nil {
+		c.JSON(commentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	isAuthor := comment.AuthorID != nil && *comment.AuthorID == userID && h.svc.WithContext(c).CanComment(docID, userID)
+	if !isAuthor && !h.svc.WithContext(c).IsOwner(docID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only the author or the document owner can delete a comment"})
+		return
+	}
+
+	if err := comments.Delete(docID, comment.ID); err != nil {
+		c.JSON(commentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Comment deleted"})
+}
+
+// Collaborate upgrades the request to a WebSocket connection joined to the
+// document's live editing session
+func (h *DocumentHandler) Collaborate(c *gin.Context) {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "WebSocket upgrade required"})
+		return
+	}
+
+	client, err := h.collab.Join(c, c.Param("id"), c.GetString("userID"))
+	switch {
+	case errors.Is(err, services.ErrCollabAccessDenied):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Document not found"})
+		return
+	case errors.Is(err, services.ErrCollabTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer client.Leave()
+
+	server := websocket.Server{
+		// Connections authenticate with a bearer token rather than cookies,
+		// so any origin may connect
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			ws.MaxPayloadBytes = collabMaxMessage
+			serveCollab(ws, client)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serveCollab relays messages between the connection and the session until
+// either side closes
+func serveCollab(ws *websocket.Conn, client *services.CollabClient) {
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		for msg := range client.Outbox() {
+			if err := websocket.JSON.Send(ws, msg); err != nil {
+				client.Leave()
+				break
+			}
+		}
+		ws.Close()
+	}()
+
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			break
+		}
+		client.Handle(data)
+	}
+	client.Leave()
+	<-written
+}
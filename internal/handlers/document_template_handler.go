@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/services"
+)
+
+type DocumentTemplateHandler struct {
+	svc         services.DocumentTemplateService
+	permissions services.PermissionService
+}
+
+func NewDocumentTemplateHandler(svc services.DocumentTemplateService, permissions services.PermissionService) *DocumentTemplateHandler {
+	return &DocumentTemplateHandler{svc: svc, permissions: permissions}
+}
+
+type documentTemplateRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Scope       *string `json:"scope"`
+	TeamID      *string `json:"teamId"`
+	Title       *string `json:"title"`
+	Content     *string `json:"content"`
+	Type        *string `json:"type"`
+}
+
+func (r documentTemplateRequest) input() services.DocumentTemplateInput {
+	return services.DocumentTemplateInput{
+		Name:        r.Name,
+		Description: r.Description,
+		Scope:       r.Scope,
+		TeamID:      r.TeamID,
+		Title:       r.Title,
+		Content:     r.Content,
+		Type:        r.Type,
+	}
+}
+
+func templateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTemplateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrTemplateDenied):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidTemplate):
+		return http.StatusBadRequest
+	}
+	return teamContentErrorStatus(err)
+}
+
+// List godoc
+// @Summary List document templates
+// @Description Personal templates of the caller, templates of their teams and global templates of the organization
+// @Tags document-templates
+// @Produce json
+// @Param scope query string false "personal, team or global"
+// @Param teamId query string false "Only the templates of this team"
+// @Success 200 {array} models.DocumentTemplate
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/document-templates [get]
+func (h *DocumentTemplateHandler) List(c *gin.Context) {
+	templates, err := h.svc.WithContext(c).List(c.GetString("userID"), c.Query("scope"), c.Query("teamId"))
+	if err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": templates})
+}
+
+// Get godoc
+// @Summary Get document template
+// @Tags document-templates
+// @Produce json
+// @Param id path string true "Template ID"
+// @Success 200 {object} models.DocumentTemplate
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/document-templates/{id} [get]
+func (h *DocumentTemplateHandler) Get(c *gin.Context) {
+	template, err := h.svc.WithContext(c).Get(c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": template})
+}
+
+// Create godoc
+// @Summary Create document template
+// @Description Scope is personal (default), team (with teamId; needs the documents:templates team role permission) or global (needs the documents:templates permission). Title and content may use {{date}}, {{time}}, {{user.name}} and other placeholders.
+// @Tags document-templates
+// @Accept json
+// @Produce json
+// @Param request body documentTemplateRequest true "Template"
+// @Success 201 {object} models.DocumentTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/document-templates [post]
+func (h *DocumentTemplateHandler) Create(c *gin.Context) {
+	var req documentTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	userID := c.GetString("userID")
+	template, err := h.svc.WithContext(c).Create(userID, req.input(), h.canManageGlobal(userID))
+	if err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": template})
+}
+
+// Update godoc
+// @Summary Update document template
+// @Description Scope and team cannot be changed once the template exists
+// @Tags document-templates
+// @Accept json
+// @Produce json
+// @Param id path string true "Template ID"
+// @Param request body documentTemplateRequest true "Fields to change"
+// @Success 200 {object} models.DocumentTemplate
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/document-templates/{id} [patch]
+func (h *DocumentTemplateHandler) Update(c *gin.Context) {
+	var req documentTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	userID := c.GetString("userID")
+	template, err := h.svc.WithContext(c).Update(c.Param("id"), userID, req.input(), h.canManageGlobal(userID))
+	if err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": template})
+}
+
+// Delete godoc
+// @Summary Delete document template
+// @Description Documents created from the template are kept
+// @Tags document-templates
+// @Param id path string true "Template ID"
+// @Success 204
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/document-templates/{id} [delete]
+func (h *DocumentTemplateHandler) Delete(c *gin.Context) {
+	userID := c.GetString("userID")
+	if err := h.svc.WithContext(c).Delete(c.Param("id"), userID, h.canManageGlobal(userID)); err != nil {
+		c.JSON(templateErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// canManageGlobal reports whether userID may manage the organization's global templates
+func (h *DocumentTemplateHandler) canManageGlobal(userID string) bool {
+	return h.permissions.HasPermission(userID, services.DocumentTemplatesManage)
+}
@@ -7,6 +7,9 @@ import (
 	"github.com/halolight/halolight-api-go/internal/services"
 )
 
+// maxFileUpload caps the content of an uploaded file
+const maxFileUpload = 100 << 20
+
 type FileHandler struct {
 	svc       services.FileService
 	folderSvc services.FolderService
@@ -18,16 +21,16 @@ func NewFileHandler(svc services.FileService, folderSvc services.FolderService)
 
 func (h *FileHandler) List(c *gin.Context) {
 	userID := c.GetString("userID")
-	page := getIntQuery(c, "page", 1)
-	limit := getIntQuery(c, "limit", 20)
+	page, limit := getPagination(c, 20)
 	path := c.Query("path")
 	fileType := c.Query("type")
 	search := c.Query("search")
 	folderID := c.Query("folderId")
+	teamID := c.Query("teamId")
 
-	files, total, err := h.svc.List(userID, page, limit, path, fileType, search, folderID)
+	files, total, err := h.svc.WithContext(c).List(userID, page, limit, path, fileType, search, folderID, teamID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
@@ -44,7 +47,7 @@ func (h *FileHandler) List(c *gin.Context) {
 }
 
 func (h *FileHandler) Get(c *gin.Context) {
-	file, err := h.svc.Get(c.Param("id"))
+	file, err := h.svc.WithContext(c).Get(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "File not found"})
 		return
@@ -54,7 +57,7 @@ func (h *FileHandler) Get(c *gin.Context) {
 
 func (h *FileHandler) GetStorage(c *gin.Context) {
 	userID := c.GetString("userID")
-	used, total, available, usedPercent := h.svc.GetStorageInfo(userID)
+	used, total, available, usedPercent := h.svc.WithContext(c).GetStorageInfo(userID)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
@@ -70,16 +73,24 @@ func (h *FileHandler) GetDownloadURL(c *gin.Context) {
 	userID := c.GetString("userID")
 	fileID := c.Param("id")
 
-	if !h.svc.IsOwner(fileID, userID) {
+	if !h.svc.WithContext(c).IsOwner(fileID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
 		return
 	}
 
-	url := h.svc.GetDownloadURL(fileID)
+	url := h.svc.WithContext(c).GetDownloadURL(fileID)
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"url": url}})
 }
 
+// Upload stores a file sent as multipart/form-data under "file", with the
+// optional fields name, path, folderId and teamId. A JSON body records the
+// metadata of a file whose content is kept elsewhere.
 func (h *FileHandler) Upload(c *gin.Context) {
+	if c.ContentType() == "multipart/form-data" {
+		h.uploadContent(c)
+		return
+	}
+
 	var req struct {
 		Name     string `json:"name" binding:"required,min=1"`
 		Path     string `json:"path" binding:"required"`
@@ -94,9 +105,47 @@ func (h *FileHandler) Upload(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	file, err := h.svc.Create(req.Name, req.Path, req.MimeType, req.Size, req.FolderID, req.TeamID, userID)
+	file, err := h.svc.WithContext(c).Create(req.Name, req.Path, req.MimeType, req.Size, req.FolderID, req.TeamID, userID, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": file, "message": "File uploaded"})
+}
+
+func (h *FileHandler) uploadContent(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "file is required"})
+		return
+	}
+	if fileHeader.Size > maxFileUpload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": "file exceeds 100MB"})
+		return
+	}
+	content, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "failed to read file"})
+		return
+	}
+	defer content.Close()
+
+	name := c.DefaultPostForm("name", fileHeader.Filename)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "name is required"})
+		return
+	}
+	mimeType := fileHeader.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	userID := c.GetString("userID")
+	file, err := h.svc.WithContext(c).Create(name, c.DefaultPostForm("path", "/"), mimeType, fileHeader.Size,
+		c.PostForm("folderId"), c.PostForm("teamId"), userID, content)
+	if err != nil {
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
@@ -107,6 +156,7 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 	var req struct {
 		Name     string  `json:"name" binding:"required,min=1"`
 		ParentID *string `json:"parentId"`
+		TeamID   *string `json:"teamId"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
@@ -114,9 +164,9 @@ func (h *FileHandler) CreateFolder(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	folder, err := h.folderSvc.Create(req.Name, req.ParentID, nil, userID)
+	folder, err := h.folderSvc.WithContext(c).Create(req.Name, req.ParentID, req.TeamID, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
@@ -127,7 +177,7 @@ func (h *FileHandler) Rename(c *gin.Context) {
 	userID := c.GetString("userID")
 	fileID := c.Param("id")
 
-	if !h.svc.IsOwner(fileID, userID) {
+	if !h.svc.WithContext(c).IsOwner(fileID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can rename"})
 		return
 	}
@@ -140,7 +190,7 @@ func (h *FileHandler) Rename(c *gin.Context) {
 		return
 	}
 
-	file, err := h.svc.Rename(fileID, req.Name)
+	file, err := h.svc.WithContext(c).Rename(fileID, req.Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -153,7 +203,7 @@ func (h *FileHandler) Move(c *gin.Context) {
 	userID := c.GetString("userID")
 	fileID := c.Param("id")
 
-	if !h.svc.IsOwner(fileID, userID) {
+	if !h.svc.WithContext(c).IsOwner(fileID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can move"})
 		return
 	}
@@ -167,7 +217,7 @@ func (h *FileHandler) Move(c *gin.Context) {
 		return
 	}
 
-	file, err := h.svc.Move(fileID, req.FolderID, req.Path)
+	file, err := h.svc.WithContext(c).Move(fileID, req.FolderID, req.Path)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -178,7 +228,7 @@ func (h *FileHandler) Move(c *gin.Context) {
 
 func (h *FileHandler) Copy(c *gin.Context) {
 	userID := c.GetString("userID")
-	file, err := h.svc.Copy(c.Param("id"), userID)
+	file, err := h.svc.WithContext(c).Copy(c.Param("id"), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -190,12 +240,12 @@ func (h *FileHandler) ToggleFavorite(c *gin.Context) {
 	userID := c.GetString("userID")
 	fileID := c.Param("id")
 
-	if !h.svc.IsOwner(fileID, userID) {
+	if !h.svc.WithContext(c).IsOwner(fileID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can favorite"})
 		return
 	}
 
-	file, err := h.svc.ToggleFavorite(fileID)
+	file, err := h.svc.WithContext(c).ToggleFavorite(fileID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -204,10 +254,6 @@ func (h *FileHandler) ToggleFavorite(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": file, "message": "Favorite toggled"})
 }
 
-func (h *FileHandler) Share(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"shared": true}, "message": "File shared"})
-}
-
 func (h *FileHandler) BatchDelete(c *gin.Context) {
 	var req struct {
 		IDs []string `json:"ids" binding:"required"`
@@ -218,7 +264,7 @@ func (h *FileHandler) BatchDelete(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	if err := h.svc.DeleteMany(req.IDs, userID); err != nil {
+	if err := h.svc.WithContext(c).DeleteMany(req.IDs, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -230,12 +276,12 @@ func (h *FileHandler) Delete(c *gin.Context) {
 	userID := c.GetString("userID")
 	fileID := c.Param("id")
 
-	if !h.svc.IsOwner(fileID, userID) {
+	if !h.svc.WithContext(c).IsOwner(fileID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can delete"})
 		return
 	}
 
-	if err := h.svc.Delete(fileID); err != nil {
+	if err := h.svc.WithContext(c).Delete(fileID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
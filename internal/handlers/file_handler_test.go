@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/repository"
+	"github.com/halolight/halolight-api-go/internal/services"
+	"github.com/halolight/halolight-api-go/internal/testdb"
+	"github.com/halolight/halolight-api-go/pkg/storage"
+)
+
+// fileFixture routes uploads, share links and their public downloads through
+// one private store, as the application does
+type fileFixture struct {
+	router *gin.Engine
+	owner  *models.User
+}
+
+func newFileFixture(t *testing.T) *fileFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := testdb.Open(t,
+		&models.User{},
+		&models.UserAttribute{},
+		&models.Folder{},
+		&models.File{},
+		&models.ShareLink{},
+		&models.ShareLinkAccess{},
+	)
+	users := services.NewUserService(repository.NewUserRepository(db), services.NewAttributeService(db))
+	owner, err := users.Create("owner@example.test", "owner", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := storage.NewLocal(t.TempDir(), "")
+	files := services.NewFileService(db, store)
+	fileHandler := NewFileHandler(files, services.NewFolderService(db))
+	linkHandler := NewShareLinkHandler(services.NewShareLinkService(db, store), nil, files)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", c.GetHeader("X-Test-User"))
+	})
+	r.POST("/files", fileHandler.Upload)
+	r.POST("/files/:id/copy", fileHandler.Copy)
+	r.POST("/files/:id/links", linkHandler.CreateFileLink)
+	r.GET("/s/:token/download", linkHandler.Download)
+	return &fileFixture{router: r, owner: owner}
+}
+
+func (f *fileFixture) do(t *testing.T, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	req.Header.Set("X-Test-User", f.owner.ID)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+// upload sends content as a multipart upload and returns the new file's id
+func (f *fileFixture) upload(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/files", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := f.do(t, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d, body %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data models.File `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Name != name || resp.Data.Size != int64(len(content)) {
+		t.Fatalf("uploaded file = %+v", resp.Data)
+	}
+	return resp.Data.ID
+}
+
+// download creates a share link for fileID and downloads it anonymously
+func (f *fileFixture) download(t *testing.T, fileID string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := f.do(t, httptest.NewRequest(http.MethodPost, "/files/"+fileID+"/links", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create link: status %d, body %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data struct {
+			URL string `json:"url"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	w = httptest.NewRecorder()
+	f.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, resp.Data.URL+"/download", nil))
+	return w
+}
+
+func TestUploadedFileDownloadsThroughShareLink(t *testing.T) {
+	f := newFileFixture(t)
+	content := []byte("quarterly numbers\n")
+
+	id := f.upload(t, "report.txt", content)
+	w := f.download(t, id)
+	if w.Code != http.StatusOK {
+		t.Fatalf("download: status %d, body %s", w.Code, w.Body)
+	}
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("downloaded %q, want %q", w.Body.Bytes(), content)
+	}
+}
+
+func TestCopiedFileKeepsContent(t *testing.T) {
+	f := newFileFixture(t)
+	content := []byte("draft")
+
+	id := f.upload(t, "draft.txt", content)
+	w := f.do(t, httptest.NewRequest(http.MethodPost, "/files/"+id+"/copy", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("copy: status %d, body %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Data models.File `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	w = f.download(t, resp.Data.ID)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("download of the copy: status %d, body %q", w.Code, w.Body)
+	}
+}
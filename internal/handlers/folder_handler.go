@@ -22,9 +22,9 @@ func (h *FolderHandler) List(c *gin.Context) {
 		parentID = &p
 	}
 
-	folders, err := h.svc.List(userID, parentID)
+	folders, err := h.svc.WithContext(c).List(userID, parentID, c.Query("teamId"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": folders})
@@ -32,7 +32,7 @@ func (h *FolderHandler) List(c *gin.Context) {
 
 func (h *FolderHandler) GetTree(c *gin.Context) {
 	userID := c.GetString("userID")
-	tree, err := h.svc.GetTree(userID)
+	tree, err := h.svc.WithContext(c).GetTree(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -41,7 +41,7 @@ func (h *FolderHandler) GetTree(c *gin.Context) {
 }
 
 func (h *FolderHandler) Get(c *gin.Context) {
-	folder, err := h.svc.Get(c.Param("id"))
+	folder, err := h.svc.WithContext(c).Get(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Folder not found"})
 		return
@@ -61,9 +61,9 @@ func (h *FolderHandler) Create(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	folder, err := h.svc.Create(req.Name, req.ParentID, req.TeamID, userID)
+	folder, err := h.svc.WithContext(c).Create(req.Name, req.ParentID, req.TeamID, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
@@ -74,7 +74,7 @@ func (h *FolderHandler) Rename(c *gin.Context) {
 	userID := c.GetString("userID")
 	folderID := c.Param("id")
 
-	if !h.svc.IsOwner(folderID, userID) {
+	if !h.svc.WithContext(c).IsOwner(folderID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can rename"})
 		return
 	}
@@ -87,7 +87,7 @@ func (h *FolderHandler) Rename(c *gin.Context) {
 		return
 	}
 
-	folder, err := h.svc.Rename(folderID, req.Name)
+	folder, err := h.svc.WithContext(c).Rename(folderID, req.Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -100,12 +100,12 @@ func (h *FolderHandler) Delete(c *gin.Context) {
 	userID := c.GetString("userID")
 	folderID := c.Param("id")
 
-	if !h.svc.IsOwner(folderID, userID) {
+	if !h.svc.WithContext(c).IsOwner(folderID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can delete"})
 		return
 	}
 
-	if err := h.svc.Delete(folderID); err != nil {
+	if err := h.svc.WithContext(c).Delete(folderID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
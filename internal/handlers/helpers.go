@@ -1,9 +1,15 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/services"
 )
 
 func getIntQuery(c *gin.Context, key string, defaultVal int) int {
@@ -18,6 +24,20 @@ func getIntQuery(c *gin.Context, key string, defaultVal int) int {
 	return i
 }
 
+// getPagination reads the page and limit query parameters. Pages start at 1
+// and a limit outside 1 to 100 falls back to defaultLimit.
+func getPagination(c *gin.Context, defaultLimit int) (page, limit int) {
+	page = getIntQuery(c, "page", 1)
+	if page < 1 {
+		page = 1
+	}
+	limit = getIntQuery(c, "limit", defaultLimit)
+	if limit < 1 || limit > 100 {
+		limit = defaultLimit
+	}
+	return page, limit
+}
+
 func getBoolQuery(c *gin.Context, key string, defaultVal bool) bool {
 	val := c.Query(key)
 	if val == "" {
@@ -29,3 +49,52 @@ func getBoolQuery(c *gin.Context, key string, defaultVal bool) bool {
 	}
 	return b
 }
+
+// getTimeQuery parses an RFC 3339 timestamp or a YYYY-MM-DD date from the query string.
+// A missing parameter yields nil without an error.
+func getTimeQuery(c *gin.Context, key string) (*time.Time, error) {
+	val := c.Query(key)
+	if val == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", val)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: expected RFC 3339 timestamp or YYYY-MM-DD date", key)
+	}
+	return &t, nil
+}
+
+// openUpload opens the multipart file in field, rejecting uploads larger than maxSize.
+// The returned status is the HTTP code to answer with when err is not nil.
+func openUpload(c *gin.Context, field string, maxSize int64) (multipart.File, int, error) {
+	fileHeader, err := c.FormFile(field)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("%s is required", field)
+	}
+	if fileHeader.Size > maxSize {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("%s exceeds %dMB", field, maxSize>>20)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to read %s", field)
+	}
+	return file, 0, nil
+}
+
+// avatarErrorStatus maps avatar processing errors to HTTP status codes
+func avatarErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrTeamNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrAvatarTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, services.ErrAvatarUnsupported):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, services.ErrAvatarDimensions), errors.Is(err, services.ErrAvatarInvalid):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
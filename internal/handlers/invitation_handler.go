@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/services"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/config"
+	"github.com/halolight/halolight-api-go/pkg/utils"
+)
+
+type InvitationHandler struct {
+	svc         services.InvitationService
+	teamInvites services.TeamInvitationService
+	cfg         config.Config
+}
+
+func NewInvitationHandler(svc services.InvitationService, teamInvites services.TeamInvitationService, cfg config.Config) *InvitationHandler {
+	return &InvitationHandler{svc: svc, teamInvites: teamInvites, cfg: cfg}
+}
+
+func invitationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvitationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrEmailExists), errors.Is(err, services.ErrUsernameExists),
+		errors.Is(err, services.ErrInvitationExists), errors.Is(err, services.ErrInvitationClosed):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidEmail), errors.Is(err, services.ErrUnknownRole),
+		errors.Is(err, services.ErrUnknownTeam), errors.Is(err, services.ErrInvitationInvalid),
+		errors.Is(err, services.ErrWeakPassword):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// List godoc
+// @Summary List invitations
+// @Tags invitations
+// @Produce json
+// @Param status query string false "PENDING, ACCEPTED or REVOKED"
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/invitations [get]
+func (h *InvitationHandler) List(c *gin.Context) {
+	page, limit := getPagination(c, 20)
+
+	invitations, total, err := h.svc.WithContext(c).List(c.Query("status"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invitations,
+		"meta": gin.H{
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// Create godoc
+// @Summary Invite a user by email
+// @Description Send an invitation link; roles and teams are assigned when the invitee accepts
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param request body object true "{\"email\": \"...\", \"roleIds\": [], \"teamIds\": []}"
+// @Success 201 {object} models.Invitation
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/invitations [post]
+func (h *InvitationHandler) Create(c *gin.Context) {
+	var req struct {
+		Email   string   `json:"email" binding:"required,email"`
+		RoleIDs []string `json:"roleIds"`
+		TeamIDs []string `json:"teamIds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	invitation, err := h.svc.WithContext(c).Create(c.GetString("userID"), services.CreateInvitationInput{
+		Email:   req.Email,
+		RoleIDs: req.RoleIDs,
+		TeamIDs: req.TeamIDs,
+	})
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": invitation, "message": "Invitation sent"})
+}
+
+// Resend godoc
+// @Summary Resend an invitation
+// @Description Issue a new link and expiry; earlier links stop working
+// @Tags invitations
+// @Produce json
+// @Param id path string true "Invitation ID"
+// @Success 200 {object} models.Invitation
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/invitations/{id}/resend [post]
+func (h *InvitationHandler) Resend(c *gin.Context) {
+	invitation, err := h.svc.WithContext(c).Resend(c.Param("id"))
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": invitation, "message": "Invitation resent"})
+}
+
+// Revoke godoc
+// @Summary Revoke an invitation
+// @Tags invitations
+// @Produce json
+// @Param id path string true "Invitation ID"
+// @Success 200 {object} models.Invitation
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/invitations/{id}/revoke [post]
+func (h *InvitationHandler) Revoke(c *gin.Context) {
+	invitation, err := h.svc.WithContext(c).Revoke(c.Param("id"))
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": invitation, "message": "Invitation revoked"})
+}
+
+// Accept godoc
+// @Summary Accept an invitation
+// @Description Create the invited account with the invitee's own password, assign the invited roles and teams, and sign in
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Param request body object true "{\"token\": \"...\", \"username\": \"...\", \"password\": \"...\", \"name\": \"...\"}"
+// @Success 201 {object} authResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/invitations/accept [post]
+func (h *InvitationHandler) Accept(c *gin.Context) {
+	var req struct {
+		Token    string `json:"token" binding:"required"`
+		Username string `json:"username" binding:"required,min=3,max=64"`
+		Password string `json:"password" binding:"required,min=6"`
+		Name     string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	user, err := h.svc.WithContext(c).Accept(services.AcceptInvitationInput{
+		Token:    req.Token,
+		Username: req.Username,
+		Password: req.Password,
+		Name:     req.Name,
+	})
+	if err != nil {
+		c.JSON(invitationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	// Team invitations sent to this address before sign-up; best effort
+	if err := h.teamInvites.WithContext(tenant.WithID(c, user.TenantID)).Claim(user.ID, user.Email); err != nil {
+		log.Printf("claim team invitations for %s: %v", user.ID, err)
+	}
+
+	token, err := utils.GenerateAccessToken(user.ID, user.TenantID, h.cfg.JWTSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to generate token"})
+		return
+	}
+	c.JSON(http.StatusCreated, authResponse{User: user, Token: token})
+}
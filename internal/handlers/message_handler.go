@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -17,7 +18,7 @@ func NewMessageHandler(svc services.MessageService) *MessageHandler {
 
 func (h *MessageHandler) GetConversations(c *gin.Context) {
 	userID := c.GetString("userID")
-	conversations, err := h.svc.GetConversations(userID)
+	conversations, err := h.svc.WithContext(c).GetConversations(userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -29,12 +30,12 @@ func (h *MessageHandler) GetConversation(c *gin.Context) {
 	userID := c.GetString("userID")
 	convID := c.Param("id")
 
-	if !h.svc.IsParticipant(convID, userID) {
+	if !h.svc.WithContext(c).IsParticipant(convID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
 		return
 	}
 
-	conversation, err := h.svc.GetConversation(convID, userID)
+	conversation, err := h.svc.WithContext(c).GetConversation(convID, userID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Conversation not found"})
 		return
@@ -55,8 +56,12 @@ func (h *MessageHandler) CreateConversation(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	conversation, err := h.svc.CreateConversation(req.Name, req.IsGroup, req.ParticipantIDs, userID)
+	conversation, err := h.svc.WithContext(c).CreateConversation(req.Name, req.IsGroup, req.ParticipantIDs, userID)
 	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -76,12 +81,12 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	if !h.svc.IsParticipant(req.ConversationID, userID) {
+	if !h.svc.WithContext(c).IsParticipant(req.ConversationID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Access denied"})
 		return
 	}
 
-	message, err := h.svc.SendMessage(req.ConversationID, userID, req.Content, req.Type)
+	message, err := h.svc.WithContext(c).SendMessage(req.ConversationID, userID, req.Content, req.Type)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -94,7 +99,7 @@ func (h *MessageHandler) MarkAsRead(c *gin.Context) {
 	userID := c.GetString("userID")
 	convID := c.Param("id")
 
-	if err := h.svc.MarkAsRead(convID, userID); err != nil {
+	if err := h.svc.WithContext(c).MarkAsRead(convID, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -106,12 +111,12 @@ func (h *MessageHandler) DeleteMessage(c *gin.Context) {
 	userID := c.GetString("userID")
 	msgID := c.Param("id")
 
-	if !h.svc.IsMessageOwner(msgID, userID) {
+	if !h.svc.WithContext(c).IsMessageOwner(msgID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only sender can delete"})
 		return
 	}
 
-	if err := h.svc.DeleteMessage(msgID); err != nil {
+	if err := h.svc.WithContext(c).DeleteMessage(msgID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
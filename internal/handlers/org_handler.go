@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/services"
+)
+
+type OrgHandler struct {
+	svc services.OrgService
+}
+
+func NewOrgHandler(svc services.OrgService) *OrgHandler {
+	return &OrgHandler{svc: svc}
+}
+
+type departmentRequest struct {
+	Name        *string `json:"name" binding:"omitempty,max=191"`
+	Description *string `json:"description"`
+	ParentID    *string `json:"parentId"`
+	HeadID      *string `json:"headId"`
+}
+
+func (r departmentRequest) input() services.DepartmentInput {
+	return services.DepartmentInput{
+		Name:        r.Name,
+		Description: r.Description,
+		ParentID:    r.ParentID,
+		HeadID:      r.HeadID,
+	}
+}
+
+func orgErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrDepartmentNotFound), errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrDepartmentExists), errors.Is(err, services.ErrDepartmentNotEmpty):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrDepartmentName), errors.Is(err, services.ErrDepartmentCycle),
+		errors.Is(err, services.ErrManagerNotFound), errors.Is(err, services.ErrManagerCycle):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// orgUserID resolves the :id path parameter, where "me" is the current user
+func orgUserID(c *gin.Context) string {
+	if id := c.Param("id"); id != "me" {
+		return id
+	}
+	return c.GetString("userID")
+}
+
+// ListDepartments godoc
+// @Summary List departments
+// @Description List departments flat, or as a tree with member counts when tree=true
+// @Tags departments
+// @Produce json
+// @Param tree query bool false "Return nested departments"
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/departments [get]
+func (h *OrgHandler) ListDepartments(c *gin.Context) {
+	var (
+		data interface{}
+		err  error
+	)
+	if getBoolQuery(c, "tree", false) {
+		data, err = h.svc.WithContext(c).DepartmentTree()
+	} else {
+		data, err = h.svc.WithContext(c).ListDepartments()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// GetDepartment godoc
+// @Summary Get department
+// @Tags departments
+// @Produce json
+// @Param id path string true "Department ID"
+// @Success 200 {object} models.Department
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/departments/{id} [get]
+func (h *OrgHandler) GetDepartment(c *gin.Context) {
+	department, err := h.svc.WithContext(c).GetDepartment(c.Param("id"))
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": department})
+}
+
+// CreateDepartment godoc
+// @Summary Create department
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param request body departmentRequest true "Department"
+// @Success 201 {object} models.Department
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/departments [post]
+func (h *OrgHandler) CreateDepartment(c *gin.Context) {
+	var req departmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	department, err := h.svc.WithContext(c).CreateDepartment(req.input())
+	if err != nil {
+		status := orgErrorStatus(err)
+		if errors.Is(err, services.ErrDepartmentNotFound) || errors.Is(err, services.ErrUserNotFound) {
+			// The parent or head in the body does not exist
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": department})
+}
+
+// UpdateDepartment godoc
+// @Summary Update department
+// @Description Rename, describe, move (parentId, "" for the top level) or set the head (headId, "" to clear) of a department
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param id path string true "Department ID"
+// @Param request body departmentRequest true "Fields to change"
+// @Success 200 {object} models.Department
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/departments/{id} [patch]
+func (h *OrgHandler) UpdateDepartment(c *gin.Context) {
+	var req departmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	department, err := h.svc.WithContext(c).UpdateDepartment(c.Param("id"), req.input())
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": department})
+}
+
+// DeleteDepartment godoc
+// @Summary Delete department
+// @Description Only departments without sub-departments and members can be deleted
+// @Tags departments
+// @Param id path string true "Department ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/departments/{id} [delete]
+func (h *OrgHandler) DeleteDepartment(c *gin.Context) {
+	if err := h.svc.WithContext(c).DeleteDepartment(c.Param("id")); err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Tree godoc
+// @Summary Get org chart
+// @Description Reporting tree built from manager relationships; users without a manager are the roots
+// @Tags org
+// @Produce json
+// @Param rootId query string false "Only return the tree below this user"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/org/tree [get]
+func (h *OrgHandler) Tree(c *gin.Context) {
+	tree, err := h.svc.WithContext(c).Tree(c.Query("rootId"))
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tree})
+}
+
+// Reports godoc
+// @Summary List reports
+// @Description Direct and indirect reports of a user with their depth below the user ("me" for the current user)
+// @Tags org
+// @Produce json
+// @Param id path string true "User ID or me"
+// @Param direct query bool false "Only direct reports"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/reports [get]
+func (h *OrgHandler) Reports(c *gin.Context) {
+	reports, err := h.svc.WithContext(c).Reports(orgUserID(c), getBoolQuery(c, "direct", false))
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reports})
+}
+
+// ManagementChain godoc
+// @Summary Get management chain
+// @Description The user's managers from the direct manager upwards ("me" for the current user)
+// @Tags org
+// @Produce json
+// @Param id path string true "User ID or me"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/manager-chain [get]
+func (h *OrgHandler) ManagementChain(c *gin.Context) {
+	chain, err := h.svc.WithContext(c).ManagementChain(orgUserID(c))
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": chain})
+}
+
+// SetManager godoc
+// @Summary Set manager
+// @Description Set the user's manager, or clear it with an empty managerId. Assignments that would create a reporting cycle are rejected.
+// @Tags org
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body object true "{\"managerId\": \"...\"}"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/manager [put]
+func (h *OrgHandler) SetManager(c *gin.Context) {
+	var req struct {
+		ManagerID string `json:"managerId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	user, err := h.svc.WithContext(c).SetManager(c.Param("id"), req.ManagerID)
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": user})
+}
+
+// SetDepartment godoc
+// @Summary Set department
+// @Description Assign the user to a department, or clear it with an empty departmentId
+// @Tags org
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body object true "{\"departmentId\": \"...\"}"
+// @Success 200 {object} models.User
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/department [put]
+func (h *OrgHandler) SetDepartment(c *gin.Context) {
+	var req struct {
+		DepartmentID string `json:"departmentId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	user, err := h.svc.WithContext(c).SetDepartment(c.Param("id"), req.DepartmentID)
+	if err != nil {
+		c.JSON(orgErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": user})
+}
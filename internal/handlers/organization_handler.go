@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/services"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+)
+
+type OrganizationHandler struct {
+	svc services.OrganizationService
+}
+
+func NewOrganizationHandler(svc services.OrganizationService) *OrganizationHandler {
+	return &OrganizationHandler{svc: svc}
+}
+
+func organizationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrOrganizationExists), errors.Is(err, services.ErrEmailExists),
+		errors.Is(err, services.ErrUsernameExists):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidOrganization), errors.Is(err, services.ErrInvalidEmail),
+		errors.Is(err, services.ErrWeakPassword), errors.Is(err, services.ErrSCIMGroupOwner):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+type renameOrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=191"`
+}
+
+// Current godoc
+// @Summary Get current organization
+// @Description The organization the signed-in user belongs to
+// @Tags organizations
+// @Produce json
+// @Success 200 {object} models.Organization
+// @Security BearerAuth
+// @Router /api/organization [get]
+func (h *OrganizationHandler) Current(c *gin.Context) {
+	organization, err := h.svc.Get(c.GetString(tenant.ContextKey))
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": organization})
+}
+
+// UpdateCurrent godoc
+// @Summary Rename current organization
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body renameOrganizationRequest true "New name"
+// @Success 200 {object} models.Organization
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/organization [patch]
+func (h *OrganizationHandler) UpdateCurrent(c *gin.Context) {
+	var req renameOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	organization, err := h.svc.Rename(c.GetString(tenant.ContextKey), req.Name)
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": organization})
+}
+
+// SetSCIMToken godoc
+// @Summary Generate SCIM token
+// @Description Replaces the current organization's SCIM bearer token. The token is only returned here; identity providers provision the organization with it.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body object false "{\"groupOwnerId\": \"...\"}"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/organization/scim-token [post]
+func (h *OrganizationHandler) SetSCIMToken(c *gin.Context) {
+	var req struct {
+		GroupOwnerID string `json:"groupOwnerId"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	token, err := h.svc.SetSCIMToken(c.GetString(tenant.ContextKey), req.GroupOwnerID)
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"token": token}, "message": "SCIM token created"})
+}
+
+// DisableSCIM godoc
+// @Summary Disable SCIM
+// @Description Removes the current organization's SCIM bearer token
+// @Tags organizations
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organization/scim-token [delete]
+func (h *OrganizationHandler) DisableSCIM(c *gin.Context) {
+	if err := h.svc.DisableSCIM(c.GetString(tenant.ContextKey)); err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "SCIM disabled"})
+}
+
+// List godoc
+// @Summary List organizations
+// @Description Platform administrators only
+// @Tags organizations
+// @Produce json
+// @Param page query int false "Page" default(1)
+// @Param limit query int false "Page size" default(20)
+// @Success 200 {object} map[string]interface{}
+// @Security BearerAuth
+// @Router /api/organizations [get]
+func (h *OrganizationHandler) List(c *gin.Context) {
+	page, limit := getPagination(c, 20)
+
+	organizations, total, err := h.svc.List(page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    organizations,
+		"meta": gin.H{
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// Get godoc
+// @Summary Get organization
+// @Description Platform administrators only
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Success 200 {object} models.Organization
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/organizations/{id} [get]
+func (h *OrganizationHandler) Get(c *gin.Context) {
+	organization, err := h.svc.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": organization})
+}
+
+// Create godoc
+// @Summary Create organization
+// @Description Platform administrators only. Creates the organization with an admin role holding every permission within it, and its first administrator.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body object true "{\"name\": \"...\", \"slug\": \"...\", \"admin\": {\"email\": \"...\", \"username\": \"...\", \"name\": \"...\", \"password\": \"...\"}}"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/organizations [post]
+func (h *OrganizationHandler) Create(c *gin.Context) {
+	var req struct {
+		Name  string `json:"name" binding:"required,max=191"`
+		Slug  string `json:"slug" binding:"required"`
+		Admin struct {
+			Email    string `json:"email" binding:"required,email"`
+			Username string `json:"username" binding:"required"`
+			Name     string `json:"name"`
+			Password string `json:"password" binding:"required"`
+		} `json:"admin" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	organization, admin, err := h.svc.Create(services.CreateOrganizationInput{
+		Name:          req.Name,
+		Slug:          req.Slug,
+		AdminEmail:    req.Admin.Email,
+		AdminUsername: req.Admin.Username,
+		AdminName:     req.Admin.Name,
+		AdminPassword: req.Admin.Password,
+	})
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    gin.H{"organization": organization, "admin": admin},
+		"message": "Organization created",
+	})
+}
+
+// Update godoc
+// @Summary Rename organization
+// @Description Platform administrators only
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "Organization ID"
+// @Param request body renameOrganizationRequest true "New name"
+// @Success 200 {object} models.Organization
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/organizations/{id} [patch]
+func (h *OrganizationHandler) Update(c *gin.Context) {
+	var req renameOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	organization, err := h.svc.Rename(c.Param("id"), req.Name)
+	if err != nil {
+		c.JSON(organizationErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": organization})
+}
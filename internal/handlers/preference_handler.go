@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/services"
+)
+
+type PreferenceHandler struct {
+	svc services.PreferenceService
+}
+
+func NewPreferenceHandler(svc services.PreferenceService) *PreferenceHandler {
+	return &PreferenceHandler{svc: svc}
+}
+
+// Get godoc
+// @Summary Get my preferences
+// @Description Locale, time zone, theme, landing page and notification settings of the current user; defaults are returned until the user saves any
+// @Tags users
+// @Produce json
+// @Success 200 {object} models.UserPreference
+// @Security BearerAuth
+// @Router /api/users/me/preferences [get]
+func (h *PreferenceHandler) Get(c *gin.Context) {
+	pref, err := h.svc.Get(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": pref})
+}
+
+// Update godoc
+// @Summary Update my preferences
+// @Description Partially update the current user's preferences; omitted fields are kept
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body services.PreferenceUpdate true "Preferences"
+// @Success 200 {object} models.UserPreference
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/me/preferences [patch]
+func (h *PreferenceHandler) Update(c *gin.Context) {
+	var req services.PreferenceUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	pref, err := h.svc.Update(c.GetString("userID"), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPreference) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": pref, "message": "Preferences updated"})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/services"
+)
+
+type PrivacyHandler struct {
+	svc services.PrivacyService
+}
+
+func NewPrivacyHandler(svc services.PrivacyService) *PrivacyHandler {
+	return &PrivacyHandler{svc: svc}
+}
+
+func privacyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrExportNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrExportInProgress), errors.Is(err, services.ErrExportNotReady),
+		errors.Is(err, services.ErrAlreadyErased):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrExportExpired):
+		return http.StatusGone
+	case errors.Is(err, services.ErrSuccessorRequired), errors.Is(err, services.ErrInvalidSuccessor):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// RequestExport godoc
+// @Summary Request a personal data export
+// @Description Queue a zip archive of everything stored about the user (profile, documents, files, calendar, messages, notifications, activity) with a JSON manifest
+// @Tags privacy
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 202 {object} models.DataExport
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/data-exports [post]
+func (h *PrivacyHandler) RequestExport(c *gin.Context) {
+	export, err := h.svc.WithContext(c).RequestExport(c.Param("id"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "data": export, "message": "Export queued"})
+}
+
+// GetExport godoc
+// @Summary Get data export status
+// @Tags privacy
+// @Produce json
+// @Param id path string true "User ID"
+// @Param exportId path string true "Export ID"
+// @Success 200 {object} models.DataExport
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/data-exports/{exportId} [get]
+func (h *PrivacyHandler) GetExport(c *gin.Context) {
+	export, err := h.svc.WithContext(c).GetExport(c.Param("id"), c.Param("exportId"))
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": export})
+}
+
+// DownloadExport godoc
+// @Summary Download a data export
+// @Tags privacy
+// @Produce application/zip
+// @Param id path string true "User ID"
+// @Param exportId path string true "Export ID"
+// @Success 200 {file} file
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/data-exports/{exportId}/download [get]
+func (h *PrivacyHandler) DownloadExport(c *gin.Context) {
+	export, rc, err := h.svc.WithContext(c).OpenExport(c.Param("id"), c.Param("exportId"))
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer rc.Close()
+
+	c.Header("Content-Disposition", `attachment; filename="personal-data-`+export.ID+`.zip"`)
+	c.Header("Cache-Control", "no-store")
+	c.DataFromReader(http.StatusOK, export.Size, "application/zip", rc, nil)
+}
+
+// Erase godoc
+// @Summary Erase a user's personal data
+// @Description Transfer owned team resources to a successor, delete private content and anonymize the account. This cannot be undone.
+// @Tags privacy
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body object false "{\"successorId\": \"...\"}"
+// @Success 200 {object} services.ErasureReport
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/erase [post]
+func (h *PrivacyHandler) Erase(c *gin.Context) {
+	var req struct {
+		SuccessorID string `json:"successorId"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	report, err := h.svc.WithContext(c).Erase(c.Param("id"), services.ErasureRequest{
+		ActorID:     c.GetString("userID"),
+		SuccessorID: req.SuccessorID,
+	})
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report, "message": "User data erased"})
+}
@@ -16,7 +16,7 @@ func NewRoleHandler(svc services.RoleService) *RoleHandler {
 }
 
 func (h *RoleHandler) List(c *gin.Context) {
-	roles, err := h.svc.List()
+	roles, err := h.svc.WithContext(c).List()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -25,7 +25,7 @@ func (h *RoleHandler) List(c *gin.Context) {
 }
 
 func (h *RoleHandler) Get(c *gin.Context) {
-	role, err := h.svc.Get(c.Param("id"))
+	role, err := h.svc.WithContext(c).Get(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Role not found"})
 		return
@@ -44,7 +44,7 @@ func (h *RoleHandler) Create(c *gin.Context) {
 		return
 	}
 
-	role, err := h.svc.Create(req.Name, req.Label, req.Description)
+	role, err := h.svc.WithContext(c).Create(req.Name, req.Label, req.Description)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -64,7 +64,7 @@ func (h *RoleHandler) Update(c *gin.Context) {
 		return
 	}
 
-	role, err := h.svc.Update(c.Param("id"), req.Name, req.Label, req.Description)
+	role, err := h.svc.WithContext(c).Update(c.Param("id"), req.Name, req.Label, req.Description)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -74,7 +74,7 @@ func (h *RoleHandler) Update(c *gin.Context) {
 }
 
 func (h *RoleHandler) Delete(c *gin.Context) {
-	if err := h.svc.Delete(c.Param("id")); err != nil {
+	if err := h.svc.WithContext(c).Delete(c.Param("id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -90,7 +90,7 @@ func (h *RoleHandler) AssignPermissions(c *gin.Context) {
 		return
 	}
 
-	role, err := h.svc.AssignPermissions(c.Param("id"), req.PermissionIDs)
+	role, err := h.svc.WithContext(c).AssignPermissions(c.Param("id"), req.PermissionIDs)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/scim"
+	"github.com/halolight/halolight-api-go/internal/services"
+)
+
+type SCIMHandler struct {
+	svc services.SCIMService
+}
+
+func NewSCIMHandler(svc services.SCIMService) *SCIMHandler {
+	return &SCIMHandler{svc: svc}
+}
+
+func writeSCIM(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", scim.ContentType)
+	c.JSON(status, body)
+}
+
+func writeSCIMError(c *gin.Context, err error) {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		scimErr = scim.NewError(http.StatusInternalServerError, "", "%s", err.Error())
+	}
+	writeSCIM(c, scimErr.HTTPStatus(), scimErr)
+}
+
+// scimBaseURL is the absolute /scim/v2 root used in meta.location
+func scimBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/scim/v2"
+}
+
+// checkPrecondition enforces If-Match; it writes 412 and returns false on mismatch
+func checkPrecondition(c *gin.Context, version string) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimSpace(tag) == version {
+			return true
+		}
+	}
+	writeSCIM(c, http.StatusPreconditionFailed, scim.NewError(http.StatusPreconditionFailed, "", "resource version does not match If-Match"))
+	return false
+}
+
+// writeVersioned sends a resource with its ETag, honouring If-None-Match on reads
+func writeVersioned(c *gin.Context, status int, version string, body interface{}) {
+	c.Header("ETag", version)
+	if c.Request.Method == http.MethodGet && c.GetHeader("If-None-Match") == version {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	writeSCIM(c, status, body)
+}
+
+func (h *SCIMHandler) ServiceProviderConfig(c *gin.Context) {
+	writeSCIM(c, http.StatusOK, scim.ServiceProviderConfig(services.SCIMMaxResults))
+}
+
+func (h *SCIMHandler) ResourceTypes(c *gin.Context) {
+	types := scim.ResourceTypes(scimBaseURL(c))
+	writeSCIM(c, http.StatusOK, scim.NewListResponse(types, int64(len(types)), 1, len(types)))
+}
+
+// ==================== Users ====================
+
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	startIndex := getIntQuery(c, "startIndex", 1)
+	count := getIntQuery(c, "count", 100)
+
+	users, total, err := h.svc.WithContext(c).ListUsers(c.Query("filter"), startIndex, count)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	base := scimBaseURL(c)
+	resources := make([]scim.User, 0, len(users))
+	for i := range users {
+		resources = append(resources, scim.FromUser(&users[i], base))
+	}
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	writeSCIM(c, http.StatusOK, scim.NewListResponse(resources, total, startIndex, len(resources)))
+}
+
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, err := h.svc.WithContext(c).GetUser(c.Param("id"))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	writeVersioned(c, http.StatusOK, scim.Version(user.UpdatedAt), scim.FromUser(user, scimBaseURL(c)))
+}
+
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var req scim.User
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "%s", err.Error()))
+		return
+	}
+
+	user, err := h.svc.WithContext(c).CreateUser(&req)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	resource := scim.FromUser(user, scimBaseURL(c))
+	c.Header("Location", resource.Meta.Location)
+	writeVersioned(c, http.StatusCreated, resource.Meta.Version, resource)
+}
+
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	var req scim.User
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "%s", err.Error()))
+		return
+	}
+	if !h.userPrecondition(c) {
+		return
+	}
+
+	user, err := h.svc.WithContext(c).ReplaceUser(c.Param("id"), &req)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	writeVersioned(c, http.StatusOK, scim.Version(user.UpdatedAt), scim.FromUser(user, scimBaseURL(c)))
+}
+
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	var req scim.PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "%s", err.Error()))
+		return
+	}
+	if !h.userPrecondition(c) {
+		return
+	}
+
+	user, err := h.svc.WithContext(c).PatchUser(c.Param("id"), req.Operations)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	writeVersioned(c, http.StatusOK, scim.Version(user.UpdatedAt), scim.FromUser(user, scimBaseURL(c)))
+}
+
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	if !h.userPrecondition(c) {
+		return
+	}
+	if err := h.svc.WithContext(c).DeleteUser(c.Param("id")); err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SCIMHandler) userPrecondition(c *gin.Context) bool {
+	if c.GetHeader("If-Match") == "" {
+		return true
+	}
+	user, err := h.svc.WithContext(c).GetUser(c.Param("id"))
+	if err != nil {
+		writeSCIMError(c, err)
+		return false
+	}
+	return checkPrecondition(c, scim.Version(user.UpdatedAt))
+}
+
+// ==================== Groups ====================
+
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	startIndex := getIntQuery(c, "startIndex", 1)
+	count := getIntQuery(c, "count", 100)
+
+	teams, total, err := h.svc.WithContext(c).ListGroups(c.Query("filter"), startIndex, count)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+
+	base := scimBaseURL(c)
+	excludeMembers := strings.Contains(c.Query("excludedAttributes"), "members")
+	resources := make([]scim.Group, 0, len(teams))
+	for i := range teams {
+		group := scim.FromTeam(&teams[i], base)
+		if excludeMembers {
+			group.Members = nil
+		}
+		resources = append(resources, group)
+	}
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	writeSCIM(c, http.StatusOK, scim.NewListResponse(resources, total, startIndex, len(resources)))
+}
+
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	team, err := h.svc.WithContext(c).GetGroup(c.Param("id"))
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	h.writeGroup(c, http.StatusOK, team)
+}
+
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var req scim.Group
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "%s", err.Error()))
+		return
+	}
+
+	team, err := h.svc.WithContext(c).CreateGroup(&req)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	c.Header("Location", scimBaseURL(c)+"/Groups/"+team.ID)
+	h.writeGroup(c, http.StatusCreated, team)
+}
+
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	var req scim.Group
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "%s", err.Error()))
+		return
+	}
+	if !h.groupPrecondition(c) {
+		return
+	}
+
+	team, err := h.svc.WithContext(c).ReplaceGroup(c.Param("id"), &req)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	h.writeGroup(c, http.StatusOK, team)
+}
+
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	var req scim.PatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, scim.NewError(http.StatusBadRequest, scim.ErrInvalidSyntax, "%s", err.Error()))
+		return
+	}
+	if !h.groupPrecondition(c) {
+		return
+	}
+
+	team, err := h.svc.WithContext(c).PatchGroup(c.Param("id"), req.Operations)
+	if err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	h.writeGroup(c, http.StatusOK, team)
+}
+
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	if !h.groupPrecondition(c) {
+		return
+	}
+	if err := h.svc.WithContext(c).DeleteGroup(c.Param("id")); err != nil {
+		writeSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SCIMHandler) groupPrecondition(c *gin.Context) bool {
+	if c.GetHeader("If-Match") == "" {
+		return true
+	}
+	team, err := h.svc.WithContext(c).GetGroup(c.Param("id"))
+	if err != nil {
+		writeSCIMError(c, err)
+		return false
+	}
+	return checkPrecondition(c, scim.Version(team.UpdatedAt))
+}
+
+func (h *SCIMHandler) writeGroup(c *gin.Context, status int, team *models.Team) {
+	writeVersioned(c, status, scim.Version(team.UpdatedAt), scim.FromTeam(team, scimBaseURL(c)))
+}
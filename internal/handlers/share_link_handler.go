@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/services"
+)
+
+// ShareLinkHandler manages public links to documents and files and serves
+// the anonymous requests made through them
+type ShareLinkHandler struct {
+	svc   services.ShareLinkService
+	docs  services.DocumentService
+	files services.FileService
+}
+
+func NewShareLinkHandler(svc services.ShareLinkService, docs services.DocumentService, files services.FileService) *ShareLinkHandler {
+	return &ShareLinkHandler{svc: svc, docs: docs, files: files}
+}
+
+func shareLinkErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrShareLinkNotFound), errors.Is(err, services.ErrShareLinkInvalid),
+		errors.Is(err, services.ErrSharedContentMissing):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidShareLink), errors.Is(err, services.ErrSharePreviewUnsupported):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrShareLinkPassword), errors.Is(err, services.ErrShareLinkWrongPassword):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrShareLinkViewOnly):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrShareLinkLimit):
+		return http.StatusGone
+	}
+	return http.StatusInternalServerError
+}
+
+// isOwner reports whether the current user owns the document or file in the
+// id parameter; only owners manage its links
+func (h *ShareLinkHandler) isOwner(c *gin.Context, resource models.ShareLinkResource) bool {
+	userID := c.GetString("userID")
+	if resource == models.ShareLinkFile {
+		return h.files.WithContext(c).IsOwner(c.Param("id"), userID)
+	}
+	return h.docs.WithContext(c).IsOwner(c.Param("id"), userID)
+}
+
+func (h *ShareLinkHandler) CreateDocumentLink(c *gin.Context) {
+	h.create(c, models.ShareLinkDocument)
+}
+
+func (h *ShareLinkHandler) CreateFileLink(c *gin.Context) {
+	h.create(c, models.ShareLinkFile)
+}
+
+func (h *ShareLinkHandler) create(c *gin.Context, resource models.ShareLinkResource) {
+	if !h.isOwner(c, resource) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can create share links"})
+		return
+	}
+
+	var req struct {
+		Password     string     `json:"password"`
+		ExpiresAt    *time.Time `json:"expiresAt"`
+		MaxDownloads *int       `json:"maxDownloads"`
+		ViewOnly     bool       `json:"viewOnly"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	link, path, err := h.svc.WithContext(c).Create(resource, c.Param("id"), c.GetString("userID"), services.ShareLinkInput{
+		Password:     req.Password,
+		ExpiresAt:    req.ExpiresAt,
+		MaxDownloads: req.MaxDownloads,
+		ViewOnly:     req.ViewOnly,
+	})
+	if err != nil {
+		c.JSON(shareLinkErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"link": link, "url": path}, "message": "Share link created"})
+}
+
+func (h *ShareLinkHandler) ListDocumentLinks(c *gin.Context) {
+	h.list(c, models.ShareLinkDocument)
+}
+
+func (h *ShareLinkHandler) ListFileLinks(c *gin.Context) {
+	h.list(c, models.ShareLinkFile)
+}
+
+func (h *ShareLinkHandler) list(c *gin.Context, resource models.ShareLinkResource) {
+	if !h.isOwner(c, resource) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can view share links"})
+		return
+	}
+
+	links, err := h.svc.WithContext(c).List(resource, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": links})
+}
+
+func (h *ShareLinkHandler) RevokeDocumentLink(c *gin.Context) {
+	h.revoke(c, models.ShareLinkDocument)
+}
+
+func (h *ShareLinkHandler) RevokeFileLink(c *gin.Context) {
+	h.revoke(c, models.ShareLinkFile)
+}
+
+func (h *ShareLinkHandler) revoke(c *gin.Context, resource models.ShareLinkResource) {
+	if !h.isOwner(c, resource) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can revoke share links"})
+		return
+	}
+
+	if err := h.svc.WithContext(c).Revoke(resource, c.Param("id"), c.Param("linkId")); err != nil {
+		c.JSON(shareLinkErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Share link revoked"})
+}
+
+func (h *ShareLinkHandler) DocumentLinkAccesses(c *gin.Context) {
+	h.accesses(c, models.ShareLinkDocument)
+}
+
+func (h *ShareLinkHandler) FileLinkAccesses(c *gin.Context) {
+	h.accesses(c, models.ShareLinkFile)
+}
+
+func (h *ShareLinkHandler) accesses(c *gin.Context, resource models.ShareLinkResource) {
+	if !h.isOwner(c, resource) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only owner can view share link accesses"})
+		return
+	}
+
+	page, limit := getPagination(c, 20)
+	accesses, total, err := h.svc.WithContext(c).Accesses(resource, c.Param("id"), c.Param("linkId"), page, limit)
+	if err != nil {
+		c.JSON(shareLinkErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    accesses,
+		"meta": gin.H{
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+// open resolves the anonymous request in the token parameter. The password is
+// read from the X-Share-Password header, or the password query parameter for
+// plain browser links.
+func (h *ShareLinkHandler) open(c *gin.Context, action string) (*services.SharedResource, io.ReadCloser, bool) {
+	password := c.GetHeader("X-Share-Password")
+	if password == "" {
+		password = c.Query("password")
+	}
+
+	shared, rc, err := h.svc.WithContext(c).Open(services.ShareLinkRequest{
+		Token:     c.Param("token"),
+		Password:  password,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}, action)
+	c.Header("Cache-Control", "no-store")
+	if err != nil {
+		c.JSON(shareLinkErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return nil, nil, false
+	}
+	return shared, rc, true
+}
+
+// View returns what a share link points to. Documents include their content;
+// files are fetched through Preview or Download.
+func (h *ShareLinkHandler) View(c *gin.Context) {
+	shared, _, ok := h.open(c, services.ShareActionView)
+	if !ok {
+		return
+	}
+
+	link := shared.Link
+	data := gin.H{
+		"resourceType": link.ResourceType,
+		"viewOnly":     link.ViewOnly,
+		"expiresAt":    link.ExpiresAt,
+	}
+	if link.MaxDownloads != nil {
+		data["downloadsRemaining"] = max(*link.MaxDownloads-link.DownloadCount, 0)
+	}
+	if doc := shared.Document; doc != nil {
+		data["document"] = gin.H{
+			"id":        doc.ID,
+			"title":     doc.Title,
+			"content":   doc.Content,
+			"type":      doc.Type,
+			"owner":     doc.Owner.Name,
+			"updatedAt": doc.UpdatedAt,
+		}
+	}
+	if file := shared.File; file != nil {
+		data["file"] = gin.H{
+			"id":        file.ID,
+			"name":      file.Name,
+			"mimeType":  file.MimeType,
+			"size":      file.Size,
+			"owner":     file.Owner.Name,
+			"updatedAt": file.UpdatedAt,
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// Preview streams a shared file for display in the browser. It is allowed on
+// view-only links and does not count as a download.
+func (h *ShareLinkHandler) Preview(c *gin.Context) {
+	shared, rc, ok := h.open(c, services.ShareActionPreview)
+	if !ok {
+		return
+	}
+	defer rc.Close()
+
+	// Shared content is untrusted; a sandbox keeps it from running scripts
+	// on this origin
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Disposition", contentDisposition("inline", shared.File.Name))
+	c.DataFromReader(http.StatusOK, shared.File.Size, shared.File.MimeType, rc, nil)
+}
+
+// Download sends a shared file, or a document's content as Markdown
+func (h *ShareLinkHandler) Download(c *gin.Context) {
+	shared, rc, ok := h.open(c, services.ShareActionDownload)
+	if !ok {
+		return
+	}
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	if doc := shared.Document; doc != nil {
+		name := strings.TrimSpace(doc.Title)
+		if name == "" {
+			name = doc.ID
+		}
+		c.Header("Content-Disposition", contentDisposition("attachment", name+".md"))
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(doc.Content))
+		return
+	}
+	defer rc.Close()
+
+	c.Header("Content-Disposition", contentDisposition("attachment", shared.File.Name))
+	c.DataFromReader(http.StatusOK, shared.File.Size, shared.File.MimeType, rc, nil)
+}
+
+// contentDisposition formats a Content-Disposition header, encoding names
+// that are not plain ASCII
+func contentDisposition(disposition, filename string) string {
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); header != "" {
+		return header
+	}
+	return disposition
+}
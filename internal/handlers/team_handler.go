@@ -1,18 +1,50 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/halolight/halolight-api-go/internal/services"
 )
 
 type TeamHandler struct {
-	svc services.TeamService
+	svc     services.TeamService
+	invites services.TeamInvitationService
+	avatars services.AvatarService
 }
 
-func NewTeamHandler(svc services.TeamService) *TeamHandler {
-	return &TeamHandler{svc: svc}
+func NewTeamHandler(svc services.TeamService, invites services.TeamInvitationService, avatars services.AvatarService) *TeamHandler {
+	return &TeamHandler{svc: svc, invites: invites, avatars: avatars}
+}
+
+func teamErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTeamNotFound), errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrTeamInvitationNotFound), errors.Is(err, services.ErrJoinLinkNotFound),
+		errors.Is(err, services.ErrNotTeamMember):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrAlreadyTeamMember), errors.Is(err, services.ErrTeamInvitationExists),
+		errors.Is(err, services.ErrTeamInvitationClosed), errors.Is(err, services.ErrTeamOwnerLeaves):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidEmail), errors.Is(err, services.ErrUnknownRole),
+		errors.Is(err, services.ErrInvalidJoinLink), errors.Is(err, services.ErrJoinLinkInvalid):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// teamContentErrorStatus maps the errors of team-owned document, file and
+// folder operations
+func teamContentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTeamNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNotTeamMember), errors.Is(err, services.ErrTeamRoleDenied):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
 }
 
 func (h *TeamHandler) List(c *gin.Context) {
@@ -21,7 +53,7 @@ func (h *TeamHandler) List(c *gin.Context) {
 	limit := getIntQuery(c, "limit", 10)
 	search := c.Query("search")
 
-	teams, total, err := h.svc.List(userID, page, limit, search)
+	teams, total, err := h.svc.WithContext(c).List(userID, page, limit, search)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -40,7 +72,7 @@ func (h *TeamHandler) List(c *gin.Context) {
 }
 
 func (h *TeamHandler) Get(c *gin.Context) {
-	team, err := h.svc.Get(c.Param("id"))
+	team, err := h.svc.WithContext(c).Get(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Team not found"})
 		return
@@ -59,7 +91,7 @@ func (h *TeamHandler) Create(c *gin.Context) {
 	}
 
 	userID := c.GetString("userID")
-	team, err := h.svc.Create(req.Name, req.Description, userID)
+	team, err := h.svc.WithContext(c).Create(req.Name, req.Description, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -72,7 +104,7 @@ func (h *TeamHandler) Update(c *gin.Context) {
 	userID := c.GetString("userID")
 	teamID := c.Param("id")
 
-	if !h.svc.IsOwner(teamID, userID) {
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can update"})
 		return
 	}
@@ -86,7 +118,7 @@ func (h *TeamHandler) Update(c *gin.Context) {
 		return
 	}
 
-	team, err := h.svc.Update(teamID, req.Name, req.Description)
+	team, err := h.svc.WithContext(c).Update(teamID, req.Name, req.Description)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
@@ -95,16 +127,41 @@ func (h *TeamHandler) Update(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": team, "message": "Team updated"})
 }
 
+func (h *TeamHandler) UploadAvatar(c *gin.Context) {
+	userID := c.GetString("userID")
+	teamID := c.Param("id")
+
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can update"})
+		return
+	}
+
+	file, status, err := openUpload(c, "file", services.AvatarMaxBytes)
+	if err != nil {
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	avatar, err := h.avatars.WithContext(c).SetTeamAvatar(teamID, file)
+	if err != nil {
+		c.JSON(avatarErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": avatar, "message": "Avatar updated"})
+}
+
 func (h *TeamHandler) Delete(c *gin.Context) {
 	userID := c.GetString("userID")
 	teamID := c.Param("id")
 
-	if !h.svc.IsOwner(teamID, userID) {
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can delete"})
 		return
 	}
 
-	if err := h.svc.Delete(teamID); err != nil {
+	if err := h.svc.WithContext(c).Delete(teamID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
@@ -112,11 +169,12 @@ func (h *TeamHandler) Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Team deleted"})
 }
 
+// AddMember invites an existing user; they join once they accept
 func (h *TeamHandler) AddMember(c *gin.Context) {
 	userID := c.GetString("userID")
 	teamID := c.Param("id")
 
-	if !h.svc.IsOwner(teamID, userID) {
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can add members"})
 		return
 	}
@@ -130,28 +188,314 @@ func (h *TeamHandler) AddMember(c *gin.Context) {
 		return
 	}
 
-	member, err := h.svc.AddMember(teamID, req.UserID, req.RoleID)
+	invitation, err := h.invites.WithContext(c).Invite(teamID, userID, services.TeamInviteInput{UserID: req.UserID, RoleID: req.RoleID})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"success": true, "data": member, "message": "Member added"})
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": invitation, "message": "Invitation sent"})
 }
 
 func (h *TeamHandler) RemoveMember(c *gin.Context) {
 	userID := c.GetString("userID")
 	teamID := c.Param("id")
 
-	if !h.svc.IsOwner(teamID, userID) {
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
 		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can remove members"})
 		return
 	}
 
-	if err := h.svc.RemoveMember(teamID, c.Param("userId")); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+	if err := h.svc.WithContext(c).RemoveMember(teamID, userID, c.Param("userId")); err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Member removed"})
 }
+
+func (h *TeamHandler) UpdateMember(c *gin.Context) {
+	userID := c.GetString("userID")
+	teamID := c.Param("id")
+
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can change member roles"})
+		return
+	}
+
+	var req struct {
+		RoleID *string `json:"roleId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	member, err := h.svc.WithContext(c).UpdateMemberRole(teamID, userID, c.Param("userId"), req.RoleID)
+	if err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": member, "message": "Member updated"})
+}
+
+func (h *TeamHandler) TransferOwnership(c *gin.Context) {
+	userID := c.GetString("userID")
+	teamID := c.Param("id")
+
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can transfer ownership"})
+		return
+	}
+
+	var req struct {
+		UserID string `json:"userId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	team, err := h.svc.WithContext(c).TransferOwnership(teamID, userID, req.UserID)
+	if err != nil {
+		status := teamErrorStatus(err)
+		if errors.Is(err, services.ErrNotTeamMember) {
+			// The new owner in the body is not part of the team
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": team, "message": "Ownership transferred"})
+}
+
+func (h *TeamHandler) Leave(c *gin.Context) {
+	if err := h.svc.WithContext(c).Leave(c.Param("id"), c.GetString("userID")); err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Left team"})
+}
+
+func (h *TeamHandler) Activity(c *gin.Context) {
+	teamID := c.Param("id")
+	if !h.svc.WithContext(c).IsMember(teamID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team members can view team activity"})
+		return
+	}
+
+	page, limit := getPagination(c, 20)
+	activities, total, err := h.svc.WithContext(c).Activity(teamID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    activities,
+		"meta": gin.H{
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+func (h *TeamHandler) Workspace(c *gin.Context) {
+	_, limit := getPagination(c, 20)
+	workspace, err := h.svc.WithContext(c).Workspace(c.Param("id"), c.GetString("userID"), limit)
+	if err != nil {
+		c.JSON(teamContentErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": workspace})
+}
+
+func (h *TeamHandler) Invite(c *gin.Context) {
+	userID := c.GetString("userID")
+	teamID := c.Param("id")
+
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can invite members"})
+		return
+	}
+
+	var req struct {
+		UserID string  `json:"userId"`
+		Email  string  `json:"email" binding:"omitempty,email"`
+		RoleID *string `json:"roleId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if (req.UserID == "") == (req.Email == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "either userId or email is required"})
+		return
+	}
+
+	invitation, err := h.invites.WithContext(c).Invite(teamID, userID, services.TeamInviteInput{
+		UserID: req.UserID,
+		Email:  req.Email,
+		RoleID: req.RoleID,
+	})
+	if err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": invitation, "message": "Invitation sent"})
+}
+
+func (h *TeamHandler) ListInvitations(c *gin.Context) {
+	teamID := c.Param("id")
+	if !h.svc.WithContext(c).IsOwner(teamID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can view invitations"})
+		return
+	}
+
+	page, limit := getPagination(c, 20)
+	invitations, total, err := h.invites.WithContext(c).ListForTeam(teamID, c.Query("status"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    invitations,
+		"meta": gin.H{
+			"total":      total,
+			"page":       page,
+			"limit":      limit,
+			"totalPages": (total + int64(limit) - 1) / int64(limit),
+		},
+	})
+}
+
+func (h *TeamHandler) RevokeInvitation(c *gin.Context) {
+	teamID := c.Param("id")
+	if !h.svc.WithContext(c).IsOwner(teamID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can revoke invitations"})
+		return
+	}
+
+	invitation, err := h.invites.WithContext(c).Revoke(teamID, c.Param("invitationId"))
+	if err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": invitation, "message": "Invitation revoked"})
+}
+
+// MyInvitations lists the pending team invitations of the current user
+func (h *TeamHandler) MyInvitations(c *gin.Context) {
+	invitations, err := h.invites.WithContext(c).ListPending(c.GetString("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": invitations})
+}
+
+func (h *TeamHandler) AcceptInvitation(c *gin.Context) {
+	member, err := h.invites.WithContext(c).Accept(c.Param("invitationId"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": member, "message": "Invitation accepted"})
+}
+
+func (h *TeamHandler) DeclineInvitation(c *gin.Context) {
+	invitation, err := h.invites.WithContext(c).Decline(c.Param("invitationId"), c.GetString("userID"))
+	if err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": invitation, "message": "Invitation declined"})
+}
+
+func (h *TeamHandler) CreateJoinLink(c *gin.Context) {
+	userID := c.GetString("userID")
+	teamID := c.Param("id")
+
+	if !h.svc.WithContext(c).IsOwner(teamID, userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can create join links"})
+		return
+	}
+
+	var req struct {
+		RoleID    *string    `json:"roleId"`
+		ExpiresAt *time.Time `json:"expiresAt"`
+		MaxUses   *int       `json:"maxUses"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	link, url, err := h.invites.WithContext(c).CreateJoinLink(teamID, userID, services.JoinLinkInput{
+		RoleID:    req.RoleID,
+		ExpiresAt: req.ExpiresAt,
+		MaxUses:   req.MaxUses,
+	})
+	if err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"link": link, "url": url}, "message": "Join link created"})
+}
+
+func (h *TeamHandler) ListJoinLinks(c *gin.Context) {
+	teamID := c.Param("id")
+	if !h.svc.WithContext(c).IsOwner(teamID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can view join links"})
+		return
+	}
+
+	links, err := h.invites.WithContext(c).ListJoinLinks(teamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": links})
+}
+
+func (h *TeamHandler) RevokeJoinLink(c *gin.Context) {
+	teamID := c.Param("id")
+	if !h.svc.WithContext(c).IsOwner(teamID, c.GetString("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only team owner can revoke join links"})
+		return
+	}
+
+	if err := h.invites.WithContext(c).RevokeJoinLink(teamID, c.Param("linkId")); err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Join link revoked"})
+}
+
+// Join adds the current user to the team of a join link
+func (h *TeamHandler) Join(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	member, err := h.invites.WithContext(c).Join(req.Token, c.GetString("userID"))
+	if err != nil {
+		c.JSON(teamErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": member, "message": "Joined team"})
+}
@@ -1,35 +1,66 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/repository"
 	"github.com/halolight/halolight-api-go/internal/services"
+	"github.com/halolight/halolight-api-go/pkg/spreadsheet"
 )
 
 type UserHandler struct {
-	users services.UserService
+	users       services.UserService
+	bulk        services.UserBulkService
+	avatars     services.AvatarService
+	purge       services.UserPurgeService
+	status      services.AccountStatusService
+	attributes  services.AttributeService
+	permissions services.PermissionService
 }
 
-func NewUserHandler(users services.UserService) *UserHandler {
-	return &UserHandler{users: users}
+func NewUserHandler(users services.UserService, bulk services.UserBulkService, avatars services.AvatarService, purge services.UserPurgeService, status services.AccountStatusService, attributes services.AttributeService, permissions services.PermissionService) *UserHandler {
+	return &UserHandler{users: users, bulk: bulk, avatars: avatars, purge: purge, status: status, attributes: attributes, permissions: permissions}
 }
 
+// maxImportFileSize caps the size of an uploaded import sheet
+const maxImportFileSize = 10 << 20
+
 type createUserRequest struct {
-	Email    string `json:"email" binding:"required,email"`
-	Username string `json:"username" binding:"required,min=3,max=64"`
-	Password string `json:"password" binding:"required,min=6"`
+	Email      string                 `json:"email" binding:"required,email"`
+	Username   string                 `json:"username" binding:"required,min=3,max=64"`
+	Password   string                 `json:"password" binding:"required,min=6"`
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
 type updateUserRequest struct {
-	Email    string `json:"email" binding:"omitempty,email"`
-	Username string `json:"username" binding:"omitempty,min=3,max=64"`
-	Password string `json:"password" binding:"omitempty,min=6"`
+	Email      *string `json:"email" binding:"omitempty,email"`
+	Username   *string `json:"username" binding:"omitempty,min=3,max=64"`
+	Password   *string `json:"password" binding:"omitempty,min=6"`
+	Name       *string `json:"name" binding:"omitempty,max=191"`
+	Phone      *string `json:"phone" binding:"omitempty,max=50"`
+	Department *string `json:"department" binding:"omitempty,max=191"`
+	Position   *string `json:"position" binding:"omitempty,max=191"`
+	Bio        *string `json:"bio"`
+	// Custom attribute values by key; null or "" removes a value
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+type updateStatusRequest struct {
+	Status         string     `json:"status" binding:"required"`
+	Reason         string     `json:"reason" binding:"max=500"`
+	SuspendedUntil *time.Time `json:"suspendedUntil"`
 }
 
 type listResponse struct {
+	Success    bool        `json:"success"`
 	Data       interface{} `json:"data"`
 	Total      int64       `json:"total"`
 	Page       int         `json:"page"`
@@ -39,32 +70,69 @@ type listResponse struct {
 
 // List godoc
 // @Summary List users
-// @Description Get paginated list of users
+// @Description Get paginated list of users with search, filters and sorting
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param page query int false "Page number" default(1)
 // @Param page_size query int false "Page size" default(20)
+// @Param search query string false "Search name, email or username"
+// @Param status query string false "ACTIVE, INACTIVE or SUSPENDED"
+// @Param department query string false "Department"
+// @Param department_id query string false "Department ID"
+// @Param manager_id query string false "Manager ID (direct reports)"
+// @Param role query string false "Role ID or name"
+// @Param team query string false "Team ID"
+// @Param created_from query string false "Created at or after (RFC 3339 or YYYY-MM-DD)"
+// @Param created_to query string false "Created at or before (RFC 3339 or YYYY-MM-DD)"
+// @Param attr.{key} query string false "Custom attribute value, e.g. attr.costCenter=4711"
+// @Param sort_by query string false "createdAt, updatedAt, lastLoginAt, name, email, username, status or department" default(createdAt)
+// @Param sort_order query string false "asc or desc" default(desc)
 // @Success 200 {object} listResponse
+// @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /api/users [get]
 func (h *UserHandler) List(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
 
-	users, total, err := h.users.List(page, pageSize)
+	filter, err := parseUserFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list users"})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
+	users, total, err := h.users.WithContext(c).List(filter, page, pageSize, h.canManageAttributes(c))
+	if err != nil {
+		if errors.Is(err, services.ErrAttributeDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidStatus) || errors.Is(err, services.ErrInvalidUserSort) ||
+			errors.Is(err, services.ErrInvalidAttribute) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to list users"})
+		return
+	}
+
+	h.redactAttributes(c, users)
+
 	totalPages := int(total) / pageSize
 	if int(total)%pageSize != 0 {
 		totalPages++
 	}
 
 	c.JSON(http.StatusOK, listResponse{
+		Success:    true,
 		Data:       users,
 		Total:      total,
 		Page:       page,
@@ -73,41 +141,201 @@ func (h *UserHandler) List(c *gin.Context) {
 	})
 }
 
+// deletedUser exposes the deletion time, which models.User hides
+type deletedUser struct {
+	models.User
+	DeletedAt time.Time  `json:"deletedAt"`
+	PurgeAt   *time.Time `json:"purgeAt,omitempty"`
+}
+
+// ListDeleted godoc
+// @Summary List deleted users
+// @Description List soft-deleted users that can still be restored, with the time they will be purged
+// @Tags users
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param search query string false "Search name, email or username"
+// @Param sort_by query string false "createdAt, updatedAt, lastLoginAt, name, email, username, status or department (default: deletion time)"
+// @Param sort_order query string false "asc or desc" default(desc)
+// @Success 200 {object} listResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/deleted [get]
+func (h *UserHandler) ListDeleted(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	users, total, err := h.users.WithContext(c).ListDeleted(filter, page, pageSize, h.canManageAttributes(c))
+	if err != nil {
+		if errors.Is(err, services.ErrAttributeDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidStatus) || errors.Is(err, services.ErrInvalidUserSort) ||
+			errors.Is(err, services.ErrInvalidAttribute) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to list deleted users"})
+		return
+	}
+	h.redactAttributes(c, users)
+
+	data := make([]deletedUser, 0, len(users))
+	for _, u := range users {
+		deletedAt := u.DeletedAt.Time
+		data = append(data, deletedUser{User: u, DeletedAt: deletedAt, PurgeAt: h.purge.PurgeAt(deletedAt)})
+	}
+
+	totalPages := int(total) / pageSize
+	if int(total)%pageSize != 0 {
+		totalPages++
+	}
+
+	c.JSON(http.StatusOK, listResponse{
+		Success:    true,
+		Data:       data,
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: totalPages,
+	})
+}
+
+// Restore godoc
+// @Summary Restore a deleted user
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/restore [post]
+func (h *UserHandler) Restore(c *gin.Context) {
+	user, err := h.users.WithContext(c).Restore(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "deleted user not found"})
+			return
+		}
+		if errors.Is(err, services.ErrIdentityTaken) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": user, "message": "User restored"})
+}
+
+// parseUserFilter reads the user list filters from the query string
+func parseUserFilter(c *gin.Context) (repository.UserFilter, error) {
+	filter := repository.UserFilter{
+		Search:       c.Query("search"),
+		Status:       models.UserStatus(c.Query("status")),
+		Department:   c.Query("department"),
+		DepartmentID: c.Query("department_id"),
+		ManagerID:    c.Query("manager_id"),
+		Role:         c.Query("role"),
+		TeamID:       c.Query("team"),
+		SortBy:       c.Query("sort_by"),
+		SortOrder:    c.DefaultQuery("sort_order", "desc"),
+	}
+	if filter.SortOrder != "asc" && filter.SortOrder != "desc" {
+		return filter, errors.New("sort_order must be asc or desc")
+	}
+
+	var err error
+	if filter.CreatedFrom, err = getTimeQuery(c, "created_from"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedTo, err = getTimeQuery(c, "created_to"); err != nil {
+		return filter, err
+	}
+	// A bare date as upper bound covers the whole day
+	if filter.CreatedTo != nil && len(c.Query("created_to")) == len("2006-01-02") {
+		end := filter.CreatedTo.Add(24*time.Hour - time.Nanosecond)
+		filter.CreatedTo = &end
+	}
+
+	// attr.<key>=<value> matches custom attributes; values are typed by the service
+	for param, values := range c.Request.URL.Query() {
+		key, ok := strings.CutPrefix(param, "attr.")
+		if !ok || key == "" || len(values) == 0 {
+			continue
+		}
+		if filter.Attributes == nil {
+			filter.Attributes = map[string]interface{}{}
+		}
+		filter.Attributes[key] = values[0]
+	}
+	return filter, nil
+}
+
+// canManageAttributes reports whether the current user may read, filter on
+// and write private and admin attributes
+func (h *UserHandler) canManageAttributes(c *gin.Context) bool {
+	return h.permissions.HasPermission(c.GetString("userID"), services.UserAttributesManage)
+}
+
+// redactAttributes hides the custom attributes the current user may not read
+func (h *UserHandler) redactAttributes(c *gin.Context, users []models.User) {
+	h.attributes.WithContext(c).Redact(users, c.GetString("userID"), h.canManageAttributes(c))
+}
+
+// redactUser is redactAttributes for a single user
+func (h *UserHandler) redactUser(c *gin.Context, user *models.User) {
+	users := []models.User{*user}
+	h.redactAttributes(c, users)
+	*user = users[0]
+}
+
 // Get godoc
 // @Summary Get user by ID
 // @Description Get a single user by ID
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param id path int true "User ID"
+// @Param id path string true "User ID"
 // @Success 200 {object} map[string]interface{}
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /api/users/{id} [get]
 func (h *UserHandler) Get(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
-		return
-	}
-
-	user, err := h.users.Get(uint(id))
+	user, err := h.users.WithContext(c).Get(c.Param("id"))
 	if err != nil {
 		if errors.Is(err, services.ErrUserNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "user not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to get user"})
 		return
 	}
+	h.redactUser(c, user)
 
-	c.JSON(http.StatusOK, gin.H{"data": user})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": user})
 }
 
 // Create godoc
 // @Summary Create a new user
-// @Description Create a new user (admin only)
+// @Description Create a new user (admin only). Required custom attributes must be provided.
 // @Tags users
 // @Accept json
 // @Produce json
@@ -120,57 +348,71 @@ func (h *UserHandler) Get(c *gin.Context) {
 func (h *UserHandler) Create(c *gin.Context) {
 	var req createUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
-	user, err := h.users.Create(req.Email, req.Username, req.Password)
+	user, err := h.users.WithContext(c).Create(req.Email, req.Username, req.Password, req.Attributes, h.canManageAttributes(c))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrAttributeDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
+	h.redactUser(c, user)
 
-	c.JSON(http.StatusCreated, gin.H{"data": user})
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": user})
 }
 
 // Update godoc
 // @Summary Update user
-// @Description Update user information
+// @Description Update account and profile fields (email, username, password, name, phone, department, position, bio) and custom attributes
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param id path int true "User ID"
+// @Param id path string true "User ID"
 // @Param request body updateUserRequest true "User details to update"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
-// @Router /api/users/{id} [put]
+// @Router /api/users/{id} [patch]
 func (h *UserHandler) Update(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
-		return
-	}
-
 	var req updateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
-	user, err := h.users.Update(uint(id), req.Email, req.Username, req.Password)
+	user, err := h.users.WithContext(c).Update(c.Param("id"), services.UserUpdate{
+		Email:      req.Email,
+		Username:   req.Username,
+		Password:   req.Password,
+		Name:       req.Name,
+		Phone:      req.Phone,
+		Department: req.Department,
+		Position:   req.Position,
+		Bio:        req.Bio,
+		Attributes: req.Attributes,
+	}, h.canManageAttributes(c))
 	if err != nil {
 		if errors.Is(err, services.ErrUserNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "user not found"})
 			return
 		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if errors.Is(err, services.ErrAttributeDenied) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
+	h.redactUser(c, user)
 
-	c.JSON(http.StatusOK, gin.H{"data": user})
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": user})
 }
 
 // Delete godoc
@@ -179,25 +421,19 @@ func (h *UserHandler) Update(c *gin.Context) {
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param id path int true "User ID"
+// @Param id path string true "User ID"
 // @Success 204
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /api/users/{id} [delete]
 func (h *UserHandler) Delete(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
-		return
-	}
-
-	if err := h.users.Delete(uint(id)); err != nil {
+	if err := h.users.WithContext(c).Delete(c.Param("id")); err != nil {
 		if errors.Is(err, services.ErrUserNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "user not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to delete user"})
 		return
 	}
 
@@ -206,7 +442,7 @@ func (h *UserHandler) Delete(c *gin.Context) {
 
 // UpdateStatus godoc
 // @Summary Update user status
-// @Description Update user status (ACTIVE/INACTIVE/SUSPENDED)
+// @Description Update user status (ACTIVE/INACTIVE/SUSPENDED). A suspension may carry a reason and an end date after which the account becomes ACTIVE again. Changes are recorded in the activity log.
 // @Tags users
 // @Accept json
 // @Produce json
@@ -214,26 +450,74 @@ func (h *UserHandler) Delete(c *gin.Context) {
 // @Param request body updateStatusRequest true "Status"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
 // @Security BearerAuth
 // @Router /api/users/{id}/status [patch]
 func (h *UserHandler) UpdateStatus(c *gin.Context) {
-	var req struct {
-		Status string `json:"status" binding:"required"`
-	}
+	var req updateStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
-	user, err := h.users.UpdateStatus(c.Param("id"), req.Status)
+	user, err := h.status.WithContext(c).ChangeStatus(c.GetString("userID"), c.Param("id"), services.StatusChange{
+		Status: models.UserStatus(req.Status),
+		Reason: req.Reason,
+		Until:  req.SuspendedUntil,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		if errors.Is(err, services.ErrInvalidStatus) || errors.Is(err, services.ErrInvalidSuspension) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrSelfStatusChange) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": user, "message": "Status updated"})
 }
 
+// UploadAvatar godoc
+// @Summary Upload user avatar
+// @Description Upload a JPEG, PNG, GIF or WebP image (max 5MB). Metadata is stripped and square thumbnails are generated.
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "User ID"
+// @Param file formData file true "Avatar image"
+// @Success 200 {object} services.Avatar
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/{id}/avatar [put]
+func (h *UserHandler) UploadAvatar(c *gin.Context) {
+	file, status, err := openUpload(c, "file", services.AvatarMaxBytes)
+	if err != nil {
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	avatar, err := h.avatars.WithContext(c).SetUserAvatar(c.Param("id"), file)
+	if err != nil {
+		c.JSON(avatarErrorStatus(err), gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": avatar, "message": "Avatar updated"})
+}
+
 // BatchDelete godoc
 // @Summary Batch delete users
 // @Description Delete multiple users by IDs
@@ -250,14 +534,145 @@ func (h *UserHandler) BatchDelete(c *gin.Context) {
 		IDs []string `json:"ids" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
-	if err := h.users.BatchDelete(req.IDs); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+	if err := h.users.WithContext(c).BatchDelete(req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Users deleted"})
 }
+
+// Import godoc
+// @Summary Import users
+// @Description Bulk create users from a CSV or XLSX sheet. Columns are matched by header name (email, username, password, name, phone, department, position, bio, status, roles, teams, attr.<key> for custom attributes) or through an explicit mapping. Roles and teams accept IDs or names separated by ";". With dryRun only the validation report is returned.
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file"
+// @Param mapping formData string false "JSON object mapping source headers to fields, e.g. {\"E-Mail\":\"email\"}"
+// @Param dryRun formData bool false "Validate without creating users"
+// @Success 200 {object} services.UserImportReport
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/import [post]
+func (h *UserHandler) Import(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "file is required"})
+		return
+	}
+	if fileHeader.Size > maxImportFileSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false, "error": "file exceeds 10MB"})
+		return
+	}
+
+	format, err := spreadsheet.FormatFromFilename(fileHeader.Filename)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	opts := services.UserImportOptions{Admin: h.canManageAttributes(c)}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.Mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "mapping must be a JSON object of header to field"})
+			return
+		}
+	}
+	opts.DryRun, _ = strconv.ParseBool(c.DefaultPostForm("dryRun", "false"))
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	rows, err := spreadsheet.Read(file, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "failed to parse file: " + err.Error()})
+		return
+	}
+
+	report, err := h.bulk.WithContext(c).Import(rows, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrImportEmpty) || errors.Is(err, services.ErrImportTooLarge) ||
+			errors.Is(err, services.ErrImportMissingColumn) || errors.Is(err, services.ErrInvalidImportMap) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	message := "Users imported"
+	if report.DryRun {
+		message = "Import validated"
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report, "message": message})
+}
+
+// Export godoc
+// @Summary Export users
+// @Description Download users matching the list filters as CSV or XLSX
+// @Tags users
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Param columns query string false "Comma separated columns: id, email, username, name, phone, status, department, position, bio, roles, teams, createdAt, lastLoginAt, attr.<key>; defaults include every custom attribute"
+// @Param search query string false "Search name, email or username"
+// @Param status query string false "ACTIVE, INACTIVE or SUSPENDED"
+// @Param role query string false "Role ID or name"
+// @Param team query string false "Team ID"
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/users/export [get]
+func (h *UserHandler) Export(c *gin.Context) {
+	format, err := spreadsheet.ParseFormat(c.DefaultQuery("format", "csv"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var columns []string
+	if raw := c.Query("columns"); raw != "" {
+		for _, col := range strings.Split(raw, ",") {
+			if col = strings.TrimSpace(col); col != "" {
+				columns = append(columns, col)
+			}
+		}
+	}
+
+	rows, err := h.bulk.WithContext(c).Export(filter, columns)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidExportColumn) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := spreadsheet.Write(&buf, format, rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	filename := "users-" + time.Now().Format("20060102") + "." + string(format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
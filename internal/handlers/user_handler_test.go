@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/middleware"
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/repository"
+	"github.com/halolight/halolight-api-go/internal/services"
+	"github.com/halolight/halolight-api-go/internal/testdb"
+	"gorm.io/gorm"
+)
+
+// userAttributeFixture has a regular user, an attribute administrator and a
+// public and an admin-only attribute
+type userAttributeFixture struct {
+	db          *gorm.DB
+	router      *gin.Engine
+	user, admin *models.User
+}
+
+func newUserAttributeFixture(t *testing.T) *userAttributeFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db := testdb.Open(t,
+		&models.User{},
+		&models.UserAttribute{},
+		&models.Role{},
+		&models.Permission{},
+		&models.RolePermission{},
+		&models.UserRole{},
+	)
+
+	attributes := services.NewAttributeService(db)
+	for _, a := range []models.UserAttribute{
+		{Key: "nickname", Label: "Nickname", Type: models.AttributeString, Visibility: models.AttributePublic},
+		{Key: "clearance", Label: "Clearance", Type: models.AttributeString, Visibility: models.AttributeAdmin},
+	} {
+		if err := db.Create(&a).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users := services.NewUserService(repository.NewUserRepository(db), attributes)
+	user, err := users.Create("user@example.test", "user", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := users.Create("admin@example.test", "admin", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	role := models.Role{Name: "attribute-admin", Label: "Attribute admin"}
+	permission := models.Permission{Action: services.UserAttributesManage, Resource: "users"}
+	for _, row := range []interface{}{&role, &permission} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, row := range []interface{}{
+		&models.RolePermission{RoleID: role.ID, PermissionID: permission.ID},
+		&models.UserRole{UserID: admin.ID, RoleID: role.ID},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	permissions := services.NewPermissionService(db)
+	h := NewUserHandler(users, nil, nil, nil, nil, attributes, permissions)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", c.GetHeader("X-Test-User"))
+	})
+	r.GET("/users", h.List)
+	r.PATCH("/users/:id", middleware.RequireSelfOrPermission(permissions, "id", "users:update"), h.Update)
+	return &userAttributeFixture{db: db, router: r, user: user, admin: admin}
+}
+
+func (f *userAttributeFixture) do(actor *models.User, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", actor.ID)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+// attributes returns the stored attribute values of userID
+func (f *userAttributeFixture) attributes(t *testing.T, userID string) map[string]interface{} {
+	t.Helper()
+	var u models.User
+	if err := f.db.First(&u, "id = ?", userID).Error; err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]interface{}{}
+	if len(u.Attributes) > 0 {
+		if err := json.Unmarshal(u.Attributes, &values); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return values
+}
+
+func TestUpdateSelfRejectsAdminAttribute(t *testing.T) {
+	f := newUserAttributeFixture(t)
+
+	w := f.do(f.user, http.MethodPatch, "/users/"+f.user.ID, `{"attributes": {"clearance": "top-secret"}}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("self-update of an admin attribute: status %d, body %s", w.Code, w.Body)
+	}
+	if _, ok := f.attributes(t, f.user.ID)["clearance"]; ok {
+		t.Fatal("the admin attribute was written")
+	}
+
+	w = f.do(f.user, http.MethodPatch, "/users/"+f.user.ID, `{"attributes": {"nickname": "Al"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("self-update of a public attribute: status %d, body %s", w.Code, w.Body)
+	}
+	if got := f.attributes(t, f.user.ID)["nickname"]; got != "Al" {
+		t.Fatalf("nickname = %v, want Al", got)
+	}
+}
+
+func TestUpdateAdminAttributeAsAttributeAdmin(t *testing.T) {
+	f := newUserAttributeFixture(t)
+
+	w := f.do(f.admin, http.MethodPatch, "/users/"+f.admin.ID, `{"attributes": {"clearance": "top-secret"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	if got := f.attributes(t, f.admin.ID)["clearance"]; got != "top-secret" {
+		t.Fatalf("clearance = %v, want top-secret", got)
+	}
+}
+
+func TestListRejectsFilterOnAdminAttribute(t *testing.T) {
+	f := newUserAttributeFixture(t)
+
+	w := f.do(f.user, http.MethodGet, "/users?attr.clearance=top-secret", "")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("filter on an admin attribute: status %d, body %s", w.Code, w.Body)
+	}
+}
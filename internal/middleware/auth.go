@@ -5,15 +5,28 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/tenant"
 	"github.com/halolight/halolight-api-go/pkg/config"
 	"github.com/halolight/halolight-api-go/pkg/utils"
 )
 
-// AuthMiddleware validates JWT token from Authorization header
-func AuthMiddleware(cfg config.Config) gin.HandlerFunc {
+// AccountChecker reports why an authenticated user may no longer use the API,
+// e.g. because the account was suspended after the token was issued
+type AccountChecker interface {
+	CheckAccount(userID string) error
+}
+
+// AuthMiddleware validates JWT token from Authorization header and rejects
+// tokens of accounts that are not active. Browsers cannot set headers on
+// WebSocket handshakes, so those may pass the token in the token query
+// parameter instead.
+func AuthMiddleware(cfg config.Config, accounts AccountChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
 		auth := c.GetHeader("Authorization")
+		if auth == "" && isWebSocketUpgrade(c) && c.Query("token") != "" {
+			auth = "Bearer " + c.Query("token")
+		}
 		if auth == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 				"error": "authorization header required",
@@ -39,8 +52,21 @@ func AuthMiddleware(cfg config.Config) gin.HandlerFunc {
 			return
 		}
 
-		// Set user ID in context for downstream handlers
+		if err := accounts.CheckAccount(claims.UserID); err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		// Set user and organization in context for downstream handlers;
+		// tokens issued before multi-tenancy belong to the default organization
+		tenantID := claims.TenantID
+		if tenantID == "" {
+			tenantID = tenant.DefaultID
+		}
 		c.Set("userID", claims.UserID)
+		c.Set(tenant.ContextKey, tenantID)
 		c.Next()
 	}
 }
@@ -54,3 +80,8 @@ func GetUserID(c *gin.Context) (uint, bool) {
 	id, ok := userID.(uint)
 	return id, ok
 }
+
+// isWebSocketUpgrade reports whether the request opens a WebSocket connection
+func isWebSocketUpgrade(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+}
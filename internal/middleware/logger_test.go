@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactQuery(t *testing.T) {
+	for path, want := range map[string]string{
+		"/api/documents/1/collab":                   "/api/documents/1/collab",
+		"/api/documents/1/collab?token=eyJ.abc.def": "/api/documents/1/collab?token=REDACTED",
+		"/s/abc?download=1&password=hunter2&x=%74":  "/s/abc?download=1&password=REDACTED&x=%74",
+		"/api/x?%74oken=secret&tokens=kept":         "/api/x?%74oken=REDACTED&tokens=kept",
+		"/api/x?token":                              "/api/x?token=REDACTED",
+	} {
+		if got := redactQuery(path); got != want {
+			t.Errorf("redactQuery(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestLoggerMiddlewareOmitsToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var out bytes.Buffer
+	defer func(w io.Writer) { gin.DefaultWriter = w }(gin.DefaultWriter)
+	gin.DefaultWriter = &out
+
+	r := gin.New()
+	r.Use(LoggerMiddleware())
+	r.GET("/ws", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ws?token=eyJ.secret", nil))
+
+	if strings.Contains(out.String(), "eyJ.secret") || !strings.Contains(out.String(), "/ws?token=REDACTED") {
+		t.Fatalf("log line: %q", out.String())
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+)
+
+// PermissionChecker resolves whether a user holds a permission action
+type PermissionChecker interface {
+	HasPermission(userID, action string) bool
+}
+
+// RequirePermission rejects the request unless the authenticated user holds action.
+// It must run after AuthMiddleware.
+func RequirePermission(checker PermissionChecker, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" || !checker.HasPermission(userID, action) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "permission denied: " + action,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfOrPermission lets users act on their own resource, identified by
+// the path parameter param, and otherwise requires action.
+func RequireSelfOrPermission(checker PermissionChecker, param, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID != "" && userID == c.Param(param) {
+			c.Next()
+			return
+		}
+		RequirePermission(checker, action)(c)
+	}
+}
+
+// RequirePlatformAdmin limits a route to members of the default organization,
+// which operates the platform, who hold action. Administrators of other
+// organizations are rejected even with the "*" permission.
+func RequirePlatformAdmin(checker PermissionChecker, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(tenant.ContextKey) != tenant.DefaultID {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "permission denied: platform administrators only",
+			})
+			return
+		}
+		RequirePermission(checker, action)(c)
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/halolight/halolight-api-go/internal/scim"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+)
+
+// SCIMTokenResolver finds the organization a SCIM bearer token provisions
+type SCIMTokenResolver interface {
+	SCIMTenantID(token string) (string, error)
+}
+
+// SCIMAuthMiddleware authenticates identity provider requests with their
+// organization's SCIM bearer token and scopes them to that organization.
+// Organizations without a token reject every request.
+func SCIMAuthMiddleware(organizations SCIMTokenResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tenantID string
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			tenantID, _ = organizations.SCIMTenantID(parts[1])
+		}
+		if tenantID == "" {
+			c.Header("Content-Type", scim.ContentType)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, scim.NewError(http.StatusUnauthorized, "", "invalid SCIM bearer token"))
+			return
+		}
+		c.Set(tenant.ContextKey, tenantID)
+		c.Next()
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 type ActivityLog struct {
 	ID         string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID   string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
 	ActorID    string         `gorm:"index;type:char(26);not null" json:"actorId"`
 	Action     string         `gorm:"size:100;not null" json:"action"`
 	TargetType string         `gorm:"size:50;not null" json:"targetType"`
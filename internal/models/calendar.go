@@ -16,6 +16,7 @@ const (
 
 type CalendarEvent struct {
 	ID          string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID    string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
 	Title       string         `gorm:"size:255;not null" json:"title"`
 	Description *string        `gorm:"type:text" json:"description,omitempty"`
 	StartAt     time.Time      `gorm:"index;not null" json:"startAt"`
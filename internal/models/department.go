@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Department is a node of the department hierarchy. Users reference it
+// through User.DepartmentID.
+type Department struct {
+	ID          string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID    string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	Name        string         `gorm:"size:191;not null" json:"name"`
+	Description *string        `gorm:"type:text" json:"description,omitempty"`
+	ParentID    *string        `gorm:"index;type:char(26)" json:"parentId,omitempty"`
+	HeadID      *string        `gorm:"index;type:char(26)" json:"headId,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relations
+	Head *User `gorm:"foreignKey:HeadID;constraint:OnDelete:SET NULL" json:"head,omitempty"`
+
+	// Filled when departments are returned as a tree
+	MemberCount int64         `gorm:"-" json:"memberCount"`
+	Children    []*Department `gorm:"-" json:"children,omitempty"`
+}
+
+func (Department) TableName() string {
+	return "departments"
+}
+
+func (d *Department) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = GenerateULID()
+	}
+	return nil
+}
@@ -14,8 +14,16 @@ const (
 	SharePermissionComment SharePermission = "COMMENT"
 )
 
+// Document types with a known content format. Content of any other type is
+// treated as Markdown.
+const (
+	DocumentTypeMarkdown = "markdown"
+	DocumentTypeHTML     = "html"
+)
+
 type Document struct {
 	ID        string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID  string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
 	Title     string         `gorm:"size:255;not null" json:"title"`
 	Content   string         `gorm:"type:text" json:"content"`
 	Folder    *string        `gorm:"index;size:255" json:"folder,omitempty"`
@@ -74,7 +82,8 @@ func (ds *DocumentShare) BeforeCreate(tx *gorm.DB) error {
 
 type Tag struct {
 	ID        string         `gorm:"primaryKey;type:char(26)" json:"id"`
-	Name      string         `gorm:"uniqueIndex;size:100;not null" json:"name"`
+	TenantID  string         `gorm:"uniqueIndex:idx_tags_tenant_name,priority:1;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	Name      string         `gorm:"uniqueIndex:idx_tags_tenant_name,priority:2;size:100;not null" json:"name"`
 	CreatedAt time.Time      `json:"createdAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DocumentComment is a comment on a document. Comments without a parent open
+// a thread, which may be anchored to a range of the content and resolved;
+// replies belong to a thread and carry neither.
+type DocumentComment struct {
+	ID           string     `gorm:"primaryKey;type:char(26)" json:"id"`
+	DocumentID   string     `gorm:"index;type:char(26);not null" json:"documentId"`
+	ParentID     *string    `gorm:"index;type:char(26)" json:"parentId,omitempty"`
+	AuthorID     *string    `gorm:"index;type:char(26)" json:"authorId,omitempty"` // cleared when the author is purged
+	Content      string     `gorm:"type:text;not null" json:"content"`
+	AnchorStart  *int       `json:"anchorStart,omitempty"` // commented range in UTF-16 code units, as the content was then
+	AnchorEnd    *int       `json:"anchorEnd,omitempty"`
+	Quote        string     `gorm:"type:text" json:"quote,omitempty"` // the commented text, to find the range again after edits
+	ResolvedAt   *time.Time `json:"resolvedAt,omitempty"`
+	ResolvedByID *string    `gorm:"type:char(26)" json:"resolvedById,omitempty"`
+	EditedAt     *time.Time `json:"editedAt,omitempty"`
+	CreatedAt    time.Time  `gorm:"index" json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+
+	// Relations
+	Document   Document          `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Author     *User             `gorm:"foreignKey:AuthorID;constraint:OnDelete:SET NULL" json:"author,omitempty"`
+	ResolvedBy *User             `gorm:"foreignKey:ResolvedByID;constraint:OnDelete:SET NULL" json:"resolvedBy,omitempty"`
+	Replies    []DocumentComment `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE" json:"replies,omitempty"`
+}
+
+func (DocumentComment) TableName() string {
+	return "document_comments"
+}
+
+func (c *DocumentComment) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = GenerateULID()
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DocumentRevision is a snapshot of a document's title and content, taken
+// every time either changes. Numbers count up per document.
+type DocumentRevision struct {
+	ID             string    `gorm:"primaryKey;type:char(26)" json:"id"`
+	DocumentID     string    `gorm:"uniqueIndex:idx_document_revisions_number,priority:1;type:char(26);not null" json:"documentId"`
+	Number         int       `gorm:"uniqueIndex:idx_document_revisions_number,priority:2;not null" json:"number"`
+	Title          string    `gorm:"size:255;not null" json:"title"`
+	Content        string    `gorm:"type:text" json:"content,omitempty"`
+	Size           int64     `gorm:"not null;default:0" json:"size"`
+	AuthorID       *string   `gorm:"index;type:char(26)" json:"authorId,omitempty"` // cleared when the author is purged
+	RestoredFromID *string   `gorm:"type:char(26)" json:"restoredFromId,omitempty"` // revision this one was restored from
+	CreatedAt      time.Time `gorm:"index" json:"createdAt"`
+
+	// Relations
+	Document Document `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Author   *User    `gorm:"foreignKey:AuthorID;constraint:OnDelete:SET NULL" json:"author,omitempty"`
+}
+
+func (DocumentRevision) TableName() string {
+	return "document_revisions"
+}
+
+func (r *DocumentRevision) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = GenerateULID()
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Template scopes: a personal template is used by its creator, a team template
+// by the team's members and a global template by the whole organization
+const (
+	TemplateScopePersonal = "personal"
+	TemplateScopeTeam     = "team"
+	TemplateScopeGlobal   = "global"
+)
+
+// DocumentTemplate is a starting point for new documents. Its title and
+// content may hold placeholders such as {{date}} and {{user.name}}, which are
+// filled in when a document is created from it.
+type DocumentTemplate struct {
+	ID          string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID    string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	Name        string         `gorm:"size:100;not null" json:"name"`
+	Description string         `gorm:"size:500" json:"description,omitempty"`
+	Scope       string         `gorm:"index;type:varchar(20);not null" json:"scope"`
+	TeamID      *string        `gorm:"index;type:char(26)" json:"teamId,omitempty"`    // set for team templates only
+	CreatorID   *string        `gorm:"index;type:char(26)" json:"creatorId,omitempty"` // cleared when the creator is purged
+	Title       string         `gorm:"size:255" json:"title"`                          // title of created documents; the name when empty
+	Content     string         `gorm:"type:text" json:"content"`
+	Type        string         `gorm:"size:50;not null" json:"type"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Relations
+	Team    *Team `gorm:"constraint:OnDelete:CASCADE" json:"team,omitempty"`
+	Creator *User `gorm:"foreignKey:CreatorID;constraint:OnDelete:SET NULL" json:"creator,omitempty"`
+}
+
+func (DocumentTemplate) TableName() string {
+	return "document_templates"
+}
+
+func (t *DocumentTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = GenerateULID()
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 type File struct {
 	ID         string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID   string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
 	Name       string         `gorm:"size:255;not null" json:"name"`
 	Path       string         `gorm:"size:500;not null" json:"path"`
 	MimeType   string         `gorm:"size:100;not null" json:"mimeType"`
@@ -40,8 +41,9 @@ func (f *File) BeforeCreate(tx *gorm.DB) error {
 
 type Folder struct {
 	ID        string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID  string         `gorm:"uniqueIndex:idx_folders_tenant_path,priority:1;type:char(26);not null;default:00000000000000000000000000" json:"-"`
 	Name      string         `gorm:"size:191;not null" json:"name"`
-	Path      string         `gorm:"uniqueIndex;size:500;not null" json:"path"`
+	Path      string         `gorm:"uniqueIndex:idx_folders_tenant_path,priority:2;size:500;not null" json:"path"`
 	ParentID  *string        `gorm:"type:char(26);index" json:"parentId,omitempty"`
 	OwnerID   string         `gorm:"index;type:char(26);not null" json:"ownerId"`
 	TeamID    *string        `gorm:"type:char(26)" json:"teamId,omitempty"`
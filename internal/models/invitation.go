@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type InvitationStatus string
+
+const (
+	InvitationPending  InvitationStatus = "PENDING"
+	InvitationAccepted InvitationStatus = "ACCEPTED"
+	InvitationRevoked  InvitationStatus = "REVOKED"
+	InvitationDeclined InvitationStatus = "DECLINED" // team invitations only
+)
+
+// Invitation lets an admin invite someone by email with roles and teams
+// assigned up front. Only the SHA-256 of the token is stored.
+type Invitation struct {
+	ID             string                      `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID       string                      `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	Email          string                      `gorm:"index;size:191;not null" json:"email"`
+	TokenHash      string                      `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	InviterID      string                      `gorm:"index;type:char(26);not null" json:"inviterId"`
+	RoleIDs        datatypes.JSONSlice[string] `json:"roleIds"`
+	TeamIDs        datatypes.JSONSlice[string] `json:"teamIds"`
+	Status         InvitationStatus            `gorm:"type:varchar(20);not null;default:PENDING" json:"status"`
+	ExpiresAt      time.Time                   `gorm:"index;not null" json:"expiresAt"`
+	SentAt         time.Time                   `json:"sentAt"`
+	SendCount      int                         `gorm:"default:1" json:"sendCount"`
+	AcceptedAt     *time.Time                  `json:"acceptedAt,omitempty"`
+	AcceptedUserID *string                     `gorm:"type:char(26)" json:"acceptedUserId,omitempty"`
+	CreatedAt      time.Time                   `json:"createdAt"`
+	UpdatedAt      time.Time                   `json:"updatedAt"`
+
+	// Relations
+	Inviter User `gorm:"foreignKey:InviterID;constraint:OnDelete:CASCADE" json:"inviter,omitempty"`
+}
+
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+func (i *Invitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = GenerateULID()
+	}
+	return nil
+}
+
+// IsExpired checks if the invitation can no longer be accepted
+func (i *Invitation) IsExpired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
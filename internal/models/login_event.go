@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How the user authenticated
+const (
+	LoginMethodPassword = "password"
+	LoginMethodOIDC     = "oidc"
+	LoginMethodToken    = "token" // access token issued from a refresh token
+)
+
+// LoginEvent records a sign-in attempt. DeviceHash and Network identify the
+// user agent and the IP range so new devices and networks can be spotted.
+type LoginEvent struct {
+	ID            string    `gorm:"primaryKey;type:char(26)" json:"id"`
+	UserID        string    `gorm:"index:idx_login_events_user_created,priority:1;type:char(26);not null" json:"userId"`
+	Method        string    `gorm:"size:20;not null" json:"method"`
+	Success       bool      `gorm:"not null" json:"success"`
+	FailureReason *string   `gorm:"size:100" json:"failureReason,omitempty"`
+	IP            string    `gorm:"size:45" json:"ip"`
+	Network       string    `gorm:"size:64" json:"network"` // /24 for IPv4, /48 for IPv6
+	UserAgent     string    `gorm:"size:500" json:"userAgent"`
+	DeviceHash    string    `gorm:"size:64" json:"-"`
+	NewDevice     bool      `gorm:"default:false" json:"newDevice"`
+	NewNetwork    bool      `gorm:"default:false" json:"newNetwork"`
+	CreatedAt     time.Time `gorm:"index:idx_login_events_user_created,priority:2" json:"createdAt"`
+
+	// Relations
+	User User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (LoginEvent) TableName() string {
+	return "login_events"
+}
+
+func (e *LoginEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = GenerateULID()
+	}
+	return nil
+}
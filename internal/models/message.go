@@ -8,6 +8,7 @@ import (
 
 type Conversation struct {
 	ID        string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID  string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
 	Name      *string        `gorm:"size:191" json:"name,omitempty"`
 	IsGroup   bool           `gorm:"default:false" json:"isGroup"`
 	Avatar    *string        `gorm:"size:255" json:"avatar,omitempty"`
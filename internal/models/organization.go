@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Organization is a tenant. Tenant-owned models reference it through
+// TenantID and are only visible to members of the same organization.
+type Organization struct {
+	ID               string    `gorm:"primaryKey;type:char(26)" json:"id"`
+	Name             string    `gorm:"size:191;not null" json:"name"`
+	Slug             string    `gorm:"uniqueIndex;size:100;not null" json:"slug"`
+	SCIMTokenHash    *string   `gorm:"uniqueIndex;size:64" json:"-"` // SHA-256 of the SCIM bearer token; nil disables SCIM
+	SCIMEnabled      bool      `gorm:"-" json:"scimEnabled"`
+	SCIMGroupOwnerID *string   `gorm:"type:char(26)" json:"scimGroupOwnerId,omitempty"` // owns teams created through SCIM; nil is the group's first member
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = GenerateULID()
+	}
+	return nil
+}
+
+func (o *Organization) AfterFind(tx *gorm.DB) error {
+	o.SCIMEnabled = o.SCIMTokenHash != nil
+	return nil
+}
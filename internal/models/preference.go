@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type Theme string
+
+const (
+	ThemeLight  Theme = "light"
+	ThemeDark   Theme = "dark"
+	ThemeSystem Theme = "system"
+)
+
+// Delivery channels a notification can be sent through
+const (
+	ChannelInApp = "inApp"
+	ChannelEmail = "email"
+)
+
+// Default preference values applied to users without a stored row
+const (
+	DefaultLocale      = "zh-CN"
+	DefaultTimeZone    = "UTC"
+	DefaultLandingPage = "/dashboard"
+)
+
+type NotificationPreferences struct {
+	InApp bool `json:"inApp"`
+	Email bool `json:"email"`
+	// MutedTypes lists notification types (system, user, message, task, alert) delivered on no channel
+	MutedTypes []string `json:"mutedTypes"`
+}
+
+type UserPreference struct {
+	UserID        string                                      `gorm:"primaryKey;type:char(26)" json:"userId"`
+	Locale        string                                      `gorm:"size:35;not null" json:"locale"`
+	TimeZone      string                                      `gorm:"size:64;not null" json:"timeZone"`
+	Theme         Theme                                       `gorm:"type:varchar(10);not null" json:"theme"`
+	LandingPage   string                                      `gorm:"size:191;not null" json:"landingPage"`
+	Notifications datatypes.JSONType[NotificationPreferences] `json:"notifications"`
+	CreatedAt     time.Time                                   `json:"createdAt"`
+	UpdatedAt     time.Time                                   `json:"updatedAt"`
+
+	// Relations
+	User User `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (UserPreference) TableName() string {
+	return "user_preferences"
+}
+
+// DefaultUserPreference returns the settings of a user who never saved any
+func DefaultUserPreference(userID string) *UserPreference {
+	return &UserPreference{
+		UserID:      userID,
+		Locale:      DefaultLocale,
+		TimeZone:    DefaultTimeZone,
+		Theme:       ThemeSystem,
+		LandingPage: DefaultLandingPage,
+		Notifications: datatypes.NewJSONType(NotificationPreferences{
+			InApp:      true,
+			Email:      true,
+			MutedTypes: []string{},
+		}),
+	}
+}
+
+// Location resolves the preferred time zone, falling back to UTC
+func (p *UserPreference) Location() *time.Location {
+	if loc, err := time.LoadLocation(p.TimeZone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// Allows reports whether a notification of notifType may be delivered on channel
+func (p *UserPreference) Allows(channel, notifType string) bool {
+	prefs := p.Notifications.Data()
+	for _, muted := range prefs.MutedTypes {
+		if muted == notifType {
+			return false
+		}
+	}
+	switch channel {
+	case ChannelInApp:
+		return prefs.InApp
+	case ChannelEmail:
+		return prefs.Email
+	}
+	return false
+}
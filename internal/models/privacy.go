@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DataExportStatus string
+
+const (
+	DataExportPending   DataExportStatus = "PENDING"
+	DataExportRunning   DataExportStatus = "RUNNING"
+	DataExportCompleted DataExportStatus = "COMPLETED"
+	DataExportFailed    DataExportStatus = "FAILED"
+)
+
+// DataExport is an asynchronous personal data export (GDPR art. 15/20).
+// The finished archive lives in private storage under StorageKey.
+type DataExport struct {
+	ID            string           `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID      string           `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	UserID        string           `gorm:"index;type:char(26);not null" json:"userId"`
+	RequestedByID string           `gorm:"type:char(26);not null" json:"requestedById"`
+	Status        DataExportStatus `gorm:"type:varchar(20);not null;default:PENDING" json:"status"`
+	StorageKey    string           `gorm:"size:255" json:"-"`
+	Size          int64            `gorm:"default:0" json:"size"`
+	Error         *string          `gorm:"type:text" json:"error,omitempty"`
+	CompletedAt   *time.Time       `json:"completedAt,omitempty"`
+	ExpiresAt     *time.Time       `json:"expiresAt,omitempty"`
+	CreatedAt     time.Time        `json:"createdAt"`
+	UpdatedAt     time.Time        `json:"updatedAt"`
+}
+
+func (DataExport) TableName() string {
+	return "data_exports"
+}
+
+func (e *DataExport) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = GenerateULID()
+	}
+	return nil
+}
+
+// IsExpired reports whether the archive may no longer be downloaded
+func (e *DataExport) IsExpired() bool {
+	return e.ExpiresAt != nil && time.Now().After(*e.ExpiresAt)
+}
@@ -8,7 +8,8 @@ import (
 
 type Role struct {
 	ID          string         `gorm:"primaryKey;type:char(26)" json:"id"`
-	Name        string         `gorm:"uniqueIndex;size:100;not null" json:"name"`
+	TenantID    string         `gorm:"uniqueIndex:idx_roles_tenant_name,priority:1;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	Name        string         `gorm:"uniqueIndex:idx_roles_tenant_name,priority:2;size:100;not null" json:"name"`
 	Label       string         `gorm:"size:191;not null" json:"label"`
 	Description *string        `gorm:"type:text" json:"description,omitempty"`
 	CreatedAt   time.Time      `json:"createdAt"`
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ShareLinkResource string
+
+const (
+	ShareLinkDocument ShareLinkResource = "document"
+	ShareLinkFile     ShareLinkResource = "file"
+)
+
+// ShareLink is a public link to a document or file that works without an
+// account. Only the hash of its token is stored.
+type ShareLink struct {
+	ID            string            `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID      string            `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	ResourceType  ShareLinkResource `gorm:"type:varchar(20);not null" json:"resourceType"`
+	DocumentID    *string           `gorm:"index;type:char(26)" json:"documentId,omitempty"`
+	FileID        *string           `gorm:"index;type:char(26)" json:"fileId,omitempty"`
+	CreatorID     string            `gorm:"index;type:char(26);not null" json:"creatorId"`
+	TokenHash     string            `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	PasswordHash  string            `gorm:"size:255" json:"-"`
+	HasPassword   bool              `gorm:"-" json:"hasPassword"`
+	ViewOnly      bool              `gorm:"default:false" json:"viewOnly"` // content can be viewed but not downloaded
+	ExpiresAt     *time.Time        `json:"expiresAt,omitempty"`           // nil never expires
+	MaxDownloads  *int              `json:"maxDownloads,omitempty"`        // nil is unlimited
+	DownloadCount int               `gorm:"default:0" json:"downloadCount"`
+	RevokedAt     *time.Time        `json:"revokedAt,omitempty"`
+	LastAccessAt  *time.Time        `json:"lastAccessAt,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+
+	// Relations
+	Document *Document `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	File     *File     `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Creator  User      `gorm:"foreignKey:CreatorID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ShareLink) TableName() string {
+	return "share_links"
+}
+
+func (l *ShareLink) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = GenerateULID()
+	}
+	return nil
+}
+
+func (l *ShareLink) AfterFind(tx *gorm.DB) error {
+	l.HasPassword = l.PasswordHash != ""
+	return nil
+}
+
+// IsUsable reports whether the link still grants access
+func (l *ShareLink) IsUsable() bool {
+	if l.RevokedAt != nil {
+		return false
+	}
+	return l.ExpiresAt == nil || time.Now().Before(*l.ExpiresAt)
+}
+
+// Share link access outcomes
+const (
+	ShareAccessGranted          = "granted"
+	ShareAccessPasswordRequired = "password_required"
+	ShareAccessWrongPassword    = "wrong_password"
+	ShareAccessExpired          = "expired"
+	ShareAccessRevoked          = "revoked"
+	ShareAccessUnavailable      = "unavailable" // the shared item or its content is gone
+	ShareAccessViewOnly         = "view_only"
+	ShareAccessLimitReached     = "limit_reached"
+)
+
+// ShareLinkAccess records one request made through a share link
+type ShareLinkAccess struct {
+	ID        string    `gorm:"primaryKey;type:char(26)" json:"id"`
+	LinkID    string    `gorm:"index;type:char(26);not null" json:"linkId"`
+	Action    string    `gorm:"size:20;not null" json:"action"` // view, preview or download
+	Outcome   string    `gorm:"size:30;not null" json:"outcome"`
+	IP        string    `gorm:"size:45" json:"ip"`
+	UserAgent string    `gorm:"size:500" json:"userAgent"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+
+	// Relations
+	Link ShareLink `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+}
+
+func (ShareLinkAccess) TableName() string {
+	return "share_link_accesses"
+}
+
+func (a *ShareLinkAccess) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = GenerateULID()
+	}
+	return nil
+}
@@ -8,10 +8,12 @@ import (
 
 type Team struct {
 	ID          string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID    string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
 	Name        string         `gorm:"size:191;not null" json:"name"`
 	Description *string        `gorm:"type:text" json:"description,omitempty"`
 	Avatar      *string        `gorm:"size:255" json:"avatar,omitempty"`
 	OwnerID     string         `gorm:"index;type:char(26);not null" json:"ownerId"`
+	ExternalID  *string        `gorm:"index;size:191" json:"externalId,omitempty"` // identity provider ID set through SCIM
 	CreatedAt   time.Time      `json:"createdAt"`
 	UpdatedAt   time.Time      `json:"updatedAt"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TeamInvitation asks a user to join a team. Invitations by email are matched
+// to the account registered with that address; InviteeID is set once known.
+type TeamInvitation struct {
+	ID          string           `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID    string           `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	TeamID      string           `gorm:"index;type:char(26);not null" json:"teamId"`
+	InviterID   string           `gorm:"index;type:char(26);not null" json:"inviterId"`
+	InviteeID   *string          `gorm:"index;type:char(26)" json:"inviteeId,omitempty"`
+	Email       string           `gorm:"index;size:191;not null" json:"email"`
+	RoleID      *string          `gorm:"type:char(26)" json:"roleId,omitempty"`
+	Status      InvitationStatus `gorm:"type:varchar(20);not null;default:PENDING" json:"status"`
+	ExpiresAt   time.Time        `gorm:"index;not null" json:"expiresAt"`
+	RespondedAt *time.Time       `json:"respondedAt,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+
+	// Relations
+	Team    Team  `gorm:"constraint:OnDelete:CASCADE" json:"team,omitempty"`
+	Inviter User  `gorm:"foreignKey:InviterID;constraint:OnDelete:CASCADE" json:"inviter,omitempty"`
+	Invitee *User `gorm:"foreignKey:InviteeID;constraint:OnDelete:CASCADE" json:"invitee,omitempty"`
+	Role    *Role `gorm:"constraint:OnDelete:SET NULL" json:"role,omitempty"`
+}
+
+func (TeamInvitation) TableName() string {
+	return "team_invitations"
+}
+
+func (i *TeamInvitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = GenerateULID()
+	}
+	return nil
+}
+
+// IsExpired checks if the invitation can no longer be accepted
+func (i *TeamInvitation) IsExpired() bool {
+	return time.Now().After(i.ExpiresAt)
+}
+
+// TeamJoinLink lets anyone signed in who has the link join a team with RoleID.
+// Only the SHA-256 of the token is stored.
+type TeamJoinLink struct {
+	ID        string     `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID  string     `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	TeamID    string     `gorm:"index;type:char(26);not null" json:"teamId"`
+	CreatorID string     `gorm:"index;type:char(26);not null" json:"creatorId"`
+	TokenHash string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	RoleID    *string    `gorm:"type:char(26)" json:"roleId,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"` // nil never expires
+	MaxUses   *int       `json:"maxUses,omitempty"`   // nil is unlimited
+	UseCount  int        `gorm:"default:0" json:"useCount"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+
+	// Relations
+	Team    Team  `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+	Creator User  `gorm:"foreignKey:CreatorID;constraint:OnDelete:CASCADE" json:"-"`
+	Role    *Role `gorm:"constraint:OnDelete:SET NULL" json:"role,omitempty"`
+}
+
+func (TeamJoinLink) TableName() string {
+	return "team_join_links"
+}
+
+func (l *TeamJoinLink) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = GenerateULID()
+	}
+	return nil
+}
+
+// IsUsable reports whether the link can still be used to join
+func (l *TeamJoinLink) IsUsable() bool {
+	if l.RevokedAt != nil {
+		return false
+	}
+	if l.ExpiresAt != nil && time.Now().After(*l.ExpiresAt) {
+		return false
+	}
+	return l.MaxUses == nil || l.UseCount < *l.MaxUses
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -15,22 +16,30 @@ const (
 )
 
 type User struct {
-	ID          string         `gorm:"primaryKey;type:char(26)" json:"id"`
-	Email       string         `gorm:"uniqueIndex;size:191;not null" json:"email"`
-	Phone       *string        `gorm:"uniqueIndex;size:50" json:"phone,omitempty"`
-	Username    string         `gorm:"uniqueIndex;size:100;not null" json:"username"`
-	Password    string         `gorm:"size:255;not null" json:"-"`
-	Name        string         `gorm:"size:191;not null" json:"name"`
-	Avatar      *string        `gorm:"size:255" json:"avatar,omitempty"`
-	Status      UserStatus     `gorm:"type:varchar(20);default:ACTIVE" json:"status"`
-	Department  *string        `gorm:"size:191" json:"department,omitempty"`
-	Position    *string        `gorm:"size:191" json:"position,omitempty"`
-	Bio         *string        `gorm:"type:text" json:"bio,omitempty"`
-	QuotaUsed   int64          `gorm:"default:0" json:"quotaUsed"`
-	LastLoginAt *time.Time     `json:"lastLoginAt,omitempty"`
-	CreatedAt   time.Time      `json:"createdAt"`
-	UpdatedAt   time.Time      `json:"updatedAt"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             string         `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID       string         `gorm:"index;type:char(26);not null;default:00000000000000000000000000" json:"tenantId"`
+	Email          string         `gorm:"uniqueIndex:idx_users_active_email,where:deleted_at IS NULL;size:191;not null" json:"email"`
+	Phone          *string        `gorm:"uniqueIndex:idx_users_active_phone,where:deleted_at IS NULL;size:50" json:"phone,omitempty"`
+	Username       string         `gorm:"uniqueIndex:idx_users_active_username,where:deleted_at IS NULL;size:100;not null" json:"username"`
+	Password       string         `gorm:"size:255;not null" json:"-"`
+	Name           string         `gorm:"size:191;not null" json:"name"`
+	Avatar         *string        `gorm:"size:255" json:"avatar,omitempty"`
+	Status         UserStatus     `gorm:"type:varchar(20);default:ACTIVE" json:"status"`
+	StatusReason   *string        `gorm:"size:500" json:"statusReason,omitempty"`
+	SuspendedUntil *time.Time     `gorm:"index" json:"suspendedUntil,omitempty"` // a suspension ends automatically at this time
+	Department     *string        `gorm:"size:191" json:"department,omitempty"`  // department name, kept in sync with DepartmentID when set
+	DepartmentID   *string        `gorm:"index;type:char(26)" json:"departmentId,omitempty"`
+	ManagerID      *string        `gorm:"index;type:char(26)" json:"managerId,omitempty"`
+	Position       *string        `gorm:"size:191" json:"position,omitempty"`
+	Bio            *string        `gorm:"type:text" json:"bio,omitempty"`
+	QuotaUsed      int64          `gorm:"default:0" json:"quotaUsed"`
+	LastLoginAt    *time.Time     `json:"lastLoginAt,omitempty"`
+	ExternalID     *string        `gorm:"index;size:191" json:"externalId,omitempty"`  // identity provider ID set through SCIM
+	Attributes     datatypes.JSON `gorm:"index:,type:gin" json:"attributes,omitempty"` // custom attribute values keyed by UserAttribute.Key
+	ErasedAt       *time.Time     `json:"erasedAt,omitempty"`                          // set when personal data was erased; the row is a tombstone
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 
 	// Relations
 	Roles         []UserRole                 `gorm:"foreignKey:UserID" json:"roles,omitempty"`
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type AttributeType string
+
+const (
+	AttributeString  AttributeType = "string"
+	AttributeNumber  AttributeType = "number"
+	AttributeBoolean AttributeType = "boolean"
+	AttributeDate    AttributeType = "date" // YYYY-MM-DD
+	AttributeEnum    AttributeType = "enum"
+)
+
+// Who can read an attribute value. Administrators always can.
+type AttributeVisibility string
+
+const (
+	AttributePublic  AttributeVisibility = "public"  // every signed-in user
+	AttributePrivate AttributeVisibility = "private" // the user themselves
+	AttributeAdmin   AttributeVisibility = "admin"   // administrators only
+)
+
+// UserAttribute defines a custom profile field. Values live in User.Attributes
+// keyed by Key.
+type UserAttribute struct {
+	ID          string                      `gorm:"primaryKey;type:char(26)" json:"id"`
+	TenantID    string                      `gorm:"uniqueIndex:idx_user_attributes_tenant_key,priority:1;type:char(26);not null;default:00000000000000000000000000" json:"-"`
+	Key         string                      `gorm:"uniqueIndex:idx_user_attributes_tenant_key,priority:2;size:64;not null" json:"key"`
+	Label       string                      `gorm:"size:191;not null" json:"label"`
+	Description *string                     `gorm:"type:text" json:"description,omitempty"`
+	Type        AttributeType               `gorm:"type:varchar(20);not null" json:"type"`
+	Required    bool                        `gorm:"default:false" json:"required"`
+	Options     datatypes.JSONSlice[string] `json:"options,omitempty"` // allowed values of an enum
+	Visibility  AttributeVisibility         `gorm:"type:varchar(20);not null;default:public" json:"visibility"`
+	Position    int                         `gorm:"default:0" json:"position"`
+	CreatedAt   time.Time                   `json:"createdAt"`
+	UpdatedAt   time.Time                   `json:"updatedAt"`
+}
+
+func (UserAttribute) TableName() string {
+	return "user_attributes"
+}
+
+func (a *UserAttribute) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = GenerateULID()
+	}
+	return nil
+}
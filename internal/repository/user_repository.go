@@ -1,27 +1,72 @@
 package repository
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
 	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/tenant"
 	"gorm.io/gorm"
 )
 
 var (
-	ErrNotFound      = errors.New("record not found")
-	ErrDuplicateKey  = errors.New("duplicate key violation")
+	ErrNotFound     = errors.New("record not found")
+	ErrDuplicateKey = errors.New("duplicate key violation")
 )
 
+// UserFilter narrows and orders the result of UserRepository.List
+type UserFilter struct {
+	Search       string // matched against name, email and username
+	Status       models.UserStatus
+	Department   string
+	DepartmentID string
+	ManagerID    string
+	Role         string // role ID or role name
+	TeamID       string
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	Attributes   map[string]interface{} // custom attribute values that must all match
+	SortBy       string                 // one of the keys in userSortColumns
+	SortOrder    string                 // asc or desc
+}
+
+// userSortColumns whitelists the columns clients may sort users by
+var userSortColumns = map[string]string{
+	"createdAt":   "created_at",
+	"updatedAt":   "updated_at",
+	"lastLoginAt": "last_login_at",
+	"name":        "name",
+	"email":       "email",
+	"username":    "username",
+	"status":      "status",
+	"department":  "department",
+}
+
+// IsValidUserSort reports whether field can be used as UserFilter.SortBy
+func IsValidUserSort(field string) bool {
+	_, ok := userSortColumns[field]
+	return ok
+}
+
 type UserRepository interface {
+	// WithContext binds the repository to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) UserRepository
 	Create(user *models.User) error
-	GetByID(id uint) (*models.User, error)
-	GetByStringID(id string) (*models.User, error)
+	GetByID(id string) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
 	GetByUsername(username string) (*models.User, error)
-	List(offset, limit int) ([]models.User, int64, error)
+	List(filter UserFilter, offset, limit int) ([]models.User, int64, error)
+	ListAll(filter UserFilter) ([]models.User, error)
 	Update(user *models.User) error
-	Delete(id uint) error
+	Delete(id string) error
 	BatchDelete(ids []string) error
+	// ListDeleted lists soft-deleted users that can still be restored
+	ListDeleted(filter UserFilter, offset, limit int) ([]models.User, int64, error)
+	// Restore returns ErrDuplicateKey when another user has taken the email,
+	// username or phone since the deletion
+	Restore(id string) error
 }
 
 type userRepo struct {
@@ -32,6 +77,10 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepo{db: db}
 }
 
+func (r *userRepo) WithContext(ctx context.Context) UserRepository {
+	return &userRepo{db: r.db.WithContext(ctx)}
+}
+
 func (r *userRepo) Create(user *models.User) error {
 	err := r.db.Create(user).Error
 	if err != nil {
@@ -44,9 +93,9 @@ func (r *userRepo) Create(user *models.User) error {
 	return nil
 }
 
-func (r *userRepo) GetByID(id uint) (*models.User, error) {
+func (r *userRepo) GetByID(id string) (*models.User, error) {
 	var u models.User
-	if err := r.db.First(&u, id).Error; err != nil {
+	if err := r.db.Where("id = ?", id).First(&u).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrNotFound
 		}
@@ -77,23 +126,85 @@ func (r *userRepo) GetByUsername(username string) (*models.User, error) {
 	return &u, nil
 }
 
-func (r *userRepo) List(offset, limit int) ([]models.User, int64, error) {
+func (r *userRepo) List(filter UserFilter, offset, limit int) ([]models.User, int64, error) {
 	var users []models.User
 	var total int64
 
+	query := applyUserFilter(r.db.Model(&models.User{}), filter)
+
 	// Get total count
-	if err := r.db.Model(&models.User{}).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated results
-	if err := r.db.Offset(offset).Limit(limit).Order("id asc").Find(&users).Error; err != nil {
+	if err := query.Offset(offset).Limit(limit).Order(userOrder(filter)).Order("id ASC").Find(&users).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return users, total, nil
 }
 
+func (r *userRepo) ListAll(filter UserFilter) ([]models.User, error) {
+	var users []models.User
+	err := applyUserFilter(r.db.Model(&models.User{}), filter).
+		Preload("Roles.Role").
+		Preload("Teams.Team").
+		Order(userOrder(filter)).
+		Order("id ASC").
+		Find(&users).Error
+	return users, err
+}
+
+func applyUserFilter(query *gorm.DB, filter UserFilter) *gorm.DB {
+	if filter.Search != "" {
+		like := "%" + filter.Search + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ? OR username ILIKE ?", like, like, like)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Department != "" {
+		query = query.Where("department = ?", filter.Department)
+	}
+	if filter.DepartmentID != "" {
+		query = query.Where("department_id = ?", filter.DepartmentID)
+	}
+	if filter.ManagerID != "" {
+		query = query.Where("manager_id = ?", filter.ManagerID)
+	}
+	if filter.Role != "" {
+		query = query.Where("id IN (SELECT ur.user_id FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE r.id = ? OR r.name = ?)", filter.Role, filter.Role)
+	}
+	if filter.TeamID != "" {
+		query = query.Where("id IN (SELECT user_id FROM team_members WHERE team_id = ?)", filter.TeamID)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at <= ?", *filter.CreatedTo)
+	}
+	if len(filter.Attributes) > 0 {
+		// Containment is served by the GIN index on attributes
+		if data, err := json.Marshal(filter.Attributes); err == nil {
+			query = query.Where("attributes @> ?::jsonb", string(data))
+		}
+	}
+	return query
+}
+
+func userOrder(filter UserFilter) string {
+	order := "created_at"
+	if col, ok := userSortColumns[filter.SortBy]; ok {
+		order = col
+	}
+	if filter.SortOrder == "asc" {
+		return order + " ASC"
+	}
+	return order + " DESC"
+}
+
 func (r *userRepo) Update(user *models.User) error {
 	err := r.db.Save(user).Error
 	if err != nil {
@@ -105,8 +216,8 @@ func (r *userRepo) Update(user *models.User) error {
 	return nil
 }
 
-func (r *userRepo) Delete(id uint) error {
-	result := r.db.Delete(&models.User{}, id)
+func (r *userRepo) Delete(id string) error {
+	result := r.db.Delete(&models.User{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -116,17 +227,60 @@ func (r *userRepo) Delete(id uint) error {
 	return nil
 }
 
-func (r *userRepo) GetByStringID(id string) (*models.User, error) {
+func (r *userRepo) BatchDelete(ids []string) error {
+	return r.db.Where("id IN ?", ids).Delete(&models.User{}).Error
+}
+
+// deletedUsers scopes to soft-deleted rows, excluding erased tombstones
+func (r *userRepo) deletedUsers() *gorm.DB {
+	return r.db.Unscoped().Model(&models.User{}).Where("deleted_at IS NOT NULL AND erased_at IS NULL")
+}
+
+func (r *userRepo) ListDeleted(filter UserFilter, offset, limit int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query := applyUserFilter(r.deletedUsers(), filter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "deleted_at DESC"
+	if filter.SortBy != "" {
+		order = userOrder(filter)
+	}
+	if err := query.Offset(offset).Limit(limit).Order(order).Order("id ASC").Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *userRepo) Restore(id string) error {
 	var u models.User
-	if err := r.db.Where("id = ?", id).First(&u).Error; err != nil {
+	if err := r.deletedUsers().Where("id = ?", id).First(&u).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, ErrNotFound
+			return ErrNotFound
 		}
-		return nil, err
+		return err
+	}
+	// Sign-in identifiers are unique across organizations
+	var taken int64
+	err := tenant.Global(r.db).Model(&models.User{}).
+		Where("email = ? OR username = ? OR phone = ?", u.Email, u.Username, u.Phone).
+		Count(&taken).Error
+	if err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrDuplicateKey
 	}
-	return &u, nil
-}
 
-func (r *userRepo) BatchDelete(ids []string) error {
-	return r.db.Where("id IN ?", ids).Delete(&models.User{}).Error
+	result := r.deletedUsers().Where("id = ?", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
@@ -1,12 +1,17 @@
 package routes
 
 import (
+	"path/filepath"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/halolight/halolight-api-go/internal/handlers"
 	"github.com/halolight/halolight-api-go/internal/middleware"
 	"github.com/halolight/halolight-api-go/internal/repository"
 	"github.com/halolight/halolight-api-go/internal/services"
 	"github.com/halolight/halolight-api-go/pkg/config"
+	"github.com/halolight/halolight-api-go/pkg/mailer"
+	"github.com/halolight/halolight-api-go/pkg/storage"
 	"gorm.io/gorm"
 )
 
@@ -16,7 +21,8 @@ func SetupRouter(cfg config.Config, db *gorm.DB) *gin.Engine {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(middleware.LoggerMiddleware(), gin.Recovery())
 
 	// Apply CORS middleware
 	r.Use(middleware.CORSMiddleware())
@@ -38,37 +44,116 @@ func SetupRouter(cfg config.Config, db *gorm.DB) *gin.Engine {
 	// Serve swagger-ui static files
 	r.Static("/docs", "./docs/swagger-ui")
 
+	// Public uploads such as avatars; private blobs are only reachable through handlers
+	publicStore := storage.NewLocal(filepath.Join(cfg.StorageDir, "public"), cfg.StoragePublicURL)
+	privateStore := storage.NewLocal(filepath.Join(cfg.StorageDir, "private"), "")
+	r.Static(cfg.StoragePublicURL, publicStore.Root())
+
+	mail := mailer.New(cfg)
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
 	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
 
 	// Initialize services
-	authSvc := services.NewAuthService(cfg, userRepo)
-	userSvc := services.NewUserService(userRepo)
+	accountSvc := services.NewAccountStatusService(db, time.Duration(cfg.AccountStatusCacheSeconds)*time.Second)
+	accountSvc.Start(time.Minute)
+	authSvc := services.NewAuthService(cfg, userRepo, accountSvc)
+	attributeSvc := services.NewAttributeService(db)
+	userSvc := services.NewUserService(userRepo, attributeSvc)
+	userBulkSvc := services.NewUserBulkService(db, userRepo, attributeSvc)
 	roleSvc := services.NewRoleService(db)
 	permissionSvc := services.NewPermissionService(db)
 	teamSvc := services.NewTeamService(db)
-	documentSvc := services.NewDocumentService(db)
-	fileSvc := services.NewFileService(db)
+	revisionPolicy := services.RevisionPolicy{
+		Keep:      cfg.DocumentRevisionKeep,
+		ThinAfter: time.Duration(cfg.DocumentRevisionThinDays) * 24 * time.Hour,
+	}
+	documentSvc := services.NewDocumentService(db, revisionPolicy, cfg.DocumentSearchLanguage)
+	revisionSvc := services.NewDocumentRevisionService(db, revisionPolicy)
+	revisionSvc.Start(time.Hour)
+	collabSvc := services.NewCollabService(db, documentSvc)
+	fileSvc := services.NewFileService(db, privateStore)
 	folderSvc := services.NewFolderService(db)
 	calendarSvc := services.NewCalendarService(db)
-	notificationSvc := services.NewNotificationService(db)
+	preferenceSvc := services.NewPreferenceService(db)
+	notificationSvc := services.NewNotificationService(db, preferenceSvc)
+	commentSvc := services.NewDocumentCommentService(db, documentSvc, notificationSvc)
+	shareLinkSvc := services.NewShareLinkService(db, privateStore)
+	exportSvc := services.NewDocumentExportService(db, cfg.DocumentExportTemplateDir, cfg.DocumentExportFont)
+	importSvc := services.NewDocumentImportService(db, documentSvc)
+	templateSvc := services.NewDocumentTemplateService(db)
+	loginHistorySvc := services.NewLoginHistoryService(db, notificationSvc, preferenceSvc, mail)
 	messageSvc := services.NewMessageService(db)
 	dashboardSvc := services.NewDashboardService(db)
+	scimSvc := services.NewSCIMService(db, attributeSvc)
+	avatarSvc := services.NewAvatarService(db, publicStore)
+	privacySvc := services.NewPrivacyService(db, privateStore, publicStore)
+	privacySvc.ResumePending()
+	userPurgeSvc := services.NewUserPurgeService(db, privateStore, publicStore, services.PurgePolicy{
+		Retention:   time.Duration(cfg.UserRetentionDays) * 24 * time.Hour,
+		Teams:       cfg.UserPurgeTeams,
+		Content:     cfg.UserPurgeContent,
+		SuccessorID: cfg.UserPurgeSuccessorID,
+	})
+	userPurgeSvc.Start(time.Hour)
+	orgSvc := services.NewOrgService(db)
+	organizationSvc := services.NewOrganizationService(db)
+	invitationSvc := services.NewInvitationService(db, mail, cfg.AppURL, time.Duration(cfg.InvitationExpireHours)*time.Hour)
+	teamInvitationSvc := services.NewTeamInvitationService(db, notificationSvc, mail, cfg.AppURL, time.Duration(cfg.InvitationExpireHours)*time.Hour)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(authSvc, refreshTokenRepo, cfg)
-	userHandler := handlers.NewUserHandler(userSvc)
+	authHandler := handlers.NewAuthHandler(authSvc, accountSvc, loginHistorySvc, teamInvitationSvc, refreshTokenRepo, cfg)
+	userHandler := handlers.NewUserHandler(userSvc, userBulkSvc, avatarSvc, userPurgeSvc, accountSvc, attributeSvc, permissionSvc)
 	roleHandler := handlers.NewRoleHandler(roleSvc)
 	permissionHandler := handlers.NewPermissionHandler(permissionSvc)
-	teamHandler := handlers.NewTeamHandler(teamSvc)
-	documentHandler := handlers.NewDocumentHandler(documentSvc)
+	teamHandler := handlers.NewTeamHandler(teamSvc, teamInvitationSvc, avatarSvc)
+	documentHandler := handlers.NewDocumentHandler(documentSvc, revisionSvc, collabSvc, commentSvc, exportSvc, importSvc, templateSvc)
 	fileHandler := handlers.NewFileHandler(fileSvc, folderSvc)
+	shareLinkHandler := handlers.NewShareLinkHandler(shareLinkSvc, documentSvc, fileSvc)
 	folderHandler := handlers.NewFolderHandler(folderSvc)
-	calendarHandler := handlers.NewCalendarHandler(calendarSvc)
+	calendarHandler := handlers.NewCalendarHandler(calendarSvc, preferenceSvc)
 	notificationHandler := handlers.NewNotificationHandler(notificationSvc)
 	messageHandler := handlers.NewMessageHandler(messageSvc)
 	dashboardHandler := handlers.NewDashboardHandler(dashboardSvc)
+	scimHandler := handlers.NewSCIMHandler(scimSvc)
+	preferenceHandler := handlers.NewPreferenceHandler(preferenceSvc)
+	privacyHandler := handlers.NewPrivacyHandler(privacySvc)
+	invitationHandler := handlers.NewInvitationHandler(invitationSvc, teamInvitationSvc, cfg)
+	orgHandler := handlers.NewOrgHandler(orgSvc)
+	organizationHandler := handlers.NewOrganizationHandler(organizationSvc)
+	attributeHandler := handlers.NewAttributeHandler(attributeSvc)
+	templateHandler := handlers.NewDocumentTemplateHandler(templateSvc, permissionSvc)
+
+	// ==================== SCIM 2.0 Provisioning ====================
+	scimRoutes := r.Group("/scim/v2")
+	scimRoutes.Use(middleware.SCIMAuthMiddleware(organizationSvc))
+	{
+		scimRoutes.GET("/ServiceProviderConfig", scimHandler.ServiceProviderConfig)
+		scimRoutes.GET("/ResourceTypes", scimHandler.ResourceTypes)
+
+		scimRoutes.GET("/Users", scimHandler.ListUsers)
+		scimRoutes.POST("/Users", scimHandler.CreateUser)
+		scimRoutes.GET("/Users/:id", scimHandler.GetUser)
+		scimRoutes.PUT("/Users/:id", scimHandler.ReplaceUser)
+		scimRoutes.PATCH("/Users/:id", scimHandler.PatchUser)
+		scimRoutes.DELETE("/Users/:id", scimHandler.DeleteUser)
+
+		scimRoutes.GET("/Groups", scimHandler.ListGroups)
+		scimRoutes.POST("/Groups", scimHandler.CreateGroup)
+		scimRoutes.GET("/Groups/:id", scimHandler.GetGroup)
+		scimRoutes.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+		scimRoutes.PATCH("/Groups/:id", scimHandler.PatchGroup)
+		scimRoutes.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+	}
+
+	// ==================== Public Share Links ====================
+	shared := r.Group("/s")
+	{
+		shared.GET("/:token", shareLinkHandler.View)
+		shared.GET("/:token/preview", shareLinkHandler.Preview)
+		shared.GET("/:token/download", shareLinkHandler.Download)
+	}
 
 	// API routes
 	api := r.Group("/api")
@@ -85,80 +170,203 @@ func SetupRouter(cfg config.Config, db *gorm.DB) *gin.Engine {
 
 		// Auth routes requiring authentication
 		authProtected := api.Group("/auth")
-		authProtected.Use(middleware.AuthMiddleware(cfg))
+		authProtected.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			authProtected.GET("/me", authHandler.Me)
+			authProtected.GET("/login-history", authHandler.LoginHistory)
 			authProtected.POST("/logout", authHandler.Logout)
 		}
 
 		// ==================== Users Routes ====================
 		users := api.Group("/users")
-		users.Use(middleware.AuthMiddleware(cfg))
+		users.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			users.GET("", userHandler.List)
+			users.GET("/export", middleware.RequirePermission(permissionSvc, "users:export"), userHandler.Export)
+			users.POST("/import", middleware.RequirePermission(permissionSvc, "users:import"), userHandler.Import)
+			users.GET("/deleted", middleware.RequirePermission(permissionSvc, "users:delete"), userHandler.ListDeleted)
+			users.GET("/me/preferences", preferenceHandler.Get)
+			users.PATCH("/me/preferences", preferenceHandler.Update)
 			users.GET("/:id", userHandler.Get)
-			users.POST("", userHandler.Create)
-			users.PATCH("/:id", userHandler.Update)
-			users.PATCH("/:id/status", userHandler.UpdateStatus)
-			users.POST("/batch-delete", userHandler.BatchDelete)
-			users.DELETE("/:id", userHandler.Delete)
+			users.POST("", middleware.RequirePermission(permissionSvc, "users:create"), userHandler.Create)
+			users.PATCH("/:id", middleware.RequireSelfOrPermission(permissionSvc, "id", "users:update"), userHandler.Update)
+			users.PATCH("/:id/status", middleware.RequirePermission(permissionSvc, "users:update"), userHandler.UpdateStatus)
+			users.GET("/:id/reports", orgHandler.Reports)
+			users.GET("/:id/manager-chain", orgHandler.ManagementChain)
+			users.PUT("/:id/manager", middleware.RequirePermission(permissionSvc, "users:update"), orgHandler.SetManager)
+			users.PUT("/:id/department", middleware.RequirePermission(permissionSvc, "users:update"), orgHandler.SetDepartment)
+			users.PUT("/:id/avatar", middleware.RequireSelfOrPermission(permissionSvc, "id", "users:update"), userHandler.UploadAvatar)
+			users.POST("/batch-delete", middleware.RequirePermission(permissionSvc, "users:delete"), userHandler.BatchDelete)
+			users.POST("/:id/restore", middleware.RequirePermission(permissionSvc, "users:delete"), userHandler.Restore)
+			users.POST("/:id/data-exports", middleware.RequireSelfOrPermission(permissionSvc, "id", "users:privacy"), privacyHandler.RequestExport)
+			users.GET("/:id/data-exports/:exportId", middleware.RequireSelfOrPermission(permissionSvc, "id", "users:privacy"), privacyHandler.GetExport)
+			users.GET("/:id/data-exports/:exportId/download", middleware.RequireSelfOrPermission(permissionSvc, "id", "users:privacy"), privacyHandler.DownloadExport)
+			users.POST("/:id/erase", middleware.RequirePermission(permissionSvc, "users:erase"), privacyHandler.Erase)
+			users.DELETE("/:id", middleware.RequirePermission(permissionSvc, "users:delete"), userHandler.Delete)
+		}
+
+		// ==================== User Attributes Routes ====================
+		userAttributes := api.Group("/user-attributes")
+		userAttributes.Use(middleware.AuthMiddleware(cfg, accountSvc))
+		{
+			userAttributes.GET("", attributeHandler.List)
+			userAttributes.GET("/:id", attributeHandler.Get)
+			userAttributes.POST("", middleware.RequirePermission(permissionSvc, services.UserAttributesManage), attributeHandler.Create)
+			userAttributes.PATCH("/:id", middleware.RequirePermission(permissionSvc, services.UserAttributesManage), attributeHandler.Update)
+			userAttributes.DELETE("/:id", middleware.RequirePermission(permissionSvc, services.UserAttributesManage), attributeHandler.Delete)
+		}
+
+		// ==================== Invitations Routes ====================
+		api.POST("/invitations/accept", invitationHandler.Accept)
+
+		invitations := api.Group("/invitations")
+		invitations.Use(middleware.AuthMiddleware(cfg, accountSvc), middleware.RequirePermission(permissionSvc, "users:invite"))
+		{
+			invitations.GET("", invitationHandler.List)
+			invitations.POST("", invitationHandler.Create)
+			invitations.POST("/:id/resend", invitationHandler.Resend)
+			invitations.POST("/:id/revoke", invitationHandler.Revoke)
+		}
+
+		// ==================== Tenant Routes ====================
+		organization := api.Group("/organization")
+		organization.Use(middleware.AuthMiddleware(cfg, accountSvc))
+		{
+			organization.GET("", organizationHandler.Current)
+			organization.PATCH("", middleware.RequirePermission(permissionSvc, "organization:manage"), organizationHandler.UpdateCurrent)
+			organization.POST("/scim-token", middleware.RequirePermission(permissionSvc, "organization:manage"), organizationHandler.SetSCIMToken)
+			organization.DELETE("/scim-token", middleware.RequirePermission(permissionSvc, "organization:manage"), organizationHandler.DisableSCIM)
+		}
+
+		organizations := api.Group("/organizations")
+		organizations.Use(middleware.AuthMiddleware(cfg, accountSvc), middleware.RequirePlatformAdmin(permissionSvc, "organizations:manage"))
+		{
+			organizations.GET("", organizationHandler.List)
+			organizations.POST("", organizationHandler.Create)
+			organizations.GET("/:id", organizationHandler.Get)
+			organizations.PATCH("/:id", organizationHandler.Update)
+		}
+
+		// ==================== Organization Routes ====================
+		departments := api.Group("/departments")
+		departments.Use(middleware.AuthMiddleware(cfg, accountSvc))
+		{
+			departments.GET("", orgHandler.ListDepartments)
+			departments.GET("/:id", orgHandler.GetDepartment)
+			departments.POST("", middleware.RequirePermission(permissionSvc, "departments:manage"), orgHandler.CreateDepartment)
+			departments.PATCH("/:id", middleware.RequirePermission(permissionSvc, "departments:manage"), orgHandler.UpdateDepartment)
+			departments.DELETE("/:id", middleware.RequirePermission(permissionSvc, "departments:manage"), orgHandler.DeleteDepartment)
+		}
+
+		org := api.Group("/org")
+		org.Use(middleware.AuthMiddleware(cfg, accountSvc))
+		{
+			org.GET("/tree", orgHandler.Tree)
 		}
 
 		// ==================== Roles Routes ====================
 		roles := api.Group("/roles")
-		roles.Use(middleware.AuthMiddleware(cfg))
+		roles.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			roles.GET("", roleHandler.List)
 			roles.GET("/:id", roleHandler.Get)
-			roles.POST("", roleHandler.Create)
-			roles.PATCH("/:id", roleHandler.Update)
-			roles.DELETE("/:id", roleHandler.Delete)
-			roles.POST("/:id/permissions", roleHandler.AssignPermissions)
+			roles.POST("", middleware.RequirePermission(permissionSvc, "roles:manage"), roleHandler.Create)
+			roles.PATCH("/:id", middleware.RequirePermission(permissionSvc, "roles:manage"), roleHandler.Update)
+			roles.DELETE("/:id", middleware.RequirePermission(permissionSvc, "roles:manage"), roleHandler.Delete)
+			roles.POST("/:id/permissions", middleware.RequirePermission(permissionSvc, "roles:manage"), roleHandler.AssignPermissions)
 		}
 
 		// ==================== Permissions Routes ====================
 		permissions := api.Group("/permissions")
-		permissions.Use(middleware.AuthMiddleware(cfg))
+		permissions.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			permissions.GET("", permissionHandler.List)
 			permissions.GET("/:id", permissionHandler.Get)
-			permissions.POST("", permissionHandler.Create)
-			permissions.DELETE("/:id", permissionHandler.Delete)
+			// Permissions are shared by all organizations
+			permissions.POST("", middleware.RequirePlatformAdmin(permissionSvc, "permissions:manage"), permissionHandler.Create)
+			permissions.DELETE("/:id", middleware.RequirePlatformAdmin(permissionSvc, "permissions:manage"), permissionHandler.Delete)
 		}
 
 		// ==================== Teams Routes ====================
 		teams := api.Group("/teams")
-		teams.Use(middleware.AuthMiddleware(cfg))
+		teams.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			teams.GET("", teamHandler.List)
+			teams.GET("/invitations", teamHandler.MyInvitations)
+			teams.POST("/invitations/:invitationId/accept", teamHandler.AcceptInvitation)
+			teams.POST("/invitations/:invitationId/decline", teamHandler.DeclineInvitation)
+			teams.POST("/join", teamHandler.Join)
 			teams.GET("/:id", teamHandler.Get)
 			teams.POST("", teamHandler.Create)
 			teams.PATCH("/:id", teamHandler.Update)
+			teams.PUT("/:id/avatar", teamHandler.UploadAvatar)
 			teams.DELETE("/:id", teamHandler.Delete)
 			teams.POST("/:id/members", teamHandler.AddMember)
+			teams.PATCH("/:id/members/:userId", teamHandler.UpdateMember)
 			teams.DELETE("/:id/members/:userId", teamHandler.RemoveMember)
+			teams.POST("/:id/transfer-ownership", teamHandler.TransferOwnership)
+			teams.POST("/:id/leave", teamHandler.Leave)
+			teams.GET("/:id/activity", teamHandler.Activity)
+			teams.GET("/:id/workspace", teamHandler.Workspace)
+			teams.GET("/:id/invitations", teamHandler.ListInvitations)
+			teams.POST("/:id/invitations", teamHandler.Invite)
+			teams.DELETE("/:id/invitations/:invitationId", teamHandler.RevokeInvitation)
+			teams.GET("/:id/join-links", teamHandler.ListJoinLinks)
+			teams.POST("/:id/join-links", teamHandler.CreateJoinLink)
+			teams.DELETE("/:id/join-links/:linkId", teamHandler.RevokeJoinLink)
 		}
 
 		// ==================== Documents Routes ====================
 		documents := api.Group("/documents")
-		documents.Use(middleware.AuthMiddleware(cfg))
+		documents.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			documents.GET("", documentHandler.List)
+			documents.GET("/search", documentHandler.Search)
 			documents.GET("/:id", documentHandler.Get)
+			documents.GET("/:id/export", documentHandler.Export)
 			documents.POST("", documentHandler.Create)
+			documents.POST("/import", documentHandler.Import)
 			documents.PUT("/:id", documentHandler.Update)
 			documents.PATCH("/:id/rename", documentHandler.Rename)
 			documents.POST("/:id/move", documentHandler.Move)
 			documents.POST("/:id/tags", documentHandler.UpdateTags)
 			documents.POST("/:id/share", documentHandler.Share)
+			documents.GET("/:id/shares", documentHandler.ListShares)
 			documents.POST("/:id/unshare", documentHandler.Unshare)
+			documents.GET("/:id/links", shareLinkHandler.ListDocumentLinks)
+			documents.POST("/:id/links", shareLinkHandler.CreateDocumentLink)
+			documents.DELETE("/:id/links/:linkId", shareLinkHandler.RevokeDocumentLink)
+			documents.GET("/:id/links/:linkId/accesses", shareLinkHandler.DocumentLinkAccesses)
+			documents.GET("/:id/revisions", documentHandler.ListRevisions)
+			documents.GET("/:id/revisions/diff", documentHandler.DiffRevisions)
+			documents.GET("/:id/revisions/:revisionId", documentHandler.GetRevision)
+			documents.POST("/:id/revisions/:revisionId/restore", documentHandler.RestoreRevision)
+			documents.GET("/:id/collab", documentHandler.Collaborate)
+			documents.GET("/:id/comments", documentHandler.ListComments)
+			documents.POST("/:id/comments", documentHandler.CreateComment)
+			documents.PUT("/:id/comments/:commentId", documentHandler.UpdateComment)
+			documents.POST("/:id/comments/:commentId/resolve", documentHandler.ResolveComment)
+			documents.POST("/:id/comments/:commentId/reopen", documentHandler.ReopenComment)
+			documents.DELETE("/:id/comments/:commentId", documentHandler.DeleteComment)
 			documents.POST("/batch-delete", documentHandler.BatchDelete)
 			documents.DELETE("/:id", documentHandler.Delete)
 		}
 
+		// ==================== Document Templates Routes ====================
+		// Permissions depend on the template scope and are checked per template
+		documentTemplates := api.Group("/document-templates")
+		documentTemplates.Use(middleware.AuthMiddleware(cfg, accountSvc))
+		{
+			documentTemplates.GET("", templateHandler.List)
+			documentTemplates.GET("/:id", templateHandler.Get)
+			documentTemplates.POST("", templateHandler.Create)
+			documentTemplates.PATCH("/:id", templateHandler.Update)
+			documentTemplates.DELETE("/:id", templateHandler.Delete)
+		}
+
 		// ==================== Files Routes ====================
 		files := api.Group("/files")
-		files.Use(middleware.AuthMiddleware(cfg))
+		files.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			files.POST("/upload", fileHandler.Upload)
 			files.POST("/folder", fileHandler.CreateFolder)
@@ -171,14 +379,18 @@ func SetupRouter(cfg config.Config, db *gorm.DB) *gin.Engine {
 			files.POST("/:id/move", fileHandler.Move)
 			files.POST("/:id/copy", fileHandler.Copy)
 			files.PATCH("/:id/favorite", fileHandler.ToggleFavorite)
-			files.POST("/:id/share", fileHandler.Share)
+			files.POST("/:id/share", shareLinkHandler.CreateFileLink)
+			files.GET("/:id/links", shareLinkHandler.ListFileLinks)
+			files.POST("/:id/links", shareLinkHandler.CreateFileLink)
+			files.DELETE("/:id/links/:linkId", shareLinkHandler.RevokeFileLink)
+			files.GET("/:id/links/:linkId/accesses", shareLinkHandler.FileLinkAccesses)
 			files.POST("/batch-delete", fileHandler.BatchDelete)
 			files.DELETE("/:id", fileHandler.Delete)
 		}
 
 		// ==================== Folders Routes ====================
 		folders := api.Group("/folders")
-		folders.Use(middleware.AuthMiddleware(cfg))
+		folders.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			folders.GET("", folderHandler.List)
 			folders.GET("/tree", folderHandler.GetTree)
@@ -189,7 +401,7 @@ func SetupRouter(cfg config.Config, db *gorm.DB) *gin.Engine {
 
 		// ==================== Calendar Routes ====================
 		calendar := api.Group("/calendar")
-		calendar.Use(middleware.AuthMiddleware(cfg))
+		calendar.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			events := calendar.Group("/events")
 			{
@@ -207,7 +419,7 @@ func SetupRouter(cfg config.Config, db *gorm.DB) *gin.Engine {
 
 		// ==================== Notifications Routes ====================
 		notifications := api.Group("/notifications")
-		notifications.Use(middleware.AuthMiddleware(cfg))
+		notifications.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			notifications.GET("", notificationHandler.List)
 			notifications.GET("/unread-count", notificationHandler.GetUnreadCount)
@@ -218,7 +430,7 @@ func SetupRouter(cfg config.Config, db *gorm.DB) *gin.Engine {
 
 		// ==================== Messages Routes ====================
 		messages := api.Group("/messages")
-		messages.Use(middleware.AuthMiddleware(cfg))
+		messages.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			messages.GET("/conversations", messageHandler.GetConversations)
 			messages.GET("/conversations/:id", messageHandler.GetConversation)
@@ -229,7 +441,7 @@ func SetupRouter(cfg config.Config, db *gorm.DB) *gin.Engine {
 
 		// ==================== Dashboard Routes ====================
 		dashboard := api.Group("/dashboard")
-		dashboard.Use(middleware.AuthMiddleware(cfg))
+		dashboard.Use(middleware.AuthMiddleware(cfg, accountSvc))
 		{
 			dashboard.GET("/stats", dashboardHandler.GetStats)
 			dashboard.GET("/visits", dashboardHandler.GetVisits)
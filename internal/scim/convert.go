@@ -0,0 +1,87 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+)
+
+// Version returns the weak ETag of a resource last modified at t
+func Version(t time.Time) string {
+	return `W/"` + strconv.FormatInt(t.UnixNano(), 36) + `"`
+}
+
+// FromUser renders a user as a SCIM resource. baseURL is the /scim/v2 root.
+func FromUser(u *models.User, baseURL string) User {
+	active := u.Status == models.UserStatusActive
+	out := User{
+		Schemas:     []string{SchemaUser},
+		ID:          u.ID,
+		UserName:    u.Username,
+		Name:        &Name{Formatted: u.Name},
+		DisplayName: u.Name,
+		Active:      &active,
+		Emails:      []MultiValue{{Value: u.Email, Type: "work", Primary: true}},
+		Meta: &Meta{
+			ResourceType: "User",
+			Created:      u.CreatedAt.UTC().Format(time.RFC3339),
+			LastModified: u.UpdatedAt.UTC().Format(time.RFC3339),
+			Location:     baseURL + "/Users/" + u.ID,
+			Version:      Version(u.UpdatedAt),
+		},
+	}
+	if u.ExternalID != nil {
+		out.ExternalID = *u.ExternalID
+	}
+	if u.Position != nil {
+		out.Title = *u.Position
+	}
+	if u.Phone != nil {
+		out.PhoneNumbers = []MultiValue{{Value: *u.Phone, Type: "work", Primary: true}}
+	}
+	if u.Department != nil {
+		out.Schemas = append(out.Schemas, SchemaEnterpriseUser)
+		out.Enterprise = &EnterpriseUser{Department: *u.Department}
+	}
+	if len(u.Attributes) > 0 && json.Unmarshal(u.Attributes, &out.Attributes) == nil && len(out.Attributes) > 0 {
+		out.Schemas = append(out.Schemas, SchemaAttributesUser)
+	}
+	for _, m := range u.Teams {
+		out.Groups = append(out.Groups, MultiValue{
+			Value:   m.TeamID,
+			Display: m.Team.Name,
+			Ref:     baseURL + "/Groups/" + m.TeamID,
+		})
+	}
+	return out
+}
+
+// FromTeam renders a team as a SCIM group. Members must be preloaded with their user.
+func FromTeam(t *models.Team, baseURL string) Group {
+	out := Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          t.ID,
+		DisplayName: t.Name,
+		Members:     []MultiValue{},
+		Meta: &Meta{
+			ResourceType: "Group",
+			Created:      t.CreatedAt.UTC().Format(time.RFC3339),
+			LastModified: t.UpdatedAt.UTC().Format(time.RFC3339),
+			Location:     baseURL + "/Groups/" + t.ID,
+			Version:      Version(t.UpdatedAt),
+		},
+	}
+	if t.ExternalID != nil {
+		out.ExternalID = *t.ExternalID
+	}
+	for _, m := range t.Members {
+		out.Members = append(out.Members, MultiValue{
+			Value:   m.UserID,
+			Display: m.User.Name,
+			Ref:     baseURL + "/Users/" + m.UserID,
+		})
+	}
+	return out
+}
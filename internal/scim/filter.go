@@ -0,0 +1,438 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a node of a parsed SCIM filter expression (RFC 7644 section 3.4.2.2)
+type Filter struct {
+	// Op is "and", "or", "not" for logical nodes, otherwise a comparison
+	// operator: eq, ne, co, sw, ew, gt, ge, lt, le or pr
+	Op    string
+	Attr  string      // lower-cased attribute path for comparisons
+	Value interface{} // string, float64, bool or nil
+	Left  *Filter
+	Right *Filter
+}
+
+// ParseFilter parses a filter expression such as
+// `userName eq "bjensen" and (emails co "example.com" or not (active eq false))`
+func ParseFilter(input string) (*Filter, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, filterError("unexpected %q", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+func filterError(format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, ErrInvalidFilter, "invalid filter: "+format, args...)
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")"})
+			i++
+		case r == '[':
+			tokens = append(tokens, token{tokLBracket, "["})
+			i++
+		case r == ']':
+			tokens = append(tokens, token{tokRBracket, "]"})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				if runes[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, filterError("unterminated string")
+			}
+			tokens = append(tokens, token{tokString, sb.String()})
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()[]\"", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokWord, string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *filterParser) peekWord(word string) bool {
+	t := p.peek()
+	return t != nil && t.kind == tokWord && strings.EqualFold(t.text, word)
+}
+
+func (p *filterParser) parseOr() (*Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &Filter{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (*Filter, error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("and") {
+		p.pos++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &Filter{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseFactor() (*Filter, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, filterError("unexpected end of expression")
+	}
+
+	if p.peekWord("not") {
+		p.pos++
+		inner, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return &Filter{Op: "not", Left: inner}, nil
+	}
+	if t.kind == tokLParen {
+		return p.parseGroup()
+	}
+	if t.kind != tokWord {
+		return nil, filterError("expected attribute, got %q", t.text)
+	}
+
+	attr := strings.ToLower(t.text)
+	p.pos++
+	if next := p.peek(); next != nil && next.kind == tokLBracket {
+		// Value filters such as emails[type eq "work"] are rewritten to the
+		// sub-attribute form so they compare against the same column
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != tokRBracket {
+			return nil, filterError("missing ]")
+		}
+		p.pos++
+		prefixAttrs(inner, attr)
+		return inner, nil
+	}
+
+	opTok := p.peek()
+	if opTok == nil || opTok.kind != tokWord {
+		return nil, filterError("expected operator after %q", attr)
+	}
+	op := strings.ToLower(opTok.text)
+	p.pos++
+
+	switch op {
+	case "pr":
+		return &Filter{Op: op, Attr: attr}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, filterError("unsupported operator %q", op)
+	}
+
+	valTok := p.peek()
+	if valTok == nil {
+		return nil, filterError("expected value after %q", op)
+	}
+	p.pos++
+
+	var value interface{}
+	switch {
+	case valTok.kind == tokString:
+		value = valTok.text
+	case valTok.kind == tokWord && valTok.text == "true":
+		value = true
+	case valTok.kind == tokWord && valTok.text == "false":
+		value = false
+	case valTok.kind == tokWord && valTok.text == "null":
+		value = nil
+	case valTok.kind == tokWord:
+		n, err := strconv.ParseFloat(valTok.text, 64)
+		if err != nil {
+			return nil, filterError("invalid value %q", valTok.text)
+		}
+		value = n
+	default:
+		return nil, filterError("invalid value %q", valTok.text)
+	}
+
+	return &Filter{Op: op, Attr: attr, Value: value}, nil
+}
+
+func (p *filterParser) parseGroup() (*Filter, error) {
+	if t := p.peek(); t == nil || t.kind != tokLParen {
+		return nil, filterError("expected (")
+	}
+	p.pos++
+	inner, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t == nil || t.kind != tokRParen {
+		return nil, filterError("missing )")
+	}
+	p.pos++
+	return inner, nil
+}
+
+func prefixAttrs(f *Filter, prefix string) {
+	if f == nil {
+		return
+	}
+	if f.Attr != "" {
+		f.Attr = prefix + "." + f.Attr
+	}
+	prefixAttrs(f.Left, prefix)
+	prefixAttrs(f.Right, prefix)
+}
+
+// Column describes how a filterable attribute maps onto the database
+type Column struct {
+	Name string // SQL column expression
+	// CaseExact disables case-insensitive string comparison
+	CaseExact bool
+	// Convert maps a filter value onto the stored value, e.g. active=true to "ACTIVE"
+	Convert func(v interface{}) (interface{}, error)
+	// Subquery wraps the comparison, e.g. "id IN (SELECT team_id FROM team_members WHERE %s)"
+	Subquery string
+}
+
+// ToSQL renders the filter as a WHERE clause using the attribute mapping.
+// Attribute keys must be lower-cased.
+func (f *Filter) ToSQL(columns map[string]Column) (string, []interface{}, error) {
+	switch f.Op {
+	case "and", "or":
+		l, largs, err := f.Left.ToSQL(columns)
+		if err != nil {
+			return "", nil, err
+		}
+		r, rargs, err := f.Right.ToSQL(columns)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + l + " " + strings.ToUpper(f.Op) + " " + r + ")", append(largs, rargs...), nil
+	case "not":
+		inner, args, err := f.Left.ToSQL(columns)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + inner + ")", args, nil
+	}
+
+	col, ok := columns[f.Attr]
+	if !ok {
+		return "", nil, filterError("unsupported attribute %q", f.Attr)
+	}
+
+	value := f.Value
+	if col.Convert != nil && f.Op != "pr" {
+		v, err := col.Convert(value)
+		if err != nil {
+			return "", nil, filterError("%s: %v", f.Attr, err)
+		}
+		value = v
+	}
+
+	clause, args, err := comparison(col, f.Op, value)
+	if err != nil {
+		return "", nil, err
+	}
+	if col.Subquery != "" {
+		clause = fmt.Sprintf(col.Subquery, clause)
+	}
+	return clause, args, nil
+}
+
+func comparison(col Column, op string, value interface{}) (string, []interface{}, error) {
+	name := col.Name
+	str, isString := value.(string)
+	fold := isString && !col.CaseExact
+	if fold {
+		name = "LOWER(" + col.Name + ")"
+		str = strings.ToLower(str)
+		value = str
+	}
+
+	switch op {
+	case "pr":
+		return "(" + col.Name + " IS NOT NULL AND CAST(" + col.Name + " AS TEXT) <> '')", nil, nil
+	case "eq":
+		if value == nil {
+			return col.Name + " IS NULL", nil, nil
+		}
+		return name + " = ?", []interface{}{value}, nil
+	case "ne":
+		if value == nil {
+			return col.Name + " IS NOT NULL", nil, nil
+		}
+		return "(" + name + " <> ? OR " + col.Name + " IS NULL)", []interface{}{value}, nil
+	case "co", "sw", "ew":
+		if !isString {
+			return "", nil, filterError("%s requires a string value", op)
+		}
+		pattern := escapeLike(str)
+		switch op {
+		case "co":
+			pattern = "%" + pattern + "%"
+		case "sw":
+			pattern = pattern + "%"
+		case "ew":
+			pattern = "%" + pattern
+		}
+		return name + " LIKE ?", []interface{}{pattern}, nil
+	case "gt", "ge", "lt", "le":
+		if value == nil {
+			return "", nil, filterError("%s requires a value", op)
+		}
+		sqlOp := map[string]string{"gt": ">", "ge": ">=", "lt": "<", "le": "<="}[op]
+		return name + " " + sqlOp + " ?", []interface{}{value}, nil
+	}
+	return "", nil, filterError("unsupported operator %q", op)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Matches evaluates the filter against a decoded JSON object. It is used for
+// value filters in PATCH paths, e.g. members[value eq "01H..."].
+func (f *Filter) Matches(item map[string]interface{}) bool {
+	switch f.Op {
+	case "and":
+		return f.Left.Matches(item) && f.Right.Matches(item)
+	case "or":
+		return f.Left.Matches(item) || f.Right.Matches(item)
+	case "not":
+		return !f.Left.Matches(item)
+	}
+
+	var actual interface{}
+	for k, v := range item {
+		if strings.EqualFold(k, f.Attr) {
+			actual = v
+			break
+		}
+	}
+
+	if f.Op == "pr" {
+		return actual != nil && actual != ""
+	}
+
+	as, aIsString := actual.(string)
+	es, eIsString := f.Value.(string)
+	if aIsString && eIsString {
+		as, es = strings.ToLower(as), strings.ToLower(es)
+		switch f.Op {
+		case "eq":
+			return as == es
+		case "ne":
+			return as != es
+		case "co":
+			return strings.Contains(as, es)
+		case "sw":
+			return strings.HasPrefix(as, es)
+		case "ew":
+			return strings.HasSuffix(as, es)
+		case "gt":
+			return as > es
+		case "ge":
+			return as >= es
+		case "lt":
+			return as < es
+		case "le":
+			return as <= es
+		}
+		return false
+	}
+
+	switch f.Op {
+	case "eq":
+		return actual == f.Value
+	case "ne":
+		return actual != f.Value
+	}
+	return false
+}
@@ -0,0 +1,301 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// patchPath is a parsed PATCH path: [extension:]attr[filter][.sub]
+type patchPath struct {
+	ext    string // extension schema URN holding the attribute, if any
+	attr   string
+	filter *Filter
+	sub    string
+}
+
+func pathError(format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, ErrInvalidPath, format, args...)
+}
+
+// parsePath splits a PATCH path. Paths prefixed with a core schema URN are
+// reduced to the bare attribute; extension URNs are kept in ext.
+func parsePath(path string, extensions []string) (*patchPath, error) {
+	p := &patchPath{}
+	lower := strings.ToLower(path)
+	for _, core := range []string{SchemaUser, SchemaGroup} {
+		if strings.HasPrefix(lower, strings.ToLower(core)+":") {
+			path = path[len(core)+1:]
+			lower = strings.ToLower(path)
+		}
+	}
+	for _, ext := range extensions {
+		if strings.HasPrefix(lower, strings.ToLower(ext)+":") {
+			p.ext = ext
+			path = path[len(ext)+1:]
+			break
+		}
+		if lower == strings.ToLower(ext) {
+			p.attr = ext
+			return p, nil
+		}
+	}
+
+	if i := strings.Index(path, "["); i >= 0 {
+		j := strings.LastIndex(path, "]")
+		if j < i {
+			return nil, pathError("invalid path %q", path)
+		}
+		f, err := ParseFilter(path[i+1 : j])
+		if err != nil {
+			return nil, pathError("invalid value filter in path %q", path)
+		}
+		p.attr = path[:i]
+		p.filter = f
+		rest := path[j+1:]
+		if rest != "" {
+			if !strings.HasPrefix(rest, ".") {
+				return nil, pathError("invalid path %q", path)
+			}
+			p.sub = rest[1:]
+		}
+	} else if i := strings.Index(path, "."); i >= 0 {
+		p.attr, p.sub = path[:i], path[i+1:]
+	} else {
+		p.attr = path
+	}
+
+	if p.attr == "" {
+		return nil, pathError("invalid path %q", path)
+	}
+	return p, nil
+}
+
+// ApplyPatch applies PATCH operations to a resource decoded as a JSON object.
+// extensions lists the schema URNs whose attributes live in nested objects.
+func ApplyPatch(doc map[string]interface{}, ops []PatchOperation, extensions ...string) error {
+	for _, op := range ops {
+		kind := strings.ToLower(op.Op)
+		if kind != "add" && kind != "replace" && kind != "remove" {
+			return NewError(http.StatusBadRequest, ErrInvalidSyntax, "unsupported patch op %q", op.Op)
+		}
+
+		var value interface{}
+		if len(op.Value) > 0 {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return NewError(http.StatusBadRequest, ErrInvalidValue, "invalid value for %q", op.Path)
+			}
+		}
+
+		if op.Path == "" {
+			if kind == "remove" {
+				return NewError(http.StatusBadRequest, ErrNoTarget, "remove requires a path")
+			}
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return NewError(http.StatusBadRequest, ErrInvalidValue, "value must be an object when path is omitted")
+			}
+			for key, v := range obj {
+				p, err := parsePath(key, extensions)
+				if err != nil {
+					return err
+				}
+				if err := applyOp(doc, kind, p, v); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		p, err := parsePath(op.Path, extensions)
+		if err != nil {
+			return err
+		}
+		if err := applyOp(doc, kind, p, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOp(doc map[string]interface{}, kind string, p *patchPath, value interface{}) error {
+	if p.ext != "" {
+		container, _ := doc[findKey(doc, p.ext)].(map[string]interface{})
+		if container == nil {
+			container = map[string]interface{}{}
+			doc[p.ext] = container
+		}
+		inner := *p
+		inner.ext = ""
+		return applyOp(container, kind, &inner, value)
+	}
+
+	key := findKey(doc, p.attr)
+
+	if p.filter != nil {
+		return applyFiltered(doc, key, kind, p, value)
+	}
+
+	if p.sub != "" {
+		obj, _ := doc[key].(map[string]interface{})
+		if obj == nil {
+			if kind == "remove" {
+				return nil
+			}
+			obj = map[string]interface{}{}
+			doc[key] = obj
+		}
+		subKey := findKey(obj, p.sub)
+		if kind == "remove" {
+			delete(obj, subKey)
+		} else {
+			obj[subKey] = value
+		}
+		return nil
+	}
+
+	switch kind {
+	case "remove":
+		existing, isArray := doc[key].([]interface{})
+		removals, hasValues := value.([]interface{})
+		if isArray && hasValues {
+			// Remove only the listed entries, e.g. members by value
+			doc[key] = filterItems(existing, func(item map[string]interface{}) bool {
+				for _, r := range removals {
+					if rm, ok := r.(map[string]interface{}); ok && sameValue(item, rm) {
+						return false
+					}
+				}
+				return true
+			})
+			return nil
+		}
+		delete(doc, key)
+	case "add":
+		switch existing := doc[key].(type) {
+		case []interface{}:
+			if additions, ok := value.([]interface{}); ok {
+				for _, a := range additions {
+					am, _ := a.(map[string]interface{})
+					duplicate := false
+					for _, e := range existing {
+						if em, ok := e.(map[string]interface{}); ok && am != nil && sameValue(em, am) {
+							duplicate = true
+							break
+						}
+					}
+					if !duplicate {
+						existing = append(existing, a)
+					}
+				}
+				doc[key] = existing
+				return nil
+			}
+		case map[string]interface{}:
+			if additions, ok := value.(map[string]interface{}); ok {
+				for k, v := range additions {
+					existing[findKey(existing, k)] = v
+				}
+				return nil
+			}
+		}
+		doc[key] = value
+	case "replace":
+		if existing, ok := doc[key].(map[string]interface{}); ok {
+			if replacement, ok := value.(map[string]interface{}); ok {
+				for k, v := range replacement {
+					existing[findKey(existing, k)] = v
+				}
+				return nil
+			}
+		}
+		doc[key] = value
+	}
+	return nil
+}
+
+// applyFiltered handles paths such as emails[type eq "work"].value
+func applyFiltered(doc map[string]interface{}, key, kind string, p *patchPath, value interface{}) error {
+	items, _ := doc[key].([]interface{})
+	// Filters were parsed standalone, so attributes carry no prefix
+	matched := false
+
+	if kind == "remove" {
+		if p.sub == "" {
+			doc[key] = filterItems(items, func(item map[string]interface{}) bool {
+				return !p.filter.Matches(item)
+			})
+			return nil
+		}
+		for _, it := range items {
+			if item, ok := it.(map[string]interface{}); ok && p.filter.Matches(item) {
+				delete(item, findKey(item, p.sub))
+			}
+		}
+		return nil
+	}
+
+	for _, it := range items {
+		item, ok := it.(map[string]interface{})
+		if !ok || !p.filter.Matches(item) {
+			continue
+		}
+		matched = true
+		if p.sub != "" {
+			item[findKey(item, p.sub)] = value
+		} else if replacement, ok := value.(map[string]interface{}); ok {
+			for k, v := range replacement {
+				item[findKey(item, k)] = v
+			}
+		}
+	}
+	if matched {
+		return nil
+	}
+
+	// No entry matched: create one from a simple equality filter so that
+	// `replace emails[type eq "work"].value` works on users without that email
+	if p.filter.Op != "eq" {
+		return NewError(http.StatusBadRequest, ErrNoTarget, "no values matched %s", key)
+	}
+	item := map[string]interface{}{p.filter.Attr: p.filter.Value}
+	if p.sub != "" {
+		item[p.sub] = value
+	} else if obj, ok := value.(map[string]interface{}); ok {
+		for k, v := range obj {
+			item[k] = v
+		}
+	}
+	doc[key] = append(items, item)
+	return nil
+}
+
+func filterItems(items []interface{}, keep func(map[string]interface{}) bool) []interface{} {
+	out := make([]interface{}, 0, len(items))
+	for _, it := range items {
+		if item, ok := it.(map[string]interface{}); ok && !keep(item) {
+			continue
+		}
+		out = append(out, it)
+	}
+	return out
+}
+
+func sameValue(a, b map[string]interface{}) bool {
+	av, aok := a["value"].(string)
+	bv, bok := b["value"].(string)
+	return aok && bok && strings.EqualFold(av, bv)
+}
+
+// findKey returns the existing key matching name case-insensitively, or name
+func findKey(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAccountInactive   = errors.New("account is inactive")
+	ErrAccountSuspended  = errors.New("account is suspended")
+	ErrInvalidSuspension = errors.New("suspendedUntil must be in the future and requires status SUSPENDED")
+	ErrSelfStatusChange  = errors.New("you cannot deactivate or suspend your own account")
+	ErrAccountCheck      = errors.New("could not verify account status")
+)
+
+// SuspensionError describes an active suspension. errors.Is matches it
+// against ErrAccountSuspended.
+type SuspensionError struct {
+	Reason string
+	Until  *time.Time
+}
+
+func (e *SuspensionError) Error() string {
+	msg := ErrAccountSuspended.Error()
+	if e.Until != nil {
+		msg += " until " + e.Until.UTC().Format(time.RFC3339)
+	}
+	if e.Reason != "" {
+		msg += ": " + e.Reason
+	}
+	return msg
+}
+
+func (e *SuspensionError) Is(target error) bool {
+	return target == ErrAccountSuspended
+}
+
+// StatusChange is an administrative status update. Reason is kept for
+// INACTIVE and SUSPENDED; Until only applies to SUSPENDED.
+type StatusChange struct {
+	Status models.UserStatus
+	Reason string
+	Until  *time.Time
+}
+
+type AccountStatusService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) AccountStatusService
+	// CheckAccount reports why the user may not use the API, or nil. Results
+	// are cached for the configured TTL.
+	CheckAccount(userID string) error
+	// CheckUser checks an already loaded user, bypassing the cache. An expired
+	// suspension is lifted and user is updated in place.
+	CheckUser(user *models.User) error
+	ChangeStatus(actorID, userID string, change StatusChange) (*models.User, error)
+	// ReactivateExpired lifts every suspension whose end date has passed
+	ReactivateExpired() (int, error)
+	// Start runs ReactivateExpired every interval in the background
+	Start(interval time.Duration)
+}
+
+type statusEntry struct {
+	err     error
+	expires time.Time
+}
+
+// statusCache is shared by the copies WithContext returns
+type statusCache struct {
+	mu      sync.RWMutex
+	entries map[string]statusEntry
+}
+
+type accountStatusService struct {
+	db    *gorm.DB
+	ttl   time.Duration
+	cache *statusCache
+}
+
+func NewAccountStatusService(db *gorm.DB, ttl time.Duration) AccountStatusService {
+	return &accountStatusService{db: db, ttl: ttl, cache: &statusCache{entries: make(map[string]statusEntry)}}
+}
+
+func (s *accountStatusService) WithContext(ctx context.Context) AccountStatusService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *accountStatusService) CheckAccount(userID string) error {
+	s.cache.mu.RLock()
+	entry, ok := s.cache.entries[userID]
+	s.cache.mu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.err
+	}
+
+	var user models.User
+	err := s.db.Select("id", "status", "status_reason", "suspended_until").First(&user, "id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.store(userID, ErrUserNotFound, nil)
+		return ErrUserNotFound
+	}
+	if err == nil {
+		err = s.CheckUser(&user)
+	}
+	if err != nil && !errors.Is(err, ErrAccountInactive) && !errors.Is(err, ErrAccountSuspended) {
+		// Database details are not for API clients
+		log.Printf("account status %s: %v", userID, err)
+		return ErrAccountCheck
+	}
+	return err
+}
+
+func (s *accountStatusService) CheckUser(user *models.User) error {
+	if suspensionExpired(user, time.Now()) {
+		if _, err := s.liftSuspension(user.ID); err != nil {
+			return err
+		}
+		// Reload in case an administrator changed the status concurrently
+		err := s.db.Select("status", "status_reason", "suspended_until").
+			Where("id = ?", user.ID).Take(user).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	err := statusError(user)
+	s.store(user.ID, err, user.SuspendedUntil)
+	return err
+}
+
+func (s *accountStatusService) ChangeStatus(actorID, userID string, change StatusChange) (*models.User, error) {
+	if !isValidUserStatus(change.Status) {
+		return nil, ErrInvalidStatus
+	}
+	if change.Until != nil && (change.Status != models.UserStatusSuspended || !change.Until.After(time.Now())) {
+		return nil, ErrInvalidSuspension
+	}
+	if actorID == userID && change.Status != models.UserStatusActive {
+		return nil, ErrSelfStatusChange
+	}
+
+	var reason *string
+	if change.Status != models.UserStatusActive {
+		reason = optionalString(change.Reason)
+	}
+
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		from := user.Status
+		err = tx.Model(&user).Updates(map[string]interface{}{
+			"status":          change.Status,
+			"status_reason":   reason,
+			"suspended_until": change.Until,
+		}).Error
+		if err != nil {
+			return err
+		}
+		user.Status = change.Status
+		user.StatusReason = reason
+		user.SuspendedUntil = change.Until
+
+		return tx.Create(statusChangeLog(actorID, userID, map[string]interface{}{
+			"from":   from,
+			"to":     change.Status,
+			"reason": reason,
+			"until":  change.Until,
+		})).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidate(userID)
+	return &user, nil
+}
+
+func (s *accountStatusService) ReactivateExpired() (int, error) {
+	var ids []string
+	err := s.db.Model(&models.User{}).
+		Where("status = ? AND suspended_until <= ?", models.UserStatusSuspended, time.Now()).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, id := range ids {
+		lifted, err := s.liftSuspension(id)
+		if err != nil {
+			return count, err
+		}
+		if lifted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *accountStatusService) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.ReactivateExpired(); err != nil {
+				log.Printf("suspension expiry: %v", err)
+			} else if n > 0 {
+				log.Printf("suspension expiry: reactivated %d users", n)
+			}
+			s.prune()
+			<-ticker.C
+		}
+	}()
+}
+
+// liftSuspension returns an expired suspension to ACTIVE. The conditional
+// update makes concurrent callers record the change only once.
+func (s *accountStatusService) liftSuspension(userID string) (bool, error) {
+	lifted := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Select("id", "status_reason", "suspended_until").First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&models.User{}).
+			Where("id = ? AND status = ? AND suspended_until <= ?", userID, models.UserStatusSuspended, time.Now()).
+			Updates(map[string]interface{}{
+				"status":          models.UserStatusActive,
+				"status_reason":   nil,
+				"suspended_until": nil,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		lifted = true
+
+		// The account itself is the actor; nobody intervened
+		return tx.Create(statusChangeLog(userID, userID, map[string]interface{}{
+			"from":      models.UserStatusSuspended,
+			"to":        models.UserStatusActive,
+			"reason":    user.StatusReason,
+			"until":     user.SuspendedUntil,
+			"automatic": true,
+		})).Error
+	})
+	if err == nil {
+		s.invalidate(userID)
+	}
+	return lifted, err
+}
+
+func (s *accountStatusService) store(userID string, err error, until *time.Time) {
+	expires := time.Now().Add(s.ttl)
+	if until != nil && until.Before(expires) {
+		expires = *until
+	}
+	s.cache.mu.Lock()
+	s.cache.entries[userID] = statusEntry{err: err, expires: expires}
+	s.cache.mu.Unlock()
+}
+
+func (s *accountStatusService) invalidate(userID string) {
+	s.cache.mu.Lock()
+	delete(s.cache.entries, userID)
+	s.cache.mu.Unlock()
+}
+
+func (s *accountStatusService) prune() {
+	now := time.Now()
+	s.cache.mu.Lock()
+	for id, entry := range s.cache.entries {
+		if !now.Before(entry.expires) {
+			delete(s.cache.entries, id)
+		}
+	}
+	s.cache.mu.Unlock()
+}
+
+func suspensionExpired(user *models.User, now time.Time) bool {
+	return user.Status == models.UserStatusSuspended && user.SuspendedUntil != nil && !now.Before(*user.SuspendedUntil)
+}
+
+func statusError(user *models.User) error {
+	switch user.Status {
+	case models.UserStatusInactive:
+		return ErrAccountInactive
+	case models.UserStatusSuspended:
+		e := &SuspensionError{Until: user.SuspendedUntil}
+		if user.StatusReason != nil {
+			e.Reason = *user.StatusReason
+		}
+		return e
+	}
+	return nil
+}
+
+func statusChangeLog(actorID, userID string, metadata map[string]interface{}) *models.ActivityLog {
+	data, _ := json.Marshal(metadata)
+	return &models.ActivityLog{
+		ActorID:    actorID,
+		Action:     "user.status_change",
+		TargetType: "user",
+		TargetID:   userID,
+		Metadata:   datatypes.JSON(data),
+	}
+}
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// UserAttributesManage is the permission to define attributes and to read,
+// filter on and write the values of private and admin attributes
+const UserAttributesManage = "users:attributes"
+
+var (
+	ErrAttributeNotFound = errors.New("attribute not found")
+	ErrAttributeExists   = errors.New("an attribute with this key already exists")
+	ErrInvalidDefinition = errors.New("invalid attribute definition")
+	ErrInvalidAttribute  = errors.New("invalid attribute value")
+	ErrAttributeDenied   = errors.New("not allowed to use this attribute")
+)
+
+// maxAttributeLength caps string attribute values
+const maxAttributeLength = 1000
+
+var attributeKeyPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+
+// AttributeInput carries definition fields; nil fields are left unchanged.
+// Key and Type are fixed once the attribute exists.
+type AttributeInput struct {
+	Key         *string
+	Label       *string
+	Description *string
+	Type        *models.AttributeType
+	Required    *bool
+	Options     []string
+	Visibility  *models.AttributeVisibility
+	Position    *int
+}
+
+type AttributeService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) AttributeService
+	List() ([]models.UserAttribute, error)
+	Get(id string) (*models.UserAttribute, error)
+	Create(input AttributeInput) (*models.UserAttribute, error)
+	Update(id string, input AttributeInput) (*models.UserAttribute, error)
+	// Delete removes the definition and its values from every user
+	Delete(id string) error
+
+	// Apply validates values and merges them into current. A nil value removes
+	// the attribute. With requireAll every required attribute must end up set;
+	// otherwise only clearing a required attribute is rejected. Only an admin,
+	// who holds UserAttributesManage, may write non-public attributes.
+	Apply(current datatypes.JSON, values map[string]interface{}, requireAll, admin bool) (datatypes.JSON, error)
+	// Parse converts the text form used in query strings and spreadsheets
+	Parse(key, raw string) (interface{}, error)
+	// ParseFilter converts text values to typed values for UserFilter.Attributes.
+	// Filtering on a non-public attribute would reveal its values, so only an
+	// admin may do it.
+	ParseFilter(raw map[string]interface{}, admin bool) (map[string]interface{}, error)
+	// Redact drops the attribute values viewerID may not read
+	Redact(users []models.User, viewerID string, admin bool)
+}
+
+type attributeService struct {
+	db *gorm.DB
+}
+
+func NewAttributeService(db *gorm.DB) AttributeService {
+	return &attributeService{db: db}
+}
+
+func (s *attributeService) WithContext(ctx context.Context) AttributeService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *attributeService) List() ([]models.UserAttribute, error) {
+	var attributes []models.UserAttribute
+	err := s.db.Order("position, key").Find(&attributes).Error
+	return attributes, err
+}
+
+func (s *attributeService) Get(id string) (*models.UserAttribute, error) {
+	var attribute models.UserAttribute
+	err := s.db.First(&attribute, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAttributeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attribute, nil
+}
+
+func (s *attributeService) Create(input AttributeInput) (*models.UserAttribute, error) {
+	if input.Key == nil || !attributeKeyPattern.MatchString(*input.Key) {
+		return nil, fmt.Errorf("%w: key must start with a letter and contain only letters, digits and underscores (max 64)", ErrInvalidDefinition)
+	}
+	if input.Type == nil {
+		return nil, fmt.Errorf("%w: type is required", ErrInvalidDefinition)
+	}
+	switch *input.Type {
+	case models.AttributeString, models.AttributeNumber, models.AttributeBoolean, models.AttributeDate, models.AttributeEnum:
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidDefinition, *input.Type)
+	}
+
+	var taken int64
+	s.db.Model(&models.UserAttribute{}).Where("LOWER(key) = LOWER(?)", *input.Key).Count(&taken)
+	if taken > 0 {
+		return nil, ErrAttributeExists
+	}
+
+	attribute := &models.UserAttribute{
+		Key:        *input.Key,
+		Label:      *input.Key,
+		Type:       *input.Type,
+		Visibility: models.AttributePublic,
+	}
+	input.Key, input.Type = nil, nil
+	if err := applyDefinition(attribute, input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(attribute).Error; err != nil {
+		return nil, err
+	}
+	return attribute, nil
+}
+
+func (s *attributeService) Update(id string, input AttributeInput) (*models.UserAttribute, error) {
+	attribute, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if input.Key != nil && *input.Key != attribute.Key {
+		return nil, fmt.Errorf("%w: key cannot be changed", ErrInvalidDefinition)
+	}
+	if input.Type != nil && *input.Type != attribute.Type {
+		return nil, fmt.Errorf("%w: type cannot be changed", ErrInvalidDefinition)
+	}
+	if err := applyDefinition(attribute, input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Save(attribute).Error; err != nil {
+		return nil, err
+	}
+	return attribute, nil
+}
+
+// applyDefinition copies the mutable fields of input onto attribute
+func applyDefinition(attribute *models.UserAttribute, input AttributeInput) error {
+	if input.Label != nil {
+		label := strings.TrimSpace(*input.Label)
+		if label == "" {
+			return fmt.Errorf("%w: label must not be empty", ErrInvalidDefinition)
+		}
+		attribute.Label = label
+	}
+	if input.Description != nil {
+		attribute.Description = optionalString(*input.Description)
+	}
+	if input.Required != nil {
+		attribute.Required = *input.Required
+	}
+	if input.Position != nil {
+		attribute.Position = *input.Position
+	}
+	if input.Visibility != nil {
+		switch *input.Visibility {
+		case models.AttributePublic, models.AttributePrivate, models.AttributeAdmin:
+			attribute.Visibility = *input.Visibility
+		default:
+			return fmt.Errorf("%w: unknown visibility %q", ErrInvalidDefinition, *input.Visibility)
+		}
+	}
+	if input.Options != nil {
+		attribute.Options = datatypes.NewJSONSlice(uniqueStrings(input.Options))
+	}
+
+	if attribute.Type == models.AttributeEnum {
+		if len(attribute.Options) == 0 {
+			return fmt.Errorf("%w: an enum needs at least one option", ErrInvalidDefinition)
+		}
+	} else if len(attribute.Options) > 0 {
+		return fmt.Errorf("%w: options only apply to enums", ErrInvalidDefinition)
+	}
+	return nil
+}
+
+func (s *attributeService) Delete(id string) error {
+	attribute, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Model(&models.User{}).
+			Where("attributes IS NOT NULL").
+			Update("attributes", gorm.Expr("attributes - ?", attribute.Key)).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(attribute).Error
+	})
+}
+
+func (s *attributeService) definitions() (map[string]models.UserAttribute, error) {
+	attributes, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return indexAttributes(attributes), nil
+}
+
+func indexAttributes(attributes []models.UserAttribute) map[string]models.UserAttribute {
+	defs := make(map[string]models.UserAttribute, len(attributes))
+	for _, a := range attributes {
+		defs[a.Key] = a
+	}
+	return defs
+}
+
+func (s *attributeService) Apply(current datatypes.JSON, values map[string]interface{}, requireAll, admin bool) (datatypes.JSON, error) {
+	defs, err := s.definitions()
+	if err != nil {
+		return nil, err
+	}
+	return applyAttributes(defs, current, values, requireAll, admin)
+}
+
+// applyAttributes implements Apply against already loaded definitions
+func applyAttributes(defs map[string]models.UserAttribute, current datatypes.JSON, values map[string]interface{}, requireAll, admin bool) (datatypes.JSON, error) {
+	merged := map[string]interface{}{}
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &merged); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range values {
+		def, ok := defs[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown attribute %q", ErrInvalidAttribute, key)
+		}
+		if def.Visibility != models.AttributePublic && !admin {
+			return nil, fmt.Errorf("%w: %s", ErrAttributeDenied, key)
+		}
+		if text, ok := value.(string); ok && strings.TrimSpace(text) == "" {
+			value = nil
+		}
+		if value == nil {
+			if def.Required {
+				return nil, fmt.Errorf("%w: %s is required", ErrInvalidAttribute, key)
+			}
+			delete(merged, key)
+			continue
+		}
+		normalized, err := normalizeAttribute(def, value)
+		if err != nil {
+			return nil, err
+		}
+		merged[key] = normalized
+	}
+
+	if requireAll {
+		for key, def := range defs {
+			if _, ok := merged[key]; def.Required && !ok {
+				return nil, fmt.Errorf("%w: %s is required", ErrInvalidAttribute, key)
+			}
+		}
+	}
+
+	if len(merged) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
+
+func (s *attributeService) Parse(key, raw string) (interface{}, error) {
+	defs, err := s.definitions()
+	if err != nil {
+		return nil, err
+	}
+	def, ok := defs[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown attribute %q", ErrInvalidAttribute, key)
+	}
+	return parseAttribute(def, raw)
+}
+
+func (s *attributeService) ParseFilter(raw map[string]interface{}, admin bool) (map[string]interface{}, error) {
+	if len(raw) == 0 {
+		return raw, nil
+	}
+	defs, err := s.definitions()
+	if err != nil {
+		return nil, err
+	}
+	typed := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		def, ok := defs[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown attribute %q", ErrInvalidAttribute, key)
+		}
+		if def.Visibility != models.AttributePublic && !admin {
+			return nil, fmt.Errorf("%w: %s", ErrAttributeDenied, key)
+		}
+		text, isText := value.(string)
+		if !isText {
+			typed[key] = value
+			continue
+		}
+		if typed[key], err = parseAttribute(def, text); err != nil {
+			return nil, err
+		}
+	}
+	return typed, nil
+}
+
+func (s *attributeService) Redact(users []models.User, viewerID string, admin bool) {
+	if admin {
+		return
+	}
+	defs, err := s.definitions()
+	if err != nil {
+		// Fail closed: without definitions nothing is known to be public
+		for i := range users {
+			users[i].Attributes = nil
+		}
+		return
+	}
+
+	for i := range users {
+		u := &users[i]
+		if len(u.Attributes) == 0 {
+			continue
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal(u.Attributes, &values); err != nil {
+			u.Attributes = nil
+			continue
+		}
+		for key := range values {
+			def, ok := defs[key]
+			switch {
+			case !ok, def.Visibility == models.AttributeAdmin:
+				delete(values, key)
+			case def.Visibility == models.AttributePrivate && u.ID != viewerID:
+				delete(values, key)
+			}
+		}
+		u.Attributes = nil
+		if len(values) > 0 {
+			data, _ := json.Marshal(values)
+			u.Attributes = datatypes.JSON(data)
+		}
+	}
+}
+
+// normalizeAttribute checks a JSON-decoded value against its definition
+func normalizeAttribute(def models.UserAttribute, value interface{}) (interface{}, error) {
+	invalid := func(expected string) error {
+		return fmt.Errorf("%w: %s must be %s", ErrInvalidAttribute, def.Key, expected)
+	}
+
+	switch def.Type {
+	case models.AttributeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case json.Number:
+			return v.Float64()
+		}
+		return nil, invalid("a number")
+	case models.AttributeBoolean:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, invalid("a boolean")
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return nil, invalid("a string")
+	}
+	return parseAttribute(def, text)
+}
+
+// parseAttribute converts the text form of a value according to its definition
+func parseAttribute(def models.UserAttribute, raw string) (interface{}, error) {
+	raw = strings.TrimSpace(raw)
+	invalid := func(expected string) error {
+		return fmt.Errorf("%w: %s must be %s", ErrInvalidAttribute, def.Key, expected)
+	}
+
+	switch def.Type {
+	case models.AttributeNumber:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, invalid("a number")
+		}
+		return v, nil
+	case models.AttributeBoolean:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, invalid("a boolean")
+		}
+		return v, nil
+	case models.AttributeDate:
+		if _, err := time.Parse("2006-01-02", raw); err != nil {
+			return nil, invalid("a date (YYYY-MM-DD)")
+		}
+		return raw, nil
+	case models.AttributeEnum:
+		for _, option := range def.Options {
+			if strings.EqualFold(option, raw) {
+				return option, nil
+			}
+		}
+		return nil, invalid("one of " + strings.Join(def.Options, ", "))
+	}
+
+	if len(raw) > maxAttributeLength {
+		return nil, invalid(fmt.Sprintf("at most %d characters", maxAttributeLength))
+	}
+	return raw, nil
+}
+
+// formatAttribute renders a stored value in the text form parseAttribute accepts
+func formatAttribute(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(value)
+}
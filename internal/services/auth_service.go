@@ -6,6 +6,7 @@ import (
 
 	"github.com/halolight/halolight-api-go/internal/models"
 	"github.com/halolight/halolight-api-go/internal/repository"
+	"github.com/halolight/halolight-api-go/internal/tenant"
 	"github.com/halolight/halolight-api-go/pkg/config"
 	"github.com/halolight/halolight-api-go/pkg/utils"
 )
@@ -24,12 +25,13 @@ type AuthService interface {
 }
 
 type authService struct {
-	cfg  config.Config
-	repo repository.UserRepository
+	cfg    config.Config
+	repo   repository.UserRepository
+	status AccountStatusService
 }
 
-func NewAuthService(cfg config.Config, repo repository.UserRepository) AuthService {
-	return &authService{cfg: cfg, repo: repo}
+func NewAuthService(cfg config.Config, repo repository.UserRepository, status AccountStatusService) AuthService {
+	return &authService{cfg: cfg, repo: repo, status: status}
 }
 
 func (s *authService) Register(email, username, password string) (*models.User, string, error) {
@@ -66,8 +68,9 @@ func (s *authService) Register(email, username, password string) (*models.User,
 		return nil, "", err
 	}
 
-	// Create user
+	// Self-registered users join the default organization
 	user := &models.User{
+		TenantID: tenant.DefaultID,
 		Email:    email,
 		Username: username,
 		Password: hash,
@@ -81,7 +84,7 @@ func (s *authService) Register(email, username, password string) (*models.User,
 	}
 
 	// Generate JWT token
-	token, err := utils.GenerateAccessToken(user.ID, s.cfg.JWTSecret)
+	token, err := utils.GenerateAccessToken(user.ID, user.TenantID, s.cfg.JWTSecret)
 	if err != nil {
 		return nil, "", err
 	}
@@ -107,8 +110,13 @@ func (s *authService) Login(email, password string) (*models.User, string, error
 		return nil, "", ErrInvalidCredentials
 	}
 
+	// Inactive and suspended accounts cannot sign in
+	if err := s.status.CheckUser(user); err != nil {
+		return nil, "", err
+	}
+
 	// Generate JWT token
-	token, err := utils.GenerateAccessToken(user.ID, s.cfg.JWTSecret)
+	token, err := utils.GenerateAccessToken(user.ID, user.TenantID, s.cfg.JWTSecret)
 	if err != nil {
 		return nil, "", err
 	}
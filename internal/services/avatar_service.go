@@ -0,0 +1,153 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/imaging"
+	"github.com/halolight/halolight-api-go/pkg/storage"
+	"gorm.io/gorm"
+)
+
+const (
+	// AvatarMaxBytes caps the size of an uploaded avatar image
+	AvatarMaxBytes = 5 << 20
+	// avatarMaxPixels rejects decompression bombs before their pixels are allocated
+	avatarMaxPixels = 40_000_000
+)
+
+// AvatarSizes are the square thumbnail edges generated for every avatar; the
+// first one is stored in the Avatar field
+var AvatarSizes = []int{256, 128, 64}
+
+var (
+	ErrAvatarTooLarge    = fmt.Errorf("avatar exceeds %dMB", AvatarMaxBytes>>20)
+	ErrAvatarUnsupported = errors.New("avatar must be a JPEG, PNG, GIF or WebP image")
+	ErrAvatarDimensions  = errors.New("avatar dimensions are too large")
+	ErrAvatarInvalid     = errors.New("avatar image could not be decoded")
+	ErrTeamNotFound      = errors.New("team not found")
+)
+
+// Avatar lists the public URLs of the generated thumbnails keyed by edge length
+type Avatar struct {
+	URL   string            `json:"url"`
+	Sizes map[string]string `json:"sizes"`
+}
+
+type AvatarService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) AvatarService
+	SetUserAvatar(userID string, r io.Reader) (*Avatar, error)
+	SetTeamAvatar(teamID string, r io.Reader) (*Avatar, error)
+}
+
+type avatarService struct {
+	db    *gorm.DB
+	store storage.Storage
+}
+
+func NewAvatarService(db *gorm.DB, store storage.Storage) AvatarService {
+	return &avatarService{db: db, store: store}
+}
+
+func (s *avatarService) WithContext(ctx context.Context) AvatarService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *avatarService) SetUserAvatar(userID string, r io.Reader) (*Avatar, error) {
+	var user models.User
+	if err := s.db.Select("id").First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	avatar, err := s.process(r, "avatars/users/"+userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&user).Update("avatar", avatar.URL).Error; err != nil {
+		return nil, err
+	}
+	return avatar, nil
+}
+
+func (s *avatarService) SetTeamAvatar(teamID string, r io.Reader) (*Avatar, error) {
+	var team models.Team
+	if err := s.db.Select("id").First(&team, "id = ?", teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTeamNotFound
+		}
+		return nil, err
+	}
+
+	avatar, err := s.process(r, "avatars/teams/"+teamID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&team).Update("avatar", avatar.URL).Error; err != nil {
+		return nil, err
+	}
+	return avatar, nil
+}
+
+// process validates the upload, renders every thumbnail size below prefix and
+// returns cache-busted URLs so clients pick up the new image immediately
+func (s *avatarService) process(r io.Reader, prefix string) (*Avatar, error) {
+	data, err := io.ReadAll(io.LimitReader(r, AvatarMaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > AvatarMaxBytes {
+		return nil, ErrAvatarTooLarge
+	}
+
+	img, err := imaging.Decode(data, avatarMaxPixels)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return nil, ErrAvatarUnsupported
+	case errors.Is(err, imaging.ErrTooManyPixels):
+		return nil, ErrAvatarDimensions
+	case err != nil:
+		return nil, ErrAvatarInvalid
+	}
+
+	version := strings.ToLower(models.GenerateULID())
+	avatar := &Avatar{Sizes: make(map[string]string, len(AvatarSizes))}
+	for i, size := range AvatarSizes {
+		var buf bytes.Buffer
+		ext, err := imaging.Encode(&buf, imaging.SquareThumbnail(img, size))
+		if err != nil {
+			return nil, err
+		}
+
+		name := fmt.Sprintf("%s/%d", prefix, size)
+		if err := s.store.Put(name+"."+ext, &buf); err != nil {
+			return nil, err
+		}
+		// Drop the thumbnail left behind by a previous upload in the other format
+		stale := "png"
+		if ext == "png" {
+			stale = "jpg"
+		}
+		if err := s.store.Delete(name + "." + stale); err != nil {
+			return nil, err
+		}
+
+		url := s.store.URL(name+"."+ext) + "?v=" + version
+		avatar.Sizes[strconv.Itoa(size)] = url
+		if i == 0 {
+			avatar.URL = url
+		}
+	}
+	return avatar, nil
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"time"
 
 	"github.com/halolight/halolight-api-go/internal/models"
@@ -8,6 +9,8 @@ import (
 )
 
 type CalendarService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) CalendarService
 	List(userID string, startDate, endDate *time.Time) ([]models.CalendarEvent, error)
 	Get(id string) (*models.CalendarEvent, error)
 	Create(title, description, location string, startAt, endAt time.Time, eventType, color string, allDay bool, ownerID string, attendeeIDs []string) (*models.CalendarEvent, error)
@@ -28,6 +31,12 @@ func NewCalendarService(db *gorm.DB) CalendarService {
 	return &calendarService{db: db}
 }
 
+func (s *calendarService) WithContext(ctx context.Context) CalendarService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
 func (s *calendarService) List(userID string, startDate, endDate *time.Time) ([]models.CalendarEvent, error) {
 	var events []models.CalendarEvent
 
@@ -66,6 +75,9 @@ func (s *calendarService) Create(title, description, location string, startAt, e
 		AllDay:      allDay,
 		OwnerID:     ownerID,
 	}
+	if err := ensureUsers(s.db, attendeeIDs); err != nil {
+		return nil, err
+	}
 
 	err := s.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(event).Error; err != nil {
@@ -139,6 +151,9 @@ func (s *calendarService) Reschedule(id string, startAt, endAt time.Time) (*mode
 }
 
 func (s *calendarService) AddAttendee(eventID, userID string) (*models.EventAttendee, error) {
+	if err := ensureUsers(s.db, []string{userID}); err != nil {
+		return nil, err
+	}
 	attendee := &models.EventAttendee{
 		EventID: eventID,
 		UserID:  userID,
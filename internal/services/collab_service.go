@@ -0,0 +1,469 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/ot"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCollabAccessDenied = errors.New("access denied")
+	ErrCollabReadOnly     = errors.New("you may view this document but not edit it")
+	ErrCollabStale        = errors.New("revision is no longer available, reload the document")
+	ErrCollabTooLarge     = errors.New("document is too large for live editing")
+	ErrCollabMessage      = errors.New("invalid message")
+)
+
+// Live editing message types. Clients send op and cursor messages; the
+// server sends every type.
+const (
+	CollabTypeInit   = "init"
+	CollabTypeOp     = "op"
+	CollabTypeAck    = "ack"
+	CollabTypeCursor = "cursor"
+	CollabTypeJoin   = "join"
+	CollabTypeLeave  = "leave"
+	CollabTypeError  = "error"
+)
+
+const (
+	// How often a room writes its text back to the document
+	collabSaveInterval = 10 * time.Second
+	// How long an edit permission check is trusted
+	collabAccessTTL = 30 * time.Second
+	// Applied operations kept for transforming operations sent against older
+	// revisions
+	collabHistory = 500
+	// Longest text a room accepts, in UTF-16 code units
+	collabMaxLength = 1 << 21
+	// Messages queued for a client before it is dropped as too slow
+	collabOutbox = 256
+)
+
+// CollabCursor is a caret position, or a selection when SelectionEnd differs
+// from Position, in UTF-16 code units
+type CollabCursor struct {
+	Position     int `json:"position"`
+	SelectionEnd int `json:"selectionEnd"`
+}
+
+// CollabPeer is a client connected to a live session
+type CollabPeer struct {
+	ClientID string        `json:"clientId"`
+	UserID   string        `json:"userId"`
+	Name     string        `json:"name"`
+	Avatar   *string       `json:"avatar,omitempty"`
+	CanEdit  bool          `json:"canEdit"`
+	Cursor   *CollabCursor `json:"cursor,omitempty"`
+}
+
+// CollabMessage is exchanged over a live editing connection.
+//
+// Revision counts the operations applied since the session opened. Clients
+// send an op along with the revision it was made against and receive an ack
+// carrying the revision it became; ops of other clients arrive with the
+// revision they produced.
+type CollabMessage struct {
+	Type     string        `json:"type"`
+	Revision int           `json:"revision"`
+	Op       *ot.Operation `json:"op,omitempty"`
+	Cursor   *CollabCursor `json:"cursor,omitempty"`
+	ClientID string        `json:"clientId,omitempty"`
+	Content  *string       `json:"content,omitempty"`
+	Peer     *CollabPeer   `json:"peer,omitempty"`
+	Peers    []CollabPeer  `json:"peers,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// CollabService runs live editing sessions. A document open for live editing
+// has one room holding its current text. Operations sent against an older
+// revision are transformed past the operations applied since, then applied
+// and broadcast. Rooms live in memory, so all clients of a document must
+// reach the same server instance.
+type CollabService interface {
+	// Join connects userID to the live session of a document, opening the
+	// session when they are the first. ctx must carry the organization.
+	Join(ctx context.Context, docID, userID string) (*CollabClient, error)
+	// Editing reports whether a document has a live session with clients
+	Editing(docID string) bool
+}
+
+type collabService struct {
+	db   *gorm.DB
+	docs DocumentService
+
+	mu    sync.Mutex
+	rooms map[string]*collabRoom
+}
+
+func NewCollabService(db *gorm.DB, docs DocumentService) CollabService {
+	return &collabService{db: db, docs: docs, rooms: make(map[string]*collabRoom)}
+}
+
+type collabRoom struct {
+	svc   *collabService
+	docID string
+	// docs is bound to the document's organization
+	docs DocumentService
+
+	mu      sync.Mutex
+	text    []uint16
+	base    int // revision before history[0]
+	history []*ot.Operation
+	clients map[string]*CollabClient
+	dirty   bool
+	author  string // last editor since the previous save
+}
+
+// CollabClient is one connection to a live session. Messages for it arrive
+// on Outbox, which is closed once the client has left or was dropped for
+// falling too far behind.
+type CollabClient struct {
+	room    *collabRoom
+	peer    CollabPeer
+	outbox  chan CollabMessage
+	closed  bool
+	checked time.Time
+}
+
+func (s *collabService) Join(ctx context.Context, docID, userID string) (*CollabClient, error) {
+	docs := s.docs.WithContext(ctx)
+	if !docs.HasAccess(docID, userID) {
+		return nil, ErrCollabAccessDenied
+	}
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("id", "name", "avatar").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	client := &CollabClient{
+		peer: CollabPeer{
+			ClientID: models.GenerateULID(),
+			UserID:   user.ID,
+			Name:     user.Name,
+			Avatar:   user.Avatar,
+			CanEdit:  docs.CanEdit(docID, userID),
+		},
+		outbox:  make(chan CollabMessage, collabOutbox),
+		checked: time.Now(),
+	}
+
+	s.mu.Lock()
+	room, ok := s.rooms[docID]
+	if !ok {
+		tenantID, _ := tenant.FromContext(ctx)
+		var err error
+		if room, err = s.openRoom(tenant.WithID(context.Background(), tenantID), docID); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+		s.rooms[docID] = room
+		go room.run()
+	}
+	room.mu.Lock()
+	s.mu.Unlock()
+	defer room.mu.Unlock()
+
+	client.room = room
+	content := ot.String(room.text)
+	self := client.peer
+	client.deliver(CollabMessage{
+		Type:     CollabTypeInit,
+		Revision: room.revision(),
+		ClientID: client.peer.ClientID,
+		Content:  &content,
+		Peer:     &self,
+		Peers:    room.peers(),
+	})
+	room.clients[client.peer.ClientID] = client
+	room.broadcast(CollabMessage{Type: CollabTypeJoin, Revision: room.revision(), Peer: &self}, client)
+	return client, nil
+}
+
+func (s *collabService) openRoom(ctx context.Context, docID string) (*collabRoom, error) {
+	var doc models.Document
+	if err := s.db.WithContext(ctx).Select("id", "content").First(&doc, "id = ?", docID).Error; err != nil {
+		return nil, err
+	}
+	text := ot.Text(doc.Content)
+	if len(text) > collabMaxLength {
+		return nil, ErrCollabTooLarge
+	}
+	return &collabRoom{
+		svc:     s,
+		docID:   docID,
+		docs:    s.docs.WithContext(ctx),
+		text:    text,
+		clients: make(map[string]*CollabClient),
+	}, nil
+}
+
+func (s *collabService) Editing(docID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room, ok := s.rooms[docID]
+	if !ok {
+		return false
+	}
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	return len(room.clients) > 0
+}
+
+// closeIdle removes the room once it has no clients and nothing left to save
+func (s *collabService) closeIdle(room *collabRoom) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if len(room.clients) > 0 || room.dirty {
+		return false
+	}
+	delete(s.rooms, room.docID)
+	return true
+}
+
+// run saves the room periodically until it is closed
+func (r *collabRoom) run() {
+	ticker := time.NewTicker(collabSaveInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.save()
+		if r.svc.closeIdle(r) {
+			return
+		}
+	}
+}
+
+func (r *collabRoom) save() {
+	r.mu.Lock()
+	if !r.dirty {
+		r.mu.Unlock()
+		return
+	}
+	content, author := ot.String(r.text), r.author
+	r.dirty = false
+	r.mu.Unlock()
+
+	err := r.docs.SaveContent(r.docID, content, author)
+	if err == nil {
+		return
+	}
+	log.Printf("collab save %s: %v", r.docID, err)
+	// A deleted document cannot be saved; give up instead of retrying forever
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		r.mu.Lock()
+		r.dirty = true
+		r.mu.Unlock()
+	}
+}
+
+// The methods below expect r.mu to be held
+
+func (r *collabRoom) revision() int {
+	return r.base + len(r.history)
+}
+
+func (r *collabRoom) peers() []CollabPeer {
+	peers := make([]CollabPeer, 0, len(r.clients))
+	for _, client := range r.clients {
+		peers = append(peers, client.peer)
+	}
+	return peers
+}
+
+func (r *collabRoom) broadcast(msg CollabMessage, except *CollabClient) {
+	for _, client := range r.clients {
+		if client != except {
+			client.deliver(msg)
+		}
+	}
+}
+
+func (r *collabRoom) remove(client *CollabClient) {
+	if client.closed {
+		return
+	}
+	client.closed = true
+	close(client.outbox)
+	delete(r.clients, client.peer.ClientID)
+	r.broadcast(CollabMessage{Type: CollabTypeLeave, Revision: r.revision(), ClientID: client.peer.ClientID}, nil)
+}
+
+// since returns the operations applied after revision, or false when they
+// are no longer kept
+func (r *collabRoom) since(revision int) ([]*ot.Operation, bool) {
+	if revision < r.base || revision > r.revision() {
+		return nil, false
+	}
+	return r.history[revision-r.base:], true
+}
+
+func (r *collabRoom) apply(client *CollabClient, msg CollabMessage) error {
+	if !client.peer.CanEdit {
+		return ErrCollabReadOnly
+	}
+	if msg.Op == nil {
+		return ot.ErrInvalidOperation
+	}
+	concurrent, ok := r.since(msg.Revision)
+	if !ok {
+		return ErrCollabStale
+	}
+	op := msg.Op
+	for _, applied := range concurrent {
+		var err error
+		if op, _, err = ot.Transform(op, applied); err != nil {
+			return err
+		}
+	}
+	if op.TargetLength > collabMaxLength {
+		return ErrCollabTooLarge
+	}
+	text, err := op.Apply(r.text)
+	if err != nil {
+		return err
+	}
+
+	r.text = text
+	r.history = append(r.history, op)
+	if drop := len(r.history) - collabHistory; drop > 0 {
+		r.history = r.history[drop:]
+		r.base += drop
+	}
+	if !op.IsNoop() {
+		r.dirty = true
+		r.author = client.peer.UserID
+	}
+	// Cursors are replaced, never modified, as queued messages share them
+	for _, other := range r.clients {
+		if other.peer.Cursor != nil {
+			moved := CollabCursor{
+				Position:     op.TransformIndex(other.peer.Cursor.Position),
+				SelectionEnd: op.TransformIndex(other.peer.Cursor.SelectionEnd),
+			}
+			other.peer.Cursor = &moved
+		}
+	}
+
+	client.deliver(CollabMessage{Type: CollabTypeAck, Revision: r.revision()})
+	r.broadcast(CollabMessage{Type: CollabTypeOp, Revision: r.revision(), Op: op, ClientID: client.peer.ClientID}, client)
+	return nil
+}
+
+func (r *collabRoom) moveCursor(client *CollabClient, msg CollabMessage) error {
+	if msg.Cursor == nil {
+		return ErrCollabMessage
+	}
+	concurrent, ok := r.since(msg.Revision)
+	if !ok {
+		// The client will send a fresh cursor once it catches up
+		return nil
+	}
+	cursor := *msg.Cursor
+	for _, applied := range concurrent {
+		cursor.Position = applied.TransformIndex(cursor.Position)
+		cursor.SelectionEnd = applied.TransformIndex(cursor.SelectionEnd)
+	}
+	cursor.Position = clampIndex(cursor.Position, len(r.text))
+	cursor.SelectionEnd = clampIndex(cursor.SelectionEnd, len(r.text))
+
+	client.peer.Cursor = &cursor
+	r.broadcast(CollabMessage{Type: CollabTypeCursor, Revision: r.revision(), ClientID: client.peer.ClientID, Cursor: &cursor}, client)
+	return nil
+}
+
+func clampIndex(index, length int) int {
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+// Outbox delivers the messages for the client
+func (c *CollabClient) Outbox() <-chan CollabMessage {
+	return c.outbox
+}
+
+// Handle processes a message received from the client. Problems are reported
+// back to the client as error messages.
+func (c *CollabClient) Handle(data []byte) {
+	var msg CollabMessage
+	err := json.Unmarshal(data, &msg)
+	if err != nil && !errors.Is(err, ot.ErrInvalidOperation) {
+		err = ErrCollabMessage
+	}
+	if err == nil && msg.Type == CollabTypeOp {
+		c.refreshAccess()
+	}
+
+	r := c.room
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.closed {
+		return
+	}
+	if err == nil {
+		switch msg.Type {
+		case CollabTypeOp:
+			err = r.apply(c, msg)
+		case CollabTypeCursor:
+			err = r.moveCursor(c, msg)
+		default:
+			err = ErrCollabMessage
+		}
+	}
+	if err != nil {
+		c.deliver(CollabMessage{Type: CollabTypeError, Revision: r.revision(), Error: err.Error()})
+	}
+}
+
+// Leave disconnects the client from the session
+func (c *CollabClient) Leave() {
+	c.room.mu.Lock()
+	defer c.room.mu.Unlock()
+	c.room.remove(c)
+}
+
+// refreshAccess re-checks the client's permissions once the last check is
+// older than collabAccessTTL, dropping clients that lost access altogether
+func (c *CollabClient) refreshAccess() {
+	if time.Since(c.checked) < collabAccessTTL {
+		return
+	}
+	c.checked = time.Now()
+	r := c.room
+	canEdit := r.docs.CanEdit(r.docID, c.peer.UserID)
+	canView := canEdit || r.docs.HasAccess(r.docID, c.peer.UserID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !canView {
+		r.remove(c)
+		return
+	}
+	c.peer.CanEdit = canEdit
+}
+
+// deliver queues msg for the client, dropping the client when its queue is
+// full so a slow connection cannot hold up the room. r.mu must be held.
+func (c *CollabClient) deliver(msg CollabMessage) {
+	if c.closed {
+		return
+	}
+	select {
+	case c.outbox <- msg:
+	default:
+		c.room.remove(c)
+	}
+}
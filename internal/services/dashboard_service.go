@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"math/rand"
 	"runtime"
 	"time"
@@ -10,6 +11,8 @@ import (
 )
 
 type DashboardService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) DashboardService
 	GetStats() (*DashboardStats, error)
 	GetVisits() []VisitData
 	GetSales() []SalesData
@@ -125,6 +128,12 @@ func NewDashboardService(db *gorm.DB) DashboardService {
 	return &dashboardService{db: db, startTime: time.Now()}
 }
 
+func (s *dashboardService) WithContext(ctx context.Context) DashboardService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
 func (s *dashboardService) GetStats() (*DashboardStats, error) {
 	var stats DashboardStats
 
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/ot"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCommentNotFound    = errors.New("comment not found")
+	ErrCommentAnchor      = errors.New("anchor must be a range within the document content")
+	ErrCommentReplyAnchor = errors.New("replies cannot be anchored")
+	ErrCommentNotThread   = errors.New("only a thread's first comment can be resolved or reopened")
+)
+
+// Comment filters for List
+const (
+	CommentsAll      = ""
+	CommentsOpen     = "open"
+	CommentsResolved = "resolved"
+)
+
+// mentionPattern finds @username mentions; the @ must not follow a word
+// character, so email addresses are not mistaken for mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_.\-]+[A-Za-z0-9_])`)
+
+// CommentAnchor is a range of the document content in UTF-16 code units
+type CommentAnchor struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+type DocumentCommentService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) DocumentCommentService
+	// List returns a document's threads, oldest first, with their replies.
+	// filter is one of CommentsAll, CommentsOpen and CommentsResolved.
+	List(docID, filter string) ([]models.DocumentComment, error)
+	Get(docID, commentID string) (*models.DocumentComment, error)
+	// Create adds a comment. With parentID it replies to that comment's
+	// thread, otherwise it opens a thread, anchored when anchor is set.
+	// Mentioned users who can view the document are notified.
+	Create(docID, authorID, content string, parentID *string, anchor *CommentAnchor) (*models.DocumentComment, error)
+	// Update replaces a comment's content, notifying users newly mentioned
+	Update(docID, commentID, content string) (*models.DocumentComment, error)
+	Resolve(docID, commentID, userID string) (*models.DocumentComment, error)
+	Reopen(docID, commentID string) (*models.DocumentComment, error)
+	// Delete removes a comment; deleting a thread's first comment removes
+	// the whole thread
+	Delete(docID, commentID string) error
+}
+
+type documentCommentService struct {
+	db            *gorm.DB
+	docs          DocumentService
+	notifications NotificationService
+}
+
+func NewDocumentCommentService(db *gorm.DB, docs DocumentService, notifications NotificationService) DocumentCommentService {
+	return &documentCommentService{db: db, docs: docs, notifications: notifications}
+}
+
+func (s *documentCommentService) WithContext(ctx context.Context) DocumentCommentService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.docs = s.docs.WithContext(ctx)
+	return &clone
+}
+
+func (s *documentCommentService) List(docID, filter string) ([]models.DocumentComment, error) {
+	query := s.db.Where("document_id = ? AND parent_id IS NULL", docID)
+	switch filter {
+	case CommentsOpen:
+		query = query.Where("resolved_at IS NULL")
+	case CommentsResolved:
+		query = query.Where("resolved_at IS NOT NULL")
+	}
+
+	threads := []models.DocumentComment{}
+	err := query.Preload("Author").Preload("ResolvedBy").
+		Preload("Replies", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Replies.Author").
+		Order("created_at ASC").Find(&threads).Error
+	return threads, err
+}
+
+func (s *documentCommentService) Get(docID, commentID string) (*models.DocumentComment, error) {
+	var comment models.DocumentComment
+	err := s.db.Preload("Author").Preload("ResolvedBy").
+		Preload("Replies", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") }).
+		Preload("Replies.Author").
+		First(&comment, "id = ? AND document_id = ?", commentID, docID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCommentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (s *documentCommentService) Create(docID, authorID, content string, parentID *string, anchor *CommentAnchor) (*models.DocumentComment, error) {
+	var doc models.Document
+	if err := s.db.First(&doc, "id = ?", docID).Error; err != nil {
+		return nil, err
+	}
+
+	comment := &models.DocumentComment{
+		DocumentID: docID,
+		AuthorID:   &authorID,
+		Content:    content,
+	}
+	if parentID != nil && *parentID != "" {
+		if anchor != nil {
+			return nil, ErrCommentReplyAnchor
+		}
+		parent, err := s.Get(docID, *parentID)
+		if err != nil {
+			return nil, err
+		}
+		// Replies to a reply join the same thread
+		threadID := parent.ID
+		if parent.ParentID != nil {
+			threadID = *parent.ParentID
+		}
+		comment.ParentID = &threadID
+	} else if anchor != nil {
+		text := ot.Text(doc.Content)
+		if anchor.Start < 0 || anchor.End < anchor.Start || anchor.End > len(text) {
+			return nil, ErrCommentAnchor
+		}
+		comment.AnchorStart = &anchor.Start
+		comment.AnchorEnd = &anchor.End
+		comment.Quote = ot.String(text[anchor.Start:anchor.End])
+	}
+
+	if err := s.db.Create(comment).Error; err != nil {
+		return nil, err
+	}
+	s.notifyMentions(&doc, comment, nil)
+	return s.Get(docID, comment.ID)
+}
+
+func (s *documentCommentService) Update(docID, commentID, content string) (*models.DocumentComment, error) {
+	comment, err := s.Get(docID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	previous := comment.Content
+
+	now := time.Now()
+	if err := s.db.Model(comment).Updates(map[string]interface{}{
+		"content":   content,
+		"edited_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	comment.Content = content
+
+	var doc models.Document
+	if err := s.db.First(&doc, "id = ?", docID).Error; err == nil {
+		s.notifyMentions(&doc, comment, mentions(previous))
+	}
+	return s.Get(docID, commentID)
+}
+
+func (s *documentCommentService) Resolve(docID, commentID, userID string) (*models.DocumentComment, error) {
+	return s.setResolved(docID, commentID, map[string]interface{}{
+		"resolved_at":    time.Now(),
+		"resolved_by_id": userID,
+	})
+}
+
+func (s *documentCommentService) Reopen(docID, commentID string) (*models.DocumentComment, error) {
+	return s.setResolved(docID, commentID, map[string]interface{}{
+		"resolved_at":    nil,
+		"resolved_by_id": nil,
+	})
+}
+
+func (s *documentCommentService) setResolved(docID, commentID string, updates map[string]interface{}) (*models.DocumentComment, error) {
+	comment, err := s.Get(docID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.ParentID != nil {
+		return nil, ErrCommentNotThread
+	}
+	if err := s.db.Model(comment).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(docID, commentID)
+}
+
+func (s *documentCommentService) Delete(docID, commentID string) error {
+	comment, err := s.Get(docID, commentID)
+	if err != nil {
+		return err
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("parent_id = ?", comment.ID).Delete(&models.DocumentComment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.DocumentComment{}, "id = ?", comment.ID).Error
+	})
+}
+
+// mentions returns the usernames mentioned in content
+func mentions(content string) map[string]bool {
+	found := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		found[strings.ToLower(match[1])] = true
+	}
+	return found
+}
+
+// notifyMentions notifies the users mentioned in comment, except its author,
+// those in skip and those who cannot view the document. Failures are logged;
+// the comment itself has been saved.
+func (s *documentCommentService) notifyMentions(doc *models.Document, comment *models.DocumentComment, skip map[string]bool) {
+	var usernames []string
+	for username := range mentions(comment.Content) {
+		if !skip[username] {
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 {
+		return
+	}
+
+	var users []models.User
+	if err := s.db.Where("LOWER(username) IN ?", usernames).Find(&users).Error; err != nil {
+		log.Printf("comment mentions: %v", err)
+		return
+	}
+
+	var author models.User
+	if comment.AuthorID != nil {
+		s.db.Select("name", "email").First(&author, "id = ?", *comment.AuthorID)
+	}
+	who := author.Name
+	if who == "" {
+		who = author.Email
+	}
+	link := "/documents/" + doc.ID
+	payload := map[string]string{"documentId": doc.ID, "commentId": comment.ID}
+
+	for _, user := range users {
+		if comment.AuthorID != nil && user.ID == *comment.AuthorID {
+			continue
+		}
+		if !s.docs.HasAccess(doc.ID, user.ID) {
+			continue
+		}
+		content := fmt.Sprintf("%s mentioned you in a comment on %q: %s", who, doc.Title, excerpt(comment.Content, 200))
+		if _, err := s.notifications.Notify(user.ID, "user", "You were mentioned in a comment", content, &link, payload); err != nil {
+			log.Printf("comment mention for %s: %v", user.ID, err)
+		}
+	}
+}
+
+// excerpt shortens s to at most n runes
+func excerpt(s string, n int) string {
+	runes := []rune(strings.TrimSpace(s))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n]) + "…"
+}
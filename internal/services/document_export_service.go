@@ -0,0 +1,377 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/docx"
+	"github.com/halolight/halolight-api-go/pkg/imaging"
+	"github.com/halolight/halolight-api-go/pkg/markup"
+	"github.com/halolight/halolight-api-go/pkg/pdf"
+	"gorm.io/gorm"
+)
+
+// Export formats
+const (
+	ExportMarkdown = "md"
+	ExportHTML     = "html"
+	ExportPDF      = "pdf"
+	ExportDOCX     = "docx"
+)
+
+var (
+	ErrExportFormat   = errors.New("format must be md, html, pdf or docx")
+	ErrExportTemplate = errors.New("export template not found")
+)
+
+// ExportTemplate is the page furniture and branding of exported documents,
+// read from <name>.json in the template directory. Its text may contain the
+// {{title}}, {{author}}, {{organization}} and {{date}} placeholders, and the
+// header and footer also {{page}} and {{pages}}.
+type ExportTemplate struct {
+	Header     string `json:"header"`
+	Footer     string `json:"footer"`
+	BrandName  string `json:"brandName"`
+	BrandColor string `json:"brandColor"` // hex, such as #2563eb
+	Logo       string `json:"logo"`       // image file, relative to the template directory
+	// ShowTitle leads the document with its title; nil means true
+	ShowTitle *bool `json:"showTitle"`
+}
+
+// defaultExportTemplate is used for the "default" template unless the
+// template directory overrides it
+var defaultExportTemplate = ExportTemplate{Header: "{{title}}", Footer: "{{page}} / {{pages}}"}
+
+var exportTemplateName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// exportLogoSize bounds the logo in pixels; it is printed about 18pt high
+const exportLogoSize = 256
+
+// DocumentExport is a rendered document ready to download
+type DocumentExport struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type DocumentExportService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) DocumentExportService
+	// Export renders a document as md, html, pdf or docx with the named
+	// template, "default" when empty. Content of type html is read as HTML,
+	// anything else as Markdown.
+	Export(docID, format, template string) (*DocumentExport, error)
+}
+
+type documentExportService struct {
+	db          *gorm.DB
+	templateDir string
+	// font supplies the glyphs the built-in PDF fonts lack, such as CJK
+	font *pdf.Font
+}
+
+// NewDocumentExportService reads templates from templateDir. fontPath names a
+// TrueType font for the characters the built-in PDF fonts lack; without it
+// they print as blanks.
+func NewDocumentExportService(db *gorm.DB, templateDir, fontPath string) DocumentExportService {
+	s := &documentExportService{db: db, templateDir: templateDir}
+	if fontPath != "" {
+		data, err := os.ReadFile(fontPath)
+		if err == nil {
+			s.font, err = pdf.ParseFont(data)
+		}
+		if err != nil {
+			log.Printf("document export font %s: %v", fontPath, err)
+		}
+	}
+	return s
+}
+
+func (s *documentExportService) WithContext(ctx context.Context) DocumentExportService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *documentExportService) Export(docID, format, name string) (*DocumentExport, error) {
+	switch format {
+	case ExportMarkdown, ExportHTML, ExportPDF, ExportDOCX:
+	default:
+		return nil, ErrExportFormat
+	}
+	if name == "" {
+		name = "default"
+	}
+	tmpl, err := s.template(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc models.Document
+	if err := s.db.Preload("Owner").First(&doc, "id = ?", docID).Error; err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{
+		"title":        doc.Title,
+		"author":       doc.Owner.Name,
+		"organization": organizationName(s.db),
+		"date":         time.Now().Format("2006-01-02"),
+	}
+	export := &DocumentExport{Filename: exportFilename(doc.Title) + "." + format}
+
+	var blocks []markup.Block
+	if doc.Type == models.DocumentTypeHTML {
+		blocks = markup.ParseHTML(doc.Content)
+	} else {
+		blocks = markup.ParseMarkdown(doc.Content)
+	}
+
+	if format == ExportMarkdown {
+		export.ContentType = "text/markdown; charset=utf-8"
+		if doc.Type == models.DocumentTypeHTML {
+			export.Data = []byte(markup.Markdown(blocks))
+		} else {
+			export.Data = []byte(doc.Content)
+		}
+		return export, nil
+	}
+
+	if tmpl.ShowTitle == nil || *tmpl.ShowTitle {
+		blocks = withTitle(blocks, doc.Title)
+	}
+	brand, err := parseHexColor(tmpl.BrandColor)
+	if err != nil {
+		return nil, fmt.Errorf("export template %q: %w", name, err)
+	}
+	logo, err := s.logo(tmpl.Logo)
+	if err != nil {
+		return nil, fmt.Errorf("export template %q: logo: %w", name, err)
+	}
+	header := expandPlaceholders(tmpl.Header, vars)
+	footer := expandPlaceholders(tmpl.Footer, vars)
+	brandName := expandPlaceholders(tmpl.BrandName, vars)
+
+	var buf bytes.Buffer
+	switch format {
+	case ExportHTML:
+		export.ContentType = "text/html; charset=utf-8"
+		err = writeExportHTML(&buf, blocks, doc.Title, header, footer, brandName, brand, logo)
+	case ExportPDF:
+		export.ContentType = "application/pdf"
+		err = pdf.Write(&buf, blocks, pdf.Options{Title: doc.Title, Author: doc.Owner.Name, Header: header, Footer: footer,
+			BrandName: brandName, BrandColor: brand, Logo: logo, Fallback: s.font})
+	case ExportDOCX:
+		export.ContentType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		err = docx.Write(&buf, blocks, docx.Options{Title: doc.Title, Author: doc.Owner.Name, Header: header, Footer: footer,
+			BrandName: brandName, BrandColor: brand, Logo: logo})
+	}
+	if err != nil {
+		return nil, err
+	}
+	export.Data = buf.Bytes()
+	return export, nil
+}
+
+// template loads the named export template
+func (s *documentExportService) template(name string) (*ExportTemplate, error) {
+	if !exportTemplateName.MatchString(name) {
+		return nil, ErrExportTemplate
+	}
+	data, err := os.ReadFile(filepath.Join(s.templateDir, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		if name == "default" {
+			tmpl := defaultExportTemplate
+			return &tmpl, nil
+		}
+		return nil, ErrExportTemplate
+	}
+	if err != nil {
+		return nil, err
+	}
+	var tmpl ExportTemplate
+	if err := json.Unmarshal(data, &tmpl); err != nil {
+		return nil, fmt.Errorf("export template %q: %w", name, err)
+	}
+	return &tmpl, nil
+}
+
+// logo loads and downscales a template's logo; an empty name is no logo
+func (s *documentExportService) logo(name string) (image.Image, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("%s is outside the template directory", name)
+	}
+	data, err := os.ReadFile(filepath.Join(s.templateDir, name))
+	if err != nil {
+		return nil, err
+	}
+	img, err := imaging.Decode(data, 4096*4096)
+	if err != nil {
+		return nil, err
+	}
+	return imaging.Fit(img, exportLogoSize*4, exportLogoSize), nil
+}
+
+// organizationName names the organization db is scoped to
+func organizationName(db *gorm.DB) string {
+	tenantID, ok := tenant.FromContext(db.Statement.Context)
+	if !ok {
+		return ""
+	}
+	var org models.Organization
+	if err := db.Select("name").First(&org, "id = ?", tenantID).Error; err != nil {
+		return ""
+	}
+	return org.Name
+}
+
+// withTitle leads blocks with a title heading unless they already start with it
+func withTitle(blocks []markup.Block, title string) []markup.Block {
+	if title == "" {
+		return blocks
+	}
+	if len(blocks) > 0 && blocks[0].Kind == markup.Heading && blocks[0].Level == 1 &&
+		strings.TrimSpace(markup.PlainText(blocks[0].Inlines)) == strings.TrimSpace(title) {
+		return blocks
+	}
+	heading := markup.Block{Kind: markup.Heading, Level: 1, Inlines: []markup.Inline{{Text: title}}}
+	return append([]markup.Block{heading}, blocks...)
+}
+
+// exportFilename makes a title safe to use as a file name
+func exportFilename(title string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	name = strings.Trim(name, ". ")
+	if runes := []rune(name); len(runes) > 100 {
+		name = string(runes[:100])
+	}
+	if name == "" {
+		return "document"
+	}
+	return name
+}
+
+// parseHexColor parses #rgb or #rrggbb; an empty value is no color
+func parseHexColor(value string) (color.Color, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if hex == "" {
+		return nil, nil
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return nil, fmt.Errorf("invalid brandColor %q", value)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// placeholderPattern matches placeholders such as {{date}} and {{ user.name }}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z][A-Za-z0-9_.]*)\s*\}\}`)
+
+// expandPlaceholders replaces the placeholders named in vars and leaves the
+// others as they are
+func expandPlaceholders(text string, vars map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// writeExportHTML writes a standalone page. A page has no page numbers, so a
+// header or footer that shows them is left out.
+func writeExportHTML(buf *bytes.Buffer, blocks []markup.Block, title, header, footer, brandName string, brand color.Color, logo image.Image) error {
+	if showsPages(header) {
+		header = ""
+	}
+	if showsPages(footer) {
+		footer = ""
+	}
+	accent := "#1f2937"
+	if brand != nil {
+		r, g, b, _ := brand.RGBA()
+		accent = fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+	}
+
+	buf.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	buf.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	buf.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	buf.WriteString("<style>\n" + strings.ReplaceAll(exportCSS, "{{accent}}", accent) + "</style>\n</head>\n<body>\n")
+
+	if logo != nil || brandName != "" || header != "" {
+		buf.WriteString("<header>")
+		if logo != nil {
+			var img bytes.Buffer
+			if err := png.Encode(&img, logo); err != nil {
+				return err
+			}
+			buf.WriteString(`<img class="logo" alt="" src="data:image/png;base64,` + base64.StdEncoding.EncodeToString(img.Bytes()) + `">`)
+		}
+		if brandName != "" {
+			buf.WriteString(`<span class="brand">` + html.EscapeString(brandName) + "</span>")
+		}
+		if header != "" {
+			buf.WriteString(`<span class="header">` + html.EscapeString(header) + "</span>")
+		}
+		buf.WriteString("</header>\n")
+	}
+	buf.WriteString("<main>\n" + markup.HTML(blocks) + "</main>\n")
+	if footer != "" {
+		buf.WriteString("<footer>" + html.EscapeString(footer) + "</footer>\n")
+	}
+	buf.WriteString("</body>\n</html>\n")
+	return nil
+}
+
+func showsPages(text string) bool {
+	return strings.Contains(text, markup.PageNumber) || strings.Contains(text, markup.PageCount)
+}
+
+const exportCSS = `body { max-width: 820px; margin: 0 auto; padding: 32px 24px; color: #212630; font: 15px/1.6 -apple-system, "Segoe UI", "PingFang SC", "Microsoft YaHei", sans-serif; }
+header { display: flex; align-items: center; gap: 8px; padding-bottom: 8px; margin-bottom: 24px; border-bottom: 1px solid #d6d9de; color: #6b7280; font-size: 13px; }
+header .logo { height: 24px; }
+header .brand { color: {{accent}}; font-weight: 600; }
+header .header { margin-left: auto; }
+footer { margin-top: 32px; padding-top: 8px; border-top: 1px solid #d6d9de; color: #6b7280; font-size: 13px; text-align: center; }
+h1, h2, h3, h4, h5, h6 { color: {{accent}}; line-height: 1.3; }
+h1, h2 { padding-bottom: 4px; border-bottom: 1px solid #d6d9de; }
+a { color: #2563eb; }
+code { padding: 1px 4px; background: #f3f4f6; border-radius: 3px; font: 0.9em Consolas, Menlo, monospace; }
+pre { padding: 10px 12px; background: #f3f4f6; overflow-x: auto; }
+pre code { padding: 0; }
+blockquote { margin: 0 0 1em; padding-left: 12px; border-left: 3px solid #d6d9de; color: #6b7280; }
+table { border-collapse: collapse; width: 100%; }
+th, td { padding: 5px 8px; border: 1px solid #d6d9de; text-align: left; }
+th { background: #edeff3; }
+hr { border: 0; border-top: 1px solid #d6d9de; }
+img { max-width: 100%; }
+`
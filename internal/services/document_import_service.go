@@ -0,0 +1,394 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/docx"
+	"github.com/halolight/halolight-api-go/pkg/markup"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+const (
+	// DocumentImportMaxFiles bounds the documents one import creates
+	DocumentImportMaxFiles = 500
+	// documentImportMaxFileSize bounds each file, inside an archive or not
+	documentImportMaxFileSize = 10 << 20
+	// documentImportMaxTotal bounds the unpacked size of all files
+	documentImportMaxTotal = 200 << 20
+	documentImportMaxTags  = 20
+)
+
+var (
+	ErrImportNoFiles       = errors.New("no files to import")
+	ErrImportTooManyFiles  = fmt.Errorf("an import holds at most %d files", DocumentImportMaxFiles)
+	ErrImportTooLargeTotal = fmt.Errorf("an import unpacks to at most %dMB", documentImportMaxTotal>>20)
+
+	errImportUnsupported = errors.New("unsupported file type, expected .md, .markdown, .html, .htm or .docx")
+	errImportFileSize    = fmt.Errorf("file exceeds %dMB", documentImportMaxFileSize>>20)
+	errImportNotText     = errors.New("file is not UTF-8 text")
+	errImportFolder      = errors.New("folder path exceeds 255 characters")
+	errImportFrontMatter = errors.New("invalid front matter")
+)
+
+// DocumentImportUpload is an uploaded file: a document or a zip of documents
+type DocumentImportUpload struct {
+	Name string
+	Data []byte
+}
+
+// DocumentImportFile is the outcome for one file; Error is set when it failed
+type DocumentImportFile struct {
+	Path       string   `json:"path"`
+	DocumentID string   `json:"documentId,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Folder     string   `json:"folder,omitempty"`
+	Type       string   `json:"type,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+type DocumentImportReport struct {
+	Total    int                  `json:"total"`
+	Imported int                  `json:"imported"`
+	Failed   int                  `json:"failed"`
+	Files    []DocumentImportFile `json:"files"`
+}
+
+type DocumentImportService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) DocumentImportService
+	// Import creates a document owned by ownerID, or by the team, from every
+	// Markdown, HTML and DOCX file uploaded or packed in an uploaded zip. A
+	// file's directory in the archive is appended to folder. Files that fail
+	// are listed in the report and do not stop the others.
+	Import(ownerID string, teamID *string, folder string, uploads []DocumentImportUpload) (*DocumentImportReport, error)
+}
+
+type documentImportService struct {
+	db   *gorm.DB
+	docs DocumentService
+}
+
+func NewDocumentImportService(db *gorm.DB, docs DocumentService) DocumentImportService {
+	return &documentImportService{db: db, docs: docs}
+}
+
+func (s *documentImportService) WithContext(ctx context.Context) DocumentImportService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.docs = s.docs.WithContext(ctx)
+	return &clone
+}
+
+// importEntry is a file to convert, or one that already failed
+type importEntry struct {
+	path string
+	data []byte
+	err  error
+}
+
+func (s *documentImportService) Import(ownerID string, teamID *string, folder string, uploads []DocumentImportUpload) (*DocumentImportReport, error) {
+	if teamID != nil && *teamID == "" {
+		teamID = nil
+	}
+	if teamID != nil {
+		if _, _, err := teamMembership(s.db, *teamID, ownerID); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := unpackImport(uploads)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrImportNoFiles
+	}
+
+	folder = strings.Trim(path.Clean("/"+strings.ReplaceAll(folder, "\\", "/")), "/")
+	report := &DocumentImportReport{Total: len(entries), Files: make([]DocumentImportFile, 0, len(entries))}
+	for _, entry := range entries {
+		result := DocumentImportFile{Path: entry.path}
+		if err := entry.err; err != nil {
+			result.Error = err.Error()
+		} else if err := s.importFile(&result, entry, ownerID, teamID, folder); err != nil {
+			result = DocumentImportFile{Path: entry.path, Error: err.Error()}
+		}
+		if result.Error != "" {
+			report.Failed++
+		} else {
+			report.Imported++
+		}
+		report.Files = append(report.Files, result)
+	}
+	return report, nil
+}
+
+func (s *documentImportService) importFile(result *DocumentImportFile, entry importEntry, ownerID string, teamID *string, folder string) error {
+	converted, err := convertImport(entry.path, entry.data)
+	if err != nil {
+		return err
+	}
+	if dir := path.Dir(entry.path); dir != "." {
+		folder = strings.TrimPrefix(folder+"/"+dir, "/")
+	}
+	if utf8.RuneCountInString(folder) > 255 {
+		return errImportFolder
+	}
+
+	doc, err := s.docs.Create(converted.title, converted.content, folder, converted.docType, ownerID, teamID)
+	if err != nil {
+		return err
+	}
+	if len(converted.tags) > 0 {
+		if _, err := s.docs.UpdateTags(doc.ID, converted.tags); err != nil {
+			return err
+		}
+	}
+	*result = DocumentImportFile{
+		Path:       entry.path,
+		DocumentID: doc.ID,
+		Title:      doc.Title,
+		Folder:     folder,
+		Type:       doc.Type,
+		Tags:       converted.tags,
+	}
+	return nil
+}
+
+// unpackImport lists the files of the uploads, expanding zip archives
+func unpackImport(uploads []DocumentImportUpload) ([]importEntry, error) {
+	var entries []importEntry
+	total := 0
+	add := func(entry importEntry) error {
+		if len(entries) >= DocumentImportMaxFiles {
+			return ErrImportTooManyFiles
+		}
+		total += len(entry.data)
+		if total > documentImportMaxTotal {
+			return ErrImportTooLargeTotal
+		}
+		entries = append(entries, entry)
+		return nil
+	}
+
+	for _, upload := range uploads {
+		name := path.Base(strings.ReplaceAll(upload.Name, "\\", "/"))
+		if !strings.EqualFold(path.Ext(name), ".zip") {
+			entry := importEntry{path: name, data: upload.Data}
+			if len(upload.Data) > documentImportMaxFileSize {
+				entry = importEntry{path: name, err: errImportFileSize}
+			}
+			if err := add(entry); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(upload.Data), int64(len(upload.Data)))
+		if err != nil {
+			if err := add(importEntry{path: name, err: errors.New("not a valid zip archive")}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, f := range zr.File {
+			p, ok := archivePath(f.Name)
+			if !ok || f.FileInfo().IsDir() {
+				continue
+			}
+			entry := importEntry{path: p}
+			entry.data, entry.err = readArchiveFile(f)
+			if err := add(entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// archivePath cleans the path of an archive entry. Entries outside the
+// archive's root, hidden files and macOS resource forks are skipped.
+func archivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") {
+		return "", false
+	}
+	p := path.Clean(name)
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return "", false
+	}
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return "", false
+		}
+	}
+	return p, true
+}
+
+func readArchiveFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > documentImportMaxFileSize {
+		return nil, errImportFileSize
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	// The recorded size may lie, so the read is bounded as well
+	data, err := io.ReadAll(io.LimitReader(rc, documentImportMaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > documentImportMaxFileSize {
+		return nil, errImportFileSize
+	}
+	return data, nil
+}
+
+// convertedImport is a file converted into document fields
+type convertedImport struct {
+	title   string
+	content string
+	docType string
+	tags    []string
+}
+
+// frontMatter is the YAML block that may open a Markdown or HTML file
+type frontMatter struct {
+	Title string      `yaml:"title"`
+	Tags  interface{} `yaml:"tags"`
+}
+
+// convertImport converts a file by its extension. Markdown is stored as it
+// is, HTML is sanitized and DOCX becomes Markdown. The title comes from the
+// front matter or document properties, else the first top-level heading,
+// else the file name.
+func convertImport(name string, data []byte) (*convertedImport, error) {
+	ext := strings.ToLower(path.Ext(name))
+	out := &convertedImport{}
+	var blocks []markup.Block
+	var tags []string
+
+	switch ext {
+	case ".md", ".markdown", ".html", ".htm":
+		if !utf8.Valid(data) {
+			return nil, errImportNotText
+		}
+		text := strings.TrimPrefix(string(data), "\ufeff")
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		fm, body, err := splitFrontMatter(text)
+		if err != nil {
+			return nil, err
+		}
+		out.title = fm.Title
+		tags = frontMatterTags(fm.Tags)
+
+		if ext == ".md" || ext == ".markdown" {
+			out.docType = models.DocumentTypeMarkdown
+			out.content = body
+			blocks = markup.ParseMarkdown(body)
+		} else {
+			out.docType = models.DocumentTypeHTML
+			blocks = markup.ParseHTML(body)
+			out.content = markup.HTML(blocks)
+			if out.title == "" {
+				out.title = markup.HTMLTitle(body)
+			}
+		}
+
+	case ".docx":
+		var props docx.Properties
+		var err error
+		blocks, props, err = docx.Read(data)
+		if err != nil {
+			return nil, err
+		}
+		out.docType = models.DocumentTypeMarkdown
+		out.content = markup.Markdown(blocks)
+		out.title = props.Title
+		tags = props.Keywords
+
+	default:
+		return nil, errImportUnsupported
+	}
+
+	if out.title == "" {
+		for _, block := range blocks {
+			if block.Kind == markup.Heading && block.Level == 1 {
+				out.title = markup.PlainText(block.Inlines)
+				break
+			}
+		}
+	}
+	if out.title = strings.Join(strings.Fields(out.title), " "); out.title == "" {
+		out.title = strings.TrimSuffix(path.Base(name), path.Ext(name))
+	}
+	if runes := []rune(out.title); len(runes) > 255 {
+		out.title = string(runes[:255])
+	}
+	out.tags = normalizeTags(tags)
+	return out, nil
+}
+
+// splitFrontMatter separates a leading YAML block fenced by --- lines
+func splitFrontMatter(text string) (frontMatter, string, error) {
+	var fm frontMatter
+	lines := strings.SplitAfter(text, "\n")
+	if strings.TrimRight(lines[0], "\n") != "---" {
+		return fm, text, nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimRight(lines[i], "\n") != "---" {
+			continue
+		}
+		if err := yaml.Unmarshal([]byte(strings.Join(lines[1:i], "")), &fm); err != nil {
+			return fm, text, errImportFrontMatter
+		}
+		return fm, strings.TrimLeft(strings.Join(lines[i+1:], ""), "\n"), nil
+	}
+	return fm, text, nil
+}
+
+// frontMatterTags accepts tags as a YAML list or a comma separated string
+func frontMatterTags(value interface{}) []string {
+	var tags []string
+	switch v := value.(type) {
+	case string:
+		tags = strings.Split(v, ",")
+	case []interface{}:
+		for _, item := range v {
+			if item != nil {
+				tags = append(tags, fmt.Sprint(item))
+			}
+		}
+	}
+	return tags
+}
+
+// normalizeTags trims tags and drops empty, overlong and repeated ones
+func normalizeTags(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || utf8.RuneCountInString(tag) > 100 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		out = append(out, tag)
+		if len(out) == documentImportMaxTags {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/textdiff"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrInvalidDiffMode  = errors.New(`diff mode must be "line" or "word"`)
+)
+
+// Diff granularities
+const (
+	DiffLine = "line"
+	DiffWord = "word"
+)
+
+// RevisionPolicy configures how much document history is kept.
+//
+// Keep caps the revisions of each document, dropping the oldest; 0 keeps all
+// of them. Revisions older than ThinAfter are thinned out to the last one of
+// each day; 0 disables thinning. The latest revision is never removed.
+type RevisionPolicy struct {
+	Keep      int
+	ThinAfter time.Duration
+}
+
+// RevisionDiff compares two revisions of a document. From and To are returned
+// without their content.
+type RevisionDiff struct {
+	From *models.DocumentRevision `json:"from"`
+	To   *models.DocumentRevision `json:"to"`
+	Mode string                   `json:"mode"`
+	textdiff.Result
+}
+
+type DocumentRevisionService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) DocumentRevisionService
+	// List returns a document's revisions, newest first and without content
+	List(docID string, page, limit int) ([]models.DocumentRevision, int64, error)
+	Get(docID, revisionID string) (*models.DocumentRevision, error)
+	Diff(docID, fromID, toID, mode string) (*RevisionDiff, error)
+	// Restore makes an old revision's title and content current again,
+	// recording the result as a new revision
+	Restore(docID, revisionID, authorID string) (*models.Document, error)
+	// Prune applies the retention policy to every document and returns the
+	// number of revisions removed
+	Prune() (int64, error)
+	// Start runs Prune every interval in the background
+	Start(interval time.Duration)
+}
+
+type documentRevisionService struct {
+	db     *gorm.DB
+	policy RevisionPolicy
+}
+
+func NewDocumentRevisionService(db *gorm.DB, policy RevisionPolicy) DocumentRevisionService {
+	return &documentRevisionService{db: db, policy: policy}
+}
+
+func (s *documentRevisionService) WithContext(ctx context.Context) DocumentRevisionService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *documentRevisionService) List(docID string, page, limit int) ([]models.DocumentRevision, int64, error) {
+	var revisions []models.DocumentRevision
+	var total int64
+
+	query := s.db.Model(&models.DocumentRevision{}).Where("document_id = ?", docID)
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	err := query.Omit("content").Preload("Author").
+		Offset(offset).Limit(limit).Order("number DESC").Find(&revisions).Error
+	return revisions, total, err
+}
+
+func (s *documentRevisionService) Get(docID, revisionID string) (*models.DocumentRevision, error) {
+	var revision models.DocumentRevision
+	err := s.db.Preload("Author").First(&revision, "id = ? AND document_id = ?", revisionID, docID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+func (s *documentRevisionService) Diff(docID, fromID, toID, mode string) (*RevisionDiff, error) {
+	if mode == "" {
+		mode = DiffLine
+	}
+	if mode != DiffLine && mode != DiffWord {
+		return nil, ErrInvalidDiffMode
+	}
+	from, err := s.Get(docID, fromID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.Get(docID, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := textdiff.Lines(from.Content, to.Content)
+	if mode == DiffWord {
+		result = textdiff.Words(from.Content, to.Content)
+	}
+	from.Content, to.Content = "", ""
+	return &RevisionDiff{From: from, To: to, Mode: mode, Result: result}, nil
+}
+
+func (s *documentRevisionService) Restore(docID, revisionID, authorID string) (*models.Document, error) {
+	revision, err := s.Get(docID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc models.Document
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doc, "id = ?", docID).Error; err != nil {
+			return err
+		}
+		doc.Title = revision.Title
+		doc.Content = revision.Content
+		if err := tx.Save(&doc).Error; err != nil {
+			return err
+		}
+		_, err := recordRevision(tx, &doc, authorID, &revision.ID, s.policy.Keep)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (s *documentRevisionService) Start(interval time.Duration) {
+	if s.policy.Keep <= 0 && s.policy.ThinAfter <= 0 {
+		log.Println("📜 Document revision pruning disabled (keeping all revisions)")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			removed, err := s.Prune()
+			if err != nil {
+				log.Printf("revision prune: %v", err)
+			} else if removed > 0 {
+				log.Printf("revision prune: removed %d revisions", removed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *documentRevisionService) Prune() (int64, error) {
+	var removed int64
+	if s.policy.Keep > 0 {
+		res := s.db.Exec(`DELETE FROM document_revisions WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY document_id ORDER BY number DESC) AS position
+				FROM document_revisions
+			) ranked WHERE position > ?
+		)`, s.policy.Keep)
+		if res.Error != nil {
+			return removed, res.Error
+		}
+		removed += res.RowsAffected
+	}
+	if s.policy.ThinAfter > 0 {
+		// The newest revision of a day outlives the others; a document's
+		// latest revision is always the newest of its day
+		res := s.db.Exec(`DELETE FROM document_revisions WHERE id IN (
+			SELECT id FROM (
+				SELECT id, ROW_NUMBER() OVER (PARTITION BY document_id, DATE_TRUNC('day', created_at) ORDER BY number DESC) AS position
+				FROM document_revisions WHERE created_at < ?
+			) ranked WHERE position > 1
+		)`, time.Now().Add(-s.policy.ThinAfter))
+		if res.Error != nil {
+			return removed, res.Error
+		}
+		removed += res.RowsAffected
+	}
+	return removed, nil
+}
+
+// recordRevision snapshots doc as its next revision and drops the oldest ones
+// beyond keep. tx must hold a lock on the document row.
+func recordRevision(tx *gorm.DB, doc *models.Document, authorID string, restoredFrom *string, keep int) (*models.DocumentRevision, error) {
+	var last int
+	if err := tx.Model(&models.DocumentRevision{}).
+		Where("document_id = ?", doc.ID).
+		Select("COALESCE(MAX(number), 0)").
+		Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	revision := &models.DocumentRevision{
+		DocumentID:     doc.ID,
+		Number:         last + 1,
+		Title:          doc.Title,
+		Content:        doc.Content,
+		Size:           int64(len(doc.Content)),
+		RestoredFromID: restoredFrom,
+	}
+	if authorID != "" {
+		revision.AuthorID = &authorID
+	}
+	if err := tx.Create(revision).Error; err != nil {
+		return nil, err
+	}
+
+	if keep > 0 {
+		kept := tx.Model(&models.DocumentRevision{}).Select("id").
+			Where("document_id = ?", doc.ID).Order("number DESC").Limit(keep)
+		if err := tx.Where("document_id = ? AND id NOT IN (?)", doc.ID, kept).
+			Delete(&models.DocumentRevision{}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return revision, nil
+}
+
+// recordBaseline snapshots a document that predates revision history, so its
+// first edit can be diffed and undone. It reports whether it did.
+func recordBaseline(tx *gorm.DB, doc *models.Document) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.DocumentRevision{}).Where("document_id = ?", doc.ID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	err := tx.Create(&models.DocumentRevision{
+		DocumentID: doc.ID,
+		Number:     1,
+		Title:      doc.Title,
+		Content:    doc.Content,
+		Size:       int64(len(doc.Content)),
+		AuthorID:   &doc.OwnerID,
+		CreatedAt:  doc.UpdatedAt,
+	}).Error
+	return err == nil, err
+}
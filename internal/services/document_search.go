@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrEmptySearchQuery = errors.New("search query is empty")
+
+// Markers ts_headline puts around matches. Private use characters survive
+// HTML escaping untouched and are swapped for <mark> afterwards.
+const (
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+const (
+	headlineMarkers        = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `"`
+	titleHeadlineOptions   = headlineMarkers + ", HighlightAll=true"
+	snippetHeadlineOptions = headlineMarkers + `, MinWords=15, MaxWords=35, MaxFragments=2, FragmentDelimiter=" … "`
+)
+
+var highlightMarks = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// DocumentSearchResult is a document matching a search. TitleHighlight and
+// Snippet are HTML-escaped text with the matches wrapped in <mark>.
+type DocumentSearchResult struct {
+	models.Document
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"titleHighlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+// searchQuery turns what a user typed into a tsquery expression. Quoted text
+// matches as a phrase, a word ending in * matches as a prefix and the other
+// words must all appear. It reports false when nothing searchable is left.
+func searchQuery(language, input string) (clause.Expr, bool) {
+	var parts []string
+	var vars []interface{}
+	var words []string
+
+	for input != "" {
+		input = strings.TrimLeft(input, " \t\r\n")
+		if input == "" {
+			break
+		}
+		if input[0] == '"' {
+			phrase := input[1:]
+			input = ""
+			if end := strings.IndexByte(phrase, '"'); end >= 0 {
+				phrase, input = phrase[:end], phrase[end+1:]
+			}
+			if strings.TrimSpace(phrase) != "" {
+				parts = append(parts, "phraseto_tsquery(?::regconfig, ?)")
+				vars = append(vars, language, phrase)
+			}
+			continue
+		}
+
+		word := input
+		input = ""
+		if end := strings.IndexAny(word, " \t\r\n\""); end >= 0 {
+			word, input = word[:end], word[end:]
+		}
+		if prefix := strings.TrimRight(word, "*"); prefix != word {
+			if prefix != "" {
+				// A quoted lexeme keeps tsquery operators in the word literal
+				lexeme := "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(prefix) + "':*"
+				parts = append(parts, "to_tsquery(?::regconfig, ?)")
+				vars = append(vars, language, lexeme)
+			}
+			continue
+		}
+		words = append(words, word)
+	}
+	if len(words) > 0 {
+		parts = append(parts, "plainto_tsquery(?::regconfig, ?)")
+		vars = append(vars, language, strings.Join(words, " "))
+	}
+
+	if len(parts) == 0 {
+		return clause.Expr{}, false
+	}
+	return gorm.Expr("("+strings.Join(parts, " && ")+")", vars...), true
+}
+
+func (s *documentService) Search(userID, query string, page, limit int, folder, teamID string, tags []string) ([]DocumentSearchResult, int64, error) {
+	tsquery, ok := searchQuery(s.searchLanguage, query)
+	if !ok {
+		return nil, 0, ErrEmptySearchQuery
+	}
+
+	scope, err := s.listable(userID, folder, teamID, tags)
+	if err != nil {
+		return nil, 0, err
+	}
+	scope = scope.Where("search_vector @@ ?", tsquery)
+
+	var total int64
+	if err := scope.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var hits []struct {
+		ID   string
+		Rank float64
+	}
+	offset := (page - 1) * limit
+	if err := scope.Select("id, ts_rank_cd(search_vector, ?) AS rank", tsquery).
+		Order("rank DESC, updated_at DESC").Offset(offset).Limit(limit).
+		Scan(&hits).Error; err != nil {
+		return nil, 0, err
+	}
+	results := []DocumentSearchResult{}
+	if len(hits) == 0 {
+		return results, total, nil
+	}
+
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	var docs []models.Document
+	if err := s.db.Preload("Owner").Preload("Tags.Tag").Where("id IN ?", ids).Find(&docs).Error; err != nil {
+		return nil, 0, err
+	}
+	var headlines []struct {
+		ID             string
+		TitleHighlight string
+		Snippet        string
+	}
+	if err := s.db.Model(&models.Document{}).
+		Select("id, ts_headline(?::regconfig, title, ?, ?) AS title_highlight, ts_headline(?::regconfig, content, ?, ?) AS snippet",
+			s.searchLanguage, tsquery, titleHeadlineOptions, s.searchLanguage, tsquery, snippetHeadlineOptions).
+		Where("id IN ?", ids).Scan(&headlines).Error; err != nil {
+		return nil, 0, err
+	}
+
+	byID := make(map[string]*models.Document, len(docs))
+	for i := range docs {
+		byID[docs[i].ID] = &docs[i]
+	}
+	type headline struct{ title, snippet string }
+	headlineByID := make(map[string]headline, len(headlines))
+	for _, h := range headlines {
+		headlineByID[h.ID] = headline{
+			title:   highlightMarks.Replace(html.EscapeString(h.TitleHighlight)),
+			snippet: highlightMarks.Replace(html.EscapeString(h.Snippet)),
+		}
+	}
+
+	// Keep the ranking order; documents deleted in between are skipped
+	for _, hit := range hits {
+		doc, ok := byID[hit.ID]
+		if !ok {
+			continue
+		}
+		h := headlineByID[hit.ID]
+		results = append(results, DocumentSearchResult{
+			Document:       *doc,
+			Rank:           hit.Rank,
+			TitleHighlight: h.title,
+			Snippet:        h.snippet,
+		})
+	}
+	return results, total, nil
+}
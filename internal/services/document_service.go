@@ -1,59 +1,138 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/halolight/halolight-api-go/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type DocumentService interface {
-	List(userID string, page, limit int, search, folder string, tags []string) ([]models.Document, int64, error)
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) DocumentService
+	// List returns the caller's own and shared documents, or with teamID the
+	// documents owned by that team
+	List(userID string, page, limit int, search, folder, teamID string, tags []string) ([]models.Document, int64, error)
+	// Search runs a full-text query over the documents List would return,
+	// best matches first
+	Search(userID, query string, page, limit int, folder, teamID string, tags []string) ([]DocumentSearchResult, int64, error)
 	Get(id string) (*models.Document, error)
+	// Create adds a document; a teamID requires the owner to belong to that team
 	Create(title, content, folder, docType, ownerID string, teamID *string) (*models.Document, error)
-	Update(id, title, content, folder string) (*models.Document, error)
-	Rename(id, title string) (*models.Document, error)
+	// Update records a revision authored by authorID when the title or
+	// content changes
+	Update(id, title, content, folder, authorID string) (*models.Document, error)
+	Rename(id, title, authorID string) (*models.Document, error)
 	Move(id, folder string) (*models.Document, error)
 	UpdateTags(id string, tagNames []string) (*models.Document, error)
-	Share(docID, userID string, permission models.SharePermission) error
-	Unshare(docID, userID string) error
+	// Share grants a user or a team access to a document, replacing the
+	// permission and expiry of an existing share with them
+	Share(docID string, input ShareInput) (*models.DocumentShare, error)
+	// Unshare removes the share with userID or, when userID is empty, teamID
+	Unshare(docID, userID, teamID string) error
+	// Shares lists a document's shares, expired ones included
+	Shares(docID string) ([]models.DocumentShare, error)
 	Delete(id string) error
 	DeleteMany(ids []string) error
+	// SaveContent stores content from a live editing session. Saves by the same
+	// author within a few minutes amend their latest revision instead of
+	// recording a new one.
+	SaveContent(id, content, authorID string) error
 	IsOwner(docID, userID string) bool
+	// HasAccess reports whether userID owns the document, holds an unexpired
+	// share with them or a team of theirs, or may view it as a member of the
+	// team owning it
 	HasAccess(docID, userID string) bool
+	// CanEdit reports whether userID owns the document, holds an EDIT share or
+	// belongs to its team with a role granting documents:edit
+	CanEdit(docID, userID string) bool
+	// CanComment reports whether userID may comment: editors, holders of a
+	// COMMENT share and team members whose role grants documents:comment
+	CanComment(docID, userID string) bool
+}
+
+var (
+	ErrInvalidShareTarget     = errors.New("share with either a user or a team")
+	ErrInvalidSharePermission = errors.New("permission must be READ, COMMENT or EDIT")
+	ErrShareExpiry            = errors.New("expiry must be in the future")
+)
+
+// ShareInput is a share with a user or, when UserID is empty, a team. A nil
+// ExpiresAt never expires.
+type ShareInput struct {
+	UserID     string
+	TeamID     string
+	Permission models.SharePermission
+	ExpiresAt  *time.Time
 }
 
+// revisionCoalesceWindow is how long live-session saves keep amending the
+// same revision
+const revisionCoalesceWindow = 5 * time.Minute
+
 type documentService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	revisions RevisionPolicy
+	// searchLanguage is the text search configuration documents are indexed with
+	searchLanguage string
 }
 
-func NewDocumentService(db *gorm.DB) DocumentService {
-	return &documentService{db: db}
+func NewDocumentService(db *gorm.DB, revisions RevisionPolicy, searchLanguage string) DocumentService {
+	return &documentService{db: db, revisions: revisions, searchLanguage: searchLanguage}
 }
 
-func (s *documentService) List(userID string, page, limit int, search, folder string, tags []string) ([]models.Document, int64, error) {
+func (s *documentService) WithContext(ctx context.Context) DocumentService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *documentService) List(userID string, page, limit int, search, folder, teamID string, tags []string) ([]models.Document, int64, error) {
 	var docs []models.Document
 	var total int64
 
-	query := s.db.Model(&models.Document{}).
-		Where("owner_id = ? OR id IN (SELECT document_id FROM document_shares WHERE shared_with_id = ?)", userID, userID)
-
+	query, err := s.listable(userID, folder, teamID, tags)
+	if err != nil {
+		return nil, 0, err
+	}
 	if search != "" {
 		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+search+"%", "%"+search+"%")
 	}
-	if folder != "" {
-		query = query.Where("folder = ?", folder)
-	}
-	if len(tags) > 0 {
-		query = query.Where("id IN (SELECT document_id FROM document_tags dt JOIN tags t ON dt.tag_id = t.id WHERE t.name IN ?)", tags)
-	}
 
 	query.Count(&total)
 
 	offset := (page - 1) * limit
-	err := query.Preload("Owner").Preload("Tags.Tag").Offset(offset).Limit(limit).Order("updated_at DESC").Find(&docs).Error
+	err = query.Preload("Owner").Preload("Tags.Tag").Offset(offset).Limit(limit).Order("updated_at DESC").Find(&docs).Error
 
 	return docs, total, err
 }
 
+// listable selects the documents List and Search may return: the caller's
+// own and shared ones, or with teamID the documents of that team, narrowed to
+// a folder and tags when given
+func (s *documentService) listable(userID, folder, teamID string, tags []string) (*gorm.DB, error) {
+	query := s.db.Model(&models.Document{})
+	if teamID != "" {
+		if err := ensureTeamAccess(s.db, teamID, userID, TeamDocumentsView); err != nil {
+			return nil, err
+		}
+		query = query.Where("team_id = ?", teamID)
+	} else {
+		query = query.Where("owner_id = ? OR id IN (?)", userID, s.sharedWith(userID))
+	}
+
+	if folder != "" {
+		query = query.Where("folder = ?", folder)
+	}
+	if len(tags) > 0 {
+		query = query.Where("id IN (SELECT document_id FROM document_tags dt JOIN tags t ON dt.tag_id = t.id WHERE t.name IN ?)", tags)
+	}
+	return query, nil
+}
+
 func (s *documentService) Get(id string) (*models.Document, error) {
 	var doc models.Document
 	// Increment views
@@ -67,6 +146,14 @@ func (s *documentService) Get(id string) (*models.Document, error) {
 }
 
 func (s *documentService) Create(title, content, folder, docType, ownerID string, teamID *string) (*models.Document, error) {
+	if teamID != nil && *teamID == "" {
+		teamID = nil
+	}
+	if teamID != nil {
+		if _, _, err := teamMembership(s.db, *teamID, ownerID); err != nil {
+			return nil, err
+		}
+	}
 	doc := &models.Document{
 		Title:   title,
 		Content: content,
@@ -75,36 +162,61 @@ func (s *documentService) Create(title, content, folder, docType, ownerID string
 		OwnerID: ownerID,
 		TeamID:  teamID,
 	}
-	err := s.db.Create(doc).Error
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(doc).Error; err != nil {
+			return err
+		}
+		_, err := recordRevision(tx, doc, ownerID, nil, s.revisions.Keep)
+		return err
+	})
 	return doc, err
 }
 
-func (s *documentService) Update(id, title, content, folder string) (*models.Document, error) {
-	doc, err := s.Get(id)
+func (s *documentService) Update(id, title, content, folder, authorID string) (*models.Document, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var doc models.Document
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doc, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		changed := (title != "" && title != doc.Title) || (content != "" && content != doc.Content)
+		if changed {
+			if _, err := recordBaseline(tx, &doc); err != nil {
+				return err
+			}
+		}
+
+		if title != "" {
+			doc.Title = title
+		}
+		if content != "" {
+			doc.Content = content
+		}
+		if folder != "" {
+			doc.Folder = &folder
+		}
+
+		if err := tx.Save(&doc).Error; err != nil {
+			return err
+		}
+		if !changed {
+			return nil
+		}
+		_, err := recordRevision(tx, &doc, authorID, nil, s.revisions.Keep)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-
-	if title != "" {
-		doc.Title = title
-	}
-	if content != "" {
-		doc.Content = content
-	}
-	if folder != "" {
-		doc.Folder = &folder
-	}
-
-	err = s.db.Save(doc).Error
-	return doc, err
+	return s.Get(id)
 }
 
-func (s *documentService) Rename(id, title string) (*models.Document, error) {
-	return s.Update(id, title, "", "")
+func (s *documentService) Rename(id, title, authorID string) (*models.Document, error) {
+	return s.Update(id, title, "", "", authorID)
 }
 
 func (s *documentService) Move(id, folder string) (*models.Document, error) {
-	return s.Update(id, "", "", folder)
+	return s.Update(id, "", "", folder, "")
 }
 
 func (s *documentService) UpdateTags(id string, tagNames []string) (*models.Document, error) {
@@ -121,17 +233,118 @@ func (s *documentService) UpdateTags(id string, tagNames []string) (*models.Docu
 	return s.Get(id)
 }
 
-func (s *documentService) Share(docID, userID string, permission models.SharePermission) error {
-	share := &models.DocumentShare{
-		DocumentID:   docID,
-		SharedWithID: &userID,
-		Permission:   permission,
+func (s *documentService) Share(docID string, input ShareInput) (*models.DocumentShare, error) {
+	if (input.UserID == "") == (input.TeamID == "") {
+		return nil, ErrInvalidShareTarget
+	}
+	switch input.Permission {
+	case models.SharePermissionRead, models.SharePermissionComment, models.SharePermissionEdit:
+	default:
+		return nil, ErrInvalidSharePermission
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, ErrShareExpiry
+	}
+
+	var share models.DocumentShare
+	query := s.db.Where("document_id = ?", docID)
+	if input.UserID != "" {
+		if err := ensureUsers(s.db, []string{input.UserID}); err != nil {
+			return nil, err
+		}
+		query = query.Where("shared_with_id = ?", input.UserID)
+		share.SharedWithID = &input.UserID
+	} else {
+		if err := s.db.Select("id").First(&models.Team{}, "id = ?", input.TeamID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrTeamNotFound
+			}
+			return nil, err
+		}
+		query = query.Where("team_id = ?", input.TeamID)
+		share.TeamID = &input.TeamID
 	}
-	return s.db.Save(share).Error
+
+	err := query.First(&share).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	share.DocumentID = docID
+	share.Permission = input.Permission
+	share.ExpiresAt = input.ExpiresAt
+	if err := s.db.Save(&share).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("SharedWith").Preload("Team").First(&share, "id = ?", share.ID).Error; err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (s *documentService) Unshare(docID, userID, teamID string) error {
+	if (userID == "") == (teamID == "") {
+		return ErrInvalidShareTarget
+	}
+	if userID != "" {
+		return s.db.Where("document_id = ? AND shared_with_id = ?", docID, userID).Delete(&models.DocumentShare{}).Error
+	}
+	return s.db.Where("document_id = ? AND team_id = ?", docID, teamID).Delete(&models.DocumentShare{}).Error
+}
+
+func (s *documentService) Shares(docID string) ([]models.DocumentShare, error) {
+	shares := []models.DocumentShare{}
+	err := s.db.Preload("SharedWith").Preload("Team").
+		Where("document_id = ?", docID).Order("created_at ASC").Find(&shares).Error
+	return shares, err
 }
 
-func (s *documentService) Unshare(docID, userID string) error {
-	return s.db.Where("document_id = ? AND shared_with_id = ?", docID, userID).Delete(&models.DocumentShare{}).Error
+// sharedWith selects the IDs of documents shared with userID, directly or
+// with a team they own or belong to, by shares that have not expired and,
+// when permissions are given, grant one of them
+func (s *documentService) sharedWith(userID string, permissions ...models.SharePermission) *gorm.DB {
+	teams := s.db.Model(&models.Team{}).Select("id").
+		Where("owner_id = ? OR id IN (SELECT team_id FROM team_members WHERE user_id = ?)", userID, userID)
+	query := s.db.Model(&models.DocumentShare{}).Select("document_id").
+		Where("shared_with_id = ? OR team_id IN (?)", userID, teams).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+	if len(permissions) > 0 {
+		query = query.Where("permission IN ?", permissions)
+	}
+	return query
+}
+
+func (s *documentService) SaveContent(id, content, authorID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var doc models.Document
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&doc, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if doc.Content == content {
+			return nil
+		}
+		baseline, err := recordBaseline(tx, &doc)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&doc).Update("content", content).Error; err != nil {
+			return err
+		}
+		doc.Content = content
+
+		var latest models.DocumentRevision
+		err = tx.Where("document_id = ?", id).Order("number DESC").First(&latest).Error
+		if err == nil && !baseline && latest.AuthorID != nil && *latest.AuthorID == authorID &&
+			latest.RestoredFromID == nil && time.Since(latest.CreatedAt) < revisionCoalesceWindow {
+			return tx.Model(&latest).Updates(map[string]interface{}{
+				"title":   doc.Title,
+				"content": content,
+				"size":    len(content),
+			}).Error
+		}
+		_, err = recordRevision(tx, &doc, authorID, nil, s.revisions.Keep)
+		return err
+	})
 }
 
 func (s *documentService) Delete(id string) error {
@@ -154,7 +367,50 @@ func (s *documentService) IsOwner(docID, userID string) bool {
 func (s *documentService) HasAccess(docID, userID string) bool {
 	var count int64
 	s.db.Model(&models.Document{}).
-		Where("id = ? AND (owner_id = ? OR id IN (SELECT document_id FROM document_shares WHERE shared_with_id = ?))", docID, userID, userID).
+		Where("id = ? AND (owner_id = ? OR id IN (?))", docID, userID, s.sharedWith(userID)).
+		Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	// Team-owned documents are visible to members whose team role allows it
+	var doc models.Document
+	if err := s.db.Select("team_id").First(&doc, "id = ?", docID).Error; err != nil || doc.TeamID == nil {
+		return false
+	}
+	return ensureTeamAccess(s.db, *doc.TeamID, userID, TeamDocumentsView) == nil
+}
+
+func (s *documentService) CanEdit(docID, userID string) bool {
+	var count int64
+	s.db.Model(&models.Document{}).
+		Where("id = ? AND (owner_id = ? OR id IN (?))", docID, userID, s.sharedWith(userID, models.SharePermissionEdit)).
+		Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	var doc models.Document
+	if err := s.db.Select("team_id").First(&doc, "id = ?", docID).Error; err != nil || doc.TeamID == nil {
+		return false
+	}
+	return ensureTeamAccess(s.db, *doc.TeamID, userID, TeamDocumentsEdit) == nil
+}
+
+func (s *documentService) CanComment(docID, userID string) bool {
+	var count int64
+	s.db.Model(&models.Document{}).
+		Where("id = ? AND (owner_id = ? OR id IN (?))",
+			docID, userID, s.sharedWith(userID, models.SharePermissionComment, models.SharePermissionEdit)).
 		Count(&count)
-	return count > 0
+	if count > 0 {
+		return true
+	}
+
+	var doc models.Document
+	if err := s.db.Select("team_id").First(&doc, "id = ?", docID).Error; err != nil || doc.TeamID == nil {
+		return false
+	}
+	return ensureTeamAccess(s.db, *doc.TeamID, userID, TeamDocumentsComment) == nil ||
+		ensureTeamAccess(s.db, *doc.TeamID, userID, TeamDocumentsEdit) == nil
 }
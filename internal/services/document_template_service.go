@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// DocumentTemplatesManage is the permission to manage global templates and,
+// as a team role permission, the templates of a team
+const DocumentTemplatesManage = "documents:templates"
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrTemplateDenied   = errors.New("not allowed to manage this template")
+	ErrInvalidTemplate  = errors.New("invalid template")
+)
+
+// DocumentTemplateInput carries template fields; nil fields are left
+// unchanged. Scope and TeamID are fixed once the template exists.
+type DocumentTemplateInput struct {
+	Name        *string
+	Description *string
+	Scope       *string
+	TeamID      *string
+	Title       *string
+	Content     *string
+	Type        *string
+}
+
+// RenderedTemplate is a template with its placeholders filled in
+type RenderedTemplate struct {
+	Title   string
+	Content string
+	Type    string
+}
+
+type DocumentTemplateService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) DocumentTemplateService
+	// List returns the templates userID may use: their personal ones, those of
+	// their teams and the global ones. A scope or teamID narrows the list.
+	List(userID, scope, teamID string) ([]models.DocumentTemplate, error)
+	// Get returns a template userID may use
+	Get(id, userID string) (*models.DocumentTemplate, error)
+
+	// Create, Update and Delete check that userID may manage the template:
+	// personal templates are managed by their creator, team templates by
+	// members whose team role grants DocumentTemplatesManage and global
+	// templates by holders of that permission, which admin reports.
+	Create(userID string, input DocumentTemplateInput, admin bool) (*models.DocumentTemplate, error)
+	Update(id, userID string, input DocumentTemplateInput, admin bool) (*models.DocumentTemplate, error)
+	Delete(id, userID string, admin bool) error
+
+	// Render fills in the placeholders of a template userID may use for a
+	// document created in teamID, if not nil. An empty title is rendered from
+	// the template's title.
+	Render(id, userID string, teamID *string, title string) (*RenderedTemplate, error)
+}
+
+type documentTemplateService struct {
+	db *gorm.DB
+}
+
+func NewDocumentTemplateService(db *gorm.DB) DocumentTemplateService {
+	return &documentTemplateService{db: db}
+}
+
+func (s *documentTemplateService) WithContext(ctx context.Context) DocumentTemplateService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+// usable selects the templates userID may use
+func (s *documentTemplateService) usable(userID string) *gorm.DB {
+	teams := s.db.Model(&models.TeamMember{}).
+		Select("team_members.team_id").
+		Joins("JOIN teams ON teams.id = team_members.team_id AND teams.deleted_at IS NULL").
+		Where("team_members.user_id = ?", userID)
+	return s.db.Model(&models.DocumentTemplate{}).
+		Where("(scope = ? AND creator_id = ?) OR (scope = ? AND team_id IN (?)) OR scope = ?",
+			models.TemplateScopePersonal, userID, models.TemplateScopeTeam, teams, models.TemplateScopeGlobal)
+}
+
+func (s *documentTemplateService) List(userID, scope, teamID string) ([]models.DocumentTemplate, error) {
+	query := s.usable(userID)
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if teamID != "" {
+		if err := ensureTeamAccess(s.db, teamID, userID, TeamDocumentsView); err != nil {
+			return nil, err
+		}
+		query = query.Where("team_id = ?", teamID)
+	}
+
+	templates := []models.DocumentTemplate{}
+	err := query.Preload("Team").Order("scope, name").Find(&templates).Error
+	return templates, err
+}
+
+func (s *documentTemplateService) Get(id, userID string) (*models.DocumentTemplate, error) {
+	var template models.DocumentTemplate
+	err := s.usable(userID).Preload("Team").Preload("Creator").First(&template, "document_templates.id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if template.Scope == models.TemplateScopeTeam {
+		if err := ensureTeamAccess(s.db, *template.TeamID, userID, TeamDocumentsView); err != nil {
+			return nil, ErrTemplateNotFound
+		}
+	}
+	return &template, nil
+}
+
+func (s *documentTemplateService) Create(userID string, input DocumentTemplateInput, admin bool) (*models.DocumentTemplate, error) {
+	template := &models.DocumentTemplate{
+		Scope:     models.TemplateScopePersonal,
+		CreatorID: &userID,
+		Type:      models.DocumentTypeMarkdown,
+	}
+	if input.Scope != nil && *input.Scope != "" {
+		template.Scope = *input.Scope
+	}
+	if input.TeamID != nil && *input.TeamID != "" {
+		template.TeamID = input.TeamID
+	}
+	switch template.Scope {
+	case models.TemplateScopePersonal, models.TemplateScopeGlobal:
+		if template.TeamID != nil {
+			return nil, fmt.Errorf("%w: teamId is only allowed for team templates", ErrInvalidTemplate)
+		}
+	case models.TemplateScopeTeam:
+		if template.TeamID == nil {
+			return nil, fmt.Errorf("%w: team templates require a teamId", ErrInvalidTemplate)
+		}
+	default:
+		return nil, fmt.Errorf("%w: scope must be personal, team or global", ErrInvalidTemplate)
+	}
+	if input.Name == nil {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+	if err := applyTemplateInput(template, input); err != nil {
+		return nil, err
+	}
+	if err := s.canManage(template, userID, admin); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Create(template).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(template.ID, userID)
+}
+
+func (s *documentTemplateService) Update(id, userID string, input DocumentTemplateInput, admin bool) (*models.DocumentTemplate, error) {
+	template, err := s.Get(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.canManage(template, userID, admin); err != nil {
+		return nil, err
+	}
+	if (input.Scope != nil && *input.Scope != template.Scope) ||
+		(input.TeamID != nil && (template.TeamID == nil || *input.TeamID != *template.TeamID)) {
+		return nil, fmt.Errorf("%w: scope and teamId cannot be changed", ErrInvalidTemplate)
+	}
+	if err := applyTemplateInput(template, input); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Model(&models.DocumentTemplate{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"name":        template.Name,
+		"description": template.Description,
+		"title":       template.Title,
+		"content":     template.Content,
+		"type":        template.Type,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(id, userID)
+}
+
+func (s *documentTemplateService) Delete(id, userID string, admin bool) error {
+	template, err := s.Get(id, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.canManage(template, userID, admin); err != nil {
+		return err
+	}
+	return s.db.Delete(&models.DocumentTemplate{}, "id = ?", id).Error
+}
+
+// canManage checks that userID may create, change or delete template
+func (s *documentTemplateService) canManage(template *models.DocumentTemplate, userID string, admin bool) error {
+	switch template.Scope {
+	case models.TemplateScopePersonal:
+		if template.CreatorID == nil || *template.CreatorID != userID {
+			return ErrTemplateDenied
+		}
+	case models.TemplateScopeTeam:
+		err := ensureTeamAccess(s.db, *template.TeamID, userID, DocumentTemplatesManage)
+		if errors.Is(err, ErrTeamRoleDenied) {
+			return ErrTemplateDenied
+		}
+		return err
+	case models.TemplateScopeGlobal:
+		if !admin {
+			return ErrTemplateDenied
+		}
+	}
+	return nil
+}
+
+// applyTemplateInput validates the editable fields of input and copies them
+// into template
+func applyTemplateInput(template *models.DocumentTemplate, input DocumentTemplateInput) error {
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" || utf8.RuneCountInString(name) > 100 {
+			return fmt.Errorf("%w: name must be 1 to 100 characters", ErrInvalidTemplate)
+		}
+		template.Name = name
+	}
+	if input.Description != nil {
+		description := strings.TrimSpace(*input.Description)
+		if utf8.RuneCountInString(description) > 500 {
+			return fmt.Errorf("%w: description must be at most 500 characters", ErrInvalidTemplate)
+		}
+		template.Description = description
+	}
+	if input.Title != nil {
+		title := strings.TrimSpace(*input.Title)
+		if utf8.RuneCountInString(title) > 255 {
+			return fmt.Errorf("%w: title must be at most 255 characters", ErrInvalidTemplate)
+		}
+		template.Title = title
+	}
+	if input.Content != nil {
+		template.Content = *input.Content
+	}
+	if input.Type != nil {
+		docType := strings.TrimSpace(*input.Type)
+		if docType == "" || len(docType) > 50 {
+			return fmt.Errorf("%w: type must be 1 to 50 characters", ErrInvalidTemplate)
+		}
+		template.Type = docType
+	}
+	return nil
+}
+
+func (s *documentTemplateService) Render(id, userID string, teamID *string, title string) (*RenderedTemplate, error) {
+	template, err := s.Get(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	vars, err := s.templateVars(userID, teamID)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := &RenderedTemplate{Title: strings.TrimSpace(title), Type: template.Type}
+	if rendered.Title == "" {
+		rendered.Title = template.Title
+		if rendered.Title == "" {
+			rendered.Title = template.Name
+		}
+		vars["title"] = template.Name
+		rendered.Title = strings.Join(strings.Fields(expandPlaceholders(rendered.Title, vars)), " ")
+		if runes := []rune(rendered.Title); len(runes) > 255 {
+			rendered.Title = string(runes[:255])
+		}
+	}
+	vars["title"] = rendered.Title
+
+	if template.Type == models.DocumentTypeHTML {
+		// Values are user data and must not add markup to the page
+		for name, value := range vars {
+			vars[name] = html.EscapeString(value)
+		}
+	}
+	rendered.Content = expandPlaceholders(template.Content, vars)
+	return rendered, nil
+}
+
+// templateVars returns the placeholder values for a document userID creates
+// in teamID. Dates and times are in the user's preferred time zone.
+func (s *documentTemplateService) templateVars(userID string, teamID *string) (map[string]string, error) {
+	var user models.User
+	if err := s.db.Select("id", "name", "username", "email").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	name := user.Name
+	if name == "" {
+		name = user.Username
+	}
+
+	loc := time.UTC
+	var pref models.UserPreference
+	if err := s.db.Select("time_zone").First(&pref, "user_id = ?", userID).Error; err == nil {
+		if l, err := time.LoadLocation(pref.TimeZone); err == nil {
+			loc = l
+		}
+	}
+	now := time.Now().In(loc)
+
+	vars := map[string]string{
+		"date":          now.Format("2006-01-02"),
+		"time":          now.Format("15:04"),
+		"datetime":      now.Format("2006-01-02 15:04"),
+		"year":          now.Format("2006"),
+		"weekday":       now.Weekday().String(),
+		"user.name":     name,
+		"user.username": user.Username,
+		"user.email":    user.Email,
+		"organization":  organizationName(s.db),
+		"team.name":     "",
+	}
+	if teamID != nil && *teamID != "" {
+		var team models.Team
+		if err := s.db.Select("name").First(&team, "id = ?", *teamID).Error; err == nil {
+			vars["team.name"] = team.Name
+		}
+	}
+	return vars, nil
+}
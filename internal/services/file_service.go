@@ -1,18 +1,40 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+
 	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/storage"
 	"gorm.io/gorm"
 )
 
+// FileBlobKey is the storage key of a file's content. It is derived from the
+// file's ID; Path is a client-chosen location and never names a blob.
+func FileBlobKey(fileID string) string {
+	return "files/" + fileID
+}
+
 type FileService interface {
-	List(userID string, page, limit int, path, fileType, search, folderID string) ([]models.File, int64, error)
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) FileService
+	// List returns the caller's own files, or with teamID the files owned by
+	// that team
+	List(userID string, page, limit int, path, fileType, search, folderID, teamID string) ([]models.File, int64, error)
 	Get(id string) (*models.File, error)
-	Create(name, path, mimeType string, size int64, folderID, teamID, ownerID string) (*models.File, error)
+	// Create adds a file; a teamID requires the owner to belong to that team.
+	// A non-nil content is stored under FileBlobKey; without it only the
+	// metadata is recorded.
+	Create(name, path, mimeType string, size int64, folderID, teamID, ownerID string, content io.Reader) (*models.File, error)
 	Rename(id, name string) (*models.File, error)
 	Move(id, folderID, newPath string) (*models.File, error)
+	// Copy duplicates the metadata and the stored content
 	Copy(id, ownerID string) (*models.File, error)
 	ToggleFavorite(id string) (*models.File, error)
+	// Delete and DeleteMany remove the stored content with the file
 	Delete(id string) error
 	DeleteMany(ids []string, userID string) error
 	GetStorageInfo(userID string) (used, total, available int64, usedPercent int)
@@ -21,18 +43,33 @@ type FileService interface {
 }
 
 type fileService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	files storage.Storage // private blobs
+}
+
+func NewFileService(db *gorm.DB, files storage.Storage) FileService {
+	return &fileService{db: db, files: files}
 }
 
-func NewFileService(db *gorm.DB) FileService {
-	return &fileService{db: db}
+func (s *fileService) WithContext(ctx context.Context) FileService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
 }
 
-func (s *fileService) List(userID string, page, limit int, path, fileType, search, folderID string) ([]models.File, int64, error) {
+func (s *fileService) List(userID string, page, limit int, path, fileType, search, folderID, teamID string) ([]models.File, int64, error) {
 	var files []models.File
 	var total int64
 
-	query := s.db.Model(&models.File{}).Where("owner_id = ?", userID)
+	query := s.db.Model(&models.File{})
+	if teamID != "" {
+		if err := ensureTeamAccess(s.db, teamID, userID, TeamFilesView); err != nil {
+			return nil, 0, err
+		}
+		query = query.Where("team_id = ?", teamID)
+	} else {
+		query = query.Where("owner_id = ?", userID)
+	}
 
 	if folderID != "" {
 		query = query.Where("folder_id = ?", folderID)
@@ -64,7 +101,7 @@ func (s *fileService) Get(id string) (*models.File, error) {
 	return &file, nil
 }
 
-func (s *fileService) Create(name, path, mimeType string, size int64, folderID, teamID, ownerID string) (*models.File, error) {
+func (s *fileService) Create(name, path, mimeType string, size int64, folderID, teamID, ownerID string, content io.Reader) (*models.File, error) {
 	file := &models.File{
 		Name:     name,
 		Path:     path,
@@ -76,6 +113,9 @@ func (s *fileService) Create(name, path, mimeType string, size int64, folderID,
 		file.FolderID = &folderID
 	}
 	if teamID != "" {
+		if _, _, err := teamMembership(s.db, teamID, ownerID); err != nil {
+			return nil, err
+		}
 		file.TeamID = &teamID
 	}
 
@@ -84,10 +124,21 @@ func (s *fileService) Create(name, path, mimeType string, size int64, folderID,
 			return err
 		}
 		// Update user quota
-		return tx.Model(&models.User{}).Where("id = ?", ownerID).Update("quota_used", gorm.Expr("quota_used + ?", size)).Error
+		if err := tx.Model(&models.User{}).Where("id = ?", ownerID).Update("quota_used", gorm.Expr("quota_used + ?", size)).Error; err != nil {
+			return err
+		}
+		if content == nil {
+			return nil
+		}
+		return s.files.Put(FileBlobKey(file.ID), content)
 	})
-
-	return file, err
+	if err != nil {
+		if content != nil && file.ID != "" {
+			_ = s.files.Delete(FileBlobKey(file.ID))
+		}
+		return nil, err
+	}
+	return file, nil
 }
 
 func (s *fileService) Rename(id, name string) (*models.File, error) {
@@ -128,8 +179,25 @@ func (s *fileService) Copy(id, ownerID string) (*models.File, error) {
 		OwnerID:  ownerID,
 	}
 
-	err = s.db.Create(copy).Error
-	return copy, err
+	if err := s.db.Create(copy).Error; err != nil {
+		return nil, err
+	}
+
+	rc, err := s.files.Open(FileBlobKey(original.ID))
+	if errors.Is(err, fs.ErrNotExist) {
+		// Metadata-only files have no content to copy
+		return copy, nil
+	}
+	if err != nil {
+		s.db.Delete(&models.File{}, "id = ?", copy.ID)
+		return nil, err
+	}
+	defer rc.Close()
+	if err := s.files.Put(FileBlobKey(copy.ID), rc); err != nil {
+		s.db.Delete(&models.File{}, "id = ?", copy.ID)
+		return nil, err
+	}
+	return copy, nil
 }
 
 func (s *fileService) ToggleFavorite(id string) (*models.File, error) {
@@ -151,25 +219,49 @@ func (s *fileService) Delete(id string) error {
 		return err
 	}
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	err = s.db.Transaction(func(tx *gorm.DB) error {
 		// Update user quota
 		if err := tx.Model(&models.User{}).Where("id = ?", file.OwnerID).Update("quota_used", gorm.Expr("quota_used - ?", file.Size)).Error; err != nil {
 			return err
 		}
 		return tx.Delete(&models.File{}, "id = ?", id).Error
 	})
+	if err != nil {
+		return err
+	}
+	s.removeBlobs([]string{id})
+	return nil
 }
 
 func (s *fileService) DeleteMany(ids []string, userID string) error {
 	var totalSize int64
 	s.db.Model(&models.File{}).Where("id IN ? AND owner_id = ?", ids, userID).Select("COALESCE(SUM(size), 0)").Scan(&totalSize)
 
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	var deleted []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("quota_used", gorm.Expr("quota_used - ?", totalSize)).Error; err != nil {
 			return err
 		}
-		return tx.Delete(&models.File{}, "id IN ?", ids).Error
+		if err := tx.Model(&models.File{}).Where("id IN ?", ids).Pluck("id", &deleted).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.File{}, "id IN ?", deleted).Error
 	})
+	if err != nil {
+		return err
+	}
+	s.removeBlobs(deleted)
+	return nil
+}
+
+// removeBlobs deletes the stored content of files once the database no longer
+// references them. Failures are logged, not returned.
+func (s *fileService) removeBlobs(ids []string) {
+	for _, id := range ids {
+		if err := s.files.Delete(FileBlobKey(id)); err != nil {
+			log.Printf("file %s: delete blob: %v", id, err)
+		}
+	}
 }
 
 func (s *fileService) GetStorageInfo(userID string) (used, total, available int64, usedPercent int) {
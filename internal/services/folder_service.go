@@ -1,14 +1,21 @@
 package services
 
 import (
+	"context"
+
 	"github.com/halolight/halolight-api-go/internal/models"
 	"gorm.io/gorm"
 )
 
 type FolderService interface {
-	List(userID string, parentID *string) ([]models.Folder, error)
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) FolderService
+	// List returns the caller's own folders, or with teamID the folders owned
+	// by that team
+	List(userID string, parentID *string, teamID string) ([]models.Folder, error)
 	Get(id string) (*models.Folder, error)
 	GetTree(userID string) ([]FolderTreeNode, error)
+	// Create adds a folder; a teamID requires the owner to belong to that team
 	Create(name string, parentID *string, teamID *string, ownerID string) (*models.Folder, error)
 	Rename(id, name string) (*models.Folder, error)
 	Delete(id string) error
@@ -31,9 +38,23 @@ func NewFolderService(db *gorm.DB) FolderService {
 	return &folderService{db: db}
 }
 
-func (s *folderService) List(userID string, parentID *string) ([]models.Folder, error) {
+func (s *folderService) WithContext(ctx context.Context) FolderService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *folderService) List(userID string, parentID *string, teamID string) ([]models.Folder, error) {
 	var folders []models.Folder
-	query := s.db.Where("owner_id = ?", userID)
+	query := s.db.Model(&models.Folder{})
+	if teamID != "" {
+		if err := ensureTeamAccess(s.db, teamID, userID, TeamFoldersView); err != nil {
+			return nil, err
+		}
+		query = query.Where("team_id = ?", teamID)
+	} else {
+		query = query.Where("owner_id = ?", userID)
+	}
 
 	if parentID != nil {
 		query = query.Where("parent_id = ?", *parentID)
@@ -89,6 +110,15 @@ func (s *folderService) GetTree(userID string) ([]FolderTreeNode, error) {
 }
 
 func (s *folderService) Create(name string, parentID *string, teamID *string, ownerID string) (*models.Folder, error) {
+	if teamID != nil && *teamID == "" {
+		teamID = nil
+	}
+	if teamID != nil {
+		if _, _, err := teamMembership(s.db, *teamID, ownerID); err != nil {
+			return nil, err
+		}
+	}
+
 	path := "/" + name
 
 	if parentID != nil {
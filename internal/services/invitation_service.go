@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/mailer"
+	"github.com/halolight/halolight-api-go/pkg/utils"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvitationNotFound = errors.New("invitation not found")
+	ErrInvitationInvalid  = errors.New("invitation is invalid or has expired")
+	ErrInvitationClosed   = errors.New("invitation is no longer pending")
+	ErrInvitationExists   = errors.New("a pending invitation already exists for this email")
+	ErrUnknownRole        = errors.New("unknown role")
+	ErrUnknownTeam        = errors.New("unknown team")
+)
+
+type CreateInvitationInput struct {
+	Email   string
+	RoleIDs []string
+	TeamIDs []string
+}
+
+type AcceptInvitationInput struct {
+	Token    string
+	Username string
+	Password string
+	Name     string
+}
+
+type InvitationService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) InvitationService
+	List(status string, page, limit int) ([]models.Invitation, int64, error)
+	Create(inviterID string, input CreateInvitationInput) (*models.Invitation, error)
+	// Resend issues a fresh token and expiry; links from earlier emails stop working
+	Resend(id string) (*models.Invitation, error)
+	Revoke(id string) (*models.Invitation, error)
+	Accept(input AcceptInvitationInput) (*models.User, error)
+}
+
+type invitationService struct {
+	db     *gorm.DB
+	mail   mailer.Mailer
+	appURL string
+	ttl    time.Duration
+}
+
+func NewInvitationService(db *gorm.DB, mail mailer.Mailer, appURL string, ttl time.Duration) InvitationService {
+	return &invitationService{db: db, mail: mail, appURL: strings.TrimRight(appURL, "/"), ttl: ttl}
+}
+
+func (s *invitationService) WithContext(ctx context.Context) InvitationService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *invitationService) List(status string, page, limit int) ([]models.Invitation, int64, error) {
+	var invitations []models.Invitation
+	var total int64
+
+	query := s.db.Model(&models.Invitation{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	err := query.Preload("Inviter").Offset(offset).Limit(limit).Order("created_at DESC").Find(&invitations).Error
+	return invitations, total, err
+}
+
+func (s *invitationService) Create(inviterID string, input CreateInvitationInput) (*models.Invitation, error) {
+	email := strings.TrimSpace(strings.ToLower(input.Email))
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, ErrInvalidEmail
+	}
+
+	var existing int64
+	tenant.Global(s.db).Model(&models.User{}).Where("email = ?", email).Count(&existing)
+	if existing > 0 {
+		return nil, ErrEmailExists
+	}
+	s.db.Model(&models.Invitation{}).
+		Where("email = ? AND status = ? AND expires_at > ?", email, models.InvitationPending, time.Now()).
+		Count(&existing)
+	if existing > 0 {
+		return nil, ErrInvitationExists
+	}
+
+	roleIDs := uniqueStrings(input.RoleIDs)
+	teamIDs := uniqueStrings(input.TeamIDs)
+	if err := s.ensureExist(&models.Role{}, roleIDs, ErrUnknownRole); err != nil {
+		return nil, err
+	}
+	if err := s.ensureExist(&models.Team{}, teamIDs, ErrUnknownTeam); err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invitation := &models.Invitation{
+		Email:     email,
+		TokenHash: utils.HashToken(token),
+		InviterID: inviterID,
+		RoleIDs:   datatypes.NewJSONSlice(roleIDs),
+		TeamIDs:   datatypes.NewJSONSlice(teamIDs),
+		Status:    models.InvitationPending,
+		ExpiresAt: now.Add(s.ttl),
+		SentAt:    now,
+		SendCount: 1,
+	}
+	if err := s.db.Create(invitation).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.send(invitation, token); err != nil {
+		// Nobody can accept an invitation that was never delivered
+		s.db.Delete(invitation)
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *invitationService) Resend(id string) (*models.Invitation, error) {
+	invitation, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Status != models.InvitationPending {
+		return nil, ErrInvitationClosed
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	invitation.TokenHash = utils.HashToken(token)
+	invitation.ExpiresAt = now.Add(s.ttl)
+	invitation.SentAt = now
+	invitation.SendCount++
+	if err := s.db.Save(invitation).Error; err != nil {
+		return nil, err
+	}
+	if err := s.send(invitation, token); err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *invitationService) Revoke(id string) (*models.Invitation, error) {
+	invitation, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Status != models.InvitationPending {
+		return nil, ErrInvitationClosed
+	}
+	invitation.Status = models.InvitationRevoked
+	if err := s.db.Model(invitation).Update("status", models.InvitationRevoked).Error; err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *invitationService) Accept(input AcceptInvitationInput) (*models.User, error) {
+	username := strings.TrimSpace(input.Username)
+	if len(username) < 3 || len(username) > 64 {
+		return nil, errors.New("username must be between 3 and 64 characters")
+	}
+	if len(input.Password) < 6 {
+		return nil, ErrWeakPassword
+	}
+
+	var invitation models.Invitation
+	err := s.db.First(&invitation, "token_hash = ?", utils.HashToken(input.Token)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvitationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Status != models.InvitationPending || invitation.IsExpired() {
+		return nil, ErrInvitationInvalid
+	}
+
+	hash, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = username
+	}
+	user := &models.User{
+		TenantID: invitation.TenantID,
+		Email:    invitation.Email,
+		Username: username,
+		Password: hash,
+		Name:     name,
+		Status:   models.UserStatusActive,
+	}
+
+	// The account joins the organization that sent the invitation
+	db := s.db.WithContext(tenant.WithID(s.db.Statement.Context, invitation.TenantID))
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		tenant.Global(tx).Model(&models.User{}).Where("email = ?", user.Email).Count(&taken)
+		if taken > 0 {
+			return ErrEmailExists
+		}
+		tenant.Global(tx).Model(&models.User{}).Where("username = ?", username).Count(&taken)
+		if taken > 0 {
+			return ErrUsernameExists
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		// Roles or teams deleted since the invitation was sent are skipped
+		var roleIDs, teamIDs []string
+		if len(invitation.RoleIDs) > 0 {
+			tx.Model(&models.Role{}).Where("id IN ?", []string(invitation.RoleIDs)).Pluck("id", &roleIDs)
+		}
+		if len(invitation.TeamIDs) > 0 {
+			tx.Model(&models.Team{}).Where("id IN ?", []string(invitation.TeamIDs)).Pluck("id", &teamIDs)
+		}
+		for _, roleID := range roleIDs {
+			if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: roleID}).Error; err != nil {
+				return err
+			}
+		}
+		for _, teamID := range teamIDs {
+			if err := tx.Create(&models.TeamMember{TeamID: teamID, UserID: user.ID}).Error; err != nil {
+				return err
+			}
+		}
+
+		// The status guard makes concurrent accepts of the same token fail
+		now := time.Now()
+		res := tx.Model(&models.Invitation{}).
+			Where("id = ? AND status = ?", invitation.ID, models.InvitationPending).
+			Updates(map[string]interface{}{
+				"status":           models.InvitationAccepted,
+				"accepted_at":      now,
+				"accepted_user_id": user.ID,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvitationInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *invitationService) get(id string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := s.db.First(&invitation, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (s *invitationService) ensureExist(model interface{}, ids []string, notFound error) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var count int64
+	if err := s.db.Model(model).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(ids) {
+		return notFound
+	}
+	return nil
+}
+
+func (s *invitationService) send(invitation *models.Invitation, token string) error {
+	var inviter models.User
+	s.db.Select("name", "email").First(&inviter, "id = ?", invitation.InviterID)
+	from := inviter.Name
+	if from == "" {
+		from = inviter.Email
+	}
+
+	link := s.appURL + "/invite/accept?token=" + url.QueryEscape(token)
+	expires := invitation.ExpiresAt.UTC().Format("2006-01-02 15:04 MST")
+	text := fmt.Sprintf("%s invited you to join HaloLight.\n\nAccept the invitation and choose your password:\n%s\n\nThis link expires on %s.\n",
+		from, link, expires)
+	body := fmt.Sprintf(`<p>%s invited you to join HaloLight.</p><p><a href="%s">Accept the invitation</a> and choose your password.</p><p>This link expires on %s.</p>`,
+		html.EscapeString(from), html.EscapeString(link), expires)
+
+	if err := s.mail.Send(mailer.Message{
+		To:      []string{invitation.Email},
+		Subject: "You're invited to HaloLight",
+		Text:    text,
+		HTML:    body,
+	}); err != nil {
+		return fmt.Errorf("failed to send invitation email: %w", err)
+	}
+	return nil
+}
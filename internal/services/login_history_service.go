@@ -0,0 +1,200 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/mailer"
+	"gorm.io/gorm"
+)
+
+// Failure reasons stored on unsuccessful login events
+const (
+	LoginFailedPassword  = "invalid_password"
+	LoginFailedInactive  = "account_inactive"
+	LoginFailedSuspended = "account_suspended"
+)
+
+// LoginAttempt describes a sign-in. UserID may be left empty on failures, in
+// which case the user is looked up by Email; unknown emails are not recorded.
+type LoginAttempt struct {
+	UserID        string
+	Email         string
+	Method        string
+	Success       bool
+	FailureReason string
+	IP            string
+	UserAgent     string
+}
+
+type LoginHistoryService interface {
+	// Record stores the attempt. Successful logins update LastLoginAt and alert
+	// the user when they come from a new device or network.
+	Record(attempt LoginAttempt) (*models.LoginEvent, error)
+	List(userID string, page, limit int) ([]models.LoginEvent, int64, error)
+}
+
+type loginHistoryService struct {
+	db            *gorm.DB
+	notifications NotificationService
+	prefs         PreferenceService
+	mail          mailer.Mailer
+}
+
+func NewLoginHistoryService(db *gorm.DB, notifications NotificationService, prefs PreferenceService, mail mailer.Mailer) LoginHistoryService {
+	return &loginHistoryService{db: db, notifications: notifications, prefs: prefs, mail: mail}
+}
+
+func (s *loginHistoryService) Record(attempt LoginAttempt) (*models.LoginEvent, error) {
+	userID := attempt.UserID
+	if userID == "" {
+		var ids []string
+		email := strings.TrimSpace(strings.ToLower(attempt.Email))
+		if err := s.db.Model(&models.User{}).Where("email = ?", email).Limit(1).Pluck("id", &ids).Error; err != nil {
+			return nil, err
+		}
+		if len(ids) == 0 {
+			return nil, nil
+		}
+		userID = ids[0]
+	}
+
+	userAgent := attempt.UserAgent
+	if len(userAgent) > 500 {
+		userAgent = strings.ToValidUTF8(userAgent[:500], "")
+	}
+	event := &models.LoginEvent{
+		UserID:        userID,
+		Method:        attempt.Method,
+		Success:       attempt.Success,
+		FailureReason: optionalString(attempt.FailureReason),
+		IP:            attempt.IP,
+		Network:       ipNetwork(attempt.IP),
+		UserAgent:     userAgent,
+		DeviceHash:    deviceHash(userAgent),
+	}
+
+	if event.Success {
+		// The very first login is not suspicious; there is nothing to compare with
+		var seen struct {
+			Logins   int64
+			Devices  int64
+			Networks int64
+		}
+		err := s.db.Model(&models.LoginEvent{}).
+			Select("COUNT(*) AS logins, "+
+				"COUNT(*) FILTER (WHERE device_hash = ?) AS devices, "+
+				"COUNT(*) FILTER (WHERE network = ?) AS networks", event.DeviceHash, event.Network).
+			Where("user_id = ? AND success = ?", userID, true).
+			Scan(&seen).Error
+		if err != nil {
+			return nil, err
+		}
+		event.NewDevice = seen.Logins > 0 && seen.Devices == 0
+		event.NewNetwork = seen.Logins > 0 && seen.Networks == 0 && event.Network != ""
+	}
+
+	if err := s.db.Create(event).Error; err != nil {
+		return nil, err
+	}
+
+	if event.Success {
+		if err := s.db.Model(&models.User{}).Where("id = ?", userID).Update("last_login_at", event.CreatedAt).Error; err != nil {
+			return nil, err
+		}
+		if event.NewDevice || event.NewNetwork {
+			// Alerts must not slow down or fail the login itself
+			go s.alert(event)
+		}
+	}
+	return event, nil
+}
+
+func (s *loginHistoryService) List(userID string, page, limit int) ([]models.LoginEvent, int64, error) {
+	var events []models.LoginEvent
+	var total int64
+
+	query := s.db.Model(&models.LoginEvent{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+	err := query.Offset(offset).Limit(limit).Order("created_at DESC").Find(&events).Error
+	return events, total, err
+}
+
+func (s *loginHistoryService) alert(event *models.LoginEvent) {
+	what := "a new device"
+	if !event.NewDevice {
+		what = "a new network"
+	} else if event.NewNetwork {
+		what = "a new device and network"
+	}
+	at := event.CreatedAt.In(s.prefs.Location(event.UserID)).Format("2006-01-02 15:04 MST")
+	content := fmt.Sprintf("Your account was signed in from %s on %s (IP %s, %s). If this wasn't you, change your password.",
+		what, at, event.IP, event.UserAgent)
+
+	payload := map[string]interface{}{
+		"loginEventId": event.ID,
+		"ip":           event.IP,
+		"userAgent":    event.UserAgent,
+		"newDevice":    event.NewDevice,
+		"newNetwork":   event.NewNetwork,
+	}
+	if _, err := s.notifications.Notify(event.UserID, "alert", "New sign-in to your account", content, nil, payload); err != nil {
+		log.Printf("login alert %s: %v", event.ID, err)
+	}
+
+	if !s.prefs.Allows(event.UserID, models.ChannelEmail, "alert") {
+		return
+	}
+	var user models.User
+	if err := s.db.Select("email").First(&user, "id = ?", event.UserID).Error; err != nil {
+		log.Printf("login alert %s: %v", event.ID, err)
+		return
+	}
+	err := s.mail.Send(mailer.Message{
+		To:      []string{user.Email},
+		Subject: "New sign-in to your HaloLight account",
+		Text:    content + "\n",
+	})
+	if err != nil {
+		log.Printf("login alert %s: %v", event.ID, err)
+	}
+}
+
+// ipNetwork returns the /24 (IPv4) or /48 (IPv6) range of ip, or "" when ip
+// cannot be parsed
+func ipNetwork(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return fmt.Sprintf("%s/24", v4.Mask(net.CIDRMask(24, 32)))
+	}
+	return fmt.Sprintf("%s/48", parsed.Mask(net.CIDRMask(48, 128)))
+}
+
+func deviceHash(userAgent string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(userAgent)))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoginFailureReason maps a login error to the reason stored on the event
+func LoginFailureReason(err error) string {
+	switch {
+	case errors.Is(err, ErrAccountSuspended):
+		return LoginFailedSuspended
+	case errors.Is(err, ErrAccountInactive):
+		return LoginFailedInactive
+	}
+	return LoginFailedPassword
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"time"
 
 	"github.com/halolight/halolight-api-go/internal/models"
@@ -8,6 +9,8 @@ import (
 )
 
 type MessageService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) MessageService
 	GetConversations(userID string) ([]ConversationWithLastMessage, error)
 	GetConversation(id, userID string) (*models.Conversation, error)
 	CreateConversation(name string, isGroup bool, participantIDs []string, creatorID string) (*models.Conversation, error)
@@ -32,6 +35,12 @@ func NewMessageService(db *gorm.DB) MessageService {
 	return &messageService{db: db}
 }
 
+func (s *messageService) WithContext(ctx context.Context) MessageService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
 func (s *messageService) GetConversations(userID string) ([]ConversationWithLastMessage, error) {
 	var conversations []models.Conversation
 	err := s.db.
@@ -97,6 +106,9 @@ func (s *messageService) CreateConversation(name string, isGroup bool, participa
 	for _, id := range participantIDs {
 		allParticipants[id] = true
 	}
+	if err := ensureUsers(s.db, participantIDs); err != nil {
+		return nil, err
+	}
 
 	conversation := &models.Conversation{
 		IsGroup: isGroup || len(allParticipants) > 2,
@@ -1,9 +1,11 @@
 package services
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/halolight/halolight-api-go/internal/models"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -13,16 +15,20 @@ type NotificationService interface {
 	MarkAsRead(id string) (*models.Notification, error)
 	MarkAllAsRead(userID string) error
 	Create(userID, notifType, title, content string, link *string, payload interface{}) (*models.Notification, error)
+	// Notify is Create for system-generated notifications: it respects the
+	// recipient's preferences and returns nil without error when they opted out
+	Notify(userID, notifType, title, content string, link *string, payload interface{}) (*models.Notification, error)
 	Delete(id string) error
 	IsOwner(notifID, userID string) bool
 }
 
 type notificationService struct {
-	db *gorm.DB
+	db    *gorm.DB
+	prefs PreferenceService
 }
 
-func NewNotificationService(db *gorm.DB) NotificationService {
-	return &notificationService{db: db}
+func NewNotificationService(db *gorm.DB, prefs PreferenceService) NotificationService {
+	return &notificationService{db: db, prefs: prefs}
 }
 
 func (s *notificationService) List(userID string, page, limit int, unreadOnly bool) ([]models.Notification, int64, int64, error) {
@@ -81,10 +87,24 @@ func (s *notificationService) Create(userID, notifType, title, content string, l
 		Content: content,
 		Link:    link,
 	}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		notification.Payload = datatypes.JSON(raw)
+	}
 	err := s.db.Create(notification).Error
 	return notification, err
 }
 
+func (s *notificationService) Notify(userID, notifType, title, content string, link *string, payload interface{}) (*models.Notification, error) {
+	if !s.prefs.Allows(userID, models.ChannelInApp, notifType) {
+		return nil, nil
+	}
+	return s.Create(userID, notifType, title, content, link, payload)
+}
+
 func (s *notificationService) Delete(id string) error {
 	return s.db.Delete(&models.Notification{}, "id = ?", id).Error
 }
@@ -0,0 +1,565 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDepartmentNotFound = errors.New("department not found")
+	ErrDepartmentName     = errors.New("department name is required")
+	ErrDepartmentExists   = errors.New("a department with this name already exists under the same parent")
+	ErrDepartmentCycle    = errors.New("a department cannot be moved below itself")
+	ErrDepartmentNotEmpty = errors.New("department still has sub-departments or members")
+	ErrManagerNotFound    = errors.New("manager not found")
+	ErrManagerCycle       = errors.New("manager assignment would create a reporting cycle")
+)
+
+// maxOrgDepth bounds hierarchy walks so bad data can never loop forever
+const maxOrgDepth = 64
+
+// orgLockKey serializes manager and department hierarchy changes so two
+// concurrent updates cannot each pass the cycle check and form a cycle together
+const orgLockKey = 0x6f7267 // "org"
+
+// DepartmentInput carries department fields; nil fields are left unchanged.
+// An empty ParentID or HeadID clears the value.
+type DepartmentInput struct {
+	Name        *string
+	Description *string
+	ParentID    *string
+	HeadID      *string
+}
+
+// OrgMember is a user in a reporting line. Depth is 1 for direct reports or
+// the direct manager and grows with each level.
+type OrgMember struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	Email        string  `json:"email"`
+	Avatar       *string `json:"avatar,omitempty"`
+	Position     *string `json:"position,omitempty"`
+	Department   *string `json:"department,omitempty"`
+	DepartmentID *string `json:"departmentId,omitempty"`
+	ManagerID    *string `json:"managerId,omitempty"`
+	Depth        int     `json:"depth"`
+}
+
+// OrgNode is a user in the org tree with their direct reports
+type OrgNode struct {
+	OrgMember
+	Reports []*OrgNode `json:"reports"`
+}
+
+type OrgService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) OrgService
+	ListDepartments() ([]models.Department, error)
+	// DepartmentTree returns the root departments with nested children and member counts
+	DepartmentTree() ([]*models.Department, error)
+	GetDepartment(id string) (*models.Department, error)
+	CreateDepartment(input DepartmentInput) (*models.Department, error)
+	UpdateDepartment(id string, input DepartmentInput) (*models.Department, error)
+	DeleteDepartment(id string) error
+
+	// SetDepartment assigns the user to a department, or clears it with ""
+	SetDepartment(userID, departmentID string) (*models.User, error)
+	// SetManager sets the user's manager, or clears it with ""
+	SetManager(userID, managerID string) (*models.User, error)
+
+	// Tree returns the reporting tree below rootID, or the whole organization
+	// starting from users without a manager when rootID is empty
+	Tree(rootID string) ([]*OrgNode, error)
+	Reports(userID string, directOnly bool) ([]OrgMember, error)
+	// ManagementChain lists the user's managers from the direct manager upwards
+	ManagementChain(userID string) ([]OrgMember, error)
+	// IsReport reports whether userID reports to managerID directly or indirectly
+	IsReport(managerID, userID string) (bool, error)
+}
+
+type orgService struct {
+	db *gorm.DB
+}
+
+func NewOrgService(db *gorm.DB) OrgService {
+	return &orgService{db: db}
+}
+
+func (s *orgService) WithContext(ctx context.Context) OrgService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *orgService) ListDepartments() ([]models.Department, error) {
+	var departments []models.Department
+	err := s.db.Order("name").Find(&departments).Error
+	return departments, err
+}
+
+func (s *orgService) DepartmentTree() ([]*models.Department, error) {
+	departments, err := s.ListDepartments()
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []struct {
+		DepartmentID string
+		Count        int64
+	}
+	err = s.db.Model(&models.User{}).
+		Select("department_id, COUNT(*) AS count").
+		Where("department_id IS NOT NULL").
+		Group("department_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*models.Department, len(departments))
+	for i := range departments {
+		byID[departments[i].ID] = &departments[i]
+	}
+	for _, c := range counts {
+		if d, ok := byID[c.DepartmentID]; ok {
+			d.MemberCount = c.Count
+		}
+	}
+
+	roots := []*models.Department{}
+	for i := range departments {
+		d := &departments[i]
+		if d.ParentID != nil {
+			if parent, ok := byID[*d.ParentID]; ok {
+				parent.Children = append(parent.Children, d)
+				continue
+			}
+		}
+		roots = append(roots, d)
+	}
+	return roots, nil
+}
+
+func (s *orgService) GetDepartment(id string) (*models.Department, error) {
+	var department models.Department
+	err := s.db.Preload("Head").First(&department, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrDepartmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.db.Model(&models.User{}).Where("department_id = ?", id).Count(&department.MemberCount)
+	return &department, nil
+}
+
+func (s *orgService) CreateDepartment(input DepartmentInput) (*models.Department, error) {
+	if input.Name == nil || strings.TrimSpace(*input.Name) == "" {
+		return nil, ErrDepartmentName
+	}
+	department := &models.Department{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.applyDepartment(tx, department, input); err != nil {
+			return err
+		}
+		return tx.Create(department).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return department, nil
+}
+
+func (s *orgService) UpdateDepartment(id string, input DepartmentInput) (*models.Department, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var department models.Department
+		err := tx.First(&department, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDepartmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		renamed := input.Name != nil && strings.TrimSpace(*input.Name) != department.Name
+		if err := s.applyDepartment(tx, &department, input); err != nil {
+			return err
+		}
+		err = tx.Model(&department).Updates(map[string]interface{}{
+			"name":        department.Name,
+			"description": department.Description,
+			"parent_id":   department.ParentID,
+			"head_id":     department.HeadID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if renamed {
+			// Keep the free-text department of members in step
+			return tx.Model(&models.User{}).Where("department_id = ?", id).Update("department", department.Name).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetDepartment(id)
+}
+
+// applyDepartment validates input and copies it onto department
+func (s *orgService) applyDepartment(tx *gorm.DB, department *models.Department, input DepartmentInput) error {
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return ErrDepartmentName
+		}
+		department.Name = name
+	}
+	if input.Description != nil {
+		department.Description = optionalString(*input.Description)
+	}
+	if input.HeadID != nil {
+		department.HeadID = optionalString(*input.HeadID)
+		if department.HeadID != nil {
+			if err := s.ensureUser(tx, *department.HeadID, ErrUserNotFound); err != nil {
+				return err
+			}
+		}
+	}
+	if input.ParentID != nil {
+		department.ParentID = optionalString(*input.ParentID)
+		if department.ParentID != nil {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", orgLockKey).Error; err != nil {
+				return err
+			}
+			var parent models.Department
+			err := tx.Select("id").First(&parent, "id = ?", *department.ParentID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDepartmentNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if department.ID != "" {
+				ancestors, err := s.departmentAncestors(tx, parent.ID)
+				if err != nil {
+					return err
+				}
+				for _, a := range append(ancestors, parent.ID) {
+					if a == department.ID {
+						return ErrDepartmentCycle
+					}
+				}
+			}
+		}
+	}
+
+	var taken int64
+	query := tx.Model(&models.Department{}).Where("LOWER(name) = LOWER(?) AND id <> ?", department.Name, department.ID)
+	if department.ParentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *department.ParentID)
+	}
+	if err := query.Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return ErrDepartmentExists
+	}
+	return nil
+}
+
+func (s *orgService) DeleteDepartment(id string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var department models.Department
+		err := tx.Select("id").First(&department, "id = ?", id).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDepartmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		var children, members int64
+		tx.Model(&models.Department{}).Where("parent_id = ?", id).Count(&children)
+		tx.Model(&models.User{}).Where("department_id = ?", id).Count(&members)
+		if children > 0 || members > 0 {
+			return ErrDepartmentNotEmpty
+		}
+		return tx.Delete(&department).Error
+	})
+}
+
+func (s *orgService) SetDepartment(userID, departmentID string) (*models.User, error) {
+	updates := map[string]interface{}{"department_id": nil}
+	if departmentID != "" {
+		var department models.Department
+		err := s.db.Select("id", "name").First(&department, "id = ?", departmentID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDepartmentNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		updates = map[string]interface{}{"department_id": department.ID, "department": department.Name}
+	}
+	return s.updateUser(userID, updates)
+}
+
+func (s *orgService) SetManager(userID, managerID string) (*models.User, error) {
+	if managerID == "" {
+		return s.updateUser(userID, map[string]interface{}{"manager_id": nil})
+	}
+	if managerID == userID {
+		return nil, ErrManagerCycle
+	}
+
+	var user models.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", orgLockKey).Error; err != nil {
+			return err
+		}
+		if err := s.ensureUser(tx, userID, ErrUserNotFound); err != nil {
+			return err
+		}
+		if err := s.ensureUser(tx, managerID, ErrManagerNotFound); err != nil {
+			return err
+		}
+
+		chain, err := s.chain(tx, managerID)
+		if err != nil {
+			return err
+		}
+		for _, m := range chain {
+			if m.ID == userID {
+				return ErrManagerCycle
+			}
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("manager_id", managerID).Error; err != nil {
+			return err
+		}
+		return tx.First(&user, "id = ?", userID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *orgService) updateUser(userID string, updates map[string]interface{}) (*models.User, error) {
+	res := s.db.Model(&models.User{}).Where("id = ?", userID).Updates(updates)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrUserNotFound
+	}
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *orgService) ensureUser(tx *gorm.DB, id string, notFound error) error {
+	var count int64
+	if err := tx.Model(&models.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func (s *orgService) Tree(rootID string) ([]*OrgNode, error) {
+	var members []OrgMember
+	err := s.db.Model(&models.User{}).
+		Select("id", "name", "email", "avatar", "position", "department", "department_id", "manager_id").
+		Order("name").
+		Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*OrgNode, len(members))
+	for _, m := range members {
+		nodes[m.ID] = &OrgNode{OrgMember: m, Reports: []*OrgNode{}}
+	}
+
+	var roots []*OrgNode
+	children := make(map[string][]*OrgNode)
+	for _, m := range members {
+		node := nodes[m.ID]
+		if m.ManagerID != nil {
+			if _, ok := nodes[*m.ManagerID]; ok {
+				children[*m.ManagerID] = append(children[*m.ManagerID], node)
+				continue
+			}
+		}
+		// Managers that were deleted or deactivated leave their reports at the top
+		roots = append(roots, node)
+	}
+
+	if rootID != "" {
+		root, ok := nodes[rootID]
+		if !ok {
+			return nil, ErrUserNotFound
+		}
+		roots = []*OrgNode{root}
+	}
+
+	// Attach reports depth-first; visited guards against cycles in bad data
+	visited := make(map[string]bool, len(nodes))
+	var attach func(node *OrgNode, depth int)
+	attach = func(node *OrgNode, depth int) {
+		visited[node.ID] = true
+		node.Depth = depth
+		if depth >= maxOrgDepth {
+			return
+		}
+		for _, child := range children[node.ID] {
+			if visited[child.ID] {
+				continue
+			}
+			node.Reports = append(node.Reports, child)
+			attach(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		attach(root, 0)
+	}
+	return roots, nil
+}
+
+func (s *orgService) Reports(userID string, directOnly bool) ([]OrgMember, error) {
+	if err := s.ensureUser(s.db, userID, ErrUserNotFound); err != nil {
+		return nil, err
+	}
+	depth := maxOrgDepth
+	if directOnly {
+		depth = 1
+	}
+
+	var levels []orgLevel
+	err := s.db.Raw(`
+		WITH RECURSIVE reports AS (
+			SELECT id, 1 AS depth, ARRAY[?::text, id::text] AS path
+			FROM users WHERE manager_id = ? AND deleted_at IS NULL
+			UNION ALL
+			SELECT u.id, r.depth + 1, r.path || u.id::text
+			FROM users u JOIN reports r ON u.manager_id = r.id
+			WHERE u.deleted_at IS NULL AND r.depth < ? AND NOT u.id::text = ANY(r.path)
+		)
+		SELECT id, depth FROM reports`, userID, userID, depth).
+		Scan(&levels).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.members(levels)
+}
+
+func (s *orgService) ManagementChain(userID string) ([]OrgMember, error) {
+	if err := s.ensureUser(s.db, userID, ErrUserNotFound); err != nil {
+		return nil, err
+	}
+	levels, err := s.chain(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.members(levels)
+}
+
+func (s *orgService) IsReport(managerID, userID string) (bool, error) {
+	chain, err := s.chain(s.db, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, m := range chain {
+		if m.ID == managerID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type orgLevel struct {
+	ID    string
+	Depth int
+}
+
+// chain walks up the manager references of userID
+func (s *orgService) chain(tx *gorm.DB, userID string) ([]orgLevel, error) {
+	var levels []orgLevel
+	err := tx.Raw(`
+		WITH RECURSIVE chain AS (
+			SELECT m.id, m.manager_id, 1 AS depth, ARRAY[u.id::text, m.id::text] AS path
+			FROM users u JOIN users m ON m.id = u.manager_id
+			WHERE u.id = ? AND m.deleted_at IS NULL
+			UNION ALL
+			SELECT m.id, m.manager_id, c.depth + 1, c.path || m.id::text
+			FROM users m JOIN chain c ON m.id = c.manager_id
+			WHERE m.deleted_at IS NULL AND c.depth < ? AND NOT m.id::text = ANY(c.path)
+		)
+		SELECT id, depth FROM chain ORDER BY depth`, userID, maxOrgDepth).
+		Scan(&levels).Error
+	return levels, err
+}
+
+func (s *orgService) departmentAncestors(tx *gorm.DB, departmentID string) ([]string, error) {
+	var ids []string
+	err := tx.Raw(`
+		WITH RECURSIVE ancestors AS (
+			SELECT p.id, p.parent_id, 1 AS depth
+			FROM departments d JOIN departments p ON p.id = d.parent_id
+			WHERE d.id = ?
+			UNION ALL
+			SELECT p.id, p.parent_id, a.depth + 1
+			FROM departments p JOIN ancestors a ON p.id = a.parent_id
+			WHERE a.depth < ?
+		)
+		SELECT id FROM ancestors`, departmentID, maxOrgDepth).
+		Scan(&ids).Error
+	return ids, err
+}
+
+// members loads the users of levels, ordered by depth and name
+func (s *orgService) members(levels []orgLevel) ([]OrgMember, error) {
+	members := []OrgMember{}
+	if len(levels) == 0 {
+		return members, nil
+	}
+	depths := make(map[string]int, len(levels))
+	ids := make([]string, 0, len(levels))
+	for _, l := range levels {
+		if _, ok := depths[l.ID]; !ok {
+			depths[l.ID] = l.Depth
+			ids = append(ids, l.ID)
+		}
+	}
+
+	err := s.db.Model(&models.User{}).
+		Select("id", "name", "email", "avatar", "position", "department", "department_id", "manager_id").
+		Where("id IN ?", ids).
+		Scan(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	for i := range members {
+		members[i].Depth = depths[members[i].ID]
+	}
+	sort.SliceStable(members, func(i, j int) bool {
+		if members[i].Depth != members[j].Depth {
+			return members[i].Depth < members[j].Depth
+		}
+		return members[i].Name < members[j].Name
+	})
+	return members, nil
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationExists   = errors.New("an organization with this slug already exists")
+	ErrInvalidOrganization  = errors.New("organization name is required and slug must be 2-64 lowercase letters, digits or dashes")
+	ErrSCIMGroupOwner       = errors.New("the SCIM group owner must be a user of the organization")
+)
+
+// AdminRoleName is the role every organization is created with. It grants
+// all permissions within that organization.
+const AdminRoleName = "admin"
+
+var organizationSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,63}$`)
+
+// CreateOrganizationInput describes a new organization and its first administrator
+type CreateOrganizationInput struct {
+	Name          string
+	Slug          string
+	AdminEmail    string
+	AdminUsername string
+	AdminName     string
+	AdminPassword string
+}
+
+// OrganizationService manages tenants. Organizations are not tenant-owned,
+// so its queries are never scoped.
+type OrganizationService interface {
+	List(page, limit int) ([]models.Organization, int64, error)
+	Get(id string) (*models.Organization, error)
+	// Create sets up the organization with an admin role and its first administrator
+	Create(input CreateOrganizationInput) (*models.Organization, *models.User, error)
+	Rename(id, name string) (*models.Organization, error)
+	// SetSCIMToken replaces the organization's SCIM bearer token and returns
+	// the new one, which cannot be recovered later. A groupOwnerID must be a
+	// user of the organization.
+	SetSCIMToken(id, groupOwnerID string) (string, error)
+	// DisableSCIM removes the organization's SCIM bearer token
+	DisableSCIM(id string) error
+	// SCIMTenantID returns the organization a SCIM bearer token provisions
+	SCIMTenantID(token string) (string, error)
+}
+
+type organizationService struct {
+	db *gorm.DB
+}
+
+func NewOrganizationService(db *gorm.DB) OrganizationService {
+	return &organizationService{db: db}
+}
+
+func (s *organizationService) List(page, limit int) ([]models.Organization, int64, error) {
+	var organizations []models.Organization
+	var total int64
+
+	query := s.db.Model(&models.Organization{})
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	err := query.Offset(offset).Limit(limit).Order("created_at ASC").Find(&organizations).Error
+	return organizations, total, err
+}
+
+func (s *organizationService) Get(id string) (*models.Organization, error) {
+	var organization models.Organization
+	err := s.db.First(&organization, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (s *organizationService) Create(input CreateOrganizationInput) (*models.Organization, *models.User, error) {
+	name := strings.TrimSpace(input.Name)
+	slug := strings.ToLower(strings.TrimSpace(input.Slug))
+	if name == "" || !organizationSlug.MatchString(slug) {
+		return nil, nil, ErrInvalidOrganization
+	}
+	email := strings.TrimSpace(strings.ToLower(input.AdminEmail))
+	if !strings.Contains(email, "@") {
+		return nil, nil, ErrInvalidEmail
+	}
+	username := strings.TrimSpace(input.AdminUsername)
+	if len(username) < 3 || len(username) > 64 {
+		return nil, nil, errors.New("username must be between 3 and 64 characters")
+	}
+	if len(input.AdminPassword) < 6 {
+		return nil, nil, ErrWeakPassword
+	}
+	hash, err := utils.HashPassword(input.AdminPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+	adminName := strings.TrimSpace(input.AdminName)
+	if adminName == "" {
+		adminName = username
+	}
+
+	organization := &models.Organization{Name: name, Slug: slug}
+	admin := &models.User{
+		Email:    email,
+		Username: username,
+		Password: hash,
+		Name:     adminName,
+		Status:   models.UserStatusActive,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var taken int64
+		tx.Model(&models.Organization{}).Where("slug = ?", slug).Count(&taken)
+		if taken > 0 {
+			return ErrOrganizationExists
+		}
+		// Sign-in identifiers are unique across organizations
+		tx.Model(&models.User{}).Where("email = ?", email).Count(&taken)
+		if taken > 0 {
+			return ErrEmailExists
+		}
+		tx.Model(&models.User{}).Where("username = ?", username).Count(&taken)
+		if taken > 0 {
+			return ErrUsernameExists
+		}
+
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+
+		// Everything below belongs to the new organization
+		tx = tx.WithContext(tenant.WithID(tx.Statement.Context, organization.ID))
+		role, err := createAdminRole(tx)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(admin).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserRole{UserID: admin.ID, RoleID: role.ID}).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return organization, admin, nil
+}
+
+func (s *organizationService) Rename(id, name string) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidOrganization
+	}
+	organization, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(organization).Update("name", name).Error; err != nil {
+		return nil, err
+	}
+	return organization, nil
+}
+
+func (s *organizationService) SetSCIMToken(id, groupOwnerID string) (string, error) {
+	organization, err := s.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	var owner *string
+	if groupOwnerID != "" {
+		var count int64
+		err := s.db.WithContext(tenant.WithID(context.Background(), id)).
+			Model(&models.User{}).Where("id = ?", groupOwnerID).Count(&count).Error
+		if err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return "", ErrSCIMGroupOwner
+		}
+		owner = &groupOwnerID
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.db.Model(organization).Updates(map[string]interface{}{
+		"scim_token_hash":     utils.HashToken(token),
+		"scim_group_owner_id": owner,
+	}).Error
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *organizationService) DisableSCIM(id string) error {
+	organization, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(organization).Updates(map[string]interface{}{
+		"scim_token_hash":     nil,
+		"scim_group_owner_id": nil,
+	}).Error
+}
+
+func (s *organizationService) SCIMTenantID(token string) (string, error) {
+	if token == "" {
+		return "", ErrOrganizationNotFound
+	}
+	var organization models.Organization
+	err := s.db.Select("id").First(&organization, "scim_token_hash = ?", utils.HashToken(token)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrOrganizationNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return organization.ID, nil
+}
+
+// createAdminRole adds the admin role to the organization tx is scoped to,
+// granting the "*" permission
+func createAdminRole(tx *gorm.DB) (*models.Role, error) {
+	var permission models.Permission
+	err := tx.Where(models.Permission{Action: "*"}).
+		Attrs(models.Permission{Resource: "*"}).
+		FirstOrCreate(&permission).Error
+	if err != nil {
+		return nil, err
+	}
+
+	description := "Full access within the organization"
+	role := &models.Role{Name: AdminRoleName, Label: "Administrator", Description: &description}
+	if err := tx.Create(role).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&models.RolePermission{RoleID: role.ID, PermissionID: permission.ID}).Error; err != nil {
+		return nil, err
+	}
+	return role, nil
+}
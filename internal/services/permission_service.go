@@ -1,6 +1,8 @@
 package services
 
 import (
+	"strings"
+
 	"github.com/halolight/halolight-api-go/internal/models"
 	"gorm.io/gorm"
 )
@@ -10,6 +12,7 @@ type PermissionService interface {
 	Get(id string) (*models.Permission, error)
 	Create(action, resource, description string) (*models.Permission, error)
 	Delete(id string) error
+	HasPermission(userID, action string) bool
 }
 
 type permissionService struct {
@@ -48,3 +51,24 @@ func (s *permissionService) Create(action, resource, description string) (*model
 func (s *permissionService) Delete(id string) error {
 	return s.db.Delete(&models.Permission{}, "id = ?", id).Error
 }
+
+// HasPermission reports whether any of the user's roles grants action, either
+// directly, through a resource wildcard such as "users:*", or through "*".
+func (s *permissionService) HasPermission(userID, action string) bool {
+	var count int64
+	s.db.Model(&models.Permission{}).
+		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
+		Joins("JOIN user_roles ur ON ur.role_id = rp.role_id").
+		Where("ur.user_id = ? AND permissions.action IN ?", userID, permissionCandidates(action)).
+		Count(&count)
+	return count > 0
+}
+
+// permissionCandidates lists the permission actions that grant action
+func permissionCandidates(action string) []string {
+	candidates := []string{action, "*"}
+	if i := strings.Index(action, ":"); i > 0 {
+		candidates = append(candidates, action[:i]+":*")
+	}
+	return candidates
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"golang.org/x/text/language"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidPreference = errors.New("invalid preference")
+
+// NotificationTypes are the notification categories users can mute
+var NotificationTypes = []string{"system", "user", "message", "task", "alert"}
+
+// NotificationPreferencesUpdate carries optional notification settings; nil fields are left unchanged
+type NotificationPreferencesUpdate struct {
+	InApp      *bool     `json:"inApp"`
+	Email      *bool     `json:"email"`
+	MutedTypes *[]string `json:"mutedTypes"`
+}
+
+// PreferenceUpdate carries optional preference fields; nil fields are left unchanged
+type PreferenceUpdate struct {
+	Locale        *string                        `json:"locale"`
+	TimeZone      *string                        `json:"timeZone"`
+	Theme         *string                        `json:"theme"`
+	LandingPage   *string                        `json:"landingPage"`
+	Notifications *NotificationPreferencesUpdate `json:"notifications"`
+}
+
+// PreferenceService stores per-user settings. Get never fails for a missing row;
+// it returns the defaults so other services can read preferences unconditionally.
+type PreferenceService interface {
+	Get(userID string) (*models.UserPreference, error)
+	Update(userID string, input PreferenceUpdate) (*models.UserPreference, error)
+	// Location returns the user's preferred time zone, or UTC on any error
+	Location(userID string) *time.Location
+	// Allows reports whether a notification may be delivered to the user on channel
+	Allows(userID, channel, notifType string) bool
+}
+
+type preferenceService struct {
+	db *gorm.DB
+}
+
+func NewPreferenceService(db *gorm.DB) PreferenceService {
+	return &preferenceService{db: db}
+}
+
+func (s *preferenceService) Get(userID string) (*models.UserPreference, error) {
+	var pref models.UserPreference
+	err := s.db.First(&pref, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultUserPreference(userID), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+func (s *preferenceService) Update(userID string, input PreferenceUpdate) (*models.UserPreference, error) {
+	pref, err := s.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.Locale != nil {
+		tag, err := language.Parse(strings.TrimSpace(*input.Locale))
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown locale %q", ErrInvalidPreference, *input.Locale)
+		}
+		pref.Locale = tag.String()
+	}
+	if input.TimeZone != nil {
+		tz := strings.TrimSpace(*input.TimeZone)
+		if _, err := time.LoadLocation(tz); err != nil || tz == "" || tz == "Local" {
+			return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidPreference, *input.TimeZone)
+		}
+		pref.TimeZone = tz
+	}
+	if input.Theme != nil {
+		theme := models.Theme(*input.Theme)
+		if theme != models.ThemeLight && theme != models.ThemeDark && theme != models.ThemeSystem {
+			return nil, fmt.Errorf("%w: theme must be light, dark or system", ErrInvalidPreference)
+		}
+		pref.Theme = theme
+	}
+	if input.LandingPage != nil {
+		page := strings.TrimSpace(*input.LandingPage)
+		// Only in-app paths, so the setting cannot redirect users to another site
+		if !strings.HasPrefix(page, "/") || strings.HasPrefix(page, "//") || len(page) > 191 {
+			return nil, fmt.Errorf("%w: landingPage must be an application path such as /dashboard", ErrInvalidPreference)
+		}
+		pref.LandingPage = page
+	}
+	if n := input.Notifications; n != nil {
+		notifications := pref.Notifications.Data()
+		if n.InApp != nil {
+			notifications.InApp = *n.InApp
+		}
+		if n.Email != nil {
+			notifications.Email = *n.Email
+		}
+		if n.MutedTypes != nil {
+			muted := uniqueStrings(*n.MutedTypes)
+			for _, t := range muted {
+				if !isNotificationType(t) {
+					return nil, fmt.Errorf("%w: unknown notification type %q", ErrInvalidPreference, t)
+				}
+			}
+			notifications.MutedTypes = muted
+		}
+		pref.Notifications = datatypes.NewJSONType(notifications)
+	}
+
+	err = s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"locale", "time_zone", "theme", "landing_page", "notifications", "updated_at"}),
+	}).Create(pref).Error
+	if err != nil {
+		return nil, err
+	}
+	return pref, nil
+}
+
+func (s *preferenceService) Location(userID string) *time.Location {
+	pref, err := s.Get(userID)
+	if err != nil {
+		return time.UTC
+	}
+	return pref.Location()
+}
+
+func (s *preferenceService) Allows(userID, channel, notifType string) bool {
+	pref, err := s.Get(userID)
+	if err != nil {
+		// Fall back to the defaults rather than dropping notifications
+		pref = models.DefaultUserPreference(userID)
+	}
+	return pref.Allows(channel, notifType)
+}
+
+func isNotificationType(t string) bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
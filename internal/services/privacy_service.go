@@ -0,0 +1,567 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/storage"
+	"github.com/halolight/halolight-api-go/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DataExportTTL is how long a finished export archive can be downloaded
+const DataExportTTL = 7 * 24 * time.Hour
+
+var (
+	ErrExportNotFound    = errors.New("data export not found")
+	ErrExportNotReady    = errors.New("data export is not ready yet")
+	ErrExportExpired     = errors.New("data export has expired")
+	ErrExportInProgress  = errors.New("a data export is already in progress for this user")
+	ErrAlreadyErased     = errors.New("user data has already been erased")
+	ErrSuccessorRequired = errors.New("user owns team resources; a successor is required")
+	ErrInvalidSuccessor  = errors.New("successor must be another active user")
+)
+
+// ErasureRequest describes who performs an erasure and who inherits team resources
+type ErasureRequest struct {
+	ActorID     string
+	SuccessorID string
+}
+
+// ErasureReport summarises what an erasure changed
+type ErasureReport struct {
+	UserID               string    `json:"userId"`
+	SuccessorID          string    `json:"successorId,omitempty"`
+	TransferredTeams     int64     `json:"transferredTeams"`
+	TransferredDocuments int64     `json:"transferredDocuments"`
+	TransferredFiles     int64     `json:"transferredFiles"`
+	TransferredFolders   int64     `json:"transferredFolders"`
+	DeletedDocuments     int64     `json:"deletedDocuments"`
+	DeletedFiles         int64     `json:"deletedFiles"`
+	DeletedFolders       int64     `json:"deletedFolders"`
+	DeletedEvents        int64     `json:"deletedEvents"`
+	DeletedNotifications int64     `json:"deletedNotifications"`
+	AnonymizedMessages   int64     `json:"anonymizedMessages"`
+	ErasedAt             time.Time `json:"erasedAt"`
+}
+
+// PrivacyService implements the GDPR access (export) and erasure workflows
+type PrivacyService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) PrivacyService
+	// RequestExport queues an export and builds it in the background
+	RequestExport(userID, requestedByID string) (*models.DataExport, error)
+	GetExport(userID, exportID string) (*models.DataExport, error)
+	// OpenExport returns the finished archive; callers must close the reader
+	OpenExport(userID, exportID string) (*models.DataExport, io.ReadCloser, error)
+	// ResumePending restarts exports interrupted by a shutdown
+	ResumePending()
+	Erase(userID string, req ErasureRequest) (*ErasureReport, error)
+}
+
+type privacyService struct {
+	db    *gorm.DB
+	files storage.Storage // private blobs: uploaded files and export archives
+	media storage.Storage // public blobs: avatars
+}
+
+func NewPrivacyService(db *gorm.DB, files, media storage.Storage) PrivacyService {
+	return &privacyService{db: db, files: files, media: media}
+}
+
+func (s *privacyService) WithContext(ctx context.Context) PrivacyService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+// ==================== Export ====================
+
+func (s *privacyService) RequestExport(userID, requestedByID string) (*models.DataExport, error) {
+	var user models.User
+	if err := s.db.Select("id", "erased_at").First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if user.ErasedAt != nil {
+		return nil, ErrAlreadyErased
+	}
+
+	var running int64
+	s.db.Model(&models.DataExport{}).
+		Where("user_id = ? AND status IN ?", userID, []models.DataExportStatus{models.DataExportPending, models.DataExportRunning}).
+		Count(&running)
+	if running > 0 {
+		return nil, ErrExportInProgress
+	}
+
+	export := &models.DataExport{
+		UserID:        userID,
+		RequestedByID: requestedByID,
+		Status:        models.DataExportPending,
+	}
+	if err := s.db.Create(export).Error; err != nil {
+		return nil, err
+	}
+
+	// The archive is built after the request has finished
+	background := *s
+	background.db = s.db.WithContext(context.Background())
+	go background.run(export.ID)
+	return export, nil
+}
+
+func (s *privacyService) GetExport(userID, exportID string) (*models.DataExport, error) {
+	var export models.DataExport
+	err := s.db.First(&export, "id = ? AND user_id = ?", exportID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+func (s *privacyService) OpenExport(userID, exportID string) (*models.DataExport, io.ReadCloser, error) {
+	export, err := s.GetExport(userID, exportID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if export.Status != models.DataExportCompleted {
+		return nil, nil, ErrExportNotReady
+	}
+	if export.IsExpired() {
+		return nil, nil, ErrExportExpired
+	}
+	rc, err := s.files.Open(export.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return export, rc, nil
+}
+
+func (s *privacyService) ResumePending() {
+	var ids []string
+	s.db.Model(&models.DataExport{}).
+		Where("status IN ?", []models.DataExportStatus{models.DataExportPending, models.DataExportRunning}).
+		Pluck("id", &ids)
+	for _, id := range ids {
+		go s.run(id)
+	}
+}
+
+// run builds the archive for one export and records the outcome
+func (s *privacyService) run(exportID string) {
+	var export models.DataExport
+	if err := s.db.First(&export, "id = ?", exportID).Error; err != nil {
+		log.Printf("data export %s: %v", exportID, err)
+		return
+	}
+	s.db.Model(&export).Update("status", models.DataExportRunning)
+
+	key := fmt.Sprintf("exports/%s/%s.zip", export.UserID, export.ID)
+	size, err := s.store(key, func(w io.Writer) error {
+		return s.writeArchive(w, &export)
+	})
+	if err != nil {
+		log.Printf("data export %s: %v", exportID, err)
+		_ = s.files.Delete(key)
+		msg := err.Error()
+		s.db.Model(&export).Updates(map[string]interface{}{
+			"status": models.DataExportFailed,
+			"error":  &msg,
+		})
+		return
+	}
+
+	now := time.Now()
+	expires := now.Add(DataExportTTL)
+	s.db.Model(&export).Updates(map[string]interface{}{
+		"status":       models.DataExportCompleted,
+		"storage_key":  key,
+		"size":         size,
+		"completed_at": now,
+		"expires_at":   expires,
+	})
+}
+
+// store streams the output of write into key and returns the number of bytes written
+func (s *privacyService) store(key string, write func(w io.Writer) error) (int64, error) {
+	pr, pw := io.Pipe()
+	counter := &countingWriter{w: pw}
+	go func() {
+		pw.CloseWithError(write(counter))
+	}()
+	err := s.files.Put(key, pr)
+	// Unblock the writer if Put gave up early
+	pr.CloseWithError(io.ErrClosedPipe)
+	return counter.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// exportManifest is written last as manifest.json and lists every entry with its checksum
+type exportManifest struct {
+	Version      int             `json:"version"`
+	ExportID     string          `json:"exportId"`
+	UserID       string          `json:"userId"`
+	GeneratedAt  time.Time       `json:"generatedAt"`
+	Entries      []manifestEntry `json:"entries"`
+	MissingBlobs []string        `json:"missingBlobs,omitempty"`
+}
+
+type manifestEntry struct {
+	Path    string `json:"path"`
+	Records *int   `json:"records,omitempty"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+}
+
+type archiveWriter struct {
+	zw       *zip.Writer
+	manifest *exportManifest
+}
+
+func (a *archiveWriter) add(name string, records *int, write func(w io.Writer) error) error {
+	w, err := a.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.manifest.GeneratedAt})
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(w, h)}
+	if err := write(counter); err != nil {
+		return err
+	}
+	a.manifest.Entries = append(a.manifest.Entries, manifestEntry{
+		Path:    name,
+		Records: records,
+		Size:    counter.n,
+		SHA256:  hexSum(h),
+	})
+	return nil
+}
+
+func (a *archiveWriter) addJSON(name string, records int, v interface{}) error {
+	return a.add(name, &records, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	})
+}
+
+func hexSum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *privacyService) writeArchive(w io.Writer, export *models.DataExport) error {
+	userID := export.UserID
+	zw := zip.NewWriter(w)
+	a := &archiveWriter{zw: zw, manifest: &exportManifest{
+		Version:     1,
+		ExportID:    export.ID,
+		UserID:      userID,
+		GeneratedAt: time.Now().UTC(),
+	}}
+
+	// Soft-deleted rows are still stored, so every query is unscoped
+	db := s.db.Unscoped()
+
+	var user models.User
+	if err := db.Preload("Roles.Role").Preload("Teams.Team").First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+	var pref models.UserPreference
+	profile := map[string]interface{}{"user": user}
+	if err := db.First(&pref, "user_id = ?", userID).Error; err == nil {
+		profile["preferences"] = pref
+	}
+	if err := a.addJSON("profile.json", 1, profile); err != nil {
+		return err
+	}
+
+	var documents []models.Document
+	if err := db.Preload("Tags.Tag").Preload("Shares").Where("owner_id = ?", userID).Order("created_at").Find(&documents).Error; err != nil {
+		return err
+	}
+	if err := a.addJSON("documents.json", len(documents), documents); err != nil {
+		return err
+	}
+
+	var files []models.File
+	if err := db.Where("owner_id = ?", userID).Order("created_at").Find(&files).Error; err != nil {
+		return err
+	}
+	if err := a.addJSON("files.json", len(files), files); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := s.addBlob(a, f); err != nil {
+			return err
+		}
+	}
+
+	var folders []models.Folder
+	if err := db.Where("owner_id = ?", userID).Order("created_at").Find(&folders).Error; err != nil {
+		return err
+	}
+	if err := a.addJSON("folders.json", len(folders), folders); err != nil {
+		return err
+	}
+
+	var events []models.CalendarEvent
+	if err := db.Preload("Attendees").Preload("Reminders").
+		Where("owner_id = ? OR id IN (SELECT event_id FROM event_attendees WHERE user_id = ?)", userID, userID).
+		Order("start_at").Find(&events).Error; err != nil {
+		return err
+	}
+	if err := a.addJSON("calendar_events.json", len(events), events); err != nil {
+		return err
+	}
+
+	var messages []models.Message
+	if err := db.Where("sender_id = ?", userID).Order("created_at").Find(&messages).Error; err != nil {
+		return err
+	}
+	if err := a.addJSON("messages.json", len(messages), messages); err != nil {
+		return err
+	}
+
+	var notifications []models.Notification
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&notifications).Error; err != nil {
+		return err
+	}
+	if err := a.addJSON("notifications.json", len(notifications), notifications); err != nil {
+		return err
+	}
+
+	var activities []models.ActivityLog
+	if err := db.Where("actor_id = ?", userID).Order("created_at").Find(&activities).Error; err != nil {
+		return err
+	}
+	if err := a.addJSON("activity_logs.json", len(activities), activities); err != nil {
+		return err
+	}
+
+	var logins []models.LoginEvent
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&logins).Error; err != nil {
+		return err
+	}
+	if err := a.addJSON("login_history.json", len(logins), logins); err != nil {
+		return err
+	}
+
+	mw, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: a.manifest.GeneratedAt})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(a.manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// addBlob copies an uploaded file's content into files/<id>/<name>. Files whose
+// blob is not in storage are listed in the manifest instead of failing the export.
+func (s *privacyService) addBlob(a *archiveWriter, f models.File) error {
+	rc, err := s.files.Open(FileBlobKey(f.ID))
+	if err != nil {
+		a.manifest.MissingBlobs = append(a.manifest.MissingBlobs, f.ID)
+		return nil
+	}
+	defer rc.Close()
+
+	name := path.Base("/" + strings.ReplaceAll(f.Name, "\\", "/"))
+	if name == "/" || name == "." {
+		name = "file"
+	}
+	return a.add("files/"+f.ID+"/"+name, nil, func(w io.Writer) error {
+		_, err := io.Copy(w, rc)
+		return err
+	})
+}
+
+// ==================== Erasure ====================
+
+// Erase removes a user's personal data. Team-owned resources move to the
+// successor, private content is deleted, and the user row is kept as an
+// anonymous, soft-deleted tombstone so authored messages and audit entries
+// remain consistent without identifying the person.
+func (s *privacyService) Erase(userID string, req ErasureRequest) (*ErasureReport, error) {
+	report := &ErasureReport{UserID: userID, SuccessorID: req.SuccessorID, ErasedAt: time.Now()}
+	var blobKeys []string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().First(&user, "id = ?", userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if user.ErasedAt != nil {
+			return ErrAlreadyErased
+		}
+
+		if err := s.transferTeamResources(tx, userID, req.SuccessorID, report); err != nil {
+			return err
+		}
+
+		// Private documents, files and folders
+		counts, keys, err := deleteContent(tx, "owner_id = ? AND team_id IS NULL", userID)
+		if err != nil {
+			return err
+		}
+		blobKeys = keys
+		report.DeletedDocuments = counts.Documents
+		report.DeletedFiles = counts.Files
+		report.DeletedFolders = counts.Folders
+
+		if report.DeletedEvents, err = deleteOwnedEvents(tx, userID); err != nil {
+			return err
+		}
+
+		res := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.Notification{})
+		if res.Error != nil {
+			return res.Error
+		}
+		report.DeletedNotifications = res.RowsAffected
+
+		if err := deleteUserLinks(tx, userID); err != nil {
+			return err
+		}
+
+		// Messages stay in their conversations, attributed to the tombstone
+		tx.Unscoped().Model(&models.Message{}).Where("sender_id = ?", userID).Count(&report.AnonymizedMessages)
+		if err := tx.Model(&models.ActivityLog{}).Where("actor_id = ?", userID).Update("metadata", nil).Error; err != nil {
+			return err
+		}
+
+		secret, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			return err
+		}
+		password, err := utils.HashPassword(secret)
+		if err != nil {
+			return err
+		}
+		tag := strings.ToLower(userID)
+		if err := tx.Unscoped().Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"email":         "erased-" + tag + "@erased.invalid",
+			"username":      "erased-" + tag,
+			"name":          "Deleted user",
+			"password":      password,
+			"phone":         nil,
+			"avatar":        nil,
+			"department":    nil,
+			"position":      nil,
+			"bio":           nil,
+			"external_id":   nil,
+			"last_login_at": nil,
+			"quota_used":    0,
+			"status":        models.UserStatusInactive,
+			"erased_at":     report.ErasedAt,
+			"deleted_at":    report.ErasedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.ActivityLog{
+			ActorID:    req.ActorID,
+			Action:     "user.erase",
+			TargetType: "user",
+			TargetID:   userID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	removeUserBlobs(s.files, s.media, userID, blobKeys)
+	return report, nil
+}
+
+// transferTeamResources hands owned teams and team-scoped content to the successor
+func (s *privacyService) transferTeamResources(tx *gorm.DB, userID, successorID string, report *ErasureReport) error {
+	var owned int64
+	tx.Model(&models.Team{}).Where("owner_id = ?", userID).Count(&owned)
+	for _, model := range []interface{}{&models.Document{}, &models.File{}, &models.Folder{}} {
+		var n int64
+		tx.Unscoped().Model(model).Where("owner_id = ? AND team_id IS NOT NULL", userID).Count(&n)
+		owned += n
+	}
+	if owned == 0 {
+		return nil
+	}
+	if successorID == "" {
+		return ErrSuccessorRequired
+	}
+
+	var successor models.User
+	err := tx.First(&successor, "id = ?", successorID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || successor.ID == userID || successor.ErasedAt != nil ||
+		(err == nil && successor.Status != models.UserStatusActive) {
+		return ErrInvalidSuccessor
+	}
+	if err != nil {
+		return err
+	}
+
+	var teamIDs []string
+	if err := tx.Model(&models.Team{}).Where("owner_id = ?", userID).Pluck("id", &teamIDs).Error; err != nil {
+		return err
+	}
+	for _, teamID := range teamIDs {
+		member := models.TeamMember{TeamID: teamID, UserID: successorID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+			return err
+		}
+	}
+	res := tx.Unscoped().Model(&models.Team{}).Where("owner_id = ?", userID).Update("owner_id", successorID)
+	if res.Error != nil {
+		return res.Error
+	}
+	report.TransferredTeams = res.RowsAffected
+
+	for _, t := range []struct {
+		model interface{}
+		count *int64
+	}{
+		{&models.Document{}, &report.TransferredDocuments},
+		{&models.File{}, &report.TransferredFiles},
+		{&models.Folder{}, &report.TransferredFolders},
+	} {
+		res := tx.Unscoped().Model(t.model).Where("owner_id = ? AND team_id IS NOT NULL", userID).Update("owner_id", successorID)
+		if res.Error != nil {
+			return res.Error
+		}
+		*t.count = res.RowsAffected
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package services
 
 import (
+	"context"
+
 	"github.com/halolight/halolight-api-go/internal/models"
 	"gorm.io/gorm"
 )
 
 type RoleService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) RoleService
 	List() ([]models.Role, error)
 	Get(id string) (*models.Role, error)
 	Create(name, label, description string) (*models.Role, error)
@@ -22,6 +26,12 @@ func NewRoleService(db *gorm.DB) RoleService {
 	return &roleService{db: db}
 }
 
+func (s *roleService) WithContext(ctx context.Context) RoleService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
 func (s *roleService) List() ([]models.Role, error) {
 	var roles []models.Role
 	err := s.db.Preload("Permissions.Permission").Find(&roles).Error
@@ -0,0 +1,618 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/scim"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// SCIMMaxResults caps the page size of SCIM list requests
+const SCIMMaxResults = 200
+
+func scimTime(v interface{}) (interface{}, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, errors.New("expected an RFC 3339 timestamp")
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// scimUserColumns maps filterable SCIM user attributes onto the users table
+var scimUserColumns = map[string]scim.Column{
+	"id":                 {Name: "id", CaseExact: true},
+	"externalid":         {Name: "external_id", CaseExact: true},
+	"username":           {Name: "username"},
+	"emails":             {Name: "email"},
+	"emails.value":       {Name: "email"},
+	"displayname":        {Name: "name"},
+	"name.formatted":     {Name: "name"},
+	"title":              {Name: "position"},
+	"phonenumbers":       {Name: "phone"},
+	"phonenumbers.value": {Name: "phone"},
+	"active":             {Name: "(status = 'ACTIVE')"},
+	"meta.created":       {Name: "created_at", Convert: scimTime},
+	"meta.lastmodified":  {Name: "updated_at", Convert: scimTime},
+	"groups":             {Name: "team_id", CaseExact: true, Subquery: "id IN (SELECT user_id FROM team_members WHERE %s)"},
+	"groups.value":       {Name: "team_id", CaseExact: true, Subquery: "id IN (SELECT user_id FROM team_members WHERE %s)"},
+	strings.ToLower(scim.SchemaEnterpriseUser) + ":department": {Name: "department"},
+}
+
+// scimGroupColumns maps filterable SCIM group attributes onto the teams table
+var scimGroupColumns = map[string]scim.Column{
+	"id":                {Name: "id", CaseExact: true},
+	"externalid":        {Name: "external_id", CaseExact: true},
+	"displayname":       {Name: "name"},
+	"meta.created":      {Name: "created_at", Convert: scimTime},
+	"meta.lastmodified": {Name: "updated_at", Convert: scimTime},
+	"members":           {Name: "user_id", CaseExact: true, Subquery: "id IN (SELECT team_id FROM team_members WHERE %s)"},
+	"members.value":     {Name: "user_id", CaseExact: true, Subquery: "id IN (SELECT team_id FROM team_members WHERE %s)"},
+}
+
+type SCIMService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) SCIMService
+	ListUsers(filter string, startIndex, count int) ([]models.User, int64, error)
+	GetUser(id string) (*models.User, error)
+	CreateUser(in *scim.User) (*models.User, error)
+	ReplaceUser(id string, in *scim.User) (*models.User, error)
+	PatchUser(id string, ops []scim.PatchOperation) (*models.User, error)
+	DeleteUser(id string) error
+
+	ListGroups(filter string, startIndex, count int) ([]models.Team, int64, error)
+	GetGroup(id string) (*models.Team, error)
+	CreateGroup(in *scim.Group) (*models.Team, error)
+	ReplaceGroup(id string, in *scim.Group) (*models.Team, error)
+	PatchGroup(id string, ops []scim.PatchOperation) (*models.Team, error)
+	DeleteGroup(id string) error
+}
+
+type scimService struct {
+	db         *gorm.DB
+	attributes AttributeService
+}
+
+func NewSCIMService(db *gorm.DB, attributes AttributeService) SCIMService {
+	return &scimService{db: db, attributes: attributes}
+}
+
+func (s *scimService) WithContext(ctx context.Context) SCIMService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.attributes = s.attributes.WithContext(ctx)
+	return &clone
+}
+
+func scimNotFound(resource, id string) *scim.Error {
+	return scim.NewError(http.StatusNotFound, "", "%s %s not found", resource, id)
+}
+
+// scimPage normalizes 1-based SCIM pagination parameters
+func scimPage(startIndex, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > SCIMMaxResults {
+		count = SCIMMaxResults
+	}
+	return startIndex, count
+}
+
+func applySCIMFilter(query *gorm.DB, filter string, columns map[string]scim.Column) (*gorm.DB, error) {
+	if strings.TrimSpace(filter) == "" {
+		return query, nil
+	}
+	f, err := scim.ParseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	clause, args, err := f.ToSQL(columns)
+	if err != nil {
+		return nil, err
+	}
+	return query.Where(clause, args...), nil
+}
+
+// ==================== Users ====================
+
+func (s *scimService) ListUsers(filter string, startIndex, count int) ([]models.User, int64, error) {
+	startIndex, count = scimPage(startIndex, count)
+
+	query, err := applySCIMFilter(s.db.Model(&models.User{}), filter, scimUserColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	users := []models.User{}
+	if count == 0 {
+		return users, total, nil
+	}
+	err = query.Preload("Teams.Team").
+		Order("created_at ASC").Order("id ASC").
+		Offset(startIndex - 1).Limit(count).
+		Find(&users).Error
+	return users, total, err
+}
+
+func (s *scimService) GetUser(id string) (*models.User, error) {
+	var user models.User
+	if err := s.db.Preload("Teams.Team").First(&user, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scimNotFound("User", id)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *scimService) CreateUser(in *scim.User) (*models.User, error) {
+	user := &models.User{Status: models.UserStatusActive}
+	if err := s.applyUser(user, in); err != nil {
+		return nil, err
+	}
+	if err := s.ensureUniqueUser("", user.Email, user.Username); err != nil {
+		return nil, err
+	}
+
+	password := in.Password
+	if password == "" {
+		// Provisioned users sign in through the IdP; the local password is unusable
+		random, err := utils.GenerateRandomToken(24)
+		if err != nil {
+			return nil, err
+		}
+		password = random
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "%s", err.Error())
+	}
+	user.Password = hash
+
+	if err := s.db.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return s.GetUser(user.ID)
+}
+
+func (s *scimService) ReplaceUser(id string, in *scim.User) (*models.User, error) {
+	user, err := s.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyUser(user, in); err != nil {
+		return nil, err
+	}
+	return s.saveUser(user, in.Password)
+}
+
+func (s *scimService) PatchUser(id string, ops []scim.PatchOperation) (*models.User, error) {
+	user, err := s.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := toDocument(scim.FromUser(user, ""))
+	if err != nil {
+		return nil, err
+	}
+	if err := scim.ApplyPatch(doc, ops, scim.SchemaEnterpriseUser, scim.SchemaAttributesUser); err != nil {
+		return nil, err
+	}
+	// Some IdPs send active as the string "True"/"False"
+	if key := findDocKey(doc, "active"); key != "" {
+		if str, ok := doc[key].(string); ok {
+			b, err := strconv.ParseBool(str)
+			if err != nil {
+				return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "active must be a boolean")
+			}
+			doc[key] = b
+		}
+	}
+
+	var patched scim.User
+	if err := fromDocument(doc, &patched); err != nil {
+		return nil, err
+	}
+	if patched.Attributes == nil {
+		// The patch removed the extension altogether
+		patched.Attributes = map[string]interface{}{}
+	}
+	// name.formatted still holds the stored name when only the name parts or
+	// displayName were patched
+	if patched.Name != nil && patched.Name.Formatted == user.Name {
+		if patched.Name.GivenName != "" || patched.Name.FamilyName != "" {
+			patched.Name.Formatted = ""
+		} else if patched.DisplayName != "" {
+			patched.Name.Formatted = patched.DisplayName
+		}
+	}
+	if err := s.applyUser(user, &patched); err != nil {
+		return nil, err
+	}
+	return s.saveUser(user, patched.Password)
+}
+
+func (s *scimService) DeleteUser(id string) error {
+	result := s.db.Delete(&models.User{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return scimNotFound("User", id)
+	}
+	return nil
+}
+
+// applyUser copies SCIM attributes onto the model. Optional attributes missing
+// from the input are cleared as PUT semantics require; PATCH passes the full
+// patched resource so the same rule holds. The custom attribute extension is
+// the exception: IdPs that do not send it leave the values untouched.
+func (s *scimService) applyUser(user *models.User, in *scim.User) error {
+	username := strings.TrimSpace(in.UserName)
+	if username == "" {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "userName is required")
+	}
+	if len(username) > 100 {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "userName is too long")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(in.PrimaryEmail()))
+	if email == "" && strings.Contains(username, "@") {
+		email = strings.ToLower(username)
+	}
+	if email == "" || !strings.Contains(email, "@") {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "a valid email is required")
+	}
+
+	name := strings.TrimSpace(in.FormattedName())
+	if name == "" {
+		name = username
+	}
+
+	user.Username = username
+	user.Email = email
+	user.Name = name
+
+	user.ExternalID = optionalString(in.ExternalID)
+	user.Position = optionalString(in.Title)
+	user.Phone = nil
+	if len(in.PhoneNumbers) > 0 {
+		user.Phone = optionalString(in.PhoneNumbers[0].Value)
+	}
+	user.Department = nil
+	if in.Enterprise != nil {
+		user.Department = optionalString(in.Enterprise.Department)
+	}
+	if in.Attributes != nil {
+		// Required attributes are not enforced; IdPs rarely know all of them.
+		// The IdP is authoritative for every attribute.
+		attributes, err := s.attributes.Apply(nil, in.Attributes, false, true)
+		if err != nil {
+			if errors.Is(err, ErrInvalidAttribute) {
+				return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "%s", err.Error())
+			}
+			return err
+		}
+		user.Attributes = attributes
+	}
+
+	// Deactivation maps to INACTIVE. Reactivation only lifts INACTIVE so that
+	// an IdP sync does not undo a suspension applied inside the application.
+	if in.Active != nil {
+		if !*in.Active {
+			user.Status = models.UserStatusInactive
+		} else if user.Status == models.UserStatusInactive || user.Status == "" {
+			user.Status = models.UserStatusActive
+		}
+	}
+	return nil
+}
+
+func (s *scimService) saveUser(user *models.User, password string) (*models.User, error) {
+	if err := s.ensureUniqueUser(user.ID, user.Email, user.Username); err != nil {
+		return nil, err
+	}
+	if password != "" {
+		hash, err := utils.HashPassword(password)
+		if err != nil {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "%s", err.Error())
+		}
+		user.Password = hash
+	}
+	// Save would also upsert the preloaded team memberships
+	if err := s.db.Omit("Teams").Save(user).Error; err != nil {
+		return nil, err
+	}
+	return s.GetUser(user.ID)
+}
+
+// ensureUniqueUser checks the unique indexes up front; deleted users do not
+// hold their email or username
+func (s *scimService) ensureUniqueUser(id, email, username string) error {
+	var count int64
+	query := tenant.Global(s.db).Model(&models.User{}).Where("(LOWER(email) = ? OR LOWER(username) = ?)", email, strings.ToLower(username))
+	if id != "" {
+		query = query.Where("id <> ?", id)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "userName or email already exists")
+	}
+	return nil
+}
+
+// ==================== Groups ====================
+
+func (s *scimService) ListGroups(filter string, startIndex, count int) ([]models.Team, int64, error) {
+	startIndex, count = scimPage(startIndex, count)
+
+	query, err := applySCIMFilter(s.db.Model(&models.Team{}), filter, scimGroupColumns)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	teams := []models.Team{}
+	if count == 0 {
+		return teams, total, nil
+	}
+	err = query.Preload("Members.User").
+		Order("created_at ASC").Order("id ASC").
+		Offset(startIndex - 1).Limit(count).
+		Find(&teams).Error
+	return teams, total, err
+}
+
+func (s *scimService) GetGroup(id string) (*models.Team, error) {
+	var team models.Team
+	if err := s.db.Preload("Members.User").First(&team, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, scimNotFound("Group", id)
+		}
+		return nil, err
+	}
+	return &team, nil
+}
+
+// groupOwnerID returns the configured owner of teams created through SCIM in
+// the organization the service is bound to, or "" when there is none
+func (s *scimService) groupOwnerID() (string, error) {
+	tenantID, _ := tenant.FromContext(s.db.Statement.Context)
+	var organization models.Organization
+	err := s.db.Select("scim_group_owner_id").First(&organization, "id = ?", tenantID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	if organization.SCIMGroupOwnerID == nil {
+		return "", nil
+	}
+	return *organization.SCIMGroupOwnerID, nil
+}
+
+func (s *scimService) CreateGroup(in *scim.Group) (*models.Team, error) {
+	name := strings.TrimSpace(in.DisplayName)
+	if name == "" {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "displayName is required")
+	}
+
+	memberIDs := memberValues(in.Members)
+	ownerID, err := s.groupOwnerID()
+	if err != nil {
+		return nil, err
+	}
+	if ownerID == "" {
+		if len(memberIDs) == 0 {
+			return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "a group needs at least one member to own it when the organization has no SCIM group owner")
+		}
+		ownerID = memberIDs[0]
+	}
+
+	team := &models.Team{
+		Name:       name,
+		OwnerID:    ownerID,
+		ExternalID: optionalString(in.ExternalID),
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureUsersExist(tx, append(memberIDs, ownerID)); err != nil {
+			return err
+		}
+		if err := tx.Create(team).Error; err != nil {
+			return err
+		}
+		return syncTeamMembers(tx, team.ID, memberIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(team.ID)
+}
+
+func (s *scimService) ReplaceGroup(id string, in *scim.Group) (*models.Team, error) {
+	team, err := s.GetGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.saveGroup(team, in)
+}
+
+func (s *scimService) PatchGroup(id string, ops []scim.PatchOperation) (*models.Team, error) {
+	team, err := s.GetGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := toDocument(scim.FromTeam(team, ""))
+	if err != nil {
+		return nil, err
+	}
+	if err := scim.ApplyPatch(doc, ops); err != nil {
+		return nil, err
+	}
+
+	var patched scim.Group
+	if err := fromDocument(doc, &patched); err != nil {
+		return nil, err
+	}
+	return s.saveGroup(team, &patched)
+}
+
+func (s *scimService) DeleteGroup(id string) error {
+	result := s.db.Delete(&models.Team{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return scimNotFound("Group", id)
+	}
+	return nil
+}
+
+func (s *scimService) saveGroup(team *models.Team, in *scim.Group) (*models.Team, error) {
+	name := strings.TrimSpace(in.DisplayName)
+	if name == "" {
+		return nil, scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "displayName is required")
+	}
+	memberIDs := memberValues(in.Members)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureUsersExist(tx, memberIDs); err != nil {
+			return err
+		}
+		// Touch updated_at even when only members change so the ETag moves
+		if err := tx.Model(&models.Team{}).Where("id = ?", team.ID).Updates(map[string]interface{}{
+			"name":        name,
+			"external_id": optionalString(in.ExternalID),
+			"updated_at":  time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return syncTeamMembers(tx, team.ID, memberIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(team.ID)
+}
+
+func memberValues(members []scim.MultiValue) []string {
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		if m.Value != "" {
+			ids = append(ids, m.Value)
+		}
+	}
+	return uniqueStrings(ids)
+}
+
+func ensureUsersExist(tx *gorm.DB, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	unique := uniqueStrings(ids)
+	var count int64
+	if err := tx.Model(&models.User{}).Where("id IN ?", unique).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(unique) {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "one or more members do not exist")
+	}
+	return nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// syncTeamMembers makes the team's membership exactly userIDs, keeping the
+// role and join date of members that stay
+func syncTeamMembers(tx *gorm.DB, teamID string, userIDs []string) error {
+	query := tx.Where("team_id = ?", teamID)
+	if len(userIDs) > 0 {
+		query = query.Where("user_id NOT IN ?", userIDs)
+	}
+	if err := query.Delete(&models.TeamMember{}).Error; err != nil {
+		return err
+	}
+
+	var existing []string
+	if err := tx.Model(&models.TeamMember{}).Where("team_id = ?", teamID).Pluck("user_id", &existing).Error; err != nil {
+		return err
+	}
+	present := map[string]bool{}
+	for _, id := range existing {
+		present[id] = true
+	}
+	for _, id := range userIDs {
+		if present[id] {
+			continue
+		}
+		if err := tx.Create(&models.TeamMember{TeamID: teamID, UserID: id}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ==================== Helpers ====================
+
+func toDocument(v interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	err = json.Unmarshal(raw, &doc)
+	return doc, err
+}
+
+func fromDocument(doc map[string]interface{}, v interface{}) error {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return scim.NewError(http.StatusBadRequest, scim.ErrInvalidValue, "patched resource is invalid: %v", err)
+	}
+	return nil
+}
+
+func findDocKey(doc map[string]interface{}, name string) string {
+	for k := range doc {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return ""
+}
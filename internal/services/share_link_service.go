@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/storage"
+	"github.com/halolight/halolight-api-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrShareLinkNotFound       = errors.New("share link not found")
+	ErrShareLinkInvalid        = errors.New("share link is invalid, expired or revoked")
+	ErrInvalidShareLink        = errors.New("invalid share link settings")
+	ErrShareLinkPassword       = errors.New("share link requires a password")
+	ErrShareLinkWrongPassword  = errors.New("incorrect share link password")
+	ErrShareLinkViewOnly       = errors.New("share link is view-only")
+	ErrShareLinkLimit          = errors.New("share link download limit reached")
+	ErrSharedContentMissing    = errors.New("shared file content is not available")
+	ErrSharePreviewUnsupported = errors.New("only shared files can be previewed")
+)
+
+// Share link actions
+const (
+	ShareActionView     = "view"     // link details, with the content of documents
+	ShareActionPreview  = "preview"  // a file's content, displayed inline
+	ShareActionDownload = "download" // counts towards the download limit
+)
+
+type ShareLinkInput struct {
+	Password     string
+	ExpiresAt    *time.Time
+	MaxDownloads *int
+	ViewOnly     bool
+}
+
+// ShareLinkRequest is an anonymous request made through a share link
+type ShareLinkRequest struct {
+	Token     string
+	Password  string
+	IP        string
+	UserAgent string
+}
+
+// SharedResource is what a share link resolves to; exactly one of Document and
+// File is set
+type SharedResource struct {
+	Link     *models.ShareLink
+	Document *models.Document
+	File     *models.File
+}
+
+type ShareLinkService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) ShareLinkService
+	// Create returns the link and its path below the server root; the token
+	// cannot be recovered later
+	Create(resource models.ShareLinkResource, resourceID, creatorID string, input ShareLinkInput) (*models.ShareLink, string, error)
+	List(resource models.ShareLinkResource, resourceID string) ([]models.ShareLink, error)
+	Revoke(resource models.ShareLinkResource, resourceID, id string) error
+	// Accesses returns the link's access log, newest first
+	Accesses(resource models.ShareLinkResource, resourceID, id string, page, limit int) ([]models.ShareLinkAccess, int64, error)
+	// Open resolves an anonymous request and records it in the access log,
+	// whether it is granted or not. Previews and downloads of files also
+	// return the file's content, which the caller must close.
+	Open(req ShareLinkRequest, action string) (*SharedResource, io.ReadCloser, error)
+}
+
+type shareLinkService struct {
+	db    *gorm.DB
+	files storage.Storage
+}
+
+func NewShareLinkService(db *gorm.DB, files storage.Storage) ShareLinkService {
+	return &shareLinkService{db: db, files: files}
+}
+
+func (s *shareLinkService) WithContext(ctx context.Context) ShareLinkService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+// resourceColumn returns the share_links column referencing resource
+func resourceColumn(resource models.ShareLinkResource) string {
+	if resource == models.ShareLinkFile {
+		return "file_id"
+	}
+	return "document_id"
+}
+
+func (s *shareLinkService) Create(resource models.ShareLinkResource, resourceID, creatorID string, input ShareLinkInput) (*models.ShareLink, string, error) {
+	link := &models.ShareLink{ResourceType: resource, CreatorID: creatorID, ViewOnly: input.ViewOnly}
+
+	var count int64
+	switch resource {
+	case models.ShareLinkDocument:
+		if err := s.db.Model(&models.Document{}).Where("id = ?", resourceID).Count(&count).Error; err != nil {
+			return nil, "", err
+		}
+		link.DocumentID = &resourceID
+	case models.ShareLinkFile:
+		if err := s.db.Model(&models.File{}).Where("id = ?", resourceID).Count(&count).Error; err != nil {
+			return nil, "", err
+		}
+		link.FileID = &resourceID
+	default:
+		return nil, "", fmt.Errorf("%w: unknown resource type %q", ErrInvalidShareLink, resource)
+	}
+	if count == 0 {
+		return nil, "", gorm.ErrRecordNotFound
+	}
+
+	if input.MaxDownloads != nil {
+		if *input.MaxDownloads < 1 {
+			return nil, "", fmt.Errorf("%w: maxDownloads must be at least 1", ErrInvalidShareLink)
+		}
+		if input.ViewOnly {
+			return nil, "", fmt.Errorf("%w: view-only links cannot be downloaded", ErrInvalidShareLink)
+		}
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expiresAt must be in the future", ErrInvalidShareLink)
+	}
+	link.ExpiresAt = input.ExpiresAt
+	link.MaxDownloads = input.MaxDownloads
+
+	if input.Password != "" {
+		hash, err := utils.HashPassword(input.Password)
+		if err != nil {
+			return nil, "", err
+		}
+		link.PasswordHash = hash
+		link.HasPassword = true
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	link.TokenHash = utils.HashToken(token)
+	if err := s.db.Create(link).Error; err != nil {
+		return nil, "", err
+	}
+	return link, "/s/" + token, nil
+}
+
+func (s *shareLinkService) List(resource models.ShareLinkResource, resourceID string) ([]models.ShareLink, error) {
+	links := []models.ShareLink{}
+	err := s.db.Where(resourceColumn(resource)+" = ?", resourceID).Order("created_at DESC").Find(&links).Error
+	return links, err
+}
+
+func (s *shareLinkService) Revoke(resource models.ShareLinkResource, resourceID, id string) error {
+	res := s.db.Model(&models.ShareLink{}).
+		Where("id = ? AND "+resourceColumn(resource)+" = ? AND revoked_at IS NULL", id, resourceID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrShareLinkNotFound
+	}
+	return nil
+}
+
+func (s *shareLinkService) Accesses(resource models.ShareLinkResource, resourceID, id string, page, limit int) ([]models.ShareLinkAccess, int64, error) {
+	var count int64
+	if err := s.db.Model(&models.ShareLink{}).
+		Where("id = ? AND "+resourceColumn(resource)+" = ?", id, resourceID).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	if count == 0 {
+		return nil, 0, ErrShareLinkNotFound
+	}
+
+	var total int64
+	query := s.db.Model(&models.ShareLinkAccess{}).Where("link_id = ?", id)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	accesses := []models.ShareLinkAccess{}
+	offset := (page - 1) * limit
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&accesses).Error
+	return accesses, total, err
+}
+
+func (s *shareLinkService) Open(req ShareLinkRequest, action string) (*SharedResource, io.ReadCloser, error) {
+	var link models.ShareLink
+	err := s.db.First(&link, "token_hash = ?", utils.HashToken(req.Token)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrShareLinkInvalid
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if action == ShareActionPreview && link.FileID == nil {
+		return nil, nil, ErrSharePreviewUnsupported
+	}
+
+	// Anonymous requests belong to no organization; the link's own scopes
+	// what it can reach
+	ctx := s.db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	db := s.db.WithContext(tenant.WithID(ctx, link.TenantID))
+	deny := func(outcome string, err error) (*SharedResource, io.ReadCloser, error) {
+		s.record(db, &link, action, outcome, req)
+		return nil, nil, err
+	}
+
+	switch {
+	case link.RevokedAt != nil:
+		return deny(models.ShareAccessRevoked, ErrShareLinkInvalid)
+	case !link.IsUsable():
+		return deny(models.ShareAccessExpired, ErrShareLinkInvalid)
+	}
+	if link.PasswordHash != "" {
+		if req.Password == "" {
+			return deny(models.ShareAccessPasswordRequired, ErrShareLinkPassword)
+		}
+		if utils.CheckPassword(req.Password, link.PasswordHash) != nil {
+			return deny(models.ShareAccessWrongPassword, ErrShareLinkWrongPassword)
+		}
+	}
+	if action == ShareActionDownload && link.ViewOnly {
+		return deny(models.ShareAccessViewOnly, ErrShareLinkViewOnly)
+	}
+
+	shared := &SharedResource{Link: &link}
+	if link.DocumentID != nil {
+		var doc models.Document
+		err = db.Preload("Owner").First(&doc, "id = ?", *link.DocumentID).Error
+		shared.Document = &doc
+	} else if link.FileID != nil {
+		var file models.File
+		err = db.Preload("Owner").First(&file, "id = ?", *link.FileID).Error
+		shared.File = &file
+	} else {
+		err = gorm.ErrRecordNotFound
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return deny(models.ShareAccessUnavailable, ErrShareLinkInvalid)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rc io.ReadCloser
+	if shared.File != nil && action != ShareActionView {
+		if rc, err = s.files.Open(FileBlobKey(shared.File.ID)); err != nil {
+			return deny(models.ShareAccessUnavailable, ErrSharedContentMissing)
+		}
+	}
+
+	if action == ShareActionDownload {
+		// The guard makes concurrent downloads respect the limit
+		res := db.Model(&models.ShareLink{}).
+			Where("id = ? AND revoked_at IS NULL", link.ID).
+			Where("max_downloads IS NULL OR download_count < max_downloads").
+			Update("download_count", gorm.Expr("download_count + 1"))
+		if res.Error != nil {
+			closeQuietly(rc)
+			return nil, nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			closeQuietly(rc)
+			return deny(models.ShareAccessLimitReached, ErrShareLinkLimit)
+		}
+		link.DownloadCount++
+	}
+
+	now := time.Now()
+	db.Model(&models.ShareLink{}).Where("id = ?", link.ID).Update("last_access_at", now)
+	link.LastAccessAt = &now
+	s.record(db, &link, action, models.ShareAccessGranted, req)
+	return shared, rc, nil
+}
+
+// record appends to the link's access log. Failures are logged; they do not
+// decide the request.
+func (s *shareLinkService) record(db *gorm.DB, link *models.ShareLink, action, outcome string, req ShareLinkRequest) {
+	userAgent := req.UserAgent
+	if len(userAgent) > 500 {
+		userAgent = strings.ToValidUTF8(userAgent[:500], "")
+	}
+	access := &models.ShareLinkAccess{
+		LinkID:    link.ID,
+		Action:    action,
+		Outcome:   outcome,
+		IP:        req.IP,
+		UserAgent: userAgent,
+	}
+	if err := db.Create(access).Error; err != nil {
+		log.Printf("share link %s access log: %v", link.ID, err)
+	}
+}
+
+func closeQuietly(rc io.ReadCloser) {
+	if rc != nil {
+		rc.Close()
+	}
+}
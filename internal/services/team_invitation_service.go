@@ -0,0 +1,524 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/mailer"
+	"github.com/halolight/halolight-api-go/pkg/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTeamInvitationNotFound = errors.New("team invitation not found")
+	ErrTeamInvitationClosed   = errors.New("team invitation is no longer pending or has expired")
+	ErrTeamInvitationExists   = errors.New("a pending invitation to this team already exists for this user")
+	ErrAlreadyTeamMember      = errors.New("user is already a member of this team")
+	ErrJoinLinkNotFound       = errors.New("join link not found")
+	ErrJoinLinkInvalid        = errors.New("join link is invalid, expired or used up")
+	ErrInvalidJoinLink        = errors.New("invalid join link settings")
+)
+
+// teamInvitationLink is where the web client lists the user's team invitations
+const teamInvitationLink = "/teams/invitations"
+
+// TeamInviteInput names the invitee by UserID or by Email
+type TeamInviteInput struct {
+	UserID string
+	Email  string
+	RoleID *string
+}
+
+type JoinLinkInput struct {
+	RoleID    *string
+	ExpiresAt *time.Time
+	MaxUses   *int
+}
+
+type TeamInvitationService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) TeamInvitationService
+	// Invite notifies an existing user, or emails an address without an account
+	Invite(teamID, inviterID string, input TeamInviteInput) (*models.TeamInvitation, error)
+	ListForTeam(teamID, status string, page, limit int) ([]models.TeamInvitation, int64, error)
+	Revoke(teamID, id string) (*models.TeamInvitation, error)
+	// ListPending returns the invitations awaiting the user's answer
+	ListPending(userID string) ([]models.TeamInvitation, error)
+	Accept(id, userID string) (*models.TeamMember, error)
+	Decline(id, userID string) (*models.TeamInvitation, error)
+	// Claim binds invitations sent to email before the account existed to
+	// userID and notifies the user about them
+	Claim(userID, email string) error
+
+	// CreateJoinLink returns the link and its URL; the token cannot be recovered later
+	CreateJoinLink(teamID, creatorID string, input JoinLinkInput) (*models.TeamJoinLink, string, error)
+	ListJoinLinks(teamID string) ([]models.TeamJoinLink, error)
+	RevokeJoinLink(teamID, id string) error
+	Join(token, userID string) (*models.TeamMember, error)
+}
+
+type teamInvitationService struct {
+	db            *gorm.DB
+	notifications NotificationService
+	mail          mailer.Mailer
+	appURL        string
+	ttl           time.Duration
+}
+
+func NewTeamInvitationService(db *gorm.DB, notifications NotificationService, mail mailer.Mailer, appURL string, ttl time.Duration) TeamInvitationService {
+	return &teamInvitationService{
+		db:            db,
+		notifications: notifications,
+		mail:          mail,
+		appURL:        strings.TrimRight(appURL, "/"),
+		ttl:           ttl,
+	}
+}
+
+func (s *teamInvitationService) WithContext(ctx context.Context) TeamInvitationService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
+func (s *teamInvitationService) Invite(teamID, inviterID string, input TeamInviteInput) (*models.TeamInvitation, error) {
+	var team models.Team
+	if err := s.db.Select("id", "name").First(&team, "id = ?", teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTeamNotFound
+		}
+		return nil, err
+	}
+	if err := s.ensureRole(input.RoleID); err != nil {
+		return nil, err
+	}
+
+	var invitee models.User
+	if input.UserID != "" {
+		if err := s.db.Select("id", "email").First(&invitee, "id = ?", input.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrUserNotFound
+			}
+			return nil, err
+		}
+	} else {
+		email := strings.TrimSpace(strings.ToLower(input.Email))
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			return nil, ErrInvalidEmail
+		}
+		err := s.db.Select("id", "email").First(&invitee, "email = ?", email).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		invitee.Email = email
+	}
+
+	invitation := &models.TeamInvitation{
+		TeamID:    teamID,
+		InviterID: inviterID,
+		Email:     invitee.Email,
+		RoleID:    input.RoleID,
+		Status:    models.InvitationPending,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if invitee.ID != "" {
+		invitation.InviteeID = &invitee.ID
+
+		var member int64
+		s.db.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", teamID, invitee.ID).Count(&member)
+		if member > 0 {
+			return nil, ErrAlreadyTeamMember
+		}
+	}
+
+	var pending int64
+	s.db.Model(&models.TeamInvitation{}).
+		Where("team_id = ? AND status = ? AND expires_at > ?", teamID, models.InvitationPending, time.Now()).
+		Where("email = ? OR invitee_id = ?", invitation.Email, invitee.ID).
+		Count(&pending)
+	if pending > 0 {
+		return nil, ErrTeamInvitationExists
+	}
+
+	if err := s.db.Create(invitation).Error; err != nil {
+		return nil, err
+	}
+
+	if invitation.InviteeID != nil {
+		s.notifyInvitee(invitation, team.Name)
+		return invitation, nil
+	}
+	if err := s.sendEmail(invitation, team.Name); err != nil {
+		// Nobody can accept an invitation that was never delivered
+		s.db.Delete(invitation)
+		return nil, err
+	}
+	return invitation, nil
+}
+
+func (s *teamInvitationService) ListForTeam(teamID, status string, page, limit int) ([]models.TeamInvitation, int64, error) {
+	var invitations []models.TeamInvitation
+	var total int64
+
+	query := s.db.Model(&models.TeamInvitation{}).Where("team_id = ?", teamID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	err := query.Preload("Inviter").Preload("Invitee").Preload("Role").
+		Offset(offset).Limit(limit).Order("created_at DESC").Find(&invitations).Error
+	return invitations, total, err
+}
+
+func (s *teamInvitationService) Revoke(teamID, id string) (*models.TeamInvitation, error) {
+	var invitation models.TeamInvitation
+	err := s.db.First(&invitation, "id = ? AND team_id = ?", id, teamID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTeamInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := s.db.Model(&models.TeamInvitation{}).
+		Where("id = ? AND status = ?", id, models.InvitationPending).
+		Update("status", models.InvitationRevoked)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrTeamInvitationClosed
+	}
+	invitation.Status = models.InvitationRevoked
+	return &invitation, nil
+}
+
+func (s *teamInvitationService) ListPending(userID string) ([]models.TeamInvitation, error) {
+	var user models.User
+	if err := s.db.Select("id", "email").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	var invitations []models.TeamInvitation
+	err := s.db.Where("status = ? AND expires_at > ?", models.InvitationPending, time.Now()).
+		Where("invitee_id = ? OR (invitee_id IS NULL AND email = ?)", userID, user.Email).
+		Joins("JOIN teams ON teams.id = team_invitations.team_id AND teams.deleted_at IS NULL").
+		Preload("Team").Preload("Inviter").Preload("Role").
+		Order("team_invitations.created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+func (s *teamInvitationService) Accept(id, userID string) (*models.TeamMember, error) {
+	var invitation models.TeamInvitation
+	member := &models.TeamMember{}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if invitation, err = s.respond(tx, id, userID, models.InvitationAccepted); err != nil {
+			return err
+		}
+
+		var team int64
+		tx.Model(&models.Team{}).Where("id = ?", invitation.TeamID).Count(&team)
+		if team == 0 {
+			return ErrTeamNotFound
+		}
+
+		// Someone may have added the user in the meantime; keep that membership
+		err = tx.First(member, "team_id = ? AND user_id = ?", invitation.TeamID, userID).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		member = &models.TeamMember{TeamID: invitation.TeamID, UserID: userID, RoleID: invitation.RoleID}
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		return tx.Create(teamActivity(userID, invitation.TeamID, "team.member_join", map[string]interface{}{
+			"teamInvitationId": invitation.ID,
+		})).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyInviter(&invitation)
+	s.db.Preload("Team").Preload("Role").First(member, "team_id = ? AND user_id = ?", member.TeamID, member.UserID)
+	return member, nil
+}
+
+func (s *teamInvitationService) Decline(id, userID string) (*models.TeamInvitation, error) {
+	var invitation models.TeamInvitation
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invitation, err = s.respond(tx, id, userID, models.InvitationDeclined)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyInviter(&invitation)
+	return &invitation, nil
+}
+
+// respond locks the invitation, checks that userID may answer it and records
+// the answer
+func (s *teamInvitationService) respond(tx *gorm.DB, id, userID string, status models.InvitationStatus) (models.TeamInvitation, error) {
+	var invitation models.TeamInvitation
+	var user models.User
+	if err := tx.Select("id", "email").First(&user, "id = ?", userID).Error; err != nil {
+		return invitation, err
+	}
+
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		Where("invitee_id = ? OR (invitee_id IS NULL AND email = ?)", userID, user.Email).
+		First(&invitation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Invitations of other users are indistinguishable from missing ones
+		return invitation, ErrTeamInvitationNotFound
+	}
+	if err != nil {
+		return invitation, err
+	}
+	if invitation.Status != models.InvitationPending || invitation.IsExpired() {
+		return invitation, ErrTeamInvitationClosed
+	}
+
+	now := time.Now()
+	invitation.Status = status
+	invitation.RespondedAt = &now
+	invitation.InviteeID = &userID
+	err = tx.Model(&invitation).Updates(map[string]interface{}{
+		"status":       status,
+		"responded_at": now,
+		"invitee_id":   userID,
+	}).Error
+	return invitation, err
+}
+
+func (s *teamInvitationService) Claim(userID, email string) error {
+	var invitations []models.TeamInvitation
+	err := s.db.Where("invitee_id IS NULL AND email = ? AND status = ? AND expires_at > ?",
+		strings.ToLower(email), models.InvitationPending, time.Now()).
+		Preload("Team").
+		Find(&invitations).Error
+	if err != nil || len(invitations) == 0 {
+		return err
+	}
+
+	ids := make([]string, 0, len(invitations))
+	for _, invitation := range invitations {
+		ids = append(ids, invitation.ID)
+	}
+	if err := s.db.Model(&models.TeamInvitation{}).
+		Where("id IN ? AND invitee_id IS NULL", ids).
+		Update("invitee_id", userID).Error; err != nil {
+		return err
+	}
+
+	for i := range invitations {
+		invitations[i].InviteeID = &userID
+		s.notifyInvitee(&invitations[i], invitations[i].Team.Name)
+	}
+	return nil
+}
+
+func (s *teamInvitationService) CreateJoinLink(teamID, creatorID string, input JoinLinkInput) (*models.TeamJoinLink, string, error) {
+	var team int64
+	if err := s.db.Model(&models.Team{}).Where("id = ?", teamID).Count(&team).Error; err != nil {
+		return nil, "", err
+	}
+	if team == 0 {
+		return nil, "", ErrTeamNotFound
+	}
+	if err := s.ensureRole(input.RoleID); err != nil {
+		return nil, "", err
+	}
+	if input.MaxUses != nil && *input.MaxUses < 1 {
+		return nil, "", fmt.Errorf("%w: maxUses must be at least 1", ErrInvalidJoinLink)
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expiresAt must be in the future", ErrInvalidJoinLink)
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	link := &models.TeamJoinLink{
+		TeamID:    teamID,
+		CreatorID: creatorID,
+		TokenHash: utils.HashToken(token),
+		RoleID:    input.RoleID,
+		ExpiresAt: input.ExpiresAt,
+		MaxUses:   input.MaxUses,
+	}
+	if err := s.db.Create(link).Error; err != nil {
+		return nil, "", err
+	}
+	return link, s.appURL + "/teams/join?token=" + url.QueryEscape(token), nil
+}
+
+func (s *teamInvitationService) ListJoinLinks(teamID string) ([]models.TeamJoinLink, error) {
+	var links []models.TeamJoinLink
+	err := s.db.Where("team_id = ?", teamID).Preload("Role").Order("created_at DESC").Find(&links).Error
+	return links, err
+}
+
+func (s *teamInvitationService) RevokeJoinLink(teamID, id string) error {
+	res := s.db.Model(&models.TeamJoinLink{}).
+		Where("id = ? AND team_id = ? AND revoked_at IS NULL", id, teamID).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrJoinLinkNotFound
+	}
+	return nil
+}
+
+func (s *teamInvitationService) Join(token, userID string) (*models.TeamMember, error) {
+	var link models.TeamJoinLink
+	err := s.db.First(&link, "token_hash = ?", utils.HashToken(token)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJoinLinkInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !link.IsUsable() {
+		return nil, ErrJoinLinkInvalid
+	}
+
+	member := &models.TeamMember{TeamID: link.TeamID, UserID: userID, RoleID: link.RoleID}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Model(&models.Team{}).Where("id = ?", link.TeamID).Count(&count)
+		if count == 0 {
+			return ErrJoinLinkInvalid
+		}
+		// Existing members do not use up the link
+		tx.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", link.TeamID, userID).Count(&count)
+		if count > 0 {
+			return ErrAlreadyTeamMember
+		}
+
+		// The guard makes concurrent joins respect the usage cap
+		res := tx.Model(&models.TeamJoinLink{}).
+			Where("id = ? AND revoked_at IS NULL", link.ID).
+			Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+			Where("max_uses IS NULL OR use_count < max_uses").
+			Update("use_count", gorm.Expr("use_count + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrJoinLinkInvalid
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		return tx.Create(teamActivity(userID, link.TeamID, "team.member_join", map[string]interface{}{
+			"joinLinkId": link.ID,
+		})).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.db.Preload("Team").Preload("Role").First(member, "team_id = ? AND user_id = ?", member.TeamID, member.UserID)
+	return member, nil
+}
+
+func (s *teamInvitationService) ensureRole(roleID *string) error {
+	if roleID == nil {
+		return nil
+	}
+	var count int64
+	if err := s.db.Model(&models.Role{}).Where("id = ?", *roleID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrUnknownRole
+	}
+	return nil
+}
+
+func (s *teamInvitationService) inviterName(inviterID string) string {
+	var inviter models.User
+	s.db.Select("name", "email").First(&inviter, "id = ?", inviterID)
+	if inviter.Name != "" {
+		return inviter.Name
+	}
+	return inviter.Email
+}
+
+func (s *teamInvitationService) notifyInvitee(invitation *models.TeamInvitation, teamName string) {
+	link := teamInvitationLink
+	content := fmt.Sprintf("%s invited you to join the team %s.", s.inviterName(invitation.InviterID), teamName)
+	payload := map[string]interface{}{
+		"teamInvitationId": invitation.ID,
+		"teamId":           invitation.TeamID,
+		"expiresAt":        invitation.ExpiresAt,
+	}
+	if _, err := s.notifications.Notify(*invitation.InviteeID, "user", "Team invitation", content, &link, payload); err != nil {
+		log.Printf("team invitation %s: %v", invitation.ID, err)
+	}
+}
+
+// notifyInviter tells the inviter how the invitee answered
+func (s *teamInvitationService) notifyInviter(invitation *models.TeamInvitation) {
+	var team models.Team
+	s.db.Unscoped().Select("name").First(&team, "id = ?", invitation.TeamID)
+
+	var invitee models.User
+	s.db.Select("name", "email").First(&invitee, "id = ?", *invitation.InviteeID)
+	who := invitee.Name
+	if who == "" {
+		who = invitee.Email
+	}
+
+	answer := "accepted"
+	if invitation.Status == models.InvitationDeclined {
+		answer = "declined"
+	}
+	content := fmt.Sprintf("%s %s your invitation to join the team %s.", who, answer, team.Name)
+	payload := map[string]interface{}{"teamInvitationId": invitation.ID, "teamId": invitation.TeamID}
+	if _, err := s.notifications.Notify(invitation.InviterID, "user", "Team invitation "+answer, content, nil, payload); err != nil {
+		log.Printf("team invitation %s: %v", invitation.ID, err)
+	}
+}
+
+func (s *teamInvitationService) sendEmail(invitation *models.TeamInvitation, teamName string) error {
+	from := s.inviterName(invitation.InviterID)
+	link := s.appURL + "/register?email=" + url.QueryEscape(invitation.Email)
+	expires := invitation.ExpiresAt.UTC().Format("2006-01-02 15:04 MST")
+	text := fmt.Sprintf("%s invited you to join the team %s on HaloLight.\n\nSign up with this email address to accept the invitation:\n%s\n\nThe invitation expires on %s.\n",
+		from, teamName, link, expires)
+	body := fmt.Sprintf(`<p>%s invited you to join the team %s on HaloLight.</p><p><a href="%s">Sign up with this email address</a> to accept the invitation.</p><p>The invitation expires on %s.</p>`,
+		html.EscapeString(from), html.EscapeString(teamName), html.EscapeString(link), expires)
+
+	if err := s.mail.Send(mailer.Message{
+		To:      []string{invitation.Email},
+		Subject: "You're invited to join " + teamName + " on HaloLight",
+		Text:    text,
+		HTML:    body,
+	}); err != nil {
+		return fmt.Errorf("failed to send invitation email: %w", err)
+	}
+	return nil
+}
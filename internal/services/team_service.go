@@ -1,19 +1,65 @@
 package services
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+
 	"github.com/halolight/halolight-api-go/internal/models"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotTeamMember   = errors.New("user is not a member of this team")
+	ErrTeamOwnerLeaves = errors.New("the team owner cannot leave or be removed; transfer ownership first")
+	ErrTeamRoleDenied  = errors.New("your team role does not grant access to this content")
 )
 
+// Team roles grant access to team-owned content through these permissions.
+// Owners and members without a team role have full access.
+const (
+	TeamDocumentsView    = "documents:view"
+	TeamDocumentsEdit    = "documents:edit"
+	TeamDocumentsComment = "documents:comment"
+	TeamFilesView        = "files:view"
+	TeamFoldersView      = "folders:view"
+)
+
+// TeamWorkspace is the content owned by a team. Each section holds the most
+// recently updated items the caller's team role may view, along with the
+// section's total; sections the role does not grant are left empty.
+type TeamWorkspace struct {
+	Team          *models.Team      `json:"team"`
+	Documents     []models.Document `json:"documents"`
+	DocumentCount int64             `json:"documentCount"`
+	Files         []models.File     `json:"files"`
+	FileCount     int64             `json:"fileCount"`
+	Folders       []models.Folder   `json:"folders"`
+	FolderCount   int64             `json:"folderCount"`
+}
+
 type TeamService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) TeamService
 	List(userID string, page, limit int, search string) ([]models.Team, int64, error)
 	Get(id string) (*models.Team, error)
 	Create(name, description, ownerID string) (*models.Team, error)
 	Update(id, name, description string) (*models.Team, error)
 	Delete(id string) error
-	AddMember(teamID, userID string, roleID *string) (*models.TeamMember, error)
-	RemoveMember(teamID, userID string) error
+	RemoveMember(teamID, actorID, userID string) error
+	// UpdateMemberRole sets the member's team role; nil clears it
+	UpdateMemberRole(teamID, actorID, userID string, roleID *string) (*models.TeamMember, error)
+	// TransferOwnership hands the team to an existing member; the previous
+	// owner stays a member
+	TransferOwnership(teamID, actorID, newOwnerID string) (*models.Team, error)
+	Leave(teamID, userID string) error
+	Activity(teamID string, page, limit int) ([]models.ActivityLog, int64, error)
+	// Workspace aggregates the team's documents, files and folders for a member
+	Workspace(teamID, userID string, limit int) (*TeamWorkspace, error)
 	IsOwner(teamID, userID string) bool
+	IsMember(teamID, userID string) bool
 }
 
 type teamService struct {
@@ -24,6 +70,12 @@ func NewTeamService(db *gorm.DB) TeamService {
 	return &teamService{db: db}
 }
 
+func (s *teamService) WithContext(ctx context.Context) TeamService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	return &clone
+}
+
 func (s *teamService) List(userID string, page, limit int, search string) ([]models.Team, int64, error) {
 	var teams []models.Team
 	var total int64
@@ -95,23 +147,204 @@ func (s *teamService) Delete(id string) error {
 	return s.db.Delete(&models.Team{}, "id = ?", id).Error
 }
 
-func (s *teamService) AddMember(teamID, userID string, roleID *string) (*models.TeamMember, error) {
-	member := &models.TeamMember{
-		TeamID: teamID,
-		UserID: userID,
-		RoleID: roleID,
+func (s *teamService) RemoveMember(teamID, actorID, userID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := removeMember(tx, teamID, userID); err != nil {
+			return err
+		}
+		return tx.Create(teamActivity(actorID, teamID, "team.member_remove", map[string]interface{}{"userId": userID})).Error
+	})
+}
+
+func (s *teamService) Leave(teamID, userID string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := removeMember(tx, teamID, userID); err != nil {
+			return err
+		}
+		return tx.Create(teamActivity(userID, teamID, "team.member_leave", nil)).Error
+	})
+}
+
+// removeMember deletes a membership other than the owner's. The team row is
+// locked so a concurrent ownership transfer cannot hand the team to userID.
+func removeMember(tx *gorm.DB, teamID, userID string) error {
+	var team models.Team
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "owner_id").First(&team, "id = ?", teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTeamNotFound
+		}
+		return err
+	}
+	if team.OwnerID == userID {
+		return ErrTeamOwnerLeaves
+	}
+	res := tx.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamMember{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotTeamMember
+	}
+	return nil
+}
+
+func (s *teamService) UpdateMemberRole(teamID, actorID, userID string, roleID *string) (*models.TeamMember, error) {
+	if roleID != nil && *roleID == "" {
+		roleID = nil
+	}
+	if roleID != nil {
+		var count int64
+		if err := s.db.Model(&models.Role{}).Where("id = ?", *roleID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, ErrUnknownRole
+		}
+	}
+
+	var member models.TeamMember
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&member, "team_id = ? AND user_id = ?", teamID, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotTeamMember
+		}
+		if err != nil {
+			return err
+		}
+
+		from := member.RoleID
+		if err := tx.Model(&models.TeamMember{}).
+			Where("team_id = ? AND user_id = ?", teamID, userID).
+			Update("role_id", roleID).Error; err != nil {
+			return err
+		}
+		return tx.Create(teamActivity(actorID, teamID, "team.member_role_change", map[string]interface{}{
+			"userId": userID,
+			"from":   from,
+			"to":     roleID,
+		})).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	member = models.TeamMember{}
+	err = s.db.Preload("User").Preload("Role").First(&member, "team_id = ? AND user_id = ?", teamID, userID).Error
+	return &member, err
+}
+
+func (s *teamService) TransferOwnership(teamID, actorID, newOwnerID string) (*models.Team, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "owner_id").First(&team, "id = ?", teamID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTeamNotFound
+			}
+			return err
+		}
+		if team.OwnerID == newOwnerID {
+			return nil
+		}
+
+		var member int64
+		tx.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", teamID, newOwnerID).Count(&member)
+		if member == 0 {
+			return ErrNotTeamMember
+		}
+
+		if err := tx.Model(&models.Team{}).Where("id = ?", teamID).Update("owner_id", newOwnerID).Error; err != nil {
+			return err
+		}
+		return tx.Create(teamActivity(actorID, teamID, "team.ownership_transfer", map[string]interface{}{
+			"from": team.OwnerID,
+			"to":   newOwnerID,
+		})).Error
+	})
+	if err != nil {
+		return nil, err
 	}
-	err := s.db.Create(member).Error
+	return s.Get(teamID)
+}
+
+func (s *teamService) Activity(teamID string, page, limit int) ([]models.ActivityLog, int64, error) {
+	var activities []models.ActivityLog
+	var total int64
+
+	query := s.db.Model(&models.ActivityLog{}).Where("target_type = ? AND target_id = ?", "team", teamID)
+	query.Count(&total)
+
+	offset := (page - 1) * limit
+	err := query.Preload("Actor").Offset(offset).Limit(limit).Order("created_at DESC").Find(&activities).Error
+	return activities, total, err
+}
+
+func (s *teamService) Workspace(teamID, userID string, limit int) (*TeamWorkspace, error) {
+	member, team, err := teamMembership(s.db, teamID, userID)
 	if err != nil {
 		return nil, err
 	}
-	// Reload with user
-	s.db.Preload("User").First(member, "team_id = ? AND user_id = ?", teamID, userID)
-	return member, nil
+	if err := s.db.Preload("Owner").First(team, "id = ?", teamID).Error; err != nil {
+		return nil, err
+	}
+
+	workspace := &TeamWorkspace{
+		Team:      team,
+		Documents: []models.Document{},
+		Files:     []models.File{},
+		Folders:   []models.Folder{},
+	}
+
+	allowed := func(action string) (bool, error) {
+		err := teamRoleGrants(s.db, team, member, action)
+		if errors.Is(err, ErrTeamRoleDenied) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	if ok, err := allowed(TeamDocumentsView); err != nil {
+		return nil, err
+	} else if ok {
+		query := s.db.Model(&models.Document{}).Where("team_id = ?", teamID)
+		query.Count(&workspace.DocumentCount)
+		if err := query.Preload("Owner").Limit(limit).Order("updated_at DESC").Find(&workspace.Documents).Error; err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := allowed(TeamFilesView); err != nil {
+		return nil, err
+	} else if ok {
+		query := s.db.Model(&models.File{}).Where("team_id = ?", teamID)
+		query.Count(&workspace.FileCount)
+		if err := query.Preload("Owner").Limit(limit).Order("updated_at DESC").Find(&workspace.Files).Error; err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := allowed(TeamFoldersView); err != nil {
+		return nil, err
+	} else if ok {
+		query := s.db.Model(&models.Folder{}).Where("team_id = ?", teamID)
+		query.Count(&workspace.FolderCount)
+		if err := query.Limit(limit).Order("path ASC").Find(&workspace.Folders).Error; err != nil {
+			return nil, err
+		}
+	}
+	return workspace, nil
 }
 
-func (s *teamService) RemoveMember(teamID, userID string) error {
-	return s.db.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamMember{}).Error
+func teamActivity(actorID, teamID, action string, metadata map[string]interface{}) *models.ActivityLog {
+	activity := &models.ActivityLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: "team",
+		TargetID:   teamID,
+	}
+	if metadata != nil {
+		data, _ := json.Marshal(metadata)
+		activity.Metadata = datatypes.JSON(data)
+	}
+	return activity
 }
 
 func (s *teamService) IsOwner(teamID, userID string) bool {
@@ -122,3 +355,59 @@ func (s *teamService) IsOwner(teamID, userID string) bool {
 	}
 	return team.OwnerID == userID
 }
+
+func (s *teamService) IsMember(teamID, userID string) bool {
+	var count int64
+	s.db.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", teamID, userID).Count(&count)
+	return count > 0
+}
+
+// teamMembership returns userID's membership of teamID and the team's owner
+func teamMembership(db *gorm.DB, teamID, userID string) (*models.TeamMember, *models.Team, error) {
+	var team models.Team
+	if err := db.Select("id", "owner_id").First(&team, "id = ?", teamID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrTeamNotFound
+		}
+		return nil, nil, err
+	}
+	var member models.TeamMember
+	err := db.First(&member, "team_id = ? AND user_id = ?", teamID, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrNotTeamMember
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &member, &team, nil
+}
+
+// teamRoleGrants checks that member may perform action on content owned by
+// team. The owner and members without a team role always may.
+func teamRoleGrants(db *gorm.DB, team *models.Team, member *models.TeamMember, action string) error {
+	if team.OwnerID == member.UserID || member.RoleID == nil {
+		return nil
+	}
+	var count int64
+	err := db.Model(&models.Permission{}).
+		Joins("JOIN role_permissions rp ON rp.permission_id = permissions.id").
+		Where("rp.role_id = ? AND permissions.action IN ?", *member.RoleID, permissionCandidates(action)).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrTeamRoleDenied
+	}
+	return nil
+}
+
+// ensureTeamAccess checks that userID belongs to teamID and that their team
+// role grants action
+func ensureTeamAccess(db *gorm.DB, teamID, userID, action string) error {
+	member, team, err := teamMembership(db, teamID, userID)
+	if err != nil {
+		return err
+	}
+	return teamRoleGrants(db, team, member, action)
+}
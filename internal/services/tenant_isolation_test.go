@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/repository"
+	"github.com/halolight/halolight-api-go/internal/scim"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/internal/testdb"
+	"github.com/halolight/halolight-api-go/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// openTenantDB returns an in-memory database with the tenant callbacks and two
+// organizations
+func openTenantDB(t *testing.T) (db *gorm.DB, ctxA, ctxB context.Context) {
+	t.Helper()
+	db = testdb.Open(t,
+		&models.Organization{},
+		&models.User{},
+		&models.UserAttribute{},
+		&models.Team{},
+		&models.TeamMember{},
+		&models.Tag{},
+		&models.Document{},
+		&models.DocumentTag{},
+		&models.DocumentShare{},
+		&models.DocumentRevision{},
+		&models.Folder{},
+		&models.File{},
+		&models.DataExport{},
+	)
+
+	orgA := models.Organization{ID: models.GenerateULID(), Name: "A", Slug: "a"}
+	orgB := models.Organization{ID: models.GenerateULID(), Name: "B", Slug: "b"}
+	if err := db.Create([]*models.Organization{&orgA, &orgB}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return db, tenant.WithID(context.Background(), orgA.ID), tenant.WithID(context.Background(), orgB.ID)
+}
+
+func TestUserServiceTenantIsolation(t *testing.T) {
+	db, ctxA, ctxB := openTenantDB(t)
+	svc := NewUserService(repository.NewUserRepository(db), NewAttributeService(db))
+
+	alice, err := svc.WithContext(ctxA).Create("alice@a.test", "alice", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := svc.WithContext(ctxB).Create("bob@b.test", "bob", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users, total, err := svc.WithContext(ctxA).List(repository.UserFilter{}, 1, 20, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(users) != 1 || users[0].ID != alice.ID {
+		t.Fatalf("organization A lists %d users (total %d), want only alice", len(users), total)
+	}
+
+	if _, err := svc.WithContext(ctxA).Get(bob.ID); err == nil {
+		t.Fatal("organization A reads a user of organization B")
+	}
+	name := "Mallory"
+	if _, err := svc.WithContext(ctxA).Update(bob.ID, UserUpdate{Name: &name}, false); err == nil {
+		t.Fatal("organization A updates a user of organization B")
+	}
+	if err := svc.WithContext(ctxA).Delete(bob.ID); err == nil {
+		t.Fatal("organization A deletes a user of organization B")
+	}
+	if got, err := svc.WithContext(ctxB).Get(bob.ID); err != nil || got.Name == name {
+		t.Fatalf("organization B's user changed: %+v, %v", got, err)
+	}
+}
+
+func TestDocumentServiceTenantIsolation(t *testing.T) {
+	db, ctxA, ctxB := openTenantDB(t)
+	users := NewUserService(repository.NewUserRepository(db), NewAttributeService(db))
+	svc := NewDocumentService(db, RevisionPolicy{}, "simple")
+
+	alice, err := users.WithContext(ctxA).Create("alice@a.test", "alice", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := users.WithContext(ctxB).Create("bob@b.test", "bob", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.WithContext(ctxA).Create("Plan A", "a", "", models.DocumentTypeMarkdown, alice.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	docB, err := svc.WithContext(ctxB).Create("Plan B", "b", "", models.DocumentTypeMarkdown, bob.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Even the owner's ID does not reach the document from another organization
+	docs, total, err := svc.WithContext(ctxA).List(bob.ID, 1, 20, "", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 || len(docs) != 0 {
+		t.Fatalf("organization A lists %d documents of organization B", len(docs))
+	}
+	if _, err := svc.WithContext(ctxA).Get(docB.ID); err == nil {
+		t.Fatal("organization A reads a document of organization B")
+	}
+	if svc.WithContext(ctxA).HasAccess(docB.ID, bob.ID) || svc.WithContext(ctxA).CanEdit(docB.ID, bob.ID) {
+		t.Fatal("organization A grants access to a document of organization B")
+	}
+	if err := svc.WithContext(ctxA).DeleteMany([]string{docB.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.WithContext(ctxB).Get(docB.ID); err != nil {
+		t.Fatalf("organization A deleted a document of organization B: %v", err)
+	}
+
+	docs, total, err = svc.WithContext(ctxB).List(bob.ID, 1, 20, "", "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(docs) != 1 || docs[0].ID != docB.ID {
+		t.Fatalf("organization B lists %d documents (total %d), want its own", len(docs), total)
+	}
+}
+
+func TestFileServiceTenantIsolation(t *testing.T) {
+	db, ctxA, ctxB := openTenantDB(t)
+	users := NewUserService(repository.NewUserRepository(db), NewAttributeService(db))
+	svc := NewFileService(db, storage.NewLocal(t.TempDir(), ""))
+
+	alice, err := users.WithContext(ctxA).Create("alice@a.test", "alice", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := users.WithContext(ctxB).Create("bob@b.test", "bob", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.WithContext(ctxA).Create("a.txt", "/", "text/plain", 1, "", "", alice.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	fileB, err := svc.WithContext(ctxB).Create("b.txt", "/", "text/plain", 1, "", "", bob.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, total, err := svc.WithContext(ctxA).List(bob.ID, 1, 20, "", "", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 || len(files) != 0 {
+		t.Fatalf("organization A lists %d files of organization B", len(files))
+	}
+	if _, err := svc.WithContext(ctxA).Get(fileB.ID); err == nil {
+		t.Fatal("organization A reads a file of organization B")
+	}
+	if svc.WithContext(ctxA).IsOwner(fileB.ID, bob.ID) {
+		t.Fatal("organization A reports ownership of a file of organization B")
+	}
+	if _, err := svc.WithContext(ctxA).Rename(fileB.ID, "stolen.txt"); err == nil {
+		t.Fatal("organization A renames a file of organization B")
+	}
+	if err := svc.WithContext(ctxA).Delete(fileB.ID); err == nil {
+		t.Fatal("organization A deletes a file of organization B")
+	}
+	got, err := svc.WithContext(ctxB).Get(fileB.ID)
+	if err != nil || got.Name != "b.txt" {
+		t.Fatalf("organization A changed a file of organization B: %+v, %v", got, err)
+	}
+}
+
+func TestPrivacyServiceTenantIsolation(t *testing.T) {
+	db, ctxA, ctxB := openTenantDB(t)
+	users := NewUserService(repository.NewUserRepository(db), NewAttributeService(db))
+	store := storage.NewLocal(t.TempDir(), "")
+	svc := NewPrivacyService(db, store, store)
+
+	bob, err := users.WithContext(ctxB).Create("bob@b.test", "bob", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	export := &models.DataExport{UserID: bob.ID, RequestedByID: bob.ID, Status: models.DataExportCompleted}
+	if err := db.WithContext(ctxB).Create(export).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.WithContext(ctxA).RequestExport(bob.ID, bob.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("organization A exports a user of organization B: %v", err)
+	}
+	if _, err := svc.WithContext(ctxA).GetExport(bob.ID, export.ID); !errors.Is(err, ErrExportNotFound) {
+		t.Fatalf("organization A reads an export of organization B: %v", err)
+	}
+	if _, _, err := svc.WithContext(ctxA).OpenExport(bob.ID, export.ID); !errors.Is(err, ErrExportNotFound) {
+		t.Fatalf("organization A downloads an export of organization B: %v", err)
+	}
+	if _, err := svc.WithContext(ctxA).Erase(bob.ID, ErasureRequest{ActorID: bob.ID}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("organization A erases a user of organization B: %v", err)
+	}
+
+	if got, err := svc.WithContext(ctxB).GetExport(bob.ID, export.ID); err != nil || got.ID != export.ID {
+		t.Fatalf("organization B reads its export: %+v, %v", got, err)
+	}
+	if got, err := users.WithContext(ctxB).Get(bob.ID); err != nil || got.ErasedAt != nil {
+		t.Fatalf("organization B's user changed: %+v, %v", got, err)
+	}
+}
+
+func TestSCIMTokenTenantIsolation(t *testing.T) {
+	db, ctxA, ctxB := openTenantDB(t)
+	users := NewUserService(repository.NewUserRepository(db), NewAttributeService(db))
+	orgs := NewOrganizationService(db)
+	idA, _ := tenant.FromContext(ctxA)
+	idB, _ := tenant.FromContext(ctxB)
+
+	alice, err := users.WithContext(ctxA).Create("alice@a.test", "alice", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := users.WithContext(ctxB).Create("bob@b.test", "bob", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := orgs.SetSCIMToken(idA, bob.ID); !errors.Is(err, ErrSCIMGroupOwner) {
+		t.Fatalf("group owner from organization B: err = %v, want ErrSCIMGroupOwner", err)
+	}
+	tokenA, err := orgs.SetSCIMToken(idA, alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenB, err := orgs.SetSCIMToken(idB, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for token, want := range map[string]string{tokenA: idA, tokenB: idB} {
+		if got, err := orgs.SCIMTenantID(token); err != nil || got != want {
+			t.Fatalf("SCIMTenantID = %q, %v; want %q", got, err, want)
+		}
+	}
+
+	// A token provisions its own organization only
+	tenantID, _ := orgs.SCIMTenantID(tokenA)
+	svc := NewSCIMService(db, NewAttributeService(db)).WithContext(tenant.WithID(context.Background(), tenantID))
+	listed, total, err := svc.ListUsers("", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(listed) != 1 || listed[0].ID != alice.ID {
+		t.Fatalf("token A lists %d users (total %d), want only alice", len(listed), total)
+	}
+	if _, err := svc.GetUser(bob.ID); err == nil {
+		t.Fatal("token A reads a user of organization B")
+	}
+	team, err := svc.CreateGroup(&scim.Group{DisplayName: "Engineering"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.OwnerID != alice.ID {
+		t.Fatalf("SCIM group owner = %s, want alice", team.OwnerID)
+	}
+
+	rotated, err := orgs.SetSCIMToken(idA, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orgs.SCIMTenantID(tokenA); !errors.Is(err, ErrOrganizationNotFound) {
+		t.Fatalf("replaced token: err = %v, want ErrOrganizationNotFound", err)
+	}
+	if err := orgs.DisableSCIM(idA); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orgs.SCIMTenantID(rotated); !errors.Is(err, ErrOrganizationNotFound) {
+		t.Fatalf("disabled token: err = %v, want ErrOrganizationNotFound", err)
+	}
+	if got, err := orgs.SCIMTenantID(tokenB); err != nil || got != idB {
+		t.Fatalf("organization B's token: %q, %v", got, err)
+	}
+}
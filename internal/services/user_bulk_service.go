@@ -0,0 +1,541 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/repository"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const maxImportRows = 5000
+
+var (
+	ErrImportEmpty         = errors.New("import file has no data rows")
+	ErrImportTooLarge      = fmt.Errorf("import file exceeds %d rows", maxImportRows)
+	ErrImportMissingColumn = errors.New("import file must map both email and username columns")
+	ErrInvalidImportMap    = errors.New("invalid column mapping")
+	ErrInvalidExportColumn = errors.New("invalid export column")
+)
+
+// userImportFields are the target fields a source column can be mapped to
+var userImportFields = map[string]bool{
+	"email":      true,
+	"username":   true,
+	"password":   true,
+	"name":       true,
+	"phone":      true,
+	"department": true,
+	"position":   true,
+	"bio":        true,
+	"status":     true,
+	"roles":      true,
+	"teams":      true,
+}
+
+// userExportColumns maps export column keys to their value extractors
+var userExportColumns = map[string]func(u *models.User) string{
+	"id":         func(u *models.User) string { return u.ID },
+	"email":      func(u *models.User) string { return u.Email },
+	"username":   func(u *models.User) string { return u.Username },
+	"name":       func(u *models.User) string { return u.Name },
+	"phone":      func(u *models.User) string { return derefString(u.Phone) },
+	"status":     func(u *models.User) string { return string(u.Status) },
+	"department": func(u *models.User) string { return derefString(u.Department) },
+	"position":   func(u *models.User) string { return derefString(u.Position) },
+	"bio":        func(u *models.User) string { return derefString(u.Bio) },
+	"roles": func(u *models.User) string {
+		names := make([]string, 0, len(u.Roles))
+		for _, r := range u.Roles {
+			names = append(names, r.Role.Name)
+		}
+		return strings.Join(names, ";")
+	},
+	"teams": func(u *models.User) string {
+		names := make([]string, 0, len(u.Teams))
+		for _, t := range u.Teams {
+			names = append(names, t.Team.Name)
+		}
+		return strings.Join(names, ";")
+	},
+	"createdAt": func(u *models.User) string { return u.CreatedAt.Format(time.RFC3339) },
+	"lastLoginAt": func(u *models.User) string {
+		if u.LastLoginAt == nil {
+			return ""
+		}
+		return u.LastLoginAt.Format(time.RFC3339)
+	},
+}
+
+// attributeColumnPrefix marks import fields and export columns holding custom
+// attributes, e.g. attr.costCenter
+const attributeColumnPrefix = "attr."
+
+// DefaultUserExportColumns is used when the caller does not select columns.
+// Every defined custom attribute is appended.
+var DefaultUserExportColumns = []string{"id", "email", "username", "name", "phone", "status", "department", "position", "roles", "teams", "createdAt"}
+
+// UserImportOptions controls how an uploaded sheet is interpreted
+type UserImportOptions struct {
+	// Mapping maps a source column header to a target field. Headers that are
+	// not mapped are matched against the field names case-insensitively.
+	// Custom attributes are addressed as attr.<key>.
+	Mapping map[string]string
+	DryRun  bool
+	// Admin allows values for non-public attributes; see UserAttributesManage
+	Admin bool
+}
+
+type UserImportRowStatus string
+
+const (
+	ImportRowValid   UserImportRowStatus = "valid"
+	ImportRowInvalid UserImportRowStatus = "invalid"
+	ImportRowCreated UserImportRowStatus = "created"
+)
+
+// UserImportRow is the validation result for one data row of the sheet
+type UserImportRow struct {
+	Row      int                 `json:"row"` // sheet line number, the header is row 1
+	Email    string              `json:"email"`
+	Username string              `json:"username"`
+	Status   UserImportRowStatus `json:"status"`
+	UserID   string              `json:"userId,omitempty"`
+	Errors   []string            `json:"errors,omitempty"`
+}
+
+type UserImportReport struct {
+	DryRun  bool            `json:"dryRun"`
+	Total   int             `json:"total"`
+	Valid   int             `json:"valid"`
+	Invalid int             `json:"invalid"`
+	Created int             `json:"created"`
+	Rows    []UserImportRow `json:"rows"`
+}
+
+type UserBulkService interface {
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) UserBulkService
+	Import(rows [][]string, opts UserImportOptions) (*UserImportReport, error)
+	Export(filter repository.UserFilter, columns []string) ([][]string, error)
+}
+
+type userBulkService struct {
+	db         *gorm.DB
+	repo       repository.UserRepository
+	attributes AttributeService
+}
+
+func NewUserBulkService(db *gorm.DB, repo repository.UserRepository, attributes AttributeService) UserBulkService {
+	return &userBulkService{db: db, repo: repo, attributes: attributes}
+}
+
+func (s *userBulkService) WithContext(ctx context.Context) UserBulkService {
+	clone := *s
+	clone.db = s.db.WithContext(ctx)
+	clone.repo = s.repo.WithContext(ctx)
+	clone.attributes = s.attributes.WithContext(ctx)
+	return &clone
+}
+
+// importRecord is a parsed row waiting to be created
+type importRecord struct {
+	index   int // position in UserImportReport.Rows
+	user    models.User
+	roleIDs []string
+	teamIDs []string
+}
+
+func (s *userBulkService) Import(rows [][]string, opts UserImportOptions) (*UserImportReport, error) {
+	if len(rows) < 2 {
+		return nil, ErrImportEmpty
+	}
+	if len(rows)-1 > maxImportRows {
+		return nil, ErrImportTooLarge
+	}
+
+	definitions, err := s.attributes.List()
+	if err != nil {
+		return nil, err
+	}
+	defs := indexAttributes(definitions)
+
+	columns, err := resolveImportColumns(rows[0], opts.Mapping, defs)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.lookupByIDOrName(&models.Role{}, "roles")
+	if err != nil {
+		return nil, err
+	}
+	teams, err := s.lookupByIDOrName(&models.Team{}, "teams")
+	if err != nil {
+		return nil, err
+	}
+	existingEmails, existingUsernames, err := s.existingIdentities(rows[1:], columns)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &UserImportReport{DryRun: opts.DryRun, Rows: []UserImportRow{}}
+	seenEmails := map[string]int{}
+	seenUsernames := map[string]int{}
+	var records []*importRecord
+
+	for i, row := range rows[1:] {
+		if isBlankRow(row) {
+			continue
+		}
+		get := func(field string) string {
+			if idx, ok := columns[field]; ok && idx < len(row) {
+				return strings.TrimSpace(row[idx])
+			}
+			return ""
+		}
+
+		line := i + 2
+		rec := &importRecord{index: len(report.Rows)}
+		result := UserImportRow{Row: line}
+		var errs []string
+
+		email := strings.ToLower(get("email"))
+		username := get("username")
+		result.Email = email
+		result.Username = username
+
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			errs = append(errs, "invalid email")
+		} else if existingEmails[email] {
+			errs = append(errs, "email already exists")
+		} else if first, dup := seenEmails[email]; dup {
+			errs = append(errs, fmt.Sprintf("duplicate email, first seen on row %d", first))
+		} else {
+			seenEmails[email] = line
+		}
+
+		if len(username) < 3 || len(username) > 64 {
+			errs = append(errs, "username must be between 3 and 64 characters")
+		} else if existingUsernames[strings.ToLower(username)] {
+			errs = append(errs, "username already exists")
+		} else if first, dup := seenUsernames[strings.ToLower(username)]; dup {
+			errs = append(errs, fmt.Sprintf("duplicate username, first seen on row %d", first))
+		} else {
+			seenUsernames[strings.ToLower(username)] = line
+		}
+
+		password := get("password")
+		if password != "" && (len(password) < 6 || len(password) > 72) {
+			errs = append(errs, "password must be between 6 and 72 characters")
+		}
+
+		status := models.UserStatusActive
+		if v := strings.ToUpper(get("status")); v != "" {
+			status = models.UserStatus(v)
+			if !isValidUserStatus(status) {
+				errs = append(errs, "invalid status "+v)
+			}
+		}
+
+		for _, ref := range splitList(get("roles")) {
+			if id, ok := roles[strings.ToLower(ref)]; ok {
+				rec.roleIDs = append(rec.roleIDs, id)
+			} else {
+				errs = append(errs, "unknown role "+ref)
+			}
+		}
+		for _, ref := range splitList(get("teams")) {
+			if id, ok := teams[strings.ToLower(ref)]; ok {
+				rec.teamIDs = append(rec.teamIDs, id)
+			} else {
+				errs = append(errs, "unknown team "+ref)
+			}
+		}
+
+		values := map[string]interface{}{}
+		for field := range columns {
+			key, ok := strings.CutPrefix(field, attributeColumnPrefix)
+			if !ok {
+				continue
+			}
+			raw := get(field)
+			if raw == "" {
+				continue
+			}
+			value, err := parseAttribute(defs[key], raw)
+			if err != nil {
+				errs = append(errs, err.Error())
+				continue
+			}
+			values[key] = value
+		}
+		attributes, err := applyAttributes(defs, nil, values, true, opts.Admin)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+
+		name := get("name")
+		if name == "" {
+			name = username
+		}
+		rec.user = models.User{
+			Email:      email,
+			Username:   username,
+			Password:   password,
+			Name:       name,
+			Phone:      optionalString(get("phone")),
+			Department: optionalString(get("department")),
+			Position:   optionalString(get("position")),
+			Bio:        optionalString(get("bio")),
+			Status:     status,
+			Attributes: attributes,
+		}
+
+		report.Total++
+		if len(errs) > 0 {
+			result.Status = ImportRowInvalid
+			result.Errors = errs
+			report.Invalid++
+		} else {
+			result.Status = ImportRowValid
+			report.Valid++
+			records = append(records, rec)
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	if report.Total == 0 {
+		return nil, ErrImportEmpty
+	}
+	if opts.DryRun || len(records) == 0 {
+		return report, nil
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, rec := range records {
+			line := report.Rows[rec.index].Row
+			password := rec.user.Password
+			if password == "" {
+				// Accounts without a password must go through the reset flow
+				random, err := utils.GenerateRandomToken(24)
+				if err != nil {
+					return err
+				}
+				password = random
+			}
+			hash, err := utils.HashPassword(password)
+			if err != nil {
+				return err
+			}
+			rec.user.Password = hash
+
+			if err := tx.Create(&rec.user).Error; err != nil {
+				return fmt.Errorf("row %d: %w", line, err)
+			}
+			for _, roleID := range rec.roleIDs {
+				if err := tx.Create(&models.UserRole{UserID: rec.user.ID, RoleID: roleID}).Error; err != nil {
+					return fmt.Errorf("row %d: %w", line, err)
+				}
+			}
+			for _, teamID := range rec.teamIDs {
+				if err := tx.Create(&models.TeamMember{TeamID: teamID, UserID: rec.user.ID}).Error; err != nil {
+					return fmt.Errorf("row %d: %w", line, err)
+				}
+			}
+			report.Rows[rec.index].Status = ImportRowCreated
+			report.Rows[rec.index].UserID = rec.user.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	report.Created = len(records)
+
+	return report, nil
+}
+
+func (s *userBulkService) Export(filter repository.UserFilter, columns []string) ([][]string, error) {
+	definitions, err := s.attributes.List()
+	if err != nil {
+		return nil, err
+	}
+	defs := indexAttributes(definitions)
+
+	if len(columns) == 0 {
+		columns = append([]string{}, DefaultUserExportColumns...)
+		for _, def := range definitions {
+			columns = append(columns, attributeColumnPrefix+def.Key)
+		}
+	}
+	for _, col := range columns {
+		if key, ok := strings.CutPrefix(col, attributeColumnPrefix); ok {
+			if _, defined := defs[key]; defined {
+				continue
+			}
+		} else if _, ok := userExportColumns[col]; ok {
+			continue
+		}
+		return nil, fmt.Errorf("%w: %s", ErrInvalidExportColumn, col)
+	}
+
+	if filter.Attributes, err = s.attributes.ParseFilter(filter.Attributes, true); err != nil {
+		return nil, err
+	}
+	users, err := s.repo.ListAll(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([][]string, 0, len(users)+1)
+	rows = append(rows, columns)
+	for i := range users {
+		var values map[string]interface{}
+		if len(users[i].Attributes) > 0 {
+			if err := json.Unmarshal(users[i].Attributes, &values); err != nil {
+				return nil, err
+			}
+		}
+		row := make([]string, len(columns))
+		for j, col := range columns {
+			if key, ok := strings.CutPrefix(col, attributeColumnPrefix); ok {
+				row[j] = formatAttribute(values[key])
+			} else {
+				row[j] = userExportColumns[col](&users[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// resolveImportColumns maps each target field to its column index in the header
+func resolveImportColumns(header []string, mapping map[string]string, attributes map[string]models.UserAttribute) (map[string]int, error) {
+	// Field names are matched case-insensitively, attribute keys keep their case
+	fields := make(map[string]string, len(userImportFields)+len(attributes))
+	for field := range userImportFields {
+		fields[field] = field
+	}
+	for key := range attributes {
+		fields[strings.ToLower(attributeColumnPrefix+key)] = attributeColumnPrefix + key
+	}
+
+	normalized := make(map[string]string, len(mapping))
+	for src, target := range mapping {
+		field, ok := fields[strings.ToLower(strings.TrimSpace(target))]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidImportMap, target)
+		}
+		normalized[strings.ToLower(strings.TrimSpace(src))] = field
+	}
+
+	columns := map[string]int{}
+	for i, h := range header {
+		key := strings.ToLower(strings.TrimSpace(h))
+		field, ok := normalized[key]
+		if !ok {
+			field, ok = fields[key]
+		}
+		if !ok {
+			continue
+		}
+		if _, dup := columns[field]; dup {
+			return nil, fmt.Errorf("%w: field %q is mapped by more than one column", ErrInvalidImportMap, field)
+		}
+		columns[field] = i
+	}
+
+	if _, ok := columns["email"]; !ok {
+		return nil, ErrImportMissingColumn
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, ErrImportMissingColumn
+	}
+	return columns, nil
+}
+
+// lookupByIDOrName indexes a table by lower-cased ID and name
+func (s *userBulkService) lookupByIDOrName(model interface{}, table string) (map[string]string, error) {
+	var entries []struct {
+		ID   string
+		Name string
+	}
+	if err := s.db.Model(model).Select("id, name").Scan(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", table, err)
+	}
+	lookup := make(map[string]string, len(entries)*2)
+	for _, e := range entries {
+		lookup[strings.ToLower(e.ID)] = e.ID
+		lookup[strings.ToLower(e.Name)] = e.ID
+	}
+	return lookup, nil
+}
+
+// existingIdentities returns the emails and usernames in rows that are already
+// taken in any organization. Deleted accounts release theirs.
+func (s *userBulkService) existingIdentities(rows [][]string, columns map[string]int) (map[string]bool, map[string]bool, error) {
+	var emails, usernames []string
+	for _, row := range rows {
+		if idx := columns["email"]; idx < len(row) && row[idx] != "" {
+			emails = append(emails, strings.ToLower(strings.TrimSpace(row[idx])))
+		}
+		if idx := columns["username"]; idx < len(row) && row[idx] != "" {
+			usernames = append(usernames, strings.ToLower(strings.TrimSpace(row[idx])))
+		}
+	}
+
+	existingEmails := map[string]bool{}
+	existingUsernames := map[string]bool{}
+	if len(emails) == 0 && len(usernames) == 0 {
+		return existingEmails, existingUsernames, nil
+	}
+
+	var taken []models.User
+	err := tenant.Global(s.db).Select("email, username").
+		Where("LOWER(email) IN ? OR LOWER(username) IN ?", append(emails, ""), append(usernames, "")).
+		Find(&taken).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, u := range taken {
+		existingEmails[strings.ToLower(u.Email)] = true
+		existingUsernames[strings.ToLower(u.Username)] = true
+	}
+	return existingEmails, existingUsernames, nil
+}
+
+func isBlankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitList splits a multi-value cell separated by ";", "," or "|"
+func splitList(v string) []string {
+	parts := strings.FieldsFunc(v, func(r rune) bool { return r == ';' || r == ',' || r == '|' })
+	out := parts[:0]
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func derefString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"errors"
+	"log"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// Helpers shared by the erasure and purge workflows. All deletes are hard
+// deletes and must run inside the caller's transaction.
+
+type contentCounts struct {
+	Documents int64
+	Files     int64
+	Folders   int64
+}
+
+// deleteContent removes the documents, files and folders matching where and
+// returns the storage keys of the deleted files so their blobs can be removed
+// after the transaction commits
+func deleteContent(tx *gorm.DB, where string, args ...interface{}) (contentCounts, []string, error) {
+	var counts contentCounts
+	var ids []string
+
+	docIDs := tx.Unscoped().Model(&models.Document{}).Select("id").Where(where, args...)
+	if err := tx.Where("document_id IN (?)", docIDs).Delete(&models.DocumentTag{}).Error; err != nil {
+		return counts, nil, err
+	}
+	if err := tx.Where("document_id IN (?)", docIDs).Delete(&models.DocumentShare{}).Error; err != nil {
+		return counts, nil, err
+	}
+	if err := tx.Where("document_id IN (?)", docIDs).Delete(&models.DocumentRevision{}).Error; err != nil {
+		return counts, nil, err
+	}
+	if err := tx.Where("document_id IN (?)", docIDs).Delete(&models.DocumentComment{}).Error; err != nil {
+		return counts, nil, err
+	}
+	if err := deleteShareLinks(tx, "document_id IN (?)", docIDs); err != nil {
+		return counts, nil, err
+	}
+	res := tx.Unscoped().Where(where, args...).Delete(&models.Document{})
+	if res.Error != nil {
+		return counts, nil, res.Error
+	}
+	counts.Documents = res.RowsAffected
+
+	if err := tx.Unscoped().Model(&models.File{}).Where(where, args...).Pluck("id", &ids).Error; err != nil {
+		return counts, nil, err
+	}
+	fileIDs := tx.Unscoped().Model(&models.File{}).Select("id").Where(where, args...)
+	if err := deleteShareLinks(tx, "file_id IN (?)", fileIDs); err != nil {
+		return counts, nil, err
+	}
+	res = tx.Unscoped().Where(where, args...).Delete(&models.File{})
+	if res.Error != nil {
+		return counts, nil, res.Error
+	}
+	counts.Files = res.RowsAffected
+
+	res = tx.Unscoped().Where(where, args...).Delete(&models.Folder{})
+	if res.Error != nil {
+		return counts, nil, res.Error
+	}
+	counts.Folders = res.RowsAffected
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = FileBlobKey(id)
+	}
+	return counts, keys, nil
+}
+
+// deleteShareLinks removes the share links matching where with their access logs
+func deleteShareLinks(tx *gorm.DB, where string, args ...interface{}) error {
+	linkIDs := tx.Model(&models.ShareLink{}).Select("id").Where(where, args...)
+	if err := tx.Where("link_id IN (?)", linkIDs).Delete(&models.ShareLinkAccess{}).Error; err != nil {
+		return err
+	}
+	return tx.Where(where, args...).Delete(&models.ShareLink{}).Error
+}
+
+// deleteOwnedEvents removes the calendar events a user owns with their attendees and reminders
+func deleteOwnedEvents(tx *gorm.DB, userID string) (int64, error) {
+	eventIDs := tx.Unscoped().Model(&models.CalendarEvent{}).Select("id").Where("owner_id = ?", userID)
+	if err := tx.Where("event_id IN (?)", eventIDs).Delete(&models.EventAttendee{}).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("event_id IN (?)", eventIDs).Delete(&models.EventReminder{}).Error; err != nil {
+		return 0, err
+	}
+	res := tx.Unscoped().Where("owner_id = ?", userID).Delete(&models.CalendarEvent{})
+	return res.RowsAffected, res.Error
+}
+
+// deleteUserLinks removes a user's links to other data (attendance, shares,
+// memberships, roles) together with their credentials, settings and personal
+// document templates
+func deleteUserLinks(tx *gorm.DB, userID string) error {
+	for _, del := range []struct {
+		model interface{}
+		where string
+	}{
+		{&models.EventAttendee{}, "user_id = ?"},
+		{&models.DocumentShare{}, "shared_with_id = ?"},
+		{&models.ConversationParticipant{}, "user_id = ?"},
+		{&models.TeamMember{}, "user_id = ?"},
+		{&models.UserRole{}, "user_id = ?"},
+		{&models.RefreshToken{}, "user_id = ?"},
+		{&models.UserPreference{}, "user_id = ?"},
+		{&models.DataExport{}, "user_id = ?"},
+		{&models.LoginEvent{}, "user_id = ?"},
+		{&models.TeamInvitation{}, "? IN (invitee_id, inviter_id)"},
+		{&models.TeamJoinLink{}, "creator_id = ?"},
+		{&models.ShareLink{}, "creator_id = ?"},
+		{&models.DocumentTemplate{}, "creator_id = ? AND scope = '" + models.TemplateScopePersonal + "'"},
+	} {
+		if err := tx.Unscoped().Where(del.where, userID).Delete(del.model).Error; err != nil {
+			return err
+		}
+	}
+
+	// Reports and departments led by the user lose their manager and head
+	if err := tx.Unscoped().Model(&models.User{}).Where("manager_id = ?", userID).Update("manager_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&models.Department{}).Where("head_id = ?", userID).Update("head_id", nil).Error
+}
+
+// removeUserBlobs deletes file blobs, export archives and avatars once the
+// database no longer references them. Failures are logged, not returned.
+func removeUserBlobs(files, media storage.Storage, userID string, keys []string) {
+	for _, key := range keys {
+		if err := files.Delete(key); err != nil && !errors.Is(err, storage.ErrInvalidKey) {
+			log.Printf("user %s: delete blob %s: %v", userID, key, err)
+		}
+	}
+	if err := files.DeletePrefix("exports/" + userID); err != nil {
+		log.Printf("user %s: delete exports: %v", userID, err)
+	}
+	if err := media.DeletePrefix("avatars/users/" + userID); err != nil {
+		log.Printf("user %s: delete avatar: %v", userID, err)
+	}
+}
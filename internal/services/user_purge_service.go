@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"github.com/halolight/halolight-api-go/pkg/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// What happens to a purged user's owned teams and personal content
+const (
+	PurgeTransfer = "transfer"
+	PurgeDelete   = "delete"
+)
+
+// PurgePolicy configures the hard purge of soft-deleted users.
+//
+// Teams: "transfer" hands each owned team to SuccessorID, or to its
+// longest-standing remaining member, and deletes it when nobody is left;
+// "delete" deletes the teams with their team content.
+//
+// Content: personal documents, files and folders are deleted, or transferred
+// to SuccessorID with "transfer". Content in other people's teams always
+// stays with the team and moves to its owner.
+//
+// SuccessorID only takes over from users of its own organization; content of
+// users in other organizations is deleted.
+type PurgePolicy struct {
+	Retention   time.Duration
+	Teams       string
+	Content     string
+	SuccessorID string
+}
+
+// Validate reports configuration errors at startup instead of at purge time
+func (p PurgePolicy) Validate() error {
+	if p.Teams != PurgeTransfer && p.Teams != PurgeDelete {
+		return fmt.Errorf("invalid team purge policy %q", p.Teams)
+	}
+	if p.Content != PurgeTransfer && p.Content != PurgeDelete {
+		return fmt.Errorf("invalid content purge policy %q", p.Content)
+	}
+	if p.Content == PurgeTransfer && p.SuccessorID == "" {
+		return errors.New("content purge policy transfer requires a successor")
+	}
+	return nil
+}
+
+type PurgeReport struct {
+	Purged []string `json:"purged"`
+	Failed []string `json:"failed,omitempty"`
+}
+
+type UserPurgeService interface {
+	// PurgeExpired hard-deletes users soft-deleted longer than the retention window
+	PurgeExpired() (*PurgeReport, error)
+	// Start runs PurgeExpired every interval in the background
+	Start(interval time.Duration)
+	// PurgeAt returns when a user deleted at deletedAt becomes eligible for
+	// purging, or nil when purging is disabled
+	PurgeAt(deletedAt time.Time) *time.Time
+}
+
+type userPurgeService struct {
+	db     *gorm.DB
+	files  storage.Storage
+	media  storage.Storage
+	policy PurgePolicy
+}
+
+func NewUserPurgeService(db *gorm.DB, files, media storage.Storage, policy PurgePolicy) UserPurgeService {
+	return &userPurgeService{db: db, files: files, media: media, policy: policy}
+}
+
+func (s *userPurgeService) Start(interval time.Duration) {
+	if s.policy.Retention <= 0 {
+		log.Println("🗑️  User purge disabled (retention is 0)")
+		return
+	}
+	if err := s.policy.Validate(); err != nil {
+		log.Printf("🗑️  User purge disabled: %v", err)
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			report, err := s.PurgeExpired()
+			if err != nil {
+				log.Printf("user purge: %v", err)
+			} else if len(report.Purged) > 0 || len(report.Failed) > 0 {
+				log.Printf("user purge: purged %d users, %d failed", len(report.Purged), len(report.Failed))
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *userPurgeService) PurgeAt(deletedAt time.Time) *time.Time {
+	if s.policy.Retention <= 0 {
+		return nil
+	}
+	at := deletedAt.Add(s.policy.Retention)
+	return &at
+}
+
+func (s *userPurgeService) PurgeExpired() (*PurgeReport, error) {
+	cutoff := time.Now().Add(-s.policy.Retention)
+	var ids []string
+	err := s.db.Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ? AND erased_at IS NULL", cutoff).
+		Order("deleted_at").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PurgeReport{Purged: []string{}}
+	for _, id := range ids {
+		if err := s.purge(id, cutoff); err != nil {
+			log.Printf("user purge %s: %v", id, err)
+			report.Failed = append(report.Failed, id)
+			continue
+		}
+		report.Purged = append(report.Purged, id)
+	}
+	return report, nil
+}
+
+func (s *userPurgeService) purge(userID string, cutoff time.Time) error {
+	var blobKeys []string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Re-check under the transaction in case the user was restored meanwhile
+		var user models.User
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deleted_at IS NOT NULL AND deleted_at < ? AND erased_at IS NULL", userID, cutoff).
+			First(&user).Error
+		if err != nil {
+			return err
+		}
+		// Heirs and successors must come from the user's own organization
+		tx = tx.WithContext(tenant.WithID(tx.Statement.Context, user.TenantID))
+
+		keys, err := s.handleTeams(tx, userID)
+		if err != nil {
+			return err
+		}
+		blobKeys = append(blobKeys, keys...)
+
+		// Content in teams owned by others stays with the team
+		for _, model := range []interface{}{&models.Document{}, &models.File{}, &models.Folder{}} {
+			err := tx.Unscoped().Model(model).
+				Where("owner_id = ? AND team_id IS NOT NULL", userID).
+				Update("owner_id", gorm.Expr("(SELECT owner_id FROM teams WHERE teams.id = team_id)")).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		transfer := s.policy.Content == PurgeTransfer
+		if successorTenant := s.successorTenant(tx); successorTenant != "" && successorTenant != user.TenantID {
+			transfer = false
+		}
+		if transfer {
+			if err := s.checkSuccessor(tx, userID); err != nil {
+				return err
+			}
+			for _, model := range []interface{}{&models.Document{}, &models.File{}, &models.Folder{}} {
+				err := tx.Unscoped().Model(model).
+					Where("owner_id = ? AND team_id IS NULL", userID).
+					Update("owner_id", s.policy.SuccessorID).Error
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			_, keys, err := deleteContent(tx, "owner_id = ? AND team_id IS NULL", userID)
+			if err != nil {
+				return err
+			}
+			blobKeys = append(blobKeys, keys...)
+		}
+
+		if _, err := deleteOwnedEvents(tx, userID); err != nil {
+			return err
+		}
+		if err := deleteUserLinks(tx, userID); err != nil {
+			return err
+		}
+		for _, del := range []struct {
+			model interface{}
+			where string
+		}{
+			{&models.Notification{}, "user_id = ?"},
+			{&models.Message{}, "sender_id = ?"},
+			{&models.ActivityLog{}, "actor_id = ?"},
+			{&models.Invitation{}, "inviter_id = ?"},
+		} {
+			if err := tx.Unscoped().Where(del.where, userID).Delete(del.model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Delete(&models.User{}, "id = ?", userID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	removeUserBlobs(s.files, s.media, userID, blobKeys)
+	return nil
+}
+
+// handleTeams applies the team policy to every team the user owns and returns
+// the storage keys of files deleted with a team
+func (s *userPurgeService) handleTeams(tx *gorm.DB, userID string) ([]string, error) {
+	var teams []models.Team
+	if err := tx.Unscoped().Where("owner_id = ?", userID).Find(&teams).Error; err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, team := range teams {
+		heir := ""
+		if s.policy.Teams == PurgeTransfer {
+			var err error
+			if heir, err = s.teamHeir(tx, team.ID, userID); err != nil {
+				return nil, err
+			}
+		}
+
+		if heir == "" {
+			_, teamKeys, err := deleteContent(tx, "team_id = ?", team.ID)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, teamKeys...)
+			if err := tx.Where("team_id = ?", team.ID).Delete(&models.DocumentShare{}).Error; err != nil {
+				return nil, err
+			}
+			if err := tx.Where("team_id = ?", team.ID).Delete(&models.TeamMember{}).Error; err != nil {
+				return nil, err
+			}
+			if err := tx.Unscoped().Delete(&models.Team{}, "id = ?", team.ID).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		member := models.TeamMember{TeamID: team.ID, UserID: heir}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Unscoped().Model(&models.Team{}).Where("id = ?", team.ID).Update("owner_id", heir).Error; err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// teamHeir picks the configured successor, or else the longest-standing active
+// member of the team. It returns "" when nobody can take the team over.
+func (s *userPurgeService) teamHeir(tx *gorm.DB, teamID, userID string) (string, error) {
+	if s.policy.SuccessorID != "" && s.checkSuccessor(tx, userID) == nil {
+		return s.policy.SuccessorID, nil
+	}
+
+	var heirs []string
+	err := tx.Table("team_members tm").
+		Joins("JOIN users u ON u.id = tm.user_id").
+		Where("tm.team_id = ? AND tm.user_id <> ? AND u.deleted_at IS NULL AND u.status = ?", teamID, userID, models.UserStatusActive).
+		Order("tm.joined_at ASC").
+		Limit(1).
+		Pluck("tm.user_id", &heirs).Error
+	if err != nil || len(heirs) == 0 {
+		return "", err
+	}
+	return heirs[0], nil
+}
+
+// successorTenant returns the organization of the configured successor, or
+// "" when it does not exist
+func (s *userPurgeService) successorTenant(tx *gorm.DB) string {
+	var tenantIDs []string
+	tx.WithContext(context.Background()).Model(&models.User{}).
+		Where("id = ?", s.policy.SuccessorID).
+		Pluck("tenant_id", &tenantIDs)
+	if len(tenantIDs) == 0 {
+		return ""
+	}
+	return tenantIDs[0]
+}
+
+func (s *userPurgeService) checkSuccessor(tx *gorm.DB, userID string) error {
+	var successor models.User
+	err := tx.Select("id", "status").First(&successor, "id = ?", s.policy.SuccessorID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || successor.ID == userID ||
+		(err == nil && successor.Status != models.UserStatusActive) {
+		return ErrInvalidSuccessor
+	}
+	return err
+}
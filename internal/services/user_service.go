@@ -1,36 +1,69 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"strings"
 
 	"github.com/halolight/halolight-api-go/internal/models"
 	"github.com/halolight/halolight-api-go/internal/repository"
 	"github.com/halolight/halolight-api-go/pkg/utils"
+	"gorm.io/gorm"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidStatus   = errors.New("invalid user status")
+	ErrInvalidUserSort = errors.New("invalid sort field")
+	ErrIdentityTaken   = errors.New("email, username or phone already belongs to another user")
+)
+
+// UserUpdate carries the optional fields of a user update; nil fields are left unchanged
+type UserUpdate struct {
+	Email      *string
+	Username   *string
+	Password   *string
+	Name       *string
+	Phone      *string
+	Department *string
+	Position   *string
+	Bio        *string
+	Attributes map[string]interface{} // merged into the stored values; nil values remove an attribute
+}
 
 type UserService interface {
-	List(page, pageSize int) ([]models.User, int64, error)
-	Get(id uint) (*models.User, error)
-	GetByID(id string) (*models.User, error)
-	Create(email, username, password string) (*models.User, error)
-	Update(id uint, email, username, password string) (*models.User, error)
-	UpdateStatus(id string, status string) (*models.User, error)
-	Delete(id uint) error
+	// WithContext binds the service to ctx, which scopes it to an organization
+	WithContext(ctx context.Context) UserService
+
+	// admin reports that the caller holds UserAttributesManage, which filtering
+	// on and writing private and admin attributes require
+	List(filter repository.UserFilter, page, pageSize int, admin bool) ([]models.User, int64, error)
+	Get(id string) (*models.User, error)
+	Create(email, username, password string, attributes map[string]interface{}, admin bool) (*models.User, error)
+	Update(id string, input UserUpdate, admin bool) (*models.User, error)
+	Delete(id string) error
 	BatchDelete(ids []string) error
+	ListDeleted(filter repository.UserFilter, page, pageSize int, admin bool) ([]models.User, int64, error)
+	Restore(id string) (*models.User, error)
 }
 
 type userService struct {
-	repo repository.UserRepository
+	repo       repository.UserRepository
+	attributes AttributeService
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
-	return &userService{repo: repo}
+func NewUserService(repo repository.UserRepository, attributes AttributeService) UserService {
+	return &userService{repo: repo, attributes: attributes}
 }
 
-func (s *userService) List(page, pageSize int) ([]models.User, int64, error) {
+func (s *userService) WithContext(ctx context.Context) UserService {
+	clone := *s
+	clone.repo = s.repo.WithContext(ctx)
+	clone.attributes = s.attributes.WithContext(ctx)
+	return &clone
+}
+
+func (s *userService) List(filter repository.UserFilter, page, pageSize int, admin bool) ([]models.User, int64, error) {
 	// Set default values
 	if page < 1 {
 		page = 1
@@ -39,11 +72,24 @@ func (s *userService) List(page, pageSize int) ([]models.User, int64, error) {
 		pageSize = 20
 	}
 
+	if filter.Status != "" && !isValidUserStatus(filter.Status) {
+		return nil, 0, ErrInvalidStatus
+	}
+	if filter.SortBy != "" && !repository.IsValidUserSort(filter.SortBy) {
+		return nil, 0, ErrInvalidUserSort
+	}
+	filter.Search = strings.TrimSpace(filter.Search)
+	attrs, err := s.attributes.ParseFilter(filter.Attributes, admin)
+	if err != nil {
+		return nil, 0, err
+	}
+	filter.Attributes = attrs
+
 	offset := (page - 1) * pageSize
-	return s.repo.List(offset, pageSize)
+	return s.repo.List(filter, offset, pageSize)
 }
 
-func (s *userService) Get(id uint) (*models.User, error) {
+func (s *userService) Get(id string) (*models.User, error) {
 	u, err := s.repo.GetByID(id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
@@ -54,7 +100,7 @@ func (s *userService) Get(id uint) (*models.User, error) {
 	return u, nil
 }
 
-func (s *userService) Create(email, username, password string) (*models.User, error) {
+func (s *userService) Create(email, username, password string, attributes map[string]interface{}, admin bool) (*models.User, error) {
 	// Validate email
 	email = strings.TrimSpace(strings.ToLower(email))
 	if !strings.Contains(email, "@") {
@@ -72,6 +118,12 @@ func (s *userService) Create(email, username, password string) (*models.User, er
 		return nil, errors.New("password must be at least 6 characters")
 	}
 
+	// Validate custom attributes; required ones must be provided
+	attrs, err := s.attributes.Apply(nil, attributes, true, admin)
+	if err != nil {
+		return nil, err
+	}
+
 	// Hash password
 	hash, err := utils.HashPassword(password)
 	if err != nil {
@@ -80,9 +132,10 @@ func (s *userService) Create(email, username, password string) (*models.User, er
 
 	// Create user
 	u := &models.User{
-		Email:    email,
-		Username: username,
-		Password: hash,
+		Email:      email,
+		Username:   username,
+		Password:   hash,
+		Attributes: attrs,
 	}
 
 	if err := s.repo.Create(u); err != nil {
@@ -95,7 +148,7 @@ func (s *userService) Create(email, username, password string) (*models.User, er
 	return u, nil
 }
 
-func (s *userService) Update(id uint, email, username, password string) (*models.User, error) {
+func (s *userService) Update(id string, input UserUpdate, admin bool) (*models.User, error) {
 	// Get existing user
 	u, err := s.Get(id)
 	if err != nil {
@@ -103,8 +156,8 @@ func (s *userService) Update(id uint, email, username, password string) (*models
 	}
 
 	// Update email
-	if email != "" {
-		email = strings.TrimSpace(strings.ToLower(email))
+	if input.Email != nil {
+		email := strings.TrimSpace(strings.ToLower(*input.Email))
 		if !strings.Contains(email, "@") {
 			return nil, errors.New("invalid email format")
 		}
@@ -112,8 +165,8 @@ func (s *userService) Update(id uint, email, username, password string) (*models
 	}
 
 	// Update username
-	if username != "" {
-		username = strings.TrimSpace(username)
+	if input.Username != nil {
+		username := strings.TrimSpace(*input.Username)
 		if len(username) < 3 || len(username) > 64 {
 			return nil, errors.New("username must be between 3 and 64 characters")
 		}
@@ -121,21 +174,49 @@ func (s *userService) Update(id uint, email, username, password string) (*models
 	}
 
 	// Update password if provided
-	if password != "" {
-		if len(password) < 6 {
+	if input.Password != nil {
+		if len(*input.Password) < 6 {
 			return nil, errors.New("password must be at least 6 characters")
 		}
-		hash, err := utils.HashPassword(password)
+		hash, err := utils.HashPassword(*input.Password)
 		if err != nil {
 			return nil, err
 		}
 		u.Password = hash
 	}
 
+	// Update profile fields
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return nil, errors.New("name must not be empty")
+		}
+		u.Name = name
+	}
+	if input.Phone != nil {
+		u.Phone = optionalString(*input.Phone)
+	}
+	if input.Department != nil {
+		u.Department = optionalString(*input.Department)
+	}
+	if input.Position != nil {
+		u.Position = optionalString(*input.Position)
+	}
+	if input.Bio != nil {
+		u.Bio = optionalString(*input.Bio)
+	}
+	if input.Attributes != nil {
+		attrs, err := s.attributes.Apply(u.Attributes, input.Attributes, false, admin)
+		if err != nil {
+			return nil, err
+		}
+		u.Attributes = attrs
+	}
+
 	// Save changes
 	if err := s.repo.Update(u); err != nil {
 		if errors.Is(err, repository.ErrDuplicateKey) {
-			return nil, errors.New("email or username already exists")
+			return nil, errors.New("email, username or phone already exists")
 		}
 		return nil, err
 	}
@@ -143,7 +224,7 @@ func (s *userService) Update(id uint, email, username, password string) (*models
 	return u, nil
 }
 
-func (s *userService) Delete(id uint) error {
+func (s *userService) Delete(id string) error {
 	err := s.repo.Delete(id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
@@ -154,24 +235,70 @@ func (s *userService) Delete(id uint) error {
 	return nil
 }
 
-func (s *userService) GetByID(id string) (*models.User, error) {
-	return s.repo.GetByStringID(id)
+func (s *userService) BatchDelete(ids []string) error {
+	return s.repo.BatchDelete(ids)
 }
 
-func (s *userService) UpdateStatus(id string, status string) (*models.User, error) {
-	user, err := s.repo.GetByStringID(id)
+func (s *userService) ListDeleted(filter repository.UserFilter, page, pageSize int, admin bool) ([]models.User, int64, error) {
+	if filter.Status != "" && !isValidUserStatus(filter.Status) {
+		return nil, 0, ErrInvalidStatus
+	}
+	if filter.SortBy != "" && !repository.IsValidUserSort(filter.SortBy) {
+		return nil, 0, ErrInvalidUserSort
+	}
+	filter.Search = strings.TrimSpace(filter.Search)
+	attrs, err := s.attributes.ParseFilter(filter.Attributes, admin)
 	if err != nil {
-		return nil, ErrUserNotFound
+		return nil, 0, err
 	}
+	filter.Attributes = attrs
 
-	user.Status = models.UserStatus(status)
-	if err := s.repo.Update(user); err != nil {
+	offset := (page - 1) * pageSize
+	return s.repo.ListDeleted(filter, offset, pageSize)
+}
+
+func (s *userService) Restore(id string) (*models.User, error) {
+	if err := s.repo.Restore(id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			return nil, ErrIdentityTaken
+		}
 		return nil, err
 	}
+	return s.Get(id)
+}
 
-	return user, nil
+func isValidUserStatus(status models.UserStatus) bool {
+	switch status {
+	case models.UserStatusActive, models.UserStatusInactive, models.UserStatusSuspended:
+		return true
+	}
+	return false
 }
 
-func (s *userService) BatchDelete(ids []string) error {
-	return s.repo.BatchDelete(ids)
+// optionalString maps a blank value to nil so clearing a nullable column stores NULL
+func optionalString(v string) *string {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+// ensureUsers checks that every ID is a user of the organization tx is scoped to
+func ensureUsers(tx *gorm.DB, ids []string) error {
+	ids = uniqueStrings(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.User{}).Where("id IN ?", ids).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(ids) {
+		return ErrUserNotFound
+	}
+	return nil
 }
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/halolight/halolight-api-go/internal/repository"
+)
+
+func TestDeletedUserReleasesIdentifiers(t *testing.T) {
+	db, ctxA, ctxB := openTenantDB(t)
+	svc := NewUserService(repository.NewUserRepository(db), NewAttributeService(db))
+
+	old, err := svc.WithContext(ctxA).Create("alice@example.test", "alice", "secret123", nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.WithContext(ctxA).Delete(old.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Identifiers are unique across organizations, deleted users aside
+	if _, err := svc.WithContext(ctxB).Create("alice@example.test", "alice", "secret123", nil, false); err != nil {
+		t.Fatalf("re-creating a deleted user's email and username: %v", err)
+	}
+	if _, err := svc.WithContext(ctxA).Create("alice@example.test", "alice2", "secret123", nil, false); err == nil {
+		t.Fatal("two active users share an email")
+	}
+
+	if _, err := svc.WithContext(ctxA).Restore(old.ID); !errors.Is(err, ErrIdentityTaken) {
+		t.Fatalf("restoring over a taken email: got %v, want ErrIdentityTaken", err)
+	}
+}
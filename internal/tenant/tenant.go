@@ -0,0 +1,140 @@
+// Package tenant isolates the data of organizations sharing one database.
+//
+// Tenant-owned models carry a TenantID field. Register installs GORM callbacks
+// that restrict every query, update and delete on such a model to the tenant
+// found in the statement context and stamp new rows with it, so services only
+// need to run their queries with db.WithContext(ctx). Statements without a
+// tenant in their context, such as background jobs, are not restricted.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultID is the organization that existing data, self-registered users and
+// tokens issued before multi-tenancy belong to. Its members operate the
+// platform and may manage the other organizations.
+const DefaultID = "00000000000000000000000000"
+
+// ContextKey is the gin.Context key the auth middleware stores the tenant under
+const ContextKey = "tenantID"
+
+// ErrCrossTenant is returned when a row of another tenant would be written
+var ErrCrossTenant = errors.New("record belongs to another organization")
+
+type contextKey struct{}
+
+// globalKey marks statements that are deliberately not restricted
+const globalKey = "tenant:global"
+
+// WithID returns a copy of ctx scoped to tenantID
+func WithID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant ctx is scoped to. A gin.Context resolves the
+// value set under ContextKey.
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	if id, ok := ctx.Value(contextKey{}).(string); ok && id != "" {
+		return id, true
+	}
+	if id, ok := ctx.Value(ContextKey).(string); ok && id != "" {
+		return id, true
+	}
+	return "", false
+}
+
+// Global lifts the restriction from statements built on db, for checks that
+// span organizations such as the uniqueness of sign-in identifiers
+func Global(db *gorm.DB) *gorm.DB {
+	return db.Set(globalKey, true)
+}
+
+// Register installs the tenant callbacks on db
+func Register(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().Before("gorm:create").Register("tenant:create", stamp); err != nil {
+		return err
+	}
+	if err := cb.Query().Before("gorm:query").Register("tenant:query", restrict); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tenant:row", restrict); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:update", restrict); err != nil {
+		return err
+	}
+	return cb.Delete().Before("gorm:delete").Register("tenant:delete", restrict)
+}
+
+// scoped returns the tenant and the name of the tenant column when stmt
+// targets a tenant-owned model inside a tenant context
+func scoped(db *gorm.DB) (string, string, bool) {
+	if db.Statement.Schema == nil {
+		return "", "", false
+	}
+	if global, ok := db.Get(globalKey); ok && global == true {
+		return "", "", false
+	}
+	field := db.Statement.Schema.LookUpField("TenantID")
+	if field == nil {
+		return "", "", false
+	}
+	id, ok := FromContext(db.Statement.Context)
+	if !ok {
+		return "", "", false
+	}
+	return id, field.DBName, true
+}
+
+// restrict limits the statement to rows of the current tenant
+func restrict(db *gorm.DB) {
+	id, column, ok := scoped(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: id},
+	}})
+}
+
+// stamp assigns the current tenant to new rows and refuses rows that already
+// belong to another tenant
+func stamp(db *gorm.DB) {
+	id, _, ok := scoped(db)
+	if !ok || db.Error != nil {
+		return
+	}
+	field := db.Statement.Schema.LookUpField("TenantID")
+	set := func(rv reflect.Value) {
+		current, zero := field.ValueOf(db.Statement.Context, rv)
+		if zero {
+			if err := field.Set(db.Statement.Context, rv, id); err != nil {
+				db.AddError(err)
+			}
+			return
+		}
+		if current != id {
+			db.AddError(ErrCrossTenant)
+		}
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			set(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		set(rv)
+	}
+}
@@ -0,0 +1,200 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// note is a tenant-owned model
+type note struct {
+	ID       uint
+	TenantID string
+	Body     string
+}
+
+// setting is a model without a tenant
+type setting struct {
+	ID   uint
+	Name string
+}
+
+const (
+	tenantA = "0000000000000000000000000A"
+	tenantB = "0000000000000000000000000B"
+)
+
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: opens its own database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := Register(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&note{}, &setting{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func seed(t *testing.T, db *gorm.DB) (ctxA, ctxB context.Context) {
+	t.Helper()
+	ctxA = WithID(context.Background(), tenantA)
+	ctxB = WithID(context.Background(), tenantB)
+	for _, n := range []struct {
+		ctx  context.Context
+		body string
+	}{{ctxA, "a1"}, {ctxA, "a2"}, {ctxB, "b1"}} {
+		if err := db.WithContext(n.ctx).Create(&note{Body: n.body}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ctxA, ctxB
+}
+
+func TestStampAssignsContextTenant(t *testing.T) {
+	db := openDB(t)
+	seed(t, db)
+
+	var notes []note
+	db.Order("body").Find(&notes)
+	want := map[string]string{"a1": tenantA, "a2": tenantA, "b1": tenantB}
+	if len(notes) != len(want) {
+		t.Fatalf("got %d notes, want %d", len(notes), len(want))
+	}
+	for _, n := range notes {
+		if n.TenantID != want[n.Body] {
+			t.Errorf("note %s: tenant %q, want %q", n.Body, n.TenantID, want[n.Body])
+		}
+	}
+}
+
+func TestStampRejectsOtherTenant(t *testing.T) {
+	db := openDB(t)
+	ctxA := WithID(context.Background(), tenantA)
+
+	err := db.WithContext(ctxA).Create(&note{TenantID: tenantB, Body: "x"}).Error
+	if !errors.Is(err, ErrCrossTenant) {
+		t.Fatalf("create with another tenant: got %v, want ErrCrossTenant", err)
+	}
+	err = db.WithContext(ctxA).Create([]note{{Body: "ok"}, {TenantID: tenantB, Body: "x"}}).Error
+	if !errors.Is(err, ErrCrossTenant) {
+		t.Fatalf("batch create with another tenant: got %v, want ErrCrossTenant", err)
+	}
+	var count int64
+	db.Model(&note{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("%d notes were written", count)
+	}
+}
+
+func TestRestrictQueries(t *testing.T) {
+	db := openDB(t)
+	ctxA, ctxB := seed(t, db)
+
+	var notes []note
+	db.WithContext(ctxA).Find(&notes)
+	if len(notes) != 2 {
+		t.Fatalf("tenant A sees %d notes, want 2", len(notes))
+	}
+	for _, n := range notes {
+		if n.TenantID != tenantA {
+			t.Errorf("tenant A sees note %s of %s", n.Body, n.TenantID)
+		}
+	}
+
+	var b note
+	if err := db.WithContext(ctxB).First(&b).Error; err != nil || b.Body != "b1" {
+		t.Fatalf("tenant B first note: %+v, %v", b, err)
+	}
+	err := db.WithContext(ctxA).First(&note{}, b.ID).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("tenant A reads tenant B's note by id: %v", err)
+	}
+
+	var count int64
+	db.WithContext(ctxB).Model(&note{}).Count(&count)
+	if count != 1 {
+		t.Errorf("tenant B counts %d notes, want 1", count)
+	}
+	var bodies []string
+	db.WithContext(ctxA).Model(&note{}).Pluck("body", &bodies)
+	if len(bodies) != 2 {
+		t.Errorf("tenant A plucks %v", bodies)
+	}
+}
+
+func TestRestrictWrites(t *testing.T) {
+	db := openDB(t)
+	ctxA, ctxB := seed(t, db)
+
+	var b note
+	db.WithContext(ctxB).First(&b)
+
+	res := db.WithContext(ctxA).Model(&note{}).Where("id = ?", b.ID).Update("body", "changed")
+	if res.Error != nil || res.RowsAffected != 0 {
+		t.Fatalf("tenant A updates tenant B's note: %d rows, %v", res.RowsAffected, res.Error)
+	}
+	res = db.WithContext(ctxA).Delete(&note{}, b.ID)
+	if res.Error != nil || res.RowsAffected != 0 {
+		t.Fatalf("tenant A deletes tenant B's note: %d rows, %v", res.RowsAffected, res.Error)
+	}
+	res = db.WithContext(ctxA).Where("1 = 1").Delete(&note{})
+	if res.RowsAffected != 2 {
+		t.Fatalf("tenant A deletes %d of its notes, want 2", res.RowsAffected)
+	}
+
+	var left []note
+	db.Find(&left)
+	if len(left) != 1 || left[0].Body != "b1" {
+		t.Fatalf("notes left: %+v", left)
+	}
+}
+
+func TestUnrestrictedStatements(t *testing.T) {
+	db := openDB(t)
+	ctxA, _ := seed(t, db)
+
+	var count int64
+	db.Model(&note{}).Count(&count)
+	if count != 3 {
+		t.Errorf("without a tenant: %d notes, want 3", count)
+	}
+	Global(db.WithContext(ctxA)).Model(&note{}).Count(&count)
+	if count != 3 {
+		t.Errorf("global statement: %d notes, want 3", count)
+	}
+
+	// Models without a tenant are shared
+	db.Create(&setting{Name: "shared"})
+	db.WithContext(ctxA).Model(&setting{}).Count(&count)
+	if count != 1 {
+		t.Errorf("tenant A sees %d settings, want 1", count)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("background context has a tenant")
+	}
+	if id, ok := FromContext(WithID(context.Background(), tenantA)); !ok || id != tenantA {
+		t.Errorf("WithID: got %q, %v", id, ok)
+	}
+	// gin.Context resolves string keys set by the auth middleware
+	ctx := context.WithValue(context.Background(), ContextKey, tenantB)
+	if id, ok := FromContext(ctx); !ok || id != tenantB {
+		t.Errorf("ContextKey: got %q, %v", id, ok)
+	}
+}
@@ -0,0 +1,60 @@
+// Package testdb opens in-memory SQLite databases for tests, with the tenant
+// callbacks the application registers on PostgreSQL
+package testdb
+
+import (
+	"testing"
+
+	"github.com/halolight/halolight-api-go/internal/tenant"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dialector is SQLite without the PostgreSQL index methods, such as GIN, that
+// some models declare
+type dialector struct {
+	*sqlite.Dialector
+}
+
+func (d dialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return migrator{d.Dialector.Migrator(db).(sqlite.Migrator)}
+}
+
+type migrator struct {
+	sqlite.Migrator
+}
+
+func (m migrator) CreateIndex(value interface{}, name string) error {
+	stmt := &gorm.Statement{DB: m.DB}
+	if err := stmt.Parse(value); err != nil {
+		return err
+	}
+	if idx := stmt.Schema.LookIndex(name); idx != nil && idx.Type != "" {
+		return nil
+	}
+	return m.Migrator.CreateIndex(value, name)
+}
+
+// Open returns a database with the tenant callbacks and the tables of models
+func Open(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(dialector{sqlite.Open("file::memory:").(*sqlite.Dialector)}, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: opens its own database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := tenant.Register(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
@@ -11,12 +11,53 @@ type Config struct {
 	JWTSecret       string
 	JWTExpireMinute int
 
+	// How long a user's account status is cached by the auth middleware
+	AccountStatusCacheSeconds int
+
 	DBHost     string
 	DBPort     string
 	DBUser     string
 	DBPassword string
 	DBName     string
 	DBSSLMode  string
+
+	// AppURL is the public address of the web client, used for links in emails
+	AppURL string
+
+	// Outgoing mail; messages are only logged while SMTPHost is empty
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	InvitationExpireHours int
+
+	// Soft-deleted users are purged after UserRetentionDays (0 disables the purge)
+	UserRetentionDays    int
+	UserPurgeTeams       string
+	UserPurgeContent     string
+	UserPurgeSuccessorID string
+
+	// Document history: at most DocumentRevisionKeep revisions per document (0
+	// keeps all); revisions older than DocumentRevisionThinDays are thinned to
+	// one per day (0 disables thinning)
+	DocumentRevisionKeep     int
+	DocumentRevisionThinDays int
+
+	// PostgreSQL text search configuration documents are indexed with, such
+	// as simple or english
+	DocumentSearchLanguage string
+
+	// Document export: templates are <name>.json files in
+	// DocumentExportTemplateDir; DocumentExportFont is a TrueType font for PDF
+	// text the built-in fonts cannot show, such as Chinese
+	DocumentExportTemplateDir string
+	DocumentExportFont        string
+
+	// Uploaded files; StorageDir/public is served at StoragePublicURL
+	StorageDir       string
+	StoragePublicURL string
 }
 
 func getEnv(key, def string) string {
@@ -28,16 +69,50 @@ func getEnv(key, def string) string {
 
 func Load() Config {
 	expire, _ := strconv.Atoi(getEnv("JWT_EXPIRE_MINUTES", "60"))
+	inviteExpire, _ := strconv.Atoi(getEnv("INVITATION_EXPIRE_HOURS", "72"))
+	retention, _ := strconv.Atoi(getEnv("USER_RETENTION_DAYS", "30"))
+	statusCache, _ := strconv.Atoi(getEnv("ACCOUNT_STATUS_CACHE_SECONDS", "30"))
+	revisionKeep, _ := strconv.Atoi(getEnv("DOCUMENT_REVISION_KEEP", "100"))
+	revisionThin, _ := strconv.Atoi(getEnv("DOCUMENT_REVISION_THIN_AFTER_DAYS", "30"))
 	return Config{
 		AppEnv:          getEnv("APP_ENV", "development"),
 		AppPort:         getEnv("APP_PORT", "8000"),
 		JWTSecret:       getEnv("JWT_SECRET", "change-me-in-production"),
 		JWTExpireMinute: expire,
-		DBHost:          getEnv("DB_HOST", "localhost"),
-		DBPort:          getEnv("DB_PORT", "5432"),
-		DBUser:          getEnv("DB_USER", "postgres"),
-		DBPassword:      getEnv("DB_PASSWORD", "postgres"),
-		DBName:          getEnv("DB_NAME", "halolight"),
-		DBSSLMode:       getEnv("DB_SSLMODE", "disable"),
+
+		AccountStatusCacheSeconds: statusCache,
+
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     getEnv("DB_PORT", "5432"),
+		DBUser:     getEnv("DB_USER", "postgres"),
+		DBPassword: getEnv("DB_PASSWORD", "postgres"),
+		DBName:     getEnv("DB_NAME", "halolight"),
+		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+		AppURL: getEnv("APP_URL", "http://localhost:3000"),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnv("SMTP_PORT", "587"),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "HaloLight <no-reply@halolight.local>"),
+
+		InvitationExpireHours: inviteExpire,
+
+		UserRetentionDays:    retention,
+		UserPurgeTeams:       getEnv("USER_PURGE_TEAMS", "transfer"),
+		UserPurgeContent:     getEnv("USER_PURGE_CONTENT", "delete"),
+		UserPurgeSuccessorID: getEnv("USER_PURGE_SUCCESSOR_ID", ""),
+
+		DocumentRevisionKeep:     revisionKeep,
+		DocumentRevisionThinDays: revisionThin,
+
+		DocumentSearchLanguage: getEnv("DOCUMENT_SEARCH_LANGUAGE", "simple"),
+
+		DocumentExportTemplateDir: getEnv("DOCUMENT_EXPORT_TEMPLATE_DIR", "./templates/export"),
+		DocumentExportFont:        getEnv("DOCUMENT_EXPORT_FONT", ""),
+
+		StorageDir:       getEnv("STORAGE_DIR", "./storage"),
+		StoragePublicURL: getEnv("STORAGE_PUBLIC_URL", "/uploads"),
 	}
 }
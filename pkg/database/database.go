@@ -3,8 +3,10 @@ package database
 import (
 	"fmt"
 	"log"
+	"regexp"
 
 	"github.com/halolight/halolight-api-go/internal/models"
+	"github.com/halolight/halolight-api-go/internal/tenant"
 	"github.com/halolight/halolight-api-go/pkg/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -31,12 +33,148 @@ func Init(cfg config.Config) (*gorm.DB, error) {
 
 	log.Println("📦 Database connected successfully")
 
+	if err := tenant.Register(db); err != nil {
+		return nil, fmt.Errorf("failed to register tenant callbacks: %w", err)
+	}
+
 	// Auto migrate schema
-	if err := db.AutoMigrate(&models.User{}); err != nil {
+	if err := db.AutoMigrate(
+		&models.Organization{},
+		&models.User{},
+		&models.Department{},
+		&models.Team{},
+		&models.Role{},
+		&models.Tag{},
+		&models.Folder{},
+		&models.Document{},
+		&models.File{},
+		&models.CalendarEvent{},
+		&models.Conversation{},
+		&models.ActivityLog{},
+		&models.UserPreference{},
+		&models.DataExport{},
+		&models.Invitation{},
+		&models.LoginEvent{},
+		&models.UserAttribute{},
+		&models.TeamInvitation{},
+		&models.TeamJoinLink{},
+		&models.DocumentRevision{},
+		&models.DocumentComment{},
+		&models.ShareLink{},
+		&models.ShareLinkAccess{},
+		&models.DocumentTemplate{},
+	); err != nil {
 		return nil, fmt.Errorf("failed to migrate schema: %w", err)
 	}
 
+	if err := migrateTenants(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate organizations: %w", err)
+	}
+
+	if err := migrateDocumentSearch(db, cfg.DocumentSearchLanguage); err != nil {
+		return nil, fmt.Errorf("failed to migrate document search: %w", err)
+	}
+
 	log.Println("✅ Database schema migrated successfully")
 
 	return db, nil
 }
+
+// legacyUniqueIndexes were global before names became unique per
+// organization, or, for users, before deleted users released their sign-in
+// identifiers. Each is replaced by an index that leads with tenant_id or that
+// skips soft-deleted rows.
+var legacyUniqueIndexes = []struct {
+	model       interface{}
+	name        string
+	replacement string
+}{
+	{&models.Role{}, "idx_roles_name", "idx_roles_tenant_name"},
+	{&models.Tag{}, "idx_tags_name", "idx_tags_tenant_name"},
+	{&models.Folder{}, "idx_folders_path", "idx_folders_tenant_path"},
+	{&models.UserAttribute{}, "idx_user_attributes_key", "idx_user_attributes_tenant_key"},
+	{&models.User{}, "idx_users_email", "idx_users_active_email"},
+	{&models.User{}, "idx_users_phone", "idx_users_active_phone"},
+	{&models.User{}, "idx_users_username", "idx_users_active_username"},
+}
+
+// migrateTenants creates the default organization, which owns the rows that
+// existed before multi-tenancy, and drops the legacy unique indexes. Existing
+// rows get the default organization from the tenant_id column default that
+// AutoMigrate adds. A legacy index is only dropped once its replacement
+// exists, so the table never loses its uniqueness.
+func migrateTenants(db *gorm.DB) error {
+	org := models.Organization{ID: tenant.DefaultID, Name: "Default", Slug: "default"}
+	if err := db.Where("id = ?", org.ID).FirstOrCreate(&org).Error; err != nil {
+		return err
+	}
+	for _, idx := range legacyUniqueIndexes {
+		if !db.Migrator().HasIndex(idx.model, idx.replacement) {
+			return fmt.Errorf("index %s is missing", idx.replacement)
+		}
+		if db.Migrator().HasIndex(idx.model, idx.name) {
+			if err := db.Migrator().DropIndex(idx.model, idx.name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// searchLanguagePattern matches the names of text search configurations,
+// which migrateDocumentSearch writes into SQL
+var searchLanguagePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// migrateDocumentSearch maintains documents.search_vector with a trigger and
+// indexes it. The configuration in use is kept as the trigger function's
+// comment so that switching languages re-indexes every document.
+func migrateDocumentSearch(db *gorm.DB, language string) error {
+	var known bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = ?)", language).Scan(&known).Error; err != nil {
+		return err
+	}
+	if !known || !searchLanguagePattern.MatchString(language) {
+		return fmt.Errorf("unknown text search configuration %q", language)
+	}
+
+	var current *string
+	if err := db.Raw("SELECT obj_description(oid, 'pg_proc') FROM pg_proc WHERE proname = 'documents_search_vector_update'").
+		Scan(&current).Error; err != nil {
+		return err
+	}
+
+	statements := []string{
+		`ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_vector tsvector`,
+		`CREATE INDEX IF NOT EXISTS idx_documents_search_vector ON documents USING GIN (search_vector)`,
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION documents_search_vector_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search_vector := %s;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql`, searchVector(language, "NEW.")),
+		fmt.Sprintf(`COMMENT ON FUNCTION documents_search_vector_update() IS '%s'`, language),
+		`DROP TRIGGER IF EXISTS documents_search_vector ON documents`,
+		`CREATE TRIGGER documents_search_vector BEFORE INSERT OR UPDATE OF title, content ON documents
+		FOR EACH ROW EXECUTE FUNCTION documents_search_vector_update()`,
+	}
+	backfill := "UPDATE documents SET search_vector = " + searchVector(language, "")
+	if current != nil && *current == language {
+		backfill += " WHERE search_vector IS NULL"
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range append(statements, backfill) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// searchVector is the SQL computing a document's search vector, weighting the
+// title above the content; row qualifies the columns
+func searchVector(language, row string) string {
+	return fmt.Sprintf("setweight(to_tsvector('%[1]s', COALESCE(%[2]stitle, '')), 'A') || "+
+		"setweight(to_tsvector('%[1]s', COALESCE(%[2]scontent, '')), 'B')", language, row)
+}
@@ -0,0 +1,359 @@
+// Package docx converts between documents parsed by package markup and Office
+// Open XML word processing files, which Word, LibreOffice and Google Docs open.
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/pkg/markup"
+)
+
+// Options control a document's metadata and page furniture
+type Options struct {
+	Title  string
+	Author string
+	// Header and Footer are printed on every page; they may contain
+	// markup.PageNumber and markup.PageCount
+	Header string
+	Footer string
+	// BrandName and Logo lead the header; BrandColor tints headings and the
+	// brand name, and is a dark gray when nil
+	BrandName  string
+	BrandColor color.Color
+	Logo       image.Image
+}
+
+// writer builds the main document part and the relationships it needs
+type writer struct {
+	body  strings.Builder
+	rels  []string // hyperlink relationships of the main part
+	lists []listNum
+}
+
+// listNum is a numbering instance; every ordered list gets its own so its
+// numbers start over
+type listNum struct {
+	level int
+	start int
+}
+
+// para is how the paragraphs of a block are set out
+type para struct {
+	style  string // paragraph style, empty for Normal
+	indent int    // left indent in twentieths of a point, unless numbered
+	numID  int    // numbering of the first paragraph, 0 for none
+	level  int    // list nesting depth
+}
+
+// Write renders blocks as a DOCX file
+func Write(w io.Writer, blocks []markup.Block, opts Options) error {
+	dw := &writer{}
+	dw.blocks(blocks, para{}, 0)
+
+	brand := "1F2937"
+	if opts.BrandColor != nil {
+		r, g, b, _ := opts.BrandColor.RGBA()
+		brand = fmt.Sprintf("%02X%02X%02X", r>>8, g>>8, b>>8)
+	}
+
+	var logo []byte
+	if opts.Logo != nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, opts.Logo); err != nil {
+			return err
+		}
+		logo = buf.Bytes()
+	}
+
+	zw := zip.NewWriter(w)
+	parts := []struct {
+		name string
+		data string
+	}{
+		{"[Content_Types].xml", contentTypes(logo != nil)},
+		{"_rels/.rels", packageRels},
+		{"docProps/core.xml", coreProps(opts.Title, opts.Author, time.Now().UTC())},
+		{"word/document.xml", dw.document()},
+		{"word/_rels/document.xml.rels", dw.documentRels()},
+		{"word/styles.xml", styles(brand)},
+		{"word/numbering.xml", dw.numbering()},
+		{"word/header1.xml", header(opts, brand, logo != nil)},
+		{"word/footer1.xml", footer(opts.Footer)},
+	}
+	if logo != nil {
+		parts = append(parts, struct {
+			name string
+			data string
+		}{"word/_rels/header1.xml.rels", headerRels})
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.data); err != nil {
+			return err
+		}
+	}
+	if logo != nil {
+		f, err := zw.Create("word/media/logo.png")
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(logo); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// escape makes s safe as XML character data or an attribute value
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func (dw *writer) blocks(blocks []markup.Block, p para, depth int) {
+	for _, block := range blocks {
+		dw.block(block, p, depth)
+		// Only the first paragraph of a list item carries its number
+		p.numID = 0
+	}
+}
+
+func (dw *writer) block(block markup.Block, p para, depth int) {
+	switch block.Kind {
+	case markup.Paragraph:
+		dw.paragraph(p, "", block.Inlines)
+
+	case markup.Heading:
+		p.style = fmt.Sprintf("Heading%d", min(max(block.Level, 1), 6))
+		dw.paragraph(p, "", block.Inlines)
+
+	case markup.List:
+		numID := 1
+		if block.Ordered {
+			dw.lists = append(dw.lists, listNum{level: depth, start: max(block.Start, 1)})
+			// 1 is the shared bullet numbering
+			numID = len(dw.lists) + 1
+		}
+		for _, item := range block.Items {
+			ip := para{style: p.style, indent: 720 + 360*depth, numID: numID, level: depth}
+			if len(item) == 0 {
+				dw.paragraph(ip, "", nil)
+				continue
+			}
+			dw.blocks(item, ip, depth+1)
+		}
+
+	case markup.CodeBlock:
+		var runs strings.Builder
+		for i, line := range strings.Split(block.Text, "\n") {
+			if i > 0 {
+				runs.WriteString("<w:r><w:br/></w:r>")
+			}
+			fmt.Fprintf(&runs, `<w:r><w:t xml:space="preserve">%s</w:t></w:r>`, escape(strings.ReplaceAll(line, "\t", "    ")))
+		}
+		p.style = "Code"
+		dw.paragraphXML(p, "", runs.String())
+
+	case markup.Quote:
+		p.style = "Quote"
+		p.indent += 360
+		dw.blocks(block.Children, p, depth)
+
+	case markup.Rule:
+		dw.paragraph(p, `<w:pBdr><w:bottom w:val="single" w:sz="6" w:space="1" w:color="D6D9DE"/></w:pBdr>`, nil)
+
+	case markup.Table:
+		dw.table(block.Rows)
+	}
+}
+
+// paragraph writes one paragraph; extra holds more paragraph properties
+func (dw *writer) paragraph(p para, extra string, inlines []markup.Inline) {
+	dw.paragraphXML(p, extra, dw.runs(inlines))
+}
+
+func (dw *writer) paragraphXML(p para, extra, runs string) {
+	dw.body.WriteString("<w:p><w:pPr>")
+	if p.style != "" {
+		fmt.Fprintf(&dw.body, `<w:pStyle w:val="%s"/>`, p.style)
+	}
+	if p.numID != 0 {
+		fmt.Fprintf(&dw.body, `<w:numPr><w:ilvl w:val="%d"/><w:numId w:val="%d"/></w:numPr>`, min(p.level, 8), p.numID)
+	}
+	dw.body.WriteString(extra)
+	// Numbered paragraphs take their indent from the numbering
+	if p.indent > 0 && p.numID == 0 {
+		fmt.Fprintf(&dw.body, `<w:ind w:left="%d"/>`, p.indent)
+	}
+	dw.body.WriteString("</w:pPr>")
+	dw.body.WriteString(runs)
+	dw.body.WriteString("</w:p>")
+}
+
+// runs renders inlines as runs, wrapping external links in hyperlinks
+func (dw *writer) runs(inlines []markup.Inline) string {
+	var b strings.Builder
+	for i := 0; i < len(inlines); i++ {
+		in := inlines[i]
+		if in.Link != "" && !in.Image && external(in.Link) {
+			// Consecutive runs with the same target share one hyperlink
+			dw.rels = append(dw.rels, in.Link)
+			fmt.Fprintf(&b, `<w:hyperlink r:id="rIdLink%d">`, len(dw.rels))
+			for ; i < len(inlines) && inlines[i].Link == in.Link && !inlines[i].Image; i++ {
+				b.WriteString(run(inlines[i], "Hyperlink"))
+			}
+			i--
+			b.WriteString("</w:hyperlink>")
+			continue
+		}
+		b.WriteString(run(in, ""))
+	}
+	return b.String()
+}
+
+func run(in markup.Inline, rStyle string) string {
+	if in.Break {
+		return "<w:r><w:br/></w:r>"
+	}
+	text := in.Text
+	var props strings.Builder
+	switch {
+	case in.Code:
+		rStyle = "CodeChar"
+	case in.Link != "" && !in.Image && rStyle == "":
+		// Links a reader cannot follow, such as relative ones, still look like links
+		rStyle = "Hyperlink"
+	}
+	if rStyle != "" {
+		fmt.Fprintf(&props, `<w:rStyle w:val="%s"/>`, rStyle)
+	}
+	if in.Bold {
+		props.WriteString("<w:b/>")
+	}
+	if in.Italic || in.Image {
+		props.WriteString("<w:i/>")
+	}
+	if in.Strike {
+		props.WriteString("<w:strike/>")
+	}
+	if in.Image {
+		if text == "" {
+			text = "image"
+		}
+		text = "[" + text + "]"
+	}
+	return fmt.Sprintf(`<w:r><w:rPr>%s</w:rPr><w:t xml:space="preserve">%s</w:t></w:r>`, props.String(), escape(text))
+}
+
+// external reports whether target is an absolute link a reader can follow
+func external(target string) bool {
+	lower := strings.ToLower(strings.TrimSpace(target))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
+
+// table writes rows as a full-width grid; the header row repeats on new pages
+func (dw *writer) table(rows [][]markup.Cell) {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return
+	}
+	cols := len(rows[0])
+	// 9026 twips is the text width of an A4 page with 1 inch margins
+	colWidth := 9026 / cols
+
+	dw.body.WriteString(`<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="5000" w:type="pct"/></w:tblPr><w:tblGrid>`)
+	for c := 0; c < cols; c++ {
+		fmt.Fprintf(&dw.body, `<w:gridCol w:w="%d"/>`, colWidth)
+	}
+	dw.body.WriteString("</w:tblGrid>")
+	for r, row := range rows {
+		dw.body.WriteString("<w:tr>")
+		if r == 0 {
+			dw.body.WriteString("<w:trPr><w:tblHeader/></w:trPr>")
+		}
+		for c := 0; c < cols; c++ {
+			fmt.Fprintf(&dw.body, `<w:tc><w:tcPr><w:tcW w:w="%d" w:type="dxa"/>`, colWidth)
+			if r == 0 {
+				dw.body.WriteString(`<w:shd w:val="clear" w:color="auto" w:fill="EDEFF3"/>`)
+			}
+			dw.body.WriteString("</w:tcPr>")
+			var inlines []markup.Inline
+			if c < len(row) {
+				inlines = row[c]
+			}
+			if r == 0 {
+				bold := make([]markup.Inline, len(inlines))
+				for i, in := range inlines {
+					in.Bold = true
+					bold[i] = in
+				}
+				inlines = bold
+			}
+			dw.paragraph(para{style: "TableText"}, "", inlines)
+			dw.body.WriteString("</w:tc>")
+		}
+		dw.body.WriteString("</w:tr>")
+	}
+	dw.body.WriteString("</w:tbl>")
+	// Word needs a paragraph between a table and whatever follows it
+	dw.body.WriteString("<w:p/>")
+}
+
+func (dw *writer) document() string {
+	return xml.Header + `<w:document xmlns:w="` + nsW + `" xmlns:r="` + nsR + `"><w:body>` +
+		dw.body.String() +
+		`<w:sectPr><w:headerReference w:type="default" r:id="rIdHeader"/><w:footerReference w:type="default" r:id="rIdFooter"/>` +
+		`<w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1440" w:right="1440" w:bottom="1440" w:left="1440" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr>` +
+		`</w:body></w:document>`
+}
+
+func (dw *writer) documentRels() string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<Relationships xmlns="` + nsPackageRels + `">`)
+	b.WriteString(`<Relationship Id="rIdStyles" Type="` + nsOfficeRels + `/styles" Target="styles.xml"/>`)
+	b.WriteString(`<Relationship Id="rIdNumbering" Type="` + nsOfficeRels + `/numbering" Target="numbering.xml"/>`)
+	b.WriteString(`<Relationship Id="rIdHeader" Type="` + nsOfficeRels + `/header" Target="header1.xml"/>`)
+	b.WriteString(`<Relationship Id="rIdFooter" Type="` + nsOfficeRels + `/footer" Target="footer1.xml"/>`)
+	for i, target := range dw.rels {
+		fmt.Fprintf(&b, `<Relationship Id="rIdLink%d" Type="%s/hyperlink" Target="%s" TargetMode="External"/>`, i+1, nsOfficeRels, escape(target))
+	}
+	b.WriteString("</Relationships>")
+	return b.String()
+}
+
+func (dw *writer) numbering() string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<w:numbering xmlns:w="` + nsW + `">`)
+	bullets := []string{"•", "◦", "▪"}
+	b.WriteString(`<w:abstractNum w:abstractNumId="0"><w:multiLevelType w:val="hybridMultilevel"/>`)
+	for lvl := 0; lvl < 9; lvl++ {
+		fmt.Fprintf(&b, `<w:lvl w:ilvl="%d"><w:start w:val="1"/><w:numFmt w:val="bullet"/><w:lvlText w:val="%s"/><w:lvlJc w:val="left"/><w:pPr><w:ind w:left="%d" w:hanging="360"/></w:pPr></w:lvl>`,
+			lvl, bullets[lvl%len(bullets)], 720+360*lvl)
+	}
+	b.WriteString(`</w:abstractNum>`)
+	b.WriteString(`<w:abstractNum w:abstractNumId="1"><w:multiLevelType w:val="hybridMultilevel"/>`)
+	for lvl := 0; lvl < 9; lvl++ {
+		fmt.Fprintf(&b, `<w:lvl w:ilvl="%d"><w:start w:val="1"/><w:numFmt w:val="decimal"/><w:lvlText w:val="%%%d."/><w:lvlJc w:val="left"/><w:pPr><w:ind w:left="%d" w:hanging="360"/></w:pPr></w:lvl>`,
+			lvl, lvl+1, 720+360*lvl)
+	}
+	b.WriteString(`</w:abstractNum>`)
+	b.WriteString(`<w:num w:numId="1"><w:abstractNumId w:val="0"/></w:num>`)
+	for i, l := range dw.lists {
+		fmt.Fprintf(&b, `<w:num w:numId="%d"><w:abstractNumId w:val="1"/><w:lvlOverride w:ilvl="%d"><w:startOverride w:val="%d"/></w:lvlOverride></w:num>`,
+			i+2, min(l.level, 8), l.start)
+	}
+	b.WriteString("</w:numbering>")
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package docx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/halolight/halolight-api-go/pkg/markup"
+)
+
+const (
+	nsW           = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	nsR           = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsOfficeRels  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsPackageRels = "http://schemas.openxmlformats.org/package/2006/relationships"
+	nsWP          = "http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"
+	nsA           = "http://schemas.openxmlformats.org/drawingml/2006/main"
+	nsPic         = "http://schemas.openxmlformats.org/drawingml/2006/picture"
+)
+
+func contentTypes(logo bool) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	if logo {
+		b.WriteString(`<Default Extension="png" ContentType="image/png"/>`)
+	}
+	for _, part := range [][2]string{
+		{"document", "document.main"},
+		{"styles", "styles"},
+		{"numbering", "numbering"},
+		{"header1", "header"},
+		{"footer1", "footer"},
+	} {
+		fmt.Fprintf(&b, `<Override PartName="/word/%s.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.%s+xml"/>`, part[0], part[1])
+	}
+	b.WriteString(`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>`)
+	b.WriteString("</Types>")
+	return b.String()
+}
+
+var packageRels = xml.Header + `<Relationships xmlns="` + nsPackageRels + `">` +
+	`<Relationship Id="rId1" Type="` + nsOfficeRels + `/officeDocument" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>` +
+	`</Relationships>`
+
+var headerRels = xml.Header + `<Relationships xmlns="` + nsPackageRels + `">` +
+	`<Relationship Id="rIdLogo" Type="` + nsOfficeRels + `/image" Target="media/logo.png"/>` +
+	`</Relationships>`
+
+func coreProps(title, author string, created time.Time) string {
+	return xml.Header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+		`<dc:title>` + escape(title) + `</dc:title><dc:creator>` + escape(author) + `</dc:creator>` +
+		`<dcterms:created xsi:type="dcterms:W3CDTF">` + created.Format(time.RFC3339) + `</dcterms:created>` +
+		`</cp:coreProperties>`
+}
+
+// styles defines the paragraph, character and table styles the body uses;
+// sizes are in half points
+func styles(brand string) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<w:styles xmlns:w="` + nsW + `">`)
+	b.WriteString(`<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:cs="Calibri"/><w:color w:val="212630"/><w:sz w:val="21"/><w:szCs w:val="21"/></w:rPr></w:rPrDefault>` +
+		`<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="300" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>`)
+	b.WriteString(`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>`)
+	for level, size := range []int{40, 32, 27, 24, 22, 21} {
+		var border string
+		if level < 2 {
+			border = `<w:pBdr><w:bottom w:val="single" w:sz="4" w:space="1" w:color="D6D9DE"/></w:pBdr>`
+		}
+		fmt.Fprintf(&b, `<w:style w:type="paragraph" w:styleId="Heading%d"><w:name w:val="heading %d"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>`+
+			`<w:pPr><w:keepNext/><w:keepLines/>%s<w:spacing w:before="240" w:after="120"/><w:outlineLvl w:val="%d"/></w:pPr>`+
+			`<w:rPr><w:b/><w:bCs/><w:color w:val="%s"/><w:sz w:val="%d"/><w:szCs w:val="%d"/></w:rPr></w:style>`,
+			level+1, level+1, border, level, brand, size, size)
+	}
+	b.WriteString(`<w:style w:type="paragraph" w:styleId="Quote"><w:name w:val="Quote"/><w:basedOn w:val="Normal"/><w:qFormat/>` +
+		`<w:pPr><w:pBdr><w:left w:val="single" w:sz="18" w:space="8" w:color="D6D9DE"/></w:pBdr><w:ind w:left="360"/></w:pPr>` +
+		`<w:rPr><w:color w:val="6B7280"/></w:rPr></w:style>`)
+	b.WriteString(`<w:style w:type="paragraph" w:styleId="Code"><w:name w:val="Code"/><w:basedOn w:val="Normal"/>` +
+		`<w:pPr><w:shd w:val="clear" w:color="auto" w:fill="F3F4F6"/><w:spacing w:after="120" w:line="240" w:lineRule="auto"/></w:pPr>` +
+		`<w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:sz w:val="18"/><w:szCs w:val="18"/></w:rPr></w:style>`)
+	b.WriteString(`<w:style w:type="paragraph" w:styleId="TableText"><w:name w:val="Table Text"/><w:basedOn w:val="Normal"/>` +
+		`<w:pPr><w:spacing w:after="0"/></w:pPr><w:rPr><w:sz w:val="19"/><w:szCs w:val="19"/></w:rPr></w:style>`)
+	b.WriteString(`<w:style w:type="character" w:styleId="CodeChar"><w:name w:val="Code Char"/>` +
+		`<w:rPr><w:rFonts w:ascii="Consolas" w:hAnsi="Consolas" w:cs="Consolas"/><w:sz w:val="19"/><w:shd w:val="clear" w:color="auto" w:fill="F3F4F6"/></w:rPr></w:style>`)
+	b.WriteString(`<w:style w:type="character" w:styleId="Hyperlink"><w:name w:val="Hyperlink"/>` +
+		`<w:rPr><w:color w:val="2563EB"/><w:u w:val="single"/></w:rPr></w:style>`)
+	b.WriteString(`<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:tblPr>` +
+		`<w:tblBorders><w:top w:val="single" w:sz="4" w:color="D6D9DE"/><w:left w:val="single" w:sz="4" w:color="D6D9DE"/>` +
+		`<w:bottom w:val="single" w:sz="4" w:color="D6D9DE"/><w:right w:val="single" w:sz="4" w:color="D6D9DE"/>` +
+		`<w:insideH w:val="single" w:sz="4" w:color="D6D9DE"/><w:insideV w:val="single" w:sz="4" w:color="D6D9DE"/></w:tblBorders>` +
+		`<w:tblCellMar><w:top w:w="60" w:type="dxa"/><w:left w:w="80" w:type="dxa"/><w:bottom w:w="60" w:type="dxa"/><w:right w:w="80" w:type="dxa"/></w:tblCellMar>` +
+		`</w:tblPr></w:style>`)
+	b.WriteString("</w:styles>")
+	return b.String()
+}
+
+// fieldRuns writes text as runs, turning the page placeholders into fields
+// that Word fills in
+func fieldRuns(text, rPr string) string {
+	var b strings.Builder
+	for text != "" {
+		page := strings.Index(text, markup.PageNumber)
+		pages := strings.Index(text, markup.PageCount)
+		next, placeholder, field := -1, "", ""
+		if page >= 0 && (pages < 0 || page < pages) {
+			next, placeholder, field = page, markup.PageNumber, "PAGE"
+		} else if pages >= 0 {
+			next, placeholder, field = pages, markup.PageCount, "NUMPAGES"
+		}
+		if next < 0 {
+			next = len(text)
+		}
+		if next > 0 {
+			fmt.Fprintf(&b, `<w:r><w:rPr>%s</w:rPr><w:t xml:space="preserve">%s</w:t></w:r>`, rPr, escape(text[:next]))
+		}
+		if field == "" {
+			break
+		}
+		fmt.Fprintf(&b, `<w:fldSimple w:instr=" %s "><w:r><w:rPr>%s</w:rPr><w:t>1</w:t></w:r></w:fldSimple>`, field, rPr)
+		text = text[next+len(placeholder):]
+	}
+	return b.String()
+}
+
+const mutedRun = `<w:color w:val="6B7280"/><w:sz w:val="17"/><w:szCs w:val="17"/>`
+
+// header leads with the logo and brand name and sets the header text flush
+// right, above a rule
+func header(opts Options, brand string, logo bool) string {
+	var b strings.Builder
+	b.WriteString(xml.Header + `<w:hdr xmlns:w="` + nsW + `" xmlns:r="` + nsR + `" xmlns:wp="` + nsWP + `" xmlns:a="` + nsA + `" xmlns:pic="` + nsPic + `">`)
+	if !logo && opts.BrandName == "" && opts.Header == "" {
+		b.WriteString("<w:p/></w:hdr>")
+		return b.String()
+	}
+	b.WriteString(`<w:p><w:pPr><w:pBdr><w:bottom w:val="single" w:sz="4" w:space="4" w:color="D6D9DE"/></w:pBdr>` +
+		`<w:tabs><w:tab w:val="right" w:pos="9026"/></w:tabs><w:spacing w:after="0"/></w:pPr>`)
+	if logo {
+		// 18pt high, in English Metric Units
+		bounds := opts.Logo.Bounds()
+		h := 18 * 12700
+		w := h * bounds.Dx() / max(bounds.Dy(), 1)
+		fmt.Fprintf(&b, `<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0"><wp:extent cx="%d" cy="%d"/><wp:docPr id="1" name="Logo"/>`+
+			`<a:graphic><a:graphicData uri="%s"><pic:pic><pic:nvPicPr><pic:cNvPr id="0" name="logo.png"/><pic:cNvPicPr/></pic:nvPicPr>`+
+			`<pic:blipFill><a:blip r:embed="rIdLogo"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+			`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+			`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`, w, h, nsPic, w, h)
+		if opts.BrandName != "" {
+			b.WriteString(`<w:r><w:t xml:space="preserve"> </w:t></w:r>`)
+		}
+	}
+	if opts.BrandName != "" {
+		fmt.Fprintf(&b, `<w:r><w:rPr><w:b/><w:color w:val="%s"/><w:sz w:val="20"/></w:rPr><w:t xml:space="preserve">%s</w:t></w:r>`, brand, escape(opts.BrandName))
+	}
+	if opts.Header != "" {
+		b.WriteString("<w:r><w:tab/></w:r>")
+		b.WriteString(fieldRuns(opts.Header, mutedRun))
+	}
+	b.WriteString("</w:p></w:hdr>")
+	return b.String()
+}
+
+// footer centers text at the foot of every page
+func footer(text string) string {
+	return xml.Header + `<w:ftr xmlns:w="` + nsW + `" xmlns:r="` + nsR + `">` +
+		`<w:p><w:pPr><w:jc w:val="center"/><w:spacing w:after="0"/></w:pPr>` + fieldRuns(text, mutedRun) + `</w:p></w:ftr>`
+}
@@ -0,0 +1,614 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/halolight/halolight-api-go/pkg/markup"
+)
+
+// ErrInvalid is returned for files that are not word processing documents
+var ErrInvalid = errors.New("not a valid DOCX file")
+
+// maxPartSize bounds each XML part read, against decompression bombs
+const maxPartSize = 32 << 20
+
+// Properties are the document properties Read extracts
+type Properties struct {
+	Title    string
+	Keywords []string
+}
+
+// Read converts a DOCX file into blocks. Headings, lists, quotes and code are
+// recognized by their paragraph styles; images, comments and tracked
+// deletions are left out.
+func Read(data []byte) ([]markup.Block, Properties, error) {
+	var props Properties
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, props, ErrInvalid
+	}
+	parts := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		parts[f.Name] = f
+	}
+
+	body, err := readPart(parts, "word/document.xml")
+	if err != nil || body == nil {
+		return nil, props, ErrInvalid
+	}
+	r := &reader{links: make(map[string]string), styles: make(map[string]paraStyle), numbering: make(map[string]map[int]numLevel)}
+	if rels, err := readPart(parts, "word/_rels/document.xml.rels"); err == nil && rels != nil {
+		for _, rel := range rels.children {
+			if rel.name == "Relationship" && strings.HasSuffix(rel.attr("Type"), "/hyperlink") {
+				r.links[rel.attr("Id")] = rel.attr("Target")
+			}
+		}
+	}
+	if styles, err := readPart(parts, "word/styles.xml"); err == nil && styles != nil {
+		r.readStyles(styles)
+	}
+	if numbering, err := readPart(parts, "word/numbering.xml"); err == nil && numbering != nil {
+		r.readNumbering(numbering)
+	}
+	if core, err := readPart(parts, "docProps/core.xml"); err == nil && core != nil {
+		for _, n := range core.children {
+			switch n.name {
+			case "title":
+				props.Title = strings.TrimSpace(n.textContent())
+			case "keywords":
+				for _, k := range strings.FieldsFunc(n.textContent(), func(r rune) bool { return r == ',' || r == ';' }) {
+					if k = strings.TrimSpace(k); k != "" {
+						props.Keywords = append(props.Keywords, k)
+					}
+				}
+			}
+		}
+	}
+
+	bodyNode := body.find("body")
+	if bodyNode == nil {
+		return nil, props, ErrInvalid
+	}
+	return r.blocks(bodyNode), props, nil
+}
+
+// node is an XML element, with names stripped of their namespaces
+type node struct {
+	name     string
+	attrs    map[string]string
+	children []*node
+	text     string // character data directly inside the element
+}
+
+func (n *node) attr(name string) string {
+	return n.attrs[name]
+}
+
+// find returns the first descendant named name
+func (n *node) find(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+		if found := c.find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// child returns the first child named name
+func (n *node) child(name string) *node {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// val returns the w:val attribute of the child named name
+func (n *node) val(name string) (string, bool) {
+	c := n.child(name)
+	if c == nil {
+		return "", false
+	}
+	return c.attr("val"), true
+}
+
+func (n *node) textContent() string {
+	var b strings.Builder
+	b.WriteString(n.text)
+	for _, c := range n.children {
+		b.WriteString(c.textContent())
+	}
+	return b.String()
+}
+
+func readPart(parts map[string]*zip.File, name string) (*node, error) {
+	f, ok := parts[name]
+	if !ok {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return parseXML(io.LimitReader(rc, maxPartSize))
+}
+
+func parseXML(r io.Reader) (*node, error) {
+	dec := xml.NewDecoder(r)
+	root := &node{}
+	stack := []*node{root}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				// Relationship IDs are r:id, which would clash with w:id
+				key := a.Name.Local
+				if a.Name.Space == nsR {
+					key = "r:" + key
+				}
+				n.attrs[key] = a.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			stack[len(stack)-1].text += string(t)
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, ErrInvalid
+	}
+	return root.children[0], nil
+}
+
+// paraStyle is what a paragraph style means for conversion
+type paraStyle struct {
+	heading int // 1 to 6, or 0
+	quote   bool
+	code    bool
+	numID   string // numbering of list styles such as List Bullet
+}
+
+type reader struct {
+	links  map[string]string // hyperlink relationship ID to target
+	styles map[string]paraStyle
+	// numbering describes list levels by numbering ID and level
+	numbering map[string]map[int]numLevel
+}
+
+type numLevel struct {
+	ordered bool
+	start   int
+}
+
+func (r *reader) readStyles(styles *node) {
+	for _, s := range styles.children {
+		if s.name != "style" || s.attr("type") != "paragraph" {
+			continue
+		}
+		name, _ := s.val("name")
+		name = strings.ToLower(name)
+		var ps paraStyle
+		switch {
+		case name == "title":
+			ps.heading = 1
+		case strings.HasPrefix(name, "heading "):
+			if level, err := strconv.Atoi(strings.TrimPrefix(name, "heading ")); err == nil {
+				ps.heading = min(max(level, 1), 6)
+			}
+		case strings.Contains(name, "quote"):
+			ps.quote = true
+		case strings.Contains(name, "code"), name == "html preformatted", name == "plain text":
+			ps.code = true
+		}
+		ps.numID, _ = s.child("pPr").child("numPr").val("numId")
+		// Localized heading styles still carry an outline level
+		if lvl, ok := s.child("pPr").val("outlineLvl"); ok && ps.heading == 0 {
+			if level, err := strconv.Atoi(lvl); err == nil && level < 6 {
+				ps.heading = level + 1
+			}
+		}
+		r.styles[s.attr("styleId")] = ps
+	}
+}
+
+func (r *reader) readNumbering(numbering *node) {
+	abstract := make(map[string]map[int]numLevel)
+	for _, a := range numbering.children {
+		if a.name != "abstractNum" {
+			continue
+		}
+		levels := make(map[int]numLevel)
+		for _, lvl := range a.children {
+			if lvl.name != "lvl" {
+				continue
+			}
+			ilvl, _ := strconv.Atoi(lvl.attr("ilvl"))
+			format, _ := lvl.val("numFmt")
+			start, _ := lvl.val("start")
+			levels[ilvl] = numLevel{
+				ordered: format != "" && format != "bullet" && format != "none",
+				start:   atoiDefault(start, 1),
+			}
+		}
+		abstract[a.attr("abstractNumId")] = levels
+	}
+	for _, num := range numbering.children {
+		if num.name != "num" {
+			continue
+		}
+		id, _ := num.val("abstractNumId")
+		levels := make(map[int]numLevel, len(abstract[id]))
+		for ilvl, lvl := range abstract[id] {
+			levels[ilvl] = lvl
+		}
+		for _, override := range num.children {
+			if override.name != "lvlOverride" {
+				continue
+			}
+			if start, ok := override.val("startOverride"); ok {
+				ilvl, _ := strconv.Atoi(override.attr("ilvl"))
+				lvl := levels[ilvl]
+				lvl.start = atoiDefault(start, 1)
+				levels[ilvl] = lvl
+			}
+		}
+		r.numbering[num.attr("numId")] = levels
+	}
+}
+
+func atoiDefault(s string, def int) int {
+	if v, err := strconv.Atoi(s); err == nil {
+		return v
+	}
+	return def
+}
+
+// paragraph is a converted paragraph before paragraphs are grouped into blocks
+type paragraph struct {
+	style   paraStyle
+	list    bool
+	numID   string
+	level   int
+	ordered bool
+	start   int
+	inlines []markup.Inline
+	text    string // for code
+}
+
+// blocks converts the children of the body or of a content control
+func (r *reader) blocks(container *node) []markup.Block {
+	var paras []paragraph
+	var out []markup.Block
+	flush := func() {
+		out = append(out, group(paras)...)
+		paras = nil
+	}
+	for _, n := range container.children {
+		switch n.name {
+		case "p":
+			paras = append(paras, r.paragraph(n))
+		case "tbl":
+			flush()
+			if table := r.table(n); len(table.Rows) > 0 {
+				out = append(out, table)
+			}
+		case "sdt":
+			// Content controls wrap ordinary paragraphs and tables
+			if content := n.child("sdtContent"); content != nil {
+				flush()
+				out = append(out, r.blocks(content)...)
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+func (r *reader) paragraph(p *node) paragraph {
+	var para paragraph
+	props := p.child("pPr")
+	if styleID, ok := props.val("pStyle"); ok {
+		para.style = r.styles[styleID]
+	}
+	if lvl, ok := props.val("outlineLvl"); ok && para.style.heading == 0 {
+		if level, err := strconv.Atoi(lvl); err == nil && level < 6 {
+			para.style.heading = level + 1
+		}
+	}
+	numID := para.style.numID
+	if numPr := props.child("numPr"); numPr != nil || numID != "" {
+		if id, ok := numPr.val("numId"); ok {
+			numID = id
+		}
+		ilvl, _ := numPr.val("ilvl")
+		para.level, _ = strconv.Atoi(ilvl)
+		// numId 0 removes numbering inherited from the style; numbered
+		// headings stay headings
+		if numID != "" && numID != "0" && para.style.heading == 0 {
+			lvl := r.numbering[numID][para.level]
+			para.list, para.numID, para.ordered, para.start = true, numID, lvl.ordered, lvl.start
+		}
+	}
+
+	if para.style.code {
+		para.text = strings.TrimRight(r.plainText(p), "\n")
+		return para
+	}
+	para.inlines = trimInlines(r.inlines(p, ""))
+	return para
+}
+
+// inlines collects the runs inside n, following hyperlinks and fields
+func (r *reader) inlines(n *node, link string) []markup.Inline {
+	var out []markup.Inline
+	for _, c := range n.children {
+		switch c.name {
+		case "r":
+			out = append(out, r.run(c, link)...)
+		case "hyperlink":
+			target := r.links[c.attr("r:id")]
+			if target == "" && c.attr("anchor") != "" {
+				target = "#" + c.attr("anchor")
+			}
+			out = append(out, r.inlines(c, target)...)
+		case "fldSimple", "smartTag", "ins", "sdt", "sdtContent", "customXml":
+			out = append(out, r.inlines(c, link)...)
+		}
+	}
+	return out
+}
+
+func (r *reader) run(run *node, link string) []markup.Inline {
+	style := markup.Inline{Link: link}
+	if props := run.child("rPr"); props != nil {
+		style.Bold = on(props, "b")
+		style.Italic = on(props, "i")
+		style.Strike = on(props, "strike") || on(props, "dstrike")
+		if font := props.child("rFonts"); font != nil {
+			style.Code = monospace(font.attr("ascii"))
+		}
+		if rStyle, ok := props.val("rStyle"); ok && strings.Contains(strings.ToLower(rStyle), "code") {
+			style.Code = true
+		}
+	}
+
+	var out []markup.Inline
+	for _, c := range run.children {
+		switch c.name {
+		case "t":
+			in := style
+			in.Text = c.text
+			out = append(out, in)
+		case "tab":
+			in := style
+			in.Text = " "
+			out = append(out, in)
+		case "br", "cr":
+			if c.attr("type") == "" || c.attr("type") == "textWrapping" {
+				out = append(out, markup.Inline{Break: true})
+			}
+		}
+	}
+	return out
+}
+
+// on reports whether a toggle property such as w:b is set
+func on(props *node, name string) bool {
+	v, ok := props.val(name)
+	return ok && v != "0" && v != "false" && v != "off"
+}
+
+func monospace(font string) bool {
+	switch strings.ToLower(font) {
+	case "consolas", "courier", "courier new", "menlo", "monaco", "lucida console", "source code pro":
+		return true
+	}
+	return false
+}
+
+// plainText returns the text of a paragraph with breaks as newlines
+func (r *reader) plainText(p *node) string {
+	var b strings.Builder
+	for _, in := range r.inlines(p, "") {
+		if in.Break {
+			b.WriteByte('\n')
+		} else {
+			b.WriteString(in.Text)
+		}
+	}
+	return b.String()
+}
+
+// trimInlines merges neighbouring runs of the same style and trims the
+// whitespace at either end
+func trimInlines(inlines []markup.Inline) []markup.Inline {
+	var out []markup.Inline
+	for _, in := range inlines {
+		if n := len(out); n > 0 && !in.Break && !out[n-1].Break {
+			prev, cur := out[n-1], in
+			prev.Text, cur.Text = "", ""
+			if prev == cur {
+				out[n-1].Text += in.Text
+				continue
+			}
+		}
+		out = append(out, in)
+	}
+	for len(out) > 0 && (out[0].Break || strings.TrimSpace(out[0].Text) == "") {
+		out = out[1:]
+	}
+	for len(out) > 0 && (out[len(out)-1].Break || strings.TrimSpace(out[len(out)-1].Text) == "") {
+		out = out[:len(out)-1]
+	}
+	if len(out) > 0 {
+		out[0].Text = strings.TrimLeft(out[0].Text, " ")
+		out[len(out)-1].Text = strings.TrimRight(out[len(out)-1].Text, " ")
+	}
+	return out
+}
+
+// group turns a run of paragraphs into blocks: consecutive list paragraphs
+// become nested lists, quote paragraphs a quote and code paragraphs one code
+// block
+func group(paras []paragraph) []markup.Block {
+	var out []markup.Block
+	for i := 0; i < len(paras); {
+		p := paras[i]
+		switch {
+		case p.list:
+			// A list ends where another numbering starts at its level
+			j := i + 1
+			for j < len(paras) && paras[j].list && (paras[j].level > p.level || paras[j].numID == p.numID) {
+				j++
+			}
+			out = append(out, buildList(paras[i:j], paras[i].level))
+			i = j
+		case p.style.code:
+			var lines []string
+			for ; i < len(paras) && paras[i].style.code && !paras[i].list; i++ {
+				lines = append(lines, paras[i].text)
+			}
+			out = append(out, markup.Block{Kind: markup.CodeBlock, Text: strings.Join(lines, "\n")})
+		case p.style.quote:
+			var quoted []paragraph
+			for ; i < len(paras) && paras[i].style.quote && !paras[i].list; i++ {
+				q := paras[i]
+				q.style.quote = false
+				quoted = append(quoted, q)
+			}
+			out = append(out, markup.Block{Kind: markup.Quote, Children: group(quoted)})
+		default:
+			if len(p.inlines) > 0 {
+				if p.style.heading > 0 {
+					out = append(out, markup.Block{Kind: markup.Heading, Level: p.style.heading, Inlines: p.inlines})
+				} else {
+					out = append(out, markup.Block{Kind: markup.Paragraph, Inlines: p.inlines})
+				}
+			}
+			i++
+		}
+	}
+	return out
+}
+
+// buildList nests list paragraphs by their level; paragraphs deeper than
+// level belong to the item before them
+func buildList(paras []paragraph, level int) markup.Block {
+	list := markup.Block{Kind: markup.List, Ordered: paras[0].ordered, Start: max(paras[0].start, 1)}
+	for i := 0; i < len(paras); {
+		p := paras[i]
+		item := []markup.Block{{Kind: markup.Paragraph, Inlines: p.inlines}}
+		if len(p.inlines) == 0 {
+			item = nil
+		}
+		j := i + 1
+		for j < len(paras) && paras[j].level > p.level {
+			j++
+		}
+		if j > i+1 {
+			item = append(item, buildList(paras[i+1:j], paras[i+1].level))
+		}
+		list.Items = append(list.Items, item)
+		i = j
+	}
+	return list
+}
+
+// table converts a table; each cell's paragraphs are joined with breaks
+func (r *reader) table(tbl *node) markup.Block {
+	table := markup.Block{Kind: markup.Table}
+	cols := 0
+	for _, tr := range tbl.children {
+		if tr.name != "tr" {
+			continue
+		}
+		var row []markup.Cell
+		for _, tc := range tr.children {
+			if tc.name != "tc" {
+				continue
+			}
+			var cell markup.Cell
+			for _, p := range tc.children {
+				if p.name != "p" {
+					continue
+				}
+				inlines := trimInlines(r.inlines(p, ""))
+				if len(inlines) == 0 {
+					continue
+				}
+				if len(cell) > 0 {
+					cell = append(cell, markup.Inline{Break: true})
+				}
+				cell = append(cell, inlines...)
+			}
+			row = append(row, cell)
+			// Merged cells keep the columns of the rows aligned
+			if span, err := strconv.Atoi(attrOf(tc.child("tcPr").child("gridSpan"), "val")); err == nil {
+				for k := 1; k < span; k++ {
+					row = append(row, nil)
+				}
+			}
+		}
+		cols = max(cols, len(row))
+		table.Rows = append(table.Rows, row)
+	}
+	// Header rows are bold anyway
+	if len(table.Rows) > 0 && allBold(table.Rows[0]) {
+		for _, cell := range table.Rows[0] {
+			for i := range cell {
+				cell[i].Bold = false
+			}
+		}
+	}
+	for i := range table.Rows {
+		for len(table.Rows[i]) < cols {
+			table.Rows[i] = append(table.Rows[i], nil)
+		}
+	}
+	return table
+}
+
+func allBold(row []markup.Cell) bool {
+	for _, cell := range row {
+		for _, in := range cell {
+			if !in.Break && !in.Bold {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func attrOf(n *node, name string) string {
+	if n == nil {
+		return ""
+	}
+	return n.attr(name)
+}
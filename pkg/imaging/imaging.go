@@ -0,0 +1,120 @@
+// Package imaging decodes untrusted uploads and renders square thumbnails.
+// Images are always re-encoded, which drops EXIF, XMP and other metadata.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooManyPixels     = errors.New("image dimensions are too large")
+)
+
+// supportedTypes maps sniffed content types to image.Decode format names
+var supportedTypes = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// DetectFormat sniffs the content type from the leading bytes of data and
+// returns the decoder name, ignoring whatever the client claimed
+func DetectFormat(data []byte) (string, error) {
+	format, ok := supportedTypes[http.DetectContentType(data)]
+	if !ok {
+		return "", ErrUnsupportedFormat
+	}
+	return format, nil
+}
+
+// Decode decodes a JPEG, PNG, GIF (first frame) or WebP image. The header is
+// checked before decoding so oversized images are rejected without allocating
+// their pixels. JPEG EXIF orientation is applied to the result.
+func Decode(data []byte, maxPixels int) (image.Image, error) {
+	format, err := DetectFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if decoded != format {
+		return nil, ErrUnsupportedFormat
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(data))
+	}
+	return img, nil
+}
+
+// SquareThumbnail center-crops img to a square and scales it to size x size
+func SquareThumbnail(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	crop := image.Rect(x0, y0, x0+side, y0+side)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, xdraw.Src, nil)
+	return dst
+}
+
+// Fit scales img down, keeping its aspect ratio, until it fits within
+// width x height. Smaller images are copied unscaled.
+func Fit(img image.Image, width, height int) *image.RGBA {
+	b := img.Bounds()
+	if b.Dx() <= width && b.Dy() <= height {
+		return toRGBA(img)
+	}
+	w, h := width, b.Dy()*width/b.Dx()
+	if h > height {
+		w, h = b.Dx()*height/b.Dy(), height
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
+
+// Encode writes img as JPEG when it is fully opaque and as PNG otherwise,
+// returning the file extension used
+func Encode(w io.Writer, img *image.RGBA) (string, error) {
+	if img.Opaque() {
+		return "jpg", jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return "png", png.Encode(w, img)
+}
+
+// toRGBA copies img into an RGBA buffer anchored at the origin
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(out, out.Bounds(), img, b.Min, draw.Src)
+	return out
+}
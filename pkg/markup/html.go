@@ -0,0 +1,421 @@
+package markup
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTML renders blocks as an HTML fragment. All text is escaped and links with
+// unsafe targets are rendered as plain text.
+func HTML(blocks []Block) string {
+	var b strings.Builder
+	writeHTMLBlocks(&b, blocks)
+	return b.String()
+}
+
+func writeHTMLBlocks(b *strings.Builder, blocks []Block) {
+	for _, block := range blocks {
+		writeHTMLBlock(b, block)
+	}
+}
+
+func writeHTMLBlock(b *strings.Builder, block Block) {
+	switch block.Kind {
+	case Paragraph:
+		b.WriteString("<p>")
+		writeHTMLInlines(b, block.Inlines)
+		b.WriteString("</p>\n")
+	case Heading:
+		tag := "h" + strconv.Itoa(block.Level)
+		b.WriteString("<" + tag + ">")
+		writeHTMLInlines(b, block.Inlines)
+		b.WriteString("</" + tag + ">\n")
+	case List:
+		tag := "ul"
+		if block.Ordered {
+			tag = "ol"
+		}
+		b.WriteString("<" + tag)
+		if block.Ordered && block.Start != 1 {
+			b.WriteString(` start="` + strconv.Itoa(block.Start) + `"`)
+		}
+		b.WriteString(">\n")
+		for _, item := range block.Items {
+			b.WriteString("<li>")
+			// A lone paragraph is written without <p>, as tight lists are
+			if len(item) == 1 && item[0].Kind == Paragraph {
+				writeHTMLInlines(b, item[0].Inlines)
+			} else {
+				writeHTMLBlocks(b, item)
+			}
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</" + tag + ">\n")
+	case CodeBlock:
+		b.WriteString("<pre><code")
+		if block.Language != "" {
+			b.WriteString(` class="language-` + html.EscapeString(block.Language) + `"`)
+		}
+		b.WriteString(">" + html.EscapeString(block.Text) + "</code></pre>\n")
+	case Quote:
+		b.WriteString("<blockquote>\n")
+		writeHTMLBlocks(b, block.Children)
+		b.WriteString("</blockquote>\n")
+	case Rule:
+		b.WriteString("<hr>\n")
+	case Table:
+		b.WriteString("<table>\n")
+		for r, row := range block.Rows {
+			cell := "td"
+			if r == 0 {
+				cell = "th"
+				b.WriteString("<thead>\n")
+			} else if r == 1 {
+				b.WriteString("<tbody>\n")
+			}
+			b.WriteString("<tr>")
+			for _, c := range row {
+				b.WriteString("<" + cell + ">")
+				writeHTMLInlines(b, c)
+				b.WriteString("</" + cell + ">")
+			}
+			b.WriteString("</tr>\n")
+			if r == 0 {
+				b.WriteString("</thead>\n")
+			}
+		}
+		if len(block.Rows) > 1 {
+			b.WriteString("</tbody>\n")
+		}
+		b.WriteString("</table>\n")
+	}
+}
+
+func writeHTMLInlines(b *strings.Builder, inlines []Inline) {
+	for _, in := range inlines {
+		if in.Break {
+			b.WriteString("<br>\n")
+			continue
+		}
+		if in.Image {
+			if SafeURL(in.Link) {
+				b.WriteString(`<img src="` + html.EscapeString(in.Link) + `" alt="` + html.EscapeString(in.Text) + `">`)
+			} else {
+				b.WriteString(html.EscapeString(in.Text))
+			}
+			continue
+		}
+
+		var open, close []string
+		wrap := func(tag, attrs string) {
+			open = append(open, "<"+tag+attrs+">")
+			close = append([]string{"</" + tag + ">"}, close...)
+		}
+		if in.Link != "" && SafeURL(in.Link) {
+			wrap("a", ` href="`+html.EscapeString(in.Link)+`"`)
+		}
+		if in.Bold {
+			wrap("strong", "")
+		}
+		if in.Italic {
+			wrap("em", "")
+		}
+		if in.Strike {
+			wrap("del", "")
+		}
+		if in.Code {
+			wrap("code", "")
+		}
+		b.WriteString(strings.Join(open, ""))
+		b.WriteString(html.EscapeString(in.Text))
+		b.WriteString(strings.Join(close, ""))
+	}
+}
+
+// ParseHTML parses an HTML document or fragment into blocks. Scripts, styles
+// and unknown elements' markup are dropped; their text is kept.
+func ParseHTML(src string) []Block {
+	root, err := xhtml.Parse(strings.NewReader(src))
+	if err != nil {
+		return []Block{{Kind: Paragraph, Inlines: []Inline{{Text: src}}}}
+	}
+	body := findElement(root, atom.Body)
+	if body == nil {
+		body = root
+	}
+	c := &htmlConverter{}
+	c.blocks(body)
+	return c.out
+}
+
+// HTMLTitle returns the text of an HTML document's title element, or ""
+func HTMLTitle(src string) string {
+	root, err := xhtml.Parse(strings.NewReader(src))
+	if err != nil {
+		return ""
+	}
+	title := findElement(root, atom.Title)
+	if title == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(textContent(title)), " ")
+}
+
+func findElement(n *xhtml.Node, a atom.Atom) *xhtml.Node {
+	if n.Type == xhtml.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func attr(n *xhtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// htmlConverter collects blocks while walking the DOM. Inline content found
+// directly in containers gathers into an implicit paragraph.
+type htmlConverter struct {
+	out     []Block
+	pending []Inline
+}
+
+func (c *htmlConverter) flush() {
+	inlines := trimInlines(c.pending)
+	c.pending = nil
+	if len(inlines) > 0 {
+		c.out = append(c.out, Block{Kind: Paragraph, Inlines: inlines})
+	}
+}
+
+func (c *htmlConverter) add(block Block) {
+	c.flush()
+	c.out = append(c.out, block)
+}
+
+// sub converts the children of n into a separate block list
+func sub(n *xhtml.Node) []Block {
+	c := &htmlConverter{}
+	c.blocks(n)
+	return c.out
+}
+
+func (c *htmlConverter) blocks(n *xhtml.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.block(child)
+	}
+	c.flush()
+}
+
+func (c *htmlConverter) block(n *xhtml.Node) {
+	if n.Type == xhtml.TextNode {
+		c.pending = append(c.pending, inlines(n, Inline{})...)
+		return
+	}
+	if n.Type != xhtml.ElementNode {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Head, atom.Template, atom.Noscript:
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		c.add(Block{Kind: Heading, Level: level, Inlines: trimInlines(childInlines(n, Inline{}))})
+	case atom.P:
+		c.flush()
+		c.pending = childInlines(n, Inline{})
+		c.flush()
+	case atom.Pre:
+		c.add(Block{Kind: CodeBlock, Language: codeLanguage(n), Text: strings.TrimSuffix(textContent(n), "\n")})
+	case atom.Blockquote:
+		c.add(Block{Kind: Quote, Children: sub(n)})
+	case atom.Hr:
+		c.add(Block{Kind: Rule})
+	case atom.Ul, atom.Ol:
+		list := Block{Kind: List, Ordered: n.DataAtom == atom.Ol, Start: 1}
+		if start, err := strconv.Atoi(attr(n, "start")); err == nil {
+			list.Start = start
+		}
+		for li := n.FirstChild; li != nil; li = li.NextSibling {
+			if li.Type == xhtml.ElementNode && li.DataAtom == atom.Li {
+				list.Items = append(list.Items, sub(li))
+			}
+		}
+		c.add(list)
+	case atom.Table:
+		c.add(htmlTable(n))
+	case atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer, atom.Aside,
+		atom.Nav, atom.Figure, atom.Body, atom.Html, atom.Form, atom.Fieldset, atom.Details,
+		atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Center, atom.Address, atom.Figcaption:
+		c.flush()
+		c.blocks(n)
+	default:
+		c.pending = append(c.pending, inlines(n, Inline{})...)
+	}
+}
+
+func codeLanguage(pre *xhtml.Node) string {
+	for n := pre.FirstChild; n != nil; n = n.NextSibling {
+		if n.Type == xhtml.ElementNode && n.DataAtom == atom.Code {
+			for _, class := range strings.Fields(attr(n, "class")) {
+				if lang, ok := strings.CutPrefix(class, "language-"); ok {
+					return lang
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func textContent(n *xhtml.Node) string {
+	if n.Type == xhtml.TextNode {
+		return n.Data
+	}
+	if n.Type == xhtml.ElementNode && n.DataAtom == atom.Br {
+		return "\n"
+	}
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
+
+func htmlTable(n *xhtml.Node) Block {
+	table := Block{Kind: Table}
+	var rows func(*xhtml.Node)
+	rows = func(n *xhtml.Node) {
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != xhtml.ElementNode {
+				continue
+			}
+			switch child.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				rows(child)
+			case atom.Tr:
+				var row []Cell
+				for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type == xhtml.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						row = append(row, Cell(trimInlines(childInlines(cell, Inline{}))))
+					}
+				}
+				table.Rows = append(table.Rows, row)
+			}
+		}
+	}
+	rows(n)
+
+	width := 0
+	for _, row := range table.Rows {
+		width = max(width, len(row))
+	}
+	for i := range table.Rows {
+		for len(table.Rows[i]) < width {
+			table.Rows[i] = append(table.Rows[i], nil)
+		}
+	}
+	return table
+}
+
+func childInlines(n *xhtml.Node, style Inline) []Inline {
+	var out []Inline
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		out = append(out, inlines(child, style)...)
+	}
+	return out
+}
+
+// inlines converts an inline node, collapsing white space as browsers do
+func inlines(n *xhtml.Node, style Inline) []Inline {
+	switch n.Type {
+	case xhtml.TextNode:
+		text := strings.Join(strings.Fields(n.Data), " ")
+		if text == "" && n.Data != "" {
+			text = " "
+		} else if text != "" {
+			if strings.TrimLeft(n.Data[:1], " \t\r\n\f") == "" {
+				text = " " + text
+			}
+			if strings.TrimRight(n.Data[len(n.Data)-1:], " \t\r\n\f") == "" {
+				text += " "
+			}
+		}
+		style.Text = text
+		return []Inline{style}
+	case xhtml.ElementNode:
+	default:
+		return nil
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style:
+		return nil
+	case atom.Br:
+		return []Inline{{Break: true}}
+	case atom.Img:
+		return []Inline{{Text: attr(n, "alt"), Link: attr(n, "src"), Image: true}}
+	case atom.Strong, atom.B:
+		style.Bold = true
+	case atom.Em, atom.I, atom.Cite:
+		style.Italic = true
+	case atom.S, atom.Del, atom.Strike:
+		style.Strike = true
+	case atom.Code, atom.Kbd, atom.Samp, atom.Tt:
+		style.Code = true
+	case atom.A:
+		if href := attr(n, "href"); href != "" {
+			style.Link = href
+		}
+	}
+	return childInlines(n, style)
+}
+
+// trimInlines drops leading and trailing white space and merges runs
+func trimInlines(inlines []Inline) []Inline {
+	inlines = mergeRuns(inlines)
+	for len(inlines) > 0 && !inlines[0].Break && !inlines[0].Image && strings.TrimSpace(inlines[0].Text) == "" {
+		inlines = inlines[1:]
+	}
+	for len(inlines) > 0 && inlines[len(inlines)-1].Break {
+		inlines = inlines[:len(inlines)-1]
+	}
+	for len(inlines) > 0 && !inlines[len(inlines)-1].Image && strings.TrimSpace(inlines[len(inlines)-1].Text) == "" {
+		inlines = inlines[:len(inlines)-1]
+		for len(inlines) > 0 && inlines[len(inlines)-1].Break {
+			inlines = inlines[:len(inlines)-1]
+		}
+	}
+	if len(inlines) == 0 {
+		return nil
+	}
+	out := append([]Inline(nil), inlines...)
+	if !out[0].Break && !out[0].Image {
+		out[0].Text = strings.TrimLeft(out[0].Text, " ")
+	}
+	if last := len(out) - 1; !out[last].Break && !out[last].Image {
+		out[last].Text = strings.TrimRight(out[last].Text, " ")
+	}
+	// Collapse the space between runs as a browser would
+	for i := 1; i < len(out); i++ {
+		if out[i].Break || out[i-1].Break || out[i].Image || out[i-1].Image {
+			continue
+		}
+		if strings.HasSuffix(out[i-1].Text, " ") && strings.HasPrefix(out[i].Text, " ") {
+			out[i].Text = strings.TrimLeft(out[i].Text, " ")
+		}
+	}
+	return out
+}
@@ -0,0 +1,921 @@
+package markup
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ParseMarkdown parses Markdown source into blocks
+func ParseMarkdown(src string) []Block {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		lines[i] = expandTabs(line)
+	}
+	return parseBlocks(lines)
+}
+
+// expandTabs replaces the tabs indenting line with spaces, four to a tab stop
+func expandTabs(line string) string {
+	if !strings.HasPrefix(strings.TrimLeft(line, " "), "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			b.WriteByte(' ')
+			col++
+		case '\t':
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+		default:
+			b.WriteString(line[i:])
+			return b.String()
+		}
+	}
+	return b.String()
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// stripIndent removes up to n leading spaces
+func stripIndent(line string, n int) string {
+	if s := leadingSpaces(line); s < n {
+		n = s
+	}
+	return line[n:]
+}
+
+func parseBlocks(lines []string) []Block {
+	var blocks []Block
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		indent := leadingSpaces(line)
+
+		if trimmed == "" {
+			i++
+			continue
+		}
+		if indent < 4 {
+			if fence, lang := openingFence(trimmed); fence != "" {
+				block, next := parseFencedCode(lines, i+1, fence, lang, indent)
+				blocks = append(blocks, block)
+				i = next
+				continue
+			}
+			if isRule(trimmed) {
+				blocks = append(blocks, Block{Kind: Rule})
+				i++
+				continue
+			}
+			if level, text, ok := atxHeading(trimmed); ok {
+				blocks = append(blocks, Block{Kind: Heading, Level: level, Inlines: parseInlines(text)})
+				i++
+				continue
+			}
+			if strings.HasPrefix(trimmed, ">") {
+				block, next := parseQuote(lines, i)
+				blocks = append(blocks, block)
+				i = next
+				continue
+			}
+			if _, _, ok := listMarker(line); ok {
+				block, next := parseList(lines, i)
+				blocks = append(blocks, block)
+				i = next
+				continue
+			}
+			if i+1 < len(lines) && strings.Contains(line, "|") && isTableDelimiter(lines[i+1]) {
+				block, next := parseTable(lines, i)
+				blocks = append(blocks, block)
+				i = next
+				continue
+			}
+		}
+
+		block, next := parseParagraph(lines, i)
+		blocks = append(blocks, block)
+		i = next
+	}
+	return blocks
+}
+
+// startsBlock reports whether line begins a block that interrupts a paragraph
+func startsBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || leadingSpaces(line) >= 4 {
+		return trimmed == ""
+	}
+	if fence, _ := openingFence(trimmed); fence != "" {
+		return true
+	}
+	if _, _, ok := atxHeading(trimmed); ok {
+		return true
+	}
+	if strings.HasPrefix(trimmed, ">") || isRule(trimmed) {
+		return true
+	}
+	_, _, ok := listMarker(line)
+	return ok
+}
+
+func parseParagraph(lines []string, i int) (Block, int) {
+	var text []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		// A line of = or - under a paragraph turns it into a heading
+		if len(text) > 0 && trimmed != "" {
+			if strings.Trim(trimmed, "=") == "" {
+				return Block{Kind: Heading, Level: 1, Inlines: parseInlines(strings.Join(text, "\n"))}, i + 1
+			}
+			if strings.Trim(trimmed, "-") == "" {
+				return Block{Kind: Heading, Level: 2, Inlines: parseInlines(strings.Join(text, "\n"))}, i + 1
+			}
+		}
+		if len(text) > 0 && startsBlock(lines[i]) {
+			break
+		}
+		text = append(text, strings.TrimLeft(lines[i], " "))
+	}
+	return Block{Kind: Paragraph, Inlines: parseInlines(strings.Join(text, "\n"))}, i
+}
+
+// openingFence returns the fence of a fenced code block and its info string
+func openingFence(trimmed string) (string, string) {
+	if len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return "", ""
+	}
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if n < 3 {
+		return "", ""
+	}
+	info := strings.TrimSpace(trimmed[n:])
+	if trimmed[0] == '`' && strings.Contains(info, "`") {
+		return "", ""
+	}
+	lang, _, _ := strings.Cut(info, " ")
+	return trimmed[:n], lang
+}
+
+func parseFencedCode(lines []string, i int, fence, lang string, indent int) (Block, int) {
+	var code []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+			i++
+			break
+		}
+		code = append(code, stripIndent(lines[i], indent))
+	}
+	return Block{Kind: CodeBlock, Language: lang, Text: strings.Join(code, "\n")}, i
+}
+
+// isRule reports whether trimmed is a thematic break such as --- or * * *
+func isRule(trimmed string) bool {
+	if trimmed == "" {
+		return false
+	}
+	c := trimmed[0]
+	if c != '-' && c != '*' && c != '_' {
+		return false
+	}
+	count := 0
+	for i := 0; i < len(trimmed); i++ {
+		switch trimmed[i] {
+		case c:
+			count++
+		case ' ':
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+func atxHeading(trimmed string) (int, string, bool) {
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	if level < 1 || level > 6 {
+		return 0, "", false
+	}
+	rest := trimmed[level:]
+	if rest != "" && rest[0] != ' ' {
+		return 0, "", false
+	}
+	rest = strings.TrimSpace(rest)
+	// Closing hashes are not part of the text
+	if stripped := strings.TrimRight(rest, "#"); stripped != rest && (stripped == "" || strings.HasSuffix(stripped, " ")) {
+		rest = strings.TrimSpace(stripped)
+	}
+	return level, rest, true
+}
+
+func parseQuote(lines []string, i int) (Block, int) {
+	var inner []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(trimmed, ">") {
+			rest := trimmed[1:]
+			if strings.HasPrefix(rest, " ") {
+				rest = rest[1:]
+			}
+			inner = append(inner, rest)
+			continue
+		}
+		// Lazy continuation of a quoted paragraph
+		if trimmed != "" && len(inner) > 0 && strings.TrimSpace(inner[len(inner)-1]) != "" && !startsBlock(lines[i]) {
+			inner = append(inner, trimmed)
+			continue
+		}
+		break
+	}
+	return Block{Kind: Quote, Children: parseBlocks(inner)}, i
+}
+
+// marker describes a list item marker
+type marker struct {
+	ordered bool
+	bullet  byte // bullet character, or the delimiter after an ordered number
+	start   int
+	width   int // column where the item's content starts
+}
+
+// listMarker parses the list item marker at the start of line
+func listMarker(line string) (marker, string, bool) {
+	indent := leadingSpaces(line)
+	if indent >= 4 {
+		return marker{}, "", false
+	}
+	rest := line[indent:]
+	var m marker
+	var n int
+	switch {
+	case rest != "" && strings.IndexByte("-*+", rest[0]) >= 0:
+		m.bullet = rest[0]
+		n = 1
+	default:
+		digits := len(rest) - len(strings.TrimLeft(rest, "0123456789"))
+		if digits == 0 || digits > 9 || len(rest) == digits || (rest[digits] != '.' && rest[digits] != ')') {
+			return marker{}, "", false
+		}
+		m.ordered = true
+		m.bullet = rest[digits]
+		m.start, _ = strconv.Atoi(rest[:digits])
+		n = digits + 1
+	}
+	content := rest[n:]
+	if content != "" && content[0] != ' ' {
+		return marker{}, "", false
+	}
+	spaces := leadingSpaces(content)
+	if spaces == 0 || spaces > 4 || strings.TrimSpace(content) == "" {
+		spaces = 1
+	}
+	m.width = indent + n + spaces
+	if strings.TrimSpace(content) == "" {
+		return m, "", true
+	}
+	return m, content[spaces:], true
+}
+
+func parseList(lines []string, i int) (Block, int) {
+	first, _, _ := listMarker(lines[i])
+	list := Block{Kind: List, Ordered: first.ordered, Start: first.start}
+
+	for i < len(lines) {
+		m, content, ok := listMarker(lines[i])
+		if !ok || m.ordered != first.ordered || m.bullet != first.bullet {
+			break
+		}
+		item := []string{content}
+		i++
+		blank := false
+		for i < len(lines) {
+			line := lines[i]
+			if strings.TrimSpace(line) == "" {
+				item = append(item, "")
+				blank = true
+				i++
+				continue
+			}
+			if leadingSpaces(line) >= m.width {
+				item = append(item, line[m.width:])
+				blank = false
+				i++
+				continue
+			}
+			if !blank && !startsBlock(line) {
+				item = append(item, strings.TrimLeft(line, " "))
+				i++
+				continue
+			}
+			break
+		}
+		// Blank lines after the item belong to the list only if it goes on
+		for len(item) > 1 && item[len(item)-1] == "" {
+			item = item[:len(item)-1]
+			i--
+		}
+		list.Items = append(list.Items, parseBlocks(item))
+
+		next := i
+		for next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+			next++
+		}
+		if next < len(lines) {
+			if m, _, ok := listMarker(lines[next]); ok && m.ordered == first.ordered && m.bullet == first.bullet {
+				i = next
+				continue
+			}
+		}
+		break
+	}
+	return list, i
+}
+
+// splitRow splits a table row into its cells at unescaped pipes
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+func isTableDelimiter(line string) bool {
+	if !strings.Contains(line, "-") {
+		return false
+	}
+	for _, cell := range splitRow(line) {
+		cell = strings.TrimSuffix(strings.TrimPrefix(cell, ":"), ":")
+		if cell == "" || strings.Trim(cell, "-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTable(lines []string, i int) (Block, int) {
+	header := splitRow(lines[i])
+	table := Block{Kind: Table}
+	row := func(cells []string) []Cell {
+		out := make([]Cell, len(header))
+		for c := range out {
+			if c < len(cells) {
+				out[c] = parseInlines(cells[c])
+			}
+		}
+		return out
+	}
+	table.Rows = append(table.Rows, row(header))
+	for i += 2; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" || !strings.Contains(lines[i], "|") {
+			break
+		}
+		table.Rows = append(table.Rows, row(splitRow(lines[i])))
+	}
+	return table, i
+}
+
+// inlineParser turns paragraph text into styled runs
+type inlineParser struct {
+	runs []Inline
+}
+
+func parseInlines(text string) []Inline {
+	p := &inlineParser{}
+	p.parse(strings.TrimSpace(text), Inline{})
+	return mergeRuns(p.runs)
+}
+
+// mergeRuns joins neighbouring runs of the same style
+func mergeRuns(runs []Inline) []Inline {
+	var out []Inline
+	for _, run := range runs {
+		if run.Text == "" && !run.Break && !run.Image {
+			continue
+		}
+		if n := len(out); n > 0 && !run.Break && !run.Image && !out[n-1].Break && !out[n-1].Image {
+			prev := out[n-1]
+			prev.Text = ""
+			cur := run
+			cur.Text = ""
+			if prev == cur {
+				out[n-1].Text += run.Text
+				continue
+			}
+		}
+		out = append(out, run)
+	}
+	return out
+}
+
+func isPunct(c byte) bool {
+	return c < utf8.RuneSelf && unicode.IsPunct(rune(c)) || strings.IndexByte("$+<=>^`|~", c) >= 0
+}
+
+func isAlnum(c byte) bool {
+	return c >= utf8.RuneSelf || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+func (p *inlineParser) parse(s string, style Inline) {
+	var buf strings.Builder
+	flush := func() {
+		if buf.Len() > 0 {
+			run := style
+			run.Text = buf.String()
+			p.runs = append(p.runs, run)
+			buf.Reset()
+		}
+	}
+	hardBreak := func() {
+		flush()
+		p.runs = append(p.runs, Inline{Break: true})
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '\n':
+			hardBreak()
+			i += 2
+			continue
+		case c == '\\' && i+1 < len(s) && isPunct(s[i+1]):
+			buf.WriteByte(s[i+1])
+			i += 2
+			continue
+		case c == '\n':
+			text := buf.String()
+			trimmed := strings.TrimRight(text, " ")
+			buf.Reset()
+			buf.WriteString(trimmed)
+			if len(text)-len(trimmed) >= 2 {
+				hardBreak()
+			} else {
+				buf.WriteByte(' ')
+			}
+			i++
+			for i < len(s) && s[i] == ' ' {
+				i++
+			}
+			continue
+		case c == '`':
+			n := runLength(s, i)
+			if end := findCodeClose(s, i+n, n); end >= 0 {
+				flush()
+				code := strings.ReplaceAll(s[i+n:end], "\n", " ")
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				run := style
+				run.Text, run.Code = code, true
+				p.runs = append(p.runs, run)
+				i = end + n
+				continue
+			}
+			buf.WriteString(s[i : i+n])
+			i += n
+			continue
+		case c == '*' || c == '_' || c == '~':
+			if end, size, ok := p.emphasis(s, i); ok {
+				flush()
+				inner := style
+				switch {
+				case c == '~':
+					inner.Strike = true
+				case size == 2:
+					inner.Bold = true
+				default:
+					inner.Italic = true
+				}
+				p.parse(s[i+size:end], inner)
+				i = end + size
+				continue
+			}
+			n := runLength(s, i)
+			buf.WriteString(s[i : i+n])
+			i += n
+			continue
+		case c == '!' && i+1 < len(s) && s[i+1] == '[':
+			if text, target, end, ok := parseLink(s, i+1); ok {
+				flush()
+				p.runs = append(p.runs, Inline{Text: text, Link: target, Image: true})
+				i = end
+				continue
+			}
+		case c == '[':
+			if text, target, end, ok := parseLink(s, i); ok {
+				flush()
+				inner := style
+				inner.Link = target
+				p.parse(text, inner)
+				i = end
+				continue
+			}
+		case c == '<':
+			if end := strings.IndexByte(s[i:], '>'); end > 0 {
+				tag := s[i+1 : i+end]
+				switch lower := strings.ToLower(strings.TrimSpace(tag)); {
+				case lower == "br" || lower == "br/" || lower == "br /":
+					hardBreak()
+					i += end + 1
+					continue
+				case !strings.ContainsAny(tag, " \n<") && (strings.Contains(tag, "://") || strings.HasPrefix(lower, "mailto:")):
+					flush()
+					p.runs = append(p.runs, withLink(style, tag, tag))
+					i += end + 1
+					continue
+				case !strings.ContainsAny(tag, " \n<:/") && strings.Contains(tag, "@"):
+					flush()
+					p.runs = append(p.runs, withLink(style, tag, "mailto:"+tag))
+					i += end + 1
+					continue
+				}
+			}
+		case (c == 'h' || c == 'w') && style.Link == "" && (i == 0 || !isAlnum(s[i-1])):
+			if url := bareURL(s[i:]); url != "" {
+				flush()
+				target := url
+				if strings.HasPrefix(url, "www.") {
+					target = "http://" + url
+				}
+				p.runs = append(p.runs, withLink(style, url, target))
+				i += len(url)
+				continue
+			}
+		}
+		buf.WriteByte(c)
+		i++
+	}
+	flush()
+}
+
+func withLink(style Inline, text, target string) Inline {
+	style.Text, style.Link = text, target
+	return style
+}
+
+func runLength(s string, i int) int {
+	n := 1
+	for i+n < len(s) && s[i+n] == s[i] {
+		n++
+	}
+	return n
+}
+
+func findCodeClose(s string, from, n int) int {
+	for i := from; i < len(s); {
+		if s[i] != '`' {
+			i++
+			continue
+		}
+		run := runLength(s, i)
+		if run == n {
+			return i
+		}
+		i += run
+	}
+	return -1
+}
+
+// emphasis matches the delimiter run at i with a closing one. It returns
+// where the closing delimiter starts and the delimiter size.
+func (p *inlineParser) emphasis(s string, i int) (int, int, bool) {
+	c := s[i]
+	n := runLength(s, i)
+	sizes := []int{1}
+	switch {
+	case c == '~':
+		if n < 2 {
+			return 0, 0, false
+		}
+		sizes = []int{2}
+	case n >= 2:
+		sizes = []int{2, 1}
+	}
+	open := i + n
+	// The opening run must be followed by text, and _ must not sit inside a word
+	if open >= len(s) || s[open] == ' ' || s[open] == '\n' {
+		return 0, 0, false
+	}
+	if c == '_' && i > 0 && isAlnum(s[i-1]) {
+		return 0, 0, false
+	}
+
+	for _, size := range sizes {
+		for j := open; j < len(s); {
+			switch s[j] {
+			case '`':
+				run := runLength(s, j)
+				if end := findCodeClose(s, j+run, run); end >= 0 {
+					j = end + run
+					continue
+				}
+				j += run
+				continue
+			case '\\':
+				j += 2
+				continue
+			case c:
+			default:
+				j++
+				continue
+			}
+			run := runLength(s, j)
+			closing := j + run - size
+			valid := run == size || run == 3 || (c == '~' && run >= 2)
+			if s[j-1] == ' ' || s[j-1] == '\n' || (c == '_' && j+run < len(s) && isAlnum(s[j+run])) {
+				valid = false
+			}
+			if valid {
+				return closing, size, true
+			}
+			j += run
+		}
+	}
+	return 0, 0, false
+}
+
+// parseLink parses [text](target "title") starting at the opening bracket
+func parseLink(s string, i int) (string, string, int, bool) {
+	depth := 0
+	close := -1
+	for j := i; j < len(s) && close < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				close = j
+			}
+		}
+	}
+	if close < 0 || close+1 >= len(s) || s[close+1] != '(' {
+		return "", "", 0, false
+	}
+	depth = 0
+	end := -1
+	for j := close + 1; j < len(s) && end < 0; j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				end = j
+			}
+		}
+	}
+	if end < 0 {
+		return "", "", 0, false
+	}
+	dest := strings.TrimSpace(s[close+2 : end])
+	if strings.HasPrefix(dest, "<") {
+		if gt := strings.IndexByte(dest, '>'); gt > 0 {
+			dest = dest[1:gt]
+		}
+	} else if sp := strings.IndexAny(dest, " \n"); sp >= 0 {
+		// Drop the optional title
+		dest = dest[:sp]
+	}
+	return s[i+1 : close], dest, end + 1, true
+}
+
+// bareURL returns the URL at the start of s, if any
+func bareURL(s string) string {
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") && !strings.HasPrefix(s, "www.") {
+		return ""
+	}
+	end := strings.IndexAny(s, " \t\n<")
+	if end < 0 {
+		end = len(s)
+	}
+	url := strings.TrimRight(s[:end], ".,;:!?*_~'\"")
+	// A closing parenthesis ends the URL unless it has a matching opening one
+	for strings.HasSuffix(url, ")") && strings.Count(url, ")") > strings.Count(url, "(") {
+		url = url[:len(url)-1]
+	}
+	if url == "http://" || url == "https://" || url == "www." {
+		return ""
+	}
+	return url
+}
+
+// Markdown renders blocks as Markdown that ParseMarkdown reads back into the
+// same blocks
+func Markdown(blocks []Block) string {
+	return strings.TrimRight(markdownBlocks(blocks), "\n") + "\n"
+}
+
+func markdownBlocks(blocks []Block) string {
+	var parts []string
+	for _, block := range blocks {
+		parts = append(parts, markdownBlock(block))
+	}
+	return strings.Join(parts, "\n")
+}
+
+func markdownBlock(block Block) string {
+	switch block.Kind {
+	case Paragraph:
+		return escapeLineStart(markdownInlines(block.Inlines)) + "\n"
+	case Heading:
+		return strings.Repeat("#", min(max(block.Level, 1), 6)) + " " + markdownInlines(block.Inlines) + "\n"
+	case List:
+		var b strings.Builder
+		// A list is tight when no item holds more than a paragraph and the
+		// list nested under it
+		tight := true
+		for _, item := range block.Items {
+			if len(item) > 2 || len(item) == 2 && item[1].Kind != List {
+				tight = false
+			}
+		}
+		for i, item := range block.Items {
+			marker := "- "
+			if block.Ordered {
+				marker = strconv.Itoa(block.Start+i) + ". "
+			}
+			if i > 0 && !tight {
+				b.WriteString("\n")
+			}
+			body := markdownBlocks(item)
+			if tight && len(item) == 2 {
+				body = markdownBlock(item[0]) + markdownBlock(item[1])
+			}
+			body = strings.TrimRight(body, "\n")
+			b.WriteString(marker + indentLines(body, strings.Repeat(" ", len(marker))) + "\n")
+		}
+		return b.String()
+	case CodeBlock:
+		fence := "```"
+		for strings.Contains(block.Text, fence) {
+			fence += "`"
+		}
+		return fence + block.Language + "\n" + block.Text + "\n" + fence + "\n"
+	case Quote:
+		body := strings.TrimRight(markdownBlocks(block.Children), "\n")
+		var b strings.Builder
+		for _, line := range strings.Split(body, "\n") {
+			if line == "" {
+				b.WriteString(">\n")
+			} else {
+				b.WriteString("> " + line + "\n")
+			}
+		}
+		return b.String()
+	case Rule:
+		return "---\n"
+	case Table:
+		var b strings.Builder
+		for r, row := range block.Rows {
+			b.WriteString("|")
+			for c := range block.Rows[0] {
+				var cell Cell
+				if c < len(row) {
+					cell = row[c]
+				}
+				text := strings.ReplaceAll(markdownInlines(cell), "|", `\|`)
+				b.WriteString(" " + strings.ReplaceAll(text, "  \n", " ") + " |")
+			}
+			b.WriteString("\n")
+			if r == 0 {
+				b.WriteString("|" + strings.Repeat(" --- |", len(row)) + "\n")
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// indentLines indents every line but the first, skipping blank ones
+func indentLines(s, indent string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escapeLineStart escapes what would otherwise start a heading, list, quote
+// or other block at the start of a paragraph line
+func escapeLineStart(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		switch {
+		case trimmed == "":
+		case strings.IndexByte("#>-+=|", trimmed[0]) >= 0:
+			lines[i] = `\` + trimmed
+		default:
+			digits := len(trimmed) - len(strings.TrimLeft(trimmed, "0123456789"))
+			if digits > 0 && digits < len(trimmed) && (trimmed[digits] == '.' || trimmed[digits] == ')') {
+				lines[i] = trimmed[:digits] + `\` + trimmed[digits:]
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`, "~", `\~`, "!", `\!`)
+
+func markdownInlines(inlines []Inline) string {
+	var b strings.Builder
+	for i := 0; i < len(inlines); i++ {
+		in := inlines[i]
+		switch {
+		case in.Break:
+			b.WriteString("  \n")
+		case in.Image:
+			b.WriteString("![" + markdownEscaper.Replace(in.Text) + "](" + markdownTarget(in.Link) + ")")
+		case in.Link != "":
+			// Neighbouring runs with the same target make up one link
+			j := i
+			for j < len(inlines) && inlines[j].Link == in.Link && !inlines[j].Image && !inlines[j].Break {
+				j++
+			}
+			var text strings.Builder
+			for _, run := range inlines[i:j] {
+				text.WriteString(markdownRun(run))
+			}
+			b.WriteString("[" + text.String() + "](" + markdownTarget(in.Link) + ")")
+			i = j - 1
+		default:
+			b.WriteString(markdownRun(in))
+		}
+	}
+	return b.String()
+}
+
+// markdownTarget writes a link target, in angle brackets when it has spaces
+// or parentheses
+func markdownTarget(target string) string {
+	if strings.ContainsAny(target, " ()<>") {
+		return "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(target) + ">"
+	}
+	return target
+}
+
+// markdownRun writes one styled run, keeping the whitespace at either end
+// outside the emphasis markers, where it would stop them from closing
+func markdownRun(in Inline) string {
+	if in.Code {
+		fence := "`"
+		for strings.Contains(in.Text, fence) {
+			fence += "`"
+		}
+		text := in.Text
+		if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+			text = " " + text + " "
+		}
+		return fence + text + fence
+	}
+
+	trimmed := strings.TrimSpace(in.Text)
+	if trimmed == "" {
+		return in.Text
+	}
+	lead := in.Text[:strings.Index(in.Text, trimmed)]
+	trail := in.Text[len(lead)+len(trimmed):]
+	text := markdownEscaper.Replace(trimmed)
+	if in.Strike {
+		text = "~~" + text + "~~"
+	}
+	if in.Italic {
+		text = "*" + text + "*"
+	}
+	if in.Bold {
+		text = "**" + text + "**"
+	}
+	return lead + text + trail
+}
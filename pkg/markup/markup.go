@@ -0,0 +1,86 @@
+// Package markup parses document content into a small block tree that the
+// export formats render from. It understands the common subset of Markdown
+// (with GitHub tables and strikethrough) and of HTML.
+package markup
+
+import "strings"
+
+type BlockKind int
+
+const (
+	Paragraph BlockKind = iota
+	Heading
+	List
+	CodeBlock
+	Quote
+	Rule
+	Table
+)
+
+// Block is a block-level element. Which fields are used depends on Kind.
+type Block struct {
+	Kind     BlockKind
+	Level    int      // Heading: 1 to 6
+	Inlines  []Inline // Paragraph and Heading
+	Ordered  bool     // List
+	Start    int      // List: number of the first item of an ordered list
+	Items    [][]Block
+	Text     string   // CodeBlock, without a trailing newline
+	Language string   // CodeBlock
+	Children []Block  // Quote
+	Rows     [][]Cell // Table; the first row is the header
+}
+
+// Cell is a table cell
+type Cell []Inline
+
+// Inline is a run of text sharing one style
+type Inline struct {
+	Text   string
+	Bold   bool
+	Italic bool
+	Strike bool
+	Code   bool
+	Link   string // target of a link, or the source of an image
+	Image  bool   // Text is the image's alternative text
+	Break  bool   // a hard line break; Text is empty
+}
+
+// Placeholders header and footer text may contain. Formats with pages
+// replace them with the page number and count; the others drop them.
+const (
+	PageNumber = "{{page}}"
+	PageCount  = "{{pages}}"
+)
+
+// PlainText joins the text of inlines, turning breaks into newlines
+func PlainText(inlines []Inline) string {
+	var b strings.Builder
+	for _, in := range inlines {
+		if in.Break {
+			b.WriteByte('\n')
+			continue
+		}
+		b.WriteString(in.Text)
+	}
+	return b.String()
+}
+
+// SafeURL reports whether a link target may be rendered as a link. Relative
+// targets and the http, https and mailto schemes are allowed; anything that
+// could run script, such as javascript: URLs, is not.
+func SafeURL(target string) bool {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return false
+	}
+	colon := strings.IndexByte(target, ':')
+	if colon < 0 || strings.ContainsAny(target[:colon], "/?#") {
+		return true
+	}
+	switch strings.ToLower(target[:colon]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
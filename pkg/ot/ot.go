@@ -0,0 +1,258 @@
+// Package ot implements operational transformation for plain text.
+//
+// Operations use the JSON form of ot.js: an array whose positive integers
+// retain characters, negative integers delete them and strings insert text.
+// Lengths and positions count UTF-16 code units, like JavaScript strings, so
+// browser editors can use their native offsets.
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+var (
+	ErrInvalidOperation = errors.New("invalid operation")
+	ErrLengthMismatch   = errors.New("operation does not match the document length")
+)
+
+type kind int
+
+const (
+	retain kind = iota
+	insert
+	remove
+)
+
+type component struct {
+	kind  kind
+	n     int      // retained or deleted units
+	units []uint16 // inserted text
+}
+
+// Operation transforms a document of BaseLength units into one of
+// TargetLength units
+type Operation struct {
+	components   []component
+	BaseLength   int
+	TargetLength int
+}
+
+// Text encodes s the way operations and documents store it
+func Text(s string) []uint16 {
+	return utf16.Encode([]rune(s))
+}
+
+// String decodes a document
+func String(units []uint16) string {
+	return string(utf16.Decode(units))
+}
+
+func (o *Operation) retain(n int) {
+	if n <= 0 {
+		return
+	}
+	o.BaseLength += n
+	o.TargetLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].kind == retain {
+		o.components[last].n += n
+		return
+	}
+	o.components = append(o.components, component{kind: retain, n: n})
+}
+
+func (o *Operation) insert(units []uint16) {
+	if len(units) == 0 {
+		return
+	}
+	o.TargetLength += len(units)
+	last := len(o.components) - 1
+	if last >= 0 && o.components[last].kind == insert {
+		o.components[last].units = append(o.components[last].units, units...)
+		return
+	}
+	// Inserts go before deletes so equivalent operations compare equal
+	if last >= 0 && o.components[last].kind == remove {
+		if last > 0 && o.components[last-1].kind == insert {
+			o.components[last-1].units = append(o.components[last-1].units, units...)
+			return
+		}
+		o.components = append(o.components, o.components[last])
+		o.components[last] = component{kind: insert, units: append([]uint16(nil), units...)}
+		return
+	}
+	o.components = append(o.components, component{kind: insert, units: append([]uint16(nil), units...)})
+}
+
+func (o *Operation) delete(n int) {
+	if n <= 0 {
+		return
+	}
+	o.BaseLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].kind == remove {
+		o.components[last].n += n
+		return
+	}
+	o.components = append(o.components, component{kind: remove, n: n})
+}
+
+// IsNoop reports whether the operation leaves the document unchanged
+func (o *Operation) IsNoop() bool {
+	return len(o.components) == 0 || (len(o.components) == 1 && o.components[0].kind == retain)
+}
+
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return ErrInvalidOperation
+	}
+	*o = Operation{}
+	for _, item := range raw {
+		var text string
+		if err := json.Unmarshal(item, &text); err == nil {
+			if text == "" {
+				return ErrInvalidOperation
+			}
+			o.insert(Text(text))
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(item, &n); err != nil || n == 0 {
+			return ErrInvalidOperation
+		}
+		if n > 0 {
+			o.retain(n)
+		} else {
+			o.delete(-n)
+		}
+	}
+	return nil
+}
+
+func (o Operation) MarshalJSON() ([]byte, error) {
+	items := make([]interface{}, 0, len(o.components))
+	for _, c := range o.components {
+		switch c.kind {
+		case retain:
+			items = append(items, c.n)
+		case insert:
+			items = append(items, String(c.units))
+		case remove:
+			items = append(items, -c.n)
+		}
+	}
+	return json.Marshal(items)
+}
+
+// Apply returns the document produced by applying the operation to doc
+func (o *Operation) Apply(doc []uint16) ([]uint16, error) {
+	if len(doc) != o.BaseLength {
+		return nil, ErrLengthMismatch
+	}
+	out := make([]uint16, 0, o.TargetLength)
+	pos := 0
+	for _, c := range o.components {
+		switch c.kind {
+		case retain:
+			out = append(out, doc[pos:pos+c.n]...)
+			pos += c.n
+		case insert:
+			out = append(out, c.units...)
+		case remove:
+			pos += c.n
+		}
+	}
+	return out, nil
+}
+
+// Transform takes two operations a and b made concurrently on the same
+// document and returns a' and b' such that applying a then b' yields the same
+// document as applying b then a'. When both insert at the same position, a's
+// text comes first.
+func Transform(a, b *Operation) (*Operation, *Operation, error) {
+	if a.BaseLength != b.BaseLength {
+		return nil, nil, fmt.Errorf("%w: concurrent operations have different base lengths", ErrLengthMismatch)
+	}
+	aPrime, bPrime := &Operation{}, &Operation{}
+	as, bs := a.components, b.components
+	var ca, cb *component
+	next := func(list *[]component) *component {
+		if len(*list) == 0 {
+			return nil
+		}
+		c := (*list)[0]
+		*list = (*list)[1:]
+		return &c
+	}
+	ca, cb = next(&as), next(&bs)
+
+	for ca != nil || cb != nil {
+		if ca != nil && ca.kind == insert {
+			aPrime.insert(ca.units)
+			bPrime.retain(len(ca.units))
+			ca = next(&as)
+			continue
+		}
+		if cb != nil && cb.kind == insert {
+			aPrime.retain(len(cb.units))
+			bPrime.insert(cb.units)
+			cb = next(&bs)
+			continue
+		}
+		if ca == nil || cb == nil {
+			return nil, nil, ErrInvalidOperation
+		}
+
+		n := ca.n
+		if cb.n < n {
+			n = cb.n
+		}
+		switch {
+		case ca.kind == retain && cb.kind == retain:
+			aPrime.retain(n)
+			bPrime.retain(n)
+		case ca.kind == remove && cb.kind == retain:
+			aPrime.delete(n)
+		case ca.kind == retain && cb.kind == remove:
+			bPrime.delete(n)
+		}
+		// Text deleted by both is already gone on either side
+
+		ca.n -= n
+		cb.n -= n
+		if ca.n == 0 {
+			ca = next(&as)
+		}
+		if cb.n == 0 {
+			cb = next(&bs)
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+// TransformIndex moves a cursor position in the operation's base document to
+// the matching position in its target document
+func (o *Operation) TransformIndex(index int) int {
+	newIndex := index
+	for _, c := range o.components {
+		switch c.kind {
+		case retain:
+			index -= c.n
+		case insert:
+			newIndex += len(c.units)
+		case remove:
+			if index < c.n {
+				newIndex -= index
+			} else {
+				newIndex -= c.n
+			}
+			index -= c.n
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return newIndex
+}
@@ -0,0 +1,166 @@
+package pdf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// ErrUnsupportedFont is returned for fonts that are not TrueType
+var ErrUnsupportedFont = errors.New("unsupported font, expected a TrueType (.ttf) file")
+
+// Font is a parsed TrueType font. It is embedded whole into the documents
+// that use it and is safe for concurrent use.
+type Font struct {
+	data       []byte
+	sfnt       *sfnt.Font
+	name       string
+	unitsPerEm float64
+	ascent     float64 // in 1/1000 em, like all metrics below
+	descent    float64 // negative
+	capHeight  float64
+	bbox       [4]float64
+	italic     bool
+	fixedPitch bool
+}
+
+// ParseFont parses a TrueType font
+func ParseFont(data []byte) (*Font, error) {
+	if bytes.HasPrefix(data, []byte("OTTO")) || bytes.HasPrefix(data, []byte("ttcf")) {
+		return nil, ErrUnsupportedFont
+	}
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFont, err)
+	}
+
+	var buf sfnt.Buffer
+	upem := float64(f.UnitsPerEm())
+	ppem := fixed.Int26_6(f.UnitsPerEm()) << 6
+	scale := func(v fixed.Int26_6) float64 { return float64(v) / 64 * 1000 / upem }
+
+	metrics, err := f.Metrics(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFont, err)
+	}
+	bounds, err := f.Bounds(&buf, ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFont, err)
+	}
+	name, _ := f.Name(&buf, sfnt.NameIDPostScript)
+	if name == "" {
+		name, _ = f.Name(&buf, sfnt.NameIDFull)
+	}
+
+	parsed := &Font{
+		data:       data,
+		sfnt:       f,
+		name:       postScriptName(name),
+		unitsPerEm: upem,
+		ascent:     scale(metrics.Ascent),
+		descent:    -scale(metrics.Descent),
+		capHeight:  scale(metrics.CapHeight),
+		// sfnt's y axis points down
+		bbox: [4]float64{scale(bounds.Min.X), -scale(bounds.Max.Y), scale(bounds.Max.X), -scale(bounds.Min.Y)},
+	}
+	if parsed.capHeight == 0 {
+		parsed.capHeight = parsed.ascent
+	}
+	if post := f.PostTable(); post != nil {
+		parsed.italic = post.ItalicAngle != 0
+		parsed.fixedPitch = post.IsFixedPitch
+	}
+	return parsed, nil
+}
+
+// postScriptName keeps the characters PDF names allow without escaping
+func postScriptName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" {
+		return "EmbeddedFont"
+	}
+	return name
+}
+
+func mustParse(data []byte) *Font {
+	f, err := ParseFont(data)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// The Go fonts are the defaults; they cover Latin, Greek and Cyrillic text
+var (
+	goRegular    = mustParse(goregular.TTF)
+	goBold       = mustParse(gobold.TTF)
+	goItalic     = mustParse(goitalic.TTF)
+	goBoldItalic = mustParse(gobolditalic.TTF)
+	goMono       = mustParse(gomono.TTF)
+	goMonoBold   = mustParse(gomonobold.TTF)
+)
+
+// face is a font as used by one document: it caches glyph lookups and
+// records the glyphs used, which the font's widths and text mapping list
+type face struct {
+	font   *Font
+	id     string // resource name, such as F1
+	buf    sfnt.Buffer
+	glyphs map[rune]sfnt.GlyphIndex
+	widths map[sfnt.GlyphIndex]float64 // in 1/1000 em
+	used   map[sfnt.GlyphIndex]rune
+}
+
+func newFace(f *Font, id string) *face {
+	return &face{
+		font:   f,
+		id:     id,
+		glyphs: make(map[rune]sfnt.GlyphIndex),
+		widths: make(map[sfnt.GlyphIndex]float64),
+		used:   make(map[sfnt.GlyphIndex]rune),
+	}
+}
+
+// glyph returns the glyph for r, or 0 when the font has none
+func (fc *face) glyph(r rune) sfnt.GlyphIndex {
+	if g, ok := fc.glyphs[r]; ok {
+		return g
+	}
+	g, err := fc.font.sfnt.GlyphIndex(&fc.buf, r)
+	if err != nil {
+		g = 0
+	}
+	fc.glyphs[r] = g
+	return g
+}
+
+// width returns the advance of glyph g in 1/1000 em
+func (fc *face) width(g sfnt.GlyphIndex) float64 {
+	if w, ok := fc.widths[g]; ok {
+		return w
+	}
+	ppem := fixed.Int26_6(fc.font.sfnt.UnitsPerEm()) << 6
+	adv, err := fc.font.sfnt.GlyphAdvance(&fc.buf, g, ppem, font.HintingNone)
+	w := 0.0
+	if err == nil {
+		w = float64(adv) / 64 * 1000 / fc.font.unitsPerEm
+	}
+	fc.widths[g] = w
+	return w
+}
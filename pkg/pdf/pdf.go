@@ -0,0 +1,347 @@
+// Package pdf writes documents parsed by package markup as PDF. Text is set
+// in embedded TrueType fonts, the Go fonts by default, so any viewer shows it
+// as laid out here and it stays searchable and copyable.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strings"
+
+	"github.com/halolight/halolight-api-go/pkg/markup"
+)
+
+// Options control a document's metadata and page furniture
+type Options struct {
+	Title  string
+	Author string
+	// Header and Footer are printed on every page; they may contain
+	// markup.PageNumber and markup.PageCount
+	Header string
+	Footer string
+	// BrandName and Logo lead the header; BrandColor tints headings and the
+	// brand name, and is a dark gray when nil
+	BrandName  string
+	BrandColor color.Color
+	Logo       image.Image
+	// Fallback supplies the glyphs the Go fonts lack, such as CJK characters
+	Fallback *Font
+}
+
+// A4 in points, with the margins around the body
+const (
+	pageWidth    = 595.28
+	pageHeight   = 841.89
+	marginX      = 56.0
+	marginTop    = 72.0
+	marginBottom = 68.0
+
+	bodySize = 10.5
+	leading  = 1.45
+)
+
+var headingSizes = [7]float64{0, 20, 16, 13.5, 12, 11, 10.5}
+
+type link struct {
+	rect [4]float64
+	uri  string
+}
+
+type page struct {
+	content bytes.Buffer
+	links   []link
+}
+
+func (p *page) text(pc piece, st textStyle, x, y float64) {
+	var hex strings.Builder
+	for _, g := range pc.glyphs {
+		fmt.Fprintf(&hex, "%04X", uint16(g))
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.2f Tf %.3f %.3f %.3f rg %.2f %.2f Td <%s> Tj ET\n",
+		pc.face.id, st.size, st.color.r, st.color.g, st.color.b, x, y, hex.String())
+}
+
+func (p *page) rect(x, y, w, h float64, fill rgb) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f rg %.2f %.2f %.2f %.2f re f\n", fill.r, fill.g, fill.b, x, y, w, h)
+}
+
+func (p *page) strokeRect(x, y, w, h, width float64, stroke rgb) {
+	fmt.Fprintf(&p.content, "%.2f w %.3f %.3f %.3f RG %.2f %.2f %.2f %.2f re S\n", width, stroke.r, stroke.g, stroke.b, x, y, w, h)
+}
+
+func (p *page) line(x1, y1, x2, y2, width float64, stroke rgb) {
+	fmt.Fprintf(&p.content, "%.2f w %.3f %.3f %.3f RG %.2f %.2f m %.2f %.2f l S\n", width, stroke.r, stroke.g, stroke.b, x1, y1, x2, y2)
+}
+
+// document lays out blocks top to bottom, starting pages as they fill.
+// y is measured down from the top edge of the current page.
+type document struct {
+	opts   Options
+	brand  rgb
+	faces  map[*Font]*face
+	order  []*face
+	pages  []*page
+	page   *page
+	y      float64
+	color  rgb
+	quotes []float64 // left edges of the quotes being laid out
+	bars   []float64 // bar tops of the open quotes on this page
+}
+
+// Write renders blocks as a PDF document
+func Write(w io.Writer, blocks []markup.Block, opts Options) error {
+	d := &document{opts: opts, faces: make(map[*Font]*face), color: textColor, brand: rgb{0.12, 0.16, 0.22}}
+	if opts.BrandColor != nil {
+		r, g, b, _ := opts.BrandColor.RGBA()
+		d.brand = rgb{float64(r) / 0xffff, float64(g) / 0xffff, float64(b) / 0xffff}
+	}
+	d.newPage()
+	d.blocks(blocks, marginX, pageWidth-2*marginX, 0)
+	d.furnish()
+	return d.write(w)
+}
+
+func (d *document) newPage() {
+	// Quote bars run to the bottom margin and continue on the next page
+	for i, x := range d.quotes {
+		d.page.line(x, pageHeight-d.bars[i], x, marginBottom, 2, ruleColor)
+	}
+	d.page = &page{}
+	d.pages = append(d.pages, d.page)
+	d.y = marginTop
+	for i := range d.bars {
+		d.bars[i] = marginTop
+	}
+}
+
+// ensure starts a new page unless h more points fit on this one
+func (d *document) ensure(h float64) {
+	if d.y+h > pageHeight-marginBottom && d.y > marginTop {
+		d.newPage()
+	}
+}
+
+// paragraph lays out inlines as wrapped lines
+func (d *document) paragraph(inlines []markup.Inline, st textStyle, x, width, after float64) {
+	lh := st.size * leading
+	for _, l := range d.wrap(d.tokens(inlines, st), width) {
+		d.ensure(lh)
+		d.drawLine(l, x, pageHeight-d.y-st.size*1.1)
+		d.y += lh
+	}
+	d.y += after
+}
+
+func (d *document) body(size float64) textStyle {
+	return textStyle{size: size, color: d.color}
+}
+
+func (d *document) blocks(blocks []markup.Block, x, width float64, depth int) {
+	for _, block := range blocks {
+		d.block(block, x, width, depth)
+	}
+}
+
+func (d *document) block(block markup.Block, x, width float64, depth int) {
+	after := 7.0
+	if depth > 0 {
+		after = 3
+	}
+
+	switch block.Kind {
+	case markup.Paragraph:
+		d.paragraph(block.Inlines, d.body(bodySize), x, width, after)
+
+	case markup.Heading:
+		size := headingSizes[min(max(block.Level, 1), 6)]
+		st := textStyle{size: size, bold: true, color: d.brand}
+		// Keep the heading with the first lines after it
+		if d.y > marginTop {
+			d.y += size * 0.6
+		}
+		d.ensure(size*leading + 3*bodySize*leading)
+		d.paragraph(block.Inlines, st, x, width, 0)
+		if block.Level <= 2 {
+			d.page.line(x, pageHeight-d.y+1, x+width, pageHeight-d.y+1, 0.6, ruleColor)
+			d.y += 3
+		}
+		d.y += 4
+
+	case markup.List:
+		indent := 18.0
+		st := d.body(bodySize)
+		for i, item := range block.Items {
+			marker := "•"
+			if depth%2 == 1 {
+				marker = "–"
+			}
+			if block.Ordered {
+				marker = fmt.Sprintf("%d.", block.Start+i)
+			}
+			d.ensure(bodySize * leading)
+			tok := d.token(marker, st)
+			d.drawLine(line{tokens: []token{tok}, width: tok.width}, x+indent-tok.width-5, pageHeight-d.y-bodySize*1.1)
+			if len(item) == 0 {
+				d.y += bodySize * leading
+			}
+			d.blocks(item, x+indent, width-indent, depth+1)
+		}
+		d.y += after - 3
+
+	case markup.CodeBlock:
+		d.code(block.Text, x, width)
+		d.y += after
+
+	case markup.Quote:
+		d.quotes = append(d.quotes, x+1)
+		d.bars = append(d.bars, d.y)
+		saved := d.color
+		d.color = mutedColor
+		d.blocks(block.Children, x+14, width-14, depth+1)
+		d.color = saved
+		n := len(d.quotes) - 1
+		d.page.line(d.quotes[n], pageHeight-d.bars[n], d.quotes[n], pageHeight-d.y+3, 2, ruleColor)
+		d.quotes, d.bars = d.quotes[:n], d.bars[:n]
+		d.y += after
+
+	case markup.Rule:
+		d.ensure(14)
+		d.page.line(x, pageHeight-d.y-7, x+width, pageHeight-d.y-7, 0.8, ruleColor)
+		d.y += 14
+
+	case markup.Table:
+		d.table(block.Rows, x, width)
+		d.y += after
+	}
+}
+
+// code lays out a code block line by line on a shaded background
+func (d *document) code(text string, x, width float64) {
+	st := textStyle{size: 9, mono: true, color: textColor}
+	lh := st.size * 1.4
+	pad := 5.0
+
+	d.ensure(lh + 2*pad)
+	d.page.rect(x, pageHeight-d.y-pad, width, pad, codeFill)
+	d.y += pad
+	for _, src := range strings.Split(strings.ReplaceAll(text, "\t", "    "), "\n") {
+		var tokens []token
+		for _, r := range src {
+			tokens = append(tokens, d.token(string(r), st))
+		}
+		for _, l := range d.wrapCode(tokens, width-2*pad) {
+			d.ensure(lh)
+			d.page.rect(x, pageHeight-d.y-lh, width, lh, codeFill)
+			d.drawLine(l, x+pad, pageHeight-d.y-st.size*1.05)
+			d.y += lh
+		}
+	}
+	d.page.rect(x, pageHeight-d.y-pad, width, pad, codeFill)
+	d.y += pad
+}
+
+// wrapCode breaks a line of code between any two characters, keeping spaces
+func (d *document) wrapCode(tokens []token, width float64) []line {
+	lines := []line{{}}
+	for _, t := range tokens {
+		cur := &lines[len(lines)-1]
+		if cur.width+t.width > width && len(cur.tokens) > 0 {
+			lines = append(lines, line{})
+			cur = &lines[len(lines)-1]
+		}
+		cur.tokens = append(cur.tokens, t)
+		cur.width += t.width
+	}
+	return lines
+}
+
+// table lays out rows in equal columns; the header row repeats on new pages
+func (d *document) table(rows [][]markup.Cell, x, width float64) {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return
+	}
+	cols := len(rows[0])
+	colWidth := width / float64(cols)
+	pad := 4.0
+	size := bodySize - 1
+	lh := size * 1.35
+
+	layout := func(row []markup.Cell, header bool) ([][]line, float64) {
+		st := textStyle{size: size, bold: header, color: d.color}
+		cells := make([][]line, cols)
+		height := 0.0
+		for c := 0; c < cols; c++ {
+			var inlines []markup.Inline
+			if c < len(row) {
+				inlines = row[c]
+			}
+			cells[c] = d.wrap(d.tokens(inlines, st), colWidth-2*pad)
+			height = max(height, float64(len(cells[c]))*lh)
+		}
+		return cells, height + 2*pad
+	}
+	draw := func(cells [][]line, height float64, header bool) {
+		top := pageHeight - d.y
+		if header {
+			d.page.rect(x, top-height, width, height, headerFill)
+		}
+		for c, lines := range cells {
+			cx := x + float64(c)*colWidth
+			d.page.strokeRect(cx, top-height, colWidth, height, 0.5, ruleColor)
+			for i, l := range lines {
+				d.drawLine(l, cx+pad, top-pad-float64(i)*lh-size*1.05)
+			}
+		}
+		d.y += height
+	}
+
+	headCells, headHeight := layout(rows[0], true)
+	d.ensure(headHeight + lh + 2*pad)
+	draw(headCells, headHeight, true)
+	for _, row := range rows[1:] {
+		cells, height := layout(row, false)
+		if d.y+height > pageHeight-marginBottom {
+			d.newPage()
+			draw(headCells, headHeight, true)
+		}
+		draw(cells, height, false)
+	}
+}
+
+// furnish adds the header and footer to every page once the page count is known
+func (d *document) furnish() {
+	count := fmt.Sprint(len(d.pages))
+	muted := textStyle{size: 8.5, color: mutedColor}
+	for i, p := range d.pages {
+		expand := strings.NewReplacer(markup.PageNumber, fmt.Sprint(i+1), markup.PageCount, count)
+		d.page = p
+
+		left := marginX
+		top := pageHeight - 42
+		if d.opts.Logo != nil {
+			b := d.opts.Logo.Bounds()
+			h := 18.0
+			w := h * float64(b.Dx()) / float64(max(b.Dy(), 1))
+			fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /Im1 Do Q\n", w, h, left, top-4)
+			left += w + 6
+		}
+		if d.opts.BrandName != "" {
+			tok := d.token(d.opts.BrandName, textStyle{size: 10, bold: true, color: d.brand})
+			d.drawLine(line{tokens: []token{tok}}, left, top)
+		}
+		if header := expand.Replace(d.opts.Header); header != "" {
+			tok := d.token(header, muted)
+			d.drawLine(line{tokens: []token{tok}}, pageWidth-marginX-tok.width, top)
+		}
+		if d.opts.Logo != nil || d.opts.BrandName != "" || d.opts.Header != "" {
+			p.line(marginX, top-9, pageWidth-marginX, top-9, 0.5, ruleColor)
+		}
+		if footer := expand.Replace(d.opts.Footer); footer != "" {
+			tok := d.token(footer, muted)
+			d.drawLine(line{tokens: []token{tok}}, (pageWidth-tok.width)/2, 36)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package pdf
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/halolight/halolight-api-go/pkg/markup"
+	"golang.org/x/image/font/sfnt"
+)
+
+type rgb struct{ r, g, b float64 }
+
+var (
+	textColor  = rgb{0.13, 0.15, 0.19}
+	mutedColor = rgb{0.42, 0.45, 0.5}
+	linkColor  = rgb{0.15, 0.39, 0.92}
+	ruleColor  = rgb{0.84, 0.85, 0.87}
+	codeFill   = rgb{0.95, 0.96, 0.97}
+	headerFill = rgb{0.93, 0.94, 0.96}
+)
+
+// textStyle is how a run of text is drawn
+type textStyle struct {
+	size      float64
+	bold      bool
+	italic    bool
+	mono      bool
+	color     rgb
+	link      string // URI of an external link
+	underline bool
+	strike    bool
+}
+
+func (s textStyle) font() *Font {
+	switch {
+	case s.mono && s.bold:
+		return goMonoBold
+	case s.mono:
+		return goMono
+	case s.bold && s.italic:
+		return goBoldItalic
+	case s.bold:
+		return goBold
+	case s.italic:
+		return goItalic
+	}
+	return goRegular
+}
+
+// piece is a sequence of glyphs from one face
+type piece struct {
+	face   *face
+	glyphs []sfnt.GlyphIndex
+	width  float64 // in points
+}
+
+// token is the unit of line breaking: a word, a space, a single CJK
+// character or a forced line break
+type token struct {
+	text    string
+	style   textStyle
+	pieces  []piece
+	width   float64
+	space   bool
+	newline bool
+}
+
+type line struct {
+	tokens []token
+	width  float64
+}
+
+func (d *document) faceFor(f *Font) *face {
+	if fc, ok := d.faces[f]; ok {
+		return fc
+	}
+	fc := newFace(f, fmt.Sprintf("F%d", len(d.order)+1))
+	d.faces[f] = fc
+	d.order = append(d.order, fc)
+	return fc
+}
+
+// shape maps text to glyphs, taking those the style's font lacks from the
+// fallback font
+func (d *document) shape(text string, st textStyle) ([]piece, float64) {
+	primary := d.faceFor(st.font())
+	var fallback *face
+	if d.opts.Fallback != nil {
+		fallback = d.faceFor(d.opts.Fallback)
+	}
+
+	var pieces []piece
+	total := 0.0
+	for _, r := range text {
+		fc := primary
+		g := fc.glyph(r)
+		if g == 0 && fallback != nil {
+			if fg := fallback.glyph(r); fg != 0 {
+				fc, g = fallback, fg
+			}
+		}
+		if g != 0 {
+			fc.used[g] = r
+		}
+		w := fc.width(g) * st.size / 1000
+		total += w
+		if n := len(pieces); n > 0 && pieces[n-1].face == fc {
+			pieces[n-1].glyphs = append(pieces[n-1].glyphs, g)
+			pieces[n-1].width += w
+			continue
+		}
+		pieces = append(pieces, piece{face: fc, glyphs: []sfnt.GlyphIndex{g}, width: w})
+	}
+	return pieces, total
+}
+
+func (d *document) token(text string, st textStyle) token {
+	pieces, width := d.shape(text, st)
+	return token{text: text, style: st, pieces: pieces, width: width, space: text == " "}
+}
+
+// wide reports whether a line may break on either side of r, as it may
+// around CJK characters
+func wide(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r) || (r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
+
+// tokens splits styled runs into tokens
+func (d *document) tokens(inlines []markup.Inline, base textStyle) []token {
+	var out []token
+	for _, in := range inlines {
+		if in.Break {
+			out = append(out, token{newline: true})
+			continue
+		}
+
+		st := base
+		st.bold = st.bold || in.Bold
+		st.italic = st.italic || in.Italic
+		st.strike = in.Strike
+		text := in.Text
+		if in.Code {
+			st.mono = true
+			st.size = base.size * 0.9
+		}
+		if in.Image {
+			if text == "" {
+				text = "image"
+			}
+			text = "[" + text + "]"
+			st.italic = true
+		}
+		if in.Link != "" {
+			st.color = linkColor
+			st.underline = true
+			if external(in.Link) {
+				st.link = in.Link
+			}
+		}
+
+		var word strings.Builder
+		flush := func() {
+			if word.Len() > 0 {
+				out = append(out, d.token(word.String(), st))
+				word.Reset()
+			}
+		}
+		for _, r := range text {
+			switch {
+			case r == ' ' || r == '\t' || r == '\n':
+				flush()
+				out = append(out, d.token(" ", st))
+			case wide(r):
+				flush()
+				out = append(out, d.token(string(r), st))
+			default:
+				word.WriteRune(r)
+			}
+		}
+		flush()
+	}
+	return out
+}
+
+// external reports whether target is an absolute link a viewer can follow
+func external(target string) bool {
+	lower := strings.ToLower(strings.TrimSpace(target))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "mailto:")
+}
+
+// wrap breaks tokens into lines no wider than width
+func (d *document) wrap(tokens []token, width float64) []line {
+	var lines []line
+	var cur line
+	push := func() {
+		for len(cur.tokens) > 0 && cur.tokens[len(cur.tokens)-1].space {
+			cur.width -= cur.tokens[len(cur.tokens)-1].width
+			cur.tokens = cur.tokens[:len(cur.tokens)-1]
+		}
+		lines = append(lines, cur)
+		cur = line{}
+	}
+
+	for _, t := range tokens {
+		if t.newline {
+			push()
+			continue
+		}
+		if t.space && len(cur.tokens) == 0 {
+			continue
+		}
+		if !t.space && cur.width+t.width > width && len(cur.tokens) > 0 {
+			push()
+		}
+		// A word wider than the line is broken between characters
+		for !t.space && t.width > width && len(cur.tokens) == 0 {
+			head, rest := d.split(t, width)
+			if rest.text == "" {
+				break
+			}
+			cur.tokens = append(cur.tokens, head)
+			cur.width += head.width
+			push()
+			t = rest
+		}
+		cur.tokens = append(cur.tokens, t)
+		cur.width += t.width
+	}
+	if len(cur.tokens) > 0 || len(lines) == 0 {
+		push()
+	}
+	return lines
+}
+
+// split cuts t into a head that fits width, of at least one character, and the rest
+func (d *document) split(t token, width float64) (token, token) {
+	runes := []rune(t.text)
+	n := 1
+	for n < len(runes) {
+		_, w := d.shape(string(runes[:n+1]), t.style)
+		if w > width {
+			break
+		}
+		n++
+	}
+	return d.token(string(runes[:n]), t.style), d.token(string(runes[n:]), t.style)
+}
+
+// drawLine draws a line of text with its left end at x and its baseline at y
+func (d *document) drawLine(l line, x, y float64) {
+	p := d.page
+	var lastLink string
+	for _, t := range l.tokens {
+		st := t.style
+		if st.mono && !t.space {
+			p.rect(x-1, y-st.size*0.28, t.width+2, st.size*1.2, codeFill)
+		}
+		if !t.space {
+			cx := x
+			for _, pc := range t.pieces {
+				p.text(pc, st, cx, y)
+				cx += pc.width
+			}
+		}
+		if st.underline {
+			p.line(x, y-st.size*0.15, x+t.width, y-st.size*0.15, 0.5, st.color)
+		}
+		if st.strike {
+			p.line(x, y+st.size*0.3, x+t.width, y+st.size*0.3, 0.6, st.color)
+		}
+		if st.link != "" {
+			rect := [4]float64{x, y - st.size*0.3, x + t.width, y + st.size*0.9}
+			if n := len(p.links); n > 0 && lastLink == st.link && p.links[n-1].uri == st.link {
+				p.links[n-1].rect[2] = rect[2]
+			} else {
+				p.links = append(p.links, link{rect: rect, uri: st.link})
+			}
+		}
+		lastLink = st.link
+		x += t.width
+	}
+}
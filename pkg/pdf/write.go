@@ -0,0 +1,219 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/md5"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// objects numbers and serializes the PDF's indirect objects
+type objects struct {
+	buf     bytes.Buffer
+	offsets []int // by object number - 1
+}
+
+func (o *objects) alloc() int {
+	o.offsets = append(o.offsets, 0)
+	return len(o.offsets)
+}
+
+func (o *objects) put(id int, body string) {
+	o.offsets[id-1] = o.buf.Len()
+	fmt.Fprintf(&o.buf, "%d 0 obj\n%s\nendobj\n", id, body)
+}
+
+// stream writes a compressed stream; extra holds more dictionary entries
+func (o *objects) stream(id int, data []byte, extra string) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	o.offsets[id-1] = o.buf.Len()
+	fmt.Fprintf(&o.buf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode%s >>\nstream\n", id, z.Len(), extra)
+	o.buf.Write(z.Bytes())
+	o.buf.WriteString("\nendstream\nendobj\n")
+}
+
+// textString encodes s as a PDF text string in UTF-16 with a byte order mark
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// hexString encodes arbitrary bytes, such as a URI, as a PDF string
+func hexString(s string) string {
+	return fmt.Sprintf("<%X>", []byte(s))
+}
+
+func (d *document) write(w io.Writer) error {
+	var o objects
+	catalog, pages, info := o.alloc(), o.alloc(), o.alloc()
+
+	var image string
+	if d.opts.Logo != nil {
+		image = fmt.Sprintf(" /XObject << /Im1 %d 0 R >>", d.writeImage(&o, d.opts.Logo))
+	}
+	var fonts strings.Builder
+	for _, fc := range d.order {
+		fmt.Fprintf(&fonts, " /%s %d 0 R", fc.id, d.writeFont(&o, fc))
+	}
+	resources := fmt.Sprintf("<< /Font <<%s >>%s >>", fonts.String(), image)
+
+	var kids []string
+	for _, p := range d.pages {
+		pageID, contentID := o.alloc(), o.alloc()
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+		o.stream(contentID, p.content.Bytes(), "")
+
+		var annots []string
+		for _, l := range p.links {
+			id := o.alloc()
+			o.put(id, fmt.Sprintf("<< /Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /A << /S /URI /URI %s >> >>",
+				l.rect[0], l.rect[1], l.rect[2], l.rect[3], hexString(l.uri)))
+			annots = append(annots, fmt.Sprintf("%d 0 R", id))
+		}
+		var annotEntry string
+		if len(annots) > 0 {
+			annotEntry = " /Annots [" + strings.Join(annots, " ") + "]"
+		}
+		o.put(pageID, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R%s >>",
+			pages, pageWidth, pageHeight, resources, contentID, annotEntry))
+	}
+	o.put(pages, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids)))
+	o.put(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pages))
+
+	now := time.Now().UTC().Format("20060102150405Z")
+	o.put(info, fmt.Sprintf("<< /Title %s /Author %s /Producer %s /CreationDate (D:%s) >>",
+		textString(d.opts.Title), textString(d.opts.Author), textString("HaloLight"), now))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	base := out.Len()
+	out.Write(o.buf.Bytes())
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(o.offsets)+1)
+	for _, off := range o.offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", base+off)
+	}
+	id := md5.Sum(o.buf.Bytes())
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R /ID [<%X> <%X>] >>\nstartxref\n%d\n%%%%EOF\n",
+		len(o.offsets)+1, catalog, info, id, id, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// writeFont embeds a face's font as a composite font addressed by glyph ID
+func (d *document) writeFont(o *objects, fc *face) int {
+	f := fc.font
+	typ0, cid, desc, file, toUnicode := o.alloc(), o.alloc(), o.alloc(), o.alloc(), o.alloc()
+
+	o.stream(file, f.data, fmt.Sprintf(" /Length1 %d", len(f.data)))
+
+	flags := 32 // nonsymbolic
+	if f.fixedPitch {
+		flags |= 1
+	}
+	if f.italic {
+		flags |= 64
+	}
+	italicAngle := 0
+	if f.italic {
+		italicAngle = -12
+	}
+	o.put(desc, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags %d /FontBBox [%.0f %.0f %.0f %.0f] /ItalicAngle %d /Ascent %.0f /Descent %.0f /CapHeight %.0f /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, flags, f.bbox[0], f.bbox[1], f.bbox[2], f.bbox[3], italicAngle, f.ascent, f.descent, f.capHeight, file))
+
+	glyphs := make([]int, 0, len(fc.widths))
+	for g := range fc.widths {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+	var widths strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&widths, " %d [%.0f]", g, fc.widths[sfnt.GlyphIndex(g)])
+	}
+	o.put(cid, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW 1000 /W [%s ] /CIDToGIDMap /Identity >>",
+		f.name, desc, widths.String()))
+
+	o.stream(toUnicode, toUnicodeCMap(fc), "")
+	o.put(typ0, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.name, cid, toUnicode))
+	return typ0
+}
+
+// toUnicodeCMap maps the glyphs used back to text, for searching and copying
+func toUnicodeCMap(fc *face) []byte {
+	glyphs := make([]int, 0, len(fc.used))
+	for g := range fc.used {
+		glyphs = append(glyphs, int(g))
+	}
+	sort.Ints(glyphs)
+
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(glyphs); start += 100 {
+		chunk := glyphs[start:min(start+100, len(glyphs))]
+		fmt.Fprintf(&b, "%d beginbfchar\n", len(chunk))
+		for _, g := range chunk {
+			fmt.Fprintf(&b, "<%04X> <", g)
+			for _, u := range utf16.Encode([]rune{fc.used[sfnt.GlyphIndex(g)]}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// writeImage embeds img as RGB samples, with a soft mask when it is not opaque
+func (d *document) writeImage(o *objects, img image.Image) int {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	samples := make([]byte, 0, w*h*3)
+	alpha := make([]byte, 0, w*h)
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			// Undo alpha premultiplication
+			if a > 0 && a < 0xffff {
+				r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+			}
+			samples = append(samples, byte(r>>8), byte(g>>8), byte(b>>8))
+			alpha = append(alpha, byte(a>>8))
+			if a != 0xffff {
+				opaque = false
+			}
+		}
+	}
+
+	id := o.alloc()
+	var mask string
+	if !opaque {
+		maskID := o.alloc()
+		o.stream(maskID, alpha, fmt.Sprintf(" /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8", w, h))
+		mask = fmt.Sprintf(" /SMask %d 0 R", maskID)
+	}
+	o.stream(id, samples, fmt.Sprintf(" /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8%s", w, h, mask))
+	return id
+}
@@ -0,0 +1,122 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported spreadsheet format, expected csv or xlsx")
+
+// ParseFormat normalizes a format name such as "CSV" or ".xlsx"
+func ParseFormat(name string) (Format, error) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), ".") {
+	case "csv":
+		return FormatCSV, nil
+	case "xlsx":
+		return FormatXLSX, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// FormatFromFilename detects the format from a file extension
+func FormatFromFilename(filename string) (Format, error) {
+	return ParseFormat(filepath.Ext(filename))
+}
+
+// ContentType returns the MIME type used when serving a spreadsheet
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Read returns every row of the first sheet. Rows shorter than the header are
+// padded so callers can index columns safely.
+func Read(r io.Reader, format Format) ([][]string, error) {
+	var rows [][]string
+
+	switch format {
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		rows = records
+	case FormatXLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		records, err := f.GetRows(f.GetSheetName(0))
+		if err != nil {
+			return nil, err
+		}
+		rows = records
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	if len(rows) == 0 {
+		return rows, nil
+	}
+	// Strip a UTF-8 BOM left by Excel's CSV export
+	if len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+	}
+	width := len(rows[0])
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		rows[i] = row
+	}
+	return rows, nil
+}
+
+// Write encodes rows (header first) into the given format
+func Write(w io.Writer, format Format, rows [][]string) error {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return writer.Error()
+	case FormatXLSX:
+		f := excelize.NewFile()
+		defer f.Close()
+		sheet := f.GetSheetName(0)
+		for i, row := range rows {
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if err != nil {
+				return err
+			}
+			values := make([]interface{}, len(row))
+			for j, v := range row {
+				values[j] = v
+			}
+			if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+				return err
+			}
+		}
+		return f.Write(w)
+	}
+	return ErrUnsupportedFormat
+}
@@ -0,0 +1,113 @@
+// Package storage persists uploaded blobs such as avatars and generated exports.
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidKey is returned for keys that are empty or escape the storage root
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage stores blobs under slash-separated keys such as "avatars/users/<id>/256.jpg"
+type Storage interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// DeletePrefix removes every blob below the given key prefix
+	DeletePrefix(prefix string) error
+	// URL returns the public URL of key, or "" when the storage is not served
+	URL(key string) string
+}
+
+// Local keeps blobs on the local filesystem below root
+type Local struct {
+	root    string
+	baseURL string
+}
+
+// NewLocal creates a filesystem storage. baseURL is the path the router serves
+// root under; leave it empty for private storage.
+func NewLocal(root, baseURL string) *Local {
+	return &Local{root: root, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// Root returns the directory the blobs are written to
+func (s *Local) Root() string {
+	return s.root
+}
+
+func (s *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+// Put writes r to key atomically so readers never observe a partial file
+func (s *Local) Put(key string, r io.Reader) error {
+	dst, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func (s *Local) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *Local) Delete(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Local) DeletePrefix(prefix string) error {
+	p, err := s.path(prefix)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+func (s *Local) URL(key string) string {
+	if s.baseURL == "" {
+		return ""
+	}
+	return s.baseURL + path.Clean("/"+key)
+}
@@ -0,0 +1,236 @@
+// Package textdiff compares two texts line by line or word by word.
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+type OpType string
+
+const (
+	Equal  OpType = "equal"
+	Insert OpType = "insert"
+	Delete OpType = "delete"
+)
+
+// Op is a run of text that is unchanged, inserted or deleted. Concatenating
+// the equal and delete runs yields the old text, the equal and insert runs the
+// new one.
+type Op struct {
+	Type OpType `json:"type"`
+	Text string `json:"text"`
+}
+
+// Result is a diff along with the number of inserted and deleted lines or words
+type Result struct {
+	Ops        []Op `json:"ops"`
+	Insertions int  `json:"insertions"`
+	Deletions  int  `json:"deletions"`
+}
+
+// maxEdits bounds the work spent on very different texts. Beyond it the
+// remaining middle section is reported as deleted and reinserted.
+const maxEdits = 4000
+
+// Lines diffs a and b line by line
+func Lines(a, b string) Result {
+	return diff(splitLines(a), splitLines(b))
+}
+
+// Words diffs a and b word by word. Whitespace and punctuation are separate
+// tokens, as is every character of scripts written without spaces.
+func Words(a, b string) Result {
+	return diff(splitWords(a), splitWords(b))
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func splitWords(s string) []string {
+	var tokens []string
+	start := -1
+	var inSpace bool
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, s[start:end])
+			start = -1
+		}
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			if start >= 0 && !inSpace {
+				flush(i)
+			}
+			if start < 0 {
+				start, inSpace = i, true
+			}
+		case isSingleRune(r):
+			flush(i)
+			tokens = append(tokens, string(r))
+		default:
+			if start >= 0 && inSpace {
+				flush(i)
+			}
+			if start < 0 {
+				start, inSpace = i, false
+			}
+		}
+	}
+	flush(len(s))
+	return tokens
+}
+
+// isSingleRune reports whether r forms a token on its own
+func isSingleRune(r rune) bool {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai) {
+		return true
+	}
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
+
+type builder struct {
+	result Result
+}
+
+func (b *builder) add(t OpType, token string) {
+	switch t {
+	case Insert:
+		if strings.TrimSpace(token) != "" {
+			b.result.Insertions++
+		}
+	case Delete:
+		if strings.TrimSpace(token) != "" {
+			b.result.Deletions++
+		}
+	}
+	ops := b.result.Ops
+	if n := len(ops); n > 0 && ops[n-1].Type == t {
+		ops[n-1].Text += token
+		return
+	}
+	b.result.Ops = append(ops, Op{Type: t, Text: token})
+}
+
+func diff(a, b []string) Result {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out builder
+	out.result.Ops = []Op{}
+	for _, token := range a[:prefix] {
+		out.add(Equal, token)
+	}
+	middleA, middleB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if edits, ok := myers(middleA, middleB); ok {
+		for _, e := range edits {
+			out.add(e.op, e.token)
+		}
+	} else {
+		for _, token := range middleA {
+			out.add(Delete, token)
+		}
+		for _, token := range middleB {
+			out.add(Insert, token)
+		}
+	}
+	for _, token := range a[len(a)-suffix:] {
+		out.add(Equal, token)
+	}
+	return out.result
+}
+
+type edit struct {
+	op    OpType
+	token string
+}
+
+// myers computes a shortest edit script with Myers' algorithm. It gives up
+// once the script would be longer than maxEdits.
+func myers(a, b []string) ([]edit, bool) {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil, true
+	}
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k. trace[d] keeps
+	// diagonals -d-1..d+1 as they were before step d, for backtracking.
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	found := -1
+	for d := 0; d <= limit && found < 0; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+	if found < 0 {
+		return nil, false
+	}
+
+	var edits []edit
+	x, y := n, m
+	for d := found; d >= 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d+1] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, edit{Insert, b[y-1]})
+				y--
+			} else {
+				edits = append(edits, edit{Delete, a[x-1]})
+				x--
+			}
+		}
+	}
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits, true
+}
@@ -21,6 +21,7 @@ const (
 
 type Claims struct {
 	UserID    string    `json:"userId"`
+	TenantID  string    `json:"tenantId,omitempty"` // organization the user belongs to
 	TokenType TokenType `json:"type"`
 	jwt.RegisteredClaims
 }
@@ -33,12 +34,13 @@ type TokenPair struct {
 }
 
 // GenerateAccessToken generates a new JWT access token (7 days default)
-func GenerateAccessToken(userID, secret string) (string, error) {
+func GenerateAccessToken(userID, tenantID, secret string) (string, error) {
 	now := time.Now()
 	expirationTime := now.Add(7 * 24 * time.Hour) // 7 days
 
 	claims := Claims{
 		UserID:    userID,
+		TenantID:  tenantID,
 		TokenType: TokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
@@ -52,12 +54,13 @@ func GenerateAccessToken(userID, secret string) (string, error) {
 }
 
 // GenerateRefreshToken generates a new JWT refresh token (30 days default)
-func GenerateRefreshToken(userID, secret string) (string, error) {
+func GenerateRefreshToken(userID, tenantID, secret string) (string, error) {
 	now := time.Now()
 	expirationTime := now.Add(30 * 24 * time.Hour) // 30 days
 
 	claims := Claims{
 		UserID:    userID,
+		TenantID:  tenantID,
 		TokenType: TokenTypeRefresh,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
@@ -71,13 +74,13 @@ func GenerateRefreshToken(userID, secret string) (string, error) {
 }
 
 // GenerateTokenPair generates both access and refresh tokens
-func GenerateTokenPair(userID, secret string) (*TokenPair, error) {
-	accessToken, err := GenerateAccessToken(userID, secret)
+func GenerateTokenPair(userID, tenantID, secret string) (*TokenPair, error) {
+	accessToken, err := GenerateAccessToken(userID, tenantID, secret)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := GenerateRefreshToken(userID, secret)
+	refreshToken, err := GenerateRefreshToken(userID, tenantID, secret)
 	if err != nil {
 		return nil, err
 	}